@@ -3,44 +3,104 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"github.com/ONSdigital/dp-net/request"
 	"github.com/ONSdigital/log.go/log"
 	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/auth"
+	"github.com/cadmiumcat/books-api/config"
+	"github.com/cadmiumcat/books-api/importer"
 	"github.com/cadmiumcat/books-api/interfaces"
-	"github.com/cadmiumcat/books-api/mongo"
-	"github.com/cadmiumcat/books-api/pagination"
 	"github.com/gorilla/mux"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strings"
+	"time"
 )
 
 type API struct {
-	host      string
-	router    *mux.Router
-	paginator interfaces.Paginator
-	dataStore interfaces.DataStore
-	hc        interfaces.HealthChecker
+	host                   string
+	baseURL                string
+	router                 *mux.Router
+	paginator              interfaces.Paginator
+	dataStore              interfaces.DataStore
+	searcher               interfaces.Searcher
+	idempotency            interfaces.IdempotencyStore
+	authenticator          interfaces.Authenticator
+	hc                     interfaces.HealthChecker
+	cascadeReviewsOnDelete bool
+	requireIfMatch         bool
+	idempotencyKeyTTL      time.Duration
+	importer               *importer.Importer
+	importJobs             *importer.Jobs
+	importMaxSyncSize      int64
+	importMaxSize          int64
+	writeTimeout           time.Duration
 }
 
 // Setup sets up the endpoints.
-func Setup(ctx context.Context, host string, router *mux.Router, paginator interfaces.Paginator, dataStore interfaces.DataStore, hc interfaces.HealthChecker) *API {
+func Setup(ctx context.Context, cfg *config.Configuration, router *mux.Router, paginator interfaces.Paginator, dataStore interfaces.DataStore, searcher interfaces.Searcher, idempotency interfaces.IdempotencyStore, authenticator interfaces.Authenticator, hc interfaces.HealthChecker) *API {
 	api := &API{
-		host:      host,
-		router:    router,
-		paginator: paginator,
-		dataStore: dataStore,
-		hc:        hc,
+		host:                   cfg.BindAddr,
+		baseURL:                BaseURL(cfg.APIURL, cfg.APIVersion),
+		router:                 router,
+		paginator:              paginator,
+		dataStore:              dataStore,
+		searcher:               searcher,
+		idempotency:            idempotency,
+		authenticator:          authenticator,
+		hc:                     hc,
+		cascadeReviewsOnDelete: cfg.CascadeReviewsOnDelete,
+		requireIfMatch:         cfg.RequireIfMatch,
+		idempotencyKeyTTL:      cfg.IdempotencyKeyTTL,
+		importMaxSyncSize:      cfg.ImportConfig.MaxSyncSize,
+		importMaxSize:          cfg.ImportConfig.MaxSize,
+		writeTimeout:           cfg.HTTPWriteTimeout,
 	}
+	api.importer = importer.New(dataStore, api.validateImportedBook, problems, cfg.ImportConfig.BatchSize)
+	api.importJobs = importer.NewJobs(api.importer, cfg.ImportConfig.JobRetention)
 
-	// Endpoints
-	api.router.HandleFunc("/books", api.addBookHandler).Methods("POST")
+	// Every request with credentials is authenticated. Reading books and reviews does not need any credentials
+	api.router.Use(api.authenticate)
+
+	// Endpoints. The books and reviews can be created with an Idempotency-Key, so that the requests can be retried
+	api.router.HandleFunc("/books", api.requireRole(api.idempotent(api.addBookHandler), auth.Librarian)).Methods("POST")
 	api.router.HandleFunc("/books", api.getBooksHandler).Methods("GET")
+	api.router.HandleFunc("/books/search", api.searchBooksHandler).Methods("GET")
+	api.router.HandleFunc("/books/import", api.requireRole(api.importBooksHandler, auth.Librarian)).Methods("POST")
+	api.router.HandleFunc("/books/import/{jobID}", api.requireRole(api.getImportJobHandler, auth.Librarian)).Methods("GET")
+	api.router.HandleFunc("/books/export", api.requireRole(api.exportBooksHandler, auth.Librarian)).Methods("GET")
 	api.router.HandleFunc("/books/{id}", api.getBookHandler).Methods("GET")
-
+	api.router.HandleFunc("/books/{id}", api.requireRole(api.updateBookHandler, auth.Librarian)).Methods("PUT")
+	api.router.HandleFunc("/books/{id}", api.requireRole(api.patchBookHandler, auth.Librarian)).Methods("PATCH")
+	api.router.HandleFunc("/books/{id}", api.requireRole(api.deleteBookHandler, auth.Librarian)).Methods("DELETE")
+
+	api.router.HandleFunc("/authors", api.requireRole(api.addAuthorHandler, auth.Librarian)).Methods("POST")
+	api.router.HandleFunc("/authors", api.getAuthorsHandler).Methods("GET")
+	api.router.HandleFunc("/authors/{id}", api.getAuthorHandler).Methods("GET")
+	api.router.HandleFunc("/authors/{id}", api.requireRole(api.updateAuthorHandler, auth.Librarian)).Methods("PUT")
+	api.router.HandleFunc("/authors/{id}", api.requireRole(api.deleteAuthorHandler, auth.Librarian)).Methods("DELETE")
+	api.router.HandleFunc("/authors/{id}/books", api.getAuthorBooksHandler).Methods("GET")
+
+	// Reviewers can only update and delete the reviews they wrote, which is checked by the handlers
 	api.router.HandleFunc("/books/{id}/reviews", api.getReviewsHandler).Methods("GET")
-	api.router.HandleFunc("/books/{id}/reviews", api.addReviewHandler).Methods("POST")
+	api.router.HandleFunc("/books/{id}/reviews", api.requireRole(api.idempotent(api.addReviewHandler), auth.Reviewer)).Methods("POST")
 	api.router.HandleFunc("/books/{id}/reviews/{reviewID}", api.getReviewHandler).Methods("GET")
-	api.router.HandleFunc("/books/{id}/reviews/{reviewID}", api.updateReviewHandler).Methods("PUT")
+	api.router.HandleFunc("/books/{id}/reviews/{reviewID}", api.requireRole(api.updateReviewHandler, auth.Reviewer)).Methods("PUT")
+	api.router.HandleFunc("/books/{id}/reviews/{reviewID}", api.requireRole(api.patchReviewHandler, auth.Reviewer)).Methods("PATCH")
+	api.router.HandleFunc("/books/{id}/reviews/{reviewID}", api.requireRole(api.deleteReviewHandler, auth.Reviewer)).Methods("DELETE")
+
+	api.router.HandleFunc("/admin/books/{id}/reviews", api.requireRole(api.getModeratedReviewsHandler, auth.Admin)).Methods("GET")
+	api.router.HandleFunc("/admin/books/{id}/reviews/{reviewID}", api.requireRole(api.getModeratedReviewHandler, auth.Admin)).Methods("GET")
+	api.router.HandleFunc("/admin/books/{id}/reviews/{reviewID}/approve", api.requireRole(api.approveReviewHandler, auth.Admin)).Methods("POST")
+	api.router.HandleFunc("/admin/books/{id}/reviews/{reviewID}/reject", api.requireRole(api.rejectReviewHandler, auth.Admin)).Methods("POST")
+
+	api.router.HandleFunc("/books/{id}/reservations", api.requireRole(api.getReservationsHandler, auth.Librarian)).Methods("GET")
+	api.router.HandleFunc("/books/{id}/reservations", api.requireRole(api.addReservationHandler, auth.Reader)).Methods("POST")
+	api.router.HandleFunc("/books/{id}/reservations/{reservationID}", api.requireRole(api.getReservationHandler, auth.Librarian)).Methods("GET")
+	api.router.HandleFunc("/books/{id}/reservations/{reservationID}", api.requireRole(api.deleteReservationHandler, auth.Librarian)).Methods("DELETE")
+	api.router.HandleFunc("/books/{id}/reservations/{reservationID}/checkout", api.requireRole(api.checkoutReservationHandler, auth.Librarian)).Methods("POST")
+	api.router.HandleFunc("/books/{id}/reservations/{reservationID}/return", api.requireRole(api.returnReservationHandler, auth.Librarian)).Methods("POST")
 
 	api.router.HandleFunc("/health", api.hc.Handler).Methods("GET")
 
@@ -50,6 +110,21 @@ func Setup(ctx context.Context, host string, router *mux.Router, paginator inter
 
 }
 
+// BaseURL returns the URL that the links of the resources start with: the URL of the API, with its version prefix if any,
+// e.g. http://localhost:8080/v1
+func BaseURL(apiURL, version string) string {
+	base := strings.TrimSuffix(apiURL, "/")
+	if version == "" {
+		return base
+	}
+	return base + "/" + strings.Trim(version, "/")
+}
+
+// Close stops the running import jobs. They are given until the context is done to finish, and are cancelled then.
+func (api *API) Close(ctx context.Context) {
+	api.importJobs.Stop(ctx)
+}
+
 // WriteJSONBody marshals the provided interface into json, and writes it to the response body.
 func WriteJSONBody(v interface{}, w http.ResponseWriter, httpStatus int) error {
 
@@ -88,40 +163,48 @@ func ReadJSONBody(ctx context.Context, body io.ReadCloser, v interface{}) error
 	return nil
 }
 
-func handleError(ctx context.Context, w http.ResponseWriter, err error, data log.Data) {
-	var status int
-	var apiError error
+// retryAfter is the number of seconds after which a client can retry a request that failed because the data store
+// was unavailable
+const retryAfter = "5"
 
-	if err != nil {
-		apiError = err
-		switch err {
-		case mongo.ErrBookNotFound,
-			mongo.ErrReviewNotFound:
-			status = http.StatusNotFound
-		case apierrors.ErrRequiredFieldMissing,
-			apierrors.ErrEmptyRequestBody,
-			apierrors.ErrEmptyBookID,
-			apierrors.ErrEmptyReviewID,
-			apierrors.ErrInvalidReview,
-			apierrors.ErrEmptyReviewMessage,
-			apierrors.ErrEmptyReviewUser,
-			apierrors.ErrLongReviewMessage,
-			pagination.ErrInvalidLimitParameter,
-			pagination.ErrInvalidOffsetParameter,
-			pagination.ErrLimitOverMax:
-			status = http.StatusBadRequest
-		default:
-			apiError = apierrors.ErrInternalServer
-			status = http.StatusInternalServerError
-		}
-	}
+// handleError writes the problem caused by the error as the response, and logs it.
+// Server errors are logged with their details, which are hidden from the response.
+func handleError(ctx context.Context, w http.ResponseWriter, err error, data log.Data) {
+	problem := problems.Problem(err)
+	problem.RequestID = request.GetRequestId(ctx)
 
 	if data == nil {
 		data = log.Data{}
 	}
 
-	data["response_status"] = status
+	data["response_status"] = problem.Status
+	data["error_code"] = problem.Code
 	log.Event(ctx, "request unsuccessful", log.ERROR, log.Error(err), data)
 
-	http.Error(w, apiError.Error(), status)
+	if problem.Status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+
+	if problem.Status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", retryAfter)
+	}
+
+	if err := writeProblem(w, problem); err != nil {
+		log.Event(ctx, "failed to write the error response", log.ERROR, log.Error(err), data)
+	}
+}
+
+// writeProblem writes a problem as an application/problem+json response
+func writeProblem(w http.ResponseWriter, problem *apierrors.Problem) error {
+	payload, err := json.Marshal(problem)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", apierrors.ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+
+	_, err = w.Write(payload)
+	return err
 }
@@ -3,7 +3,10 @@ package api
 import (
 	"context"
 	"fmt"
+	"github.com/ONSdigital/dp-net/request"
 	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/auth"
+	"github.com/cadmiumcat/books-api/config"
 	"github.com/cadmiumcat/books-api/interfaces/mock"
 	"github.com/cadmiumcat/books-api/mongo"
 	"github.com/gorilla/mux"
@@ -26,15 +29,41 @@ func TestSetup(t *testing.T) {
 	Convey("Given an API instance", t, func() {
 		r := mux.NewRouter()
 		ctx := context.Background()
-		api := Setup(ctx, "", r, &mock.PaginatorMock{}, &mock.DataStoreMock{}, &mock.HealthCheckerMock{})
+		api := Setup(ctx, &config.Configuration{}, r, &mock.PaginatorMock{}, &mock.DataStoreMock{}, &mock.SearcherMock{}, &mock.IdempotencyStoreMock{}, &mock.AuthenticatorMock{}, &mock.HealthCheckerMock{})
 
 		Convey("When created the following routes should have been added", func() {
 			So(hasRoute(t, api.router, "/books", "GET"), ShouldBeTrue)
 			So(hasRoute(t, api.router, "/books", "POST"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/books/search", "GET"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/books/import", "POST"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/books/import/{jobID}", "GET"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/books/export", "GET"), ShouldBeTrue)
 			So(hasRoute(t, api.router, "/books/{id}", "GET"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/books/{id}", "PUT"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/books/{id}", "PATCH"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/books/{id}", "DELETE"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/authors", "GET"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/authors", "POST"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/authors/{id}", "GET"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/authors/{id}", "PUT"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/authors/{id}", "DELETE"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/authors/{id}/books", "GET"), ShouldBeTrue)
 			So(hasRoute(t, api.router, "/books/{id}/reviews", "GET"), ShouldBeTrue)
 			So(hasRoute(t, api.router, "/books/{id}/reviews", "POST"), ShouldBeTrue)
 			So(hasRoute(t, api.router, "/books/{id}/reviews/{review_id}", "GET"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/books/{id}/reviews/{review_id}", "PUT"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/books/{id}/reviews/{review_id}", "PATCH"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/books/{id}/reviews/{review_id}", "DELETE"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/admin/books/{id}/reviews", "GET"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/admin/books/{id}/reviews/{review_id}", "GET"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/admin/books/{id}/reviews/{review_id}/approve", "POST"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/admin/books/{id}/reviews/{review_id}/reject", "POST"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/books/{id}/reservations", "GET"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/books/{id}/reservations", "POST"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/books/{id}/reservations/{reservation_id}", "GET"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/books/{id}/reservations/{reservation_id}", "DELETE"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/books/{id}/reservations/{reservation_id}/checkout", "POST"), ShouldBeTrue)
+			So(hasRoute(t, api.router, "/books/{id}/reservations/{reservation_id}/return", "POST"), ShouldBeTrue)
 		})
 	})
 }
@@ -56,9 +85,49 @@ func TestHandleError(t *testing.T) {
 			expected: http.StatusNotFound,
 		},
 		{
-			input:    apierrors.ErrRequiredFieldMissing,
+			input:    mongo.ErrAuthorNotFound,
+			expected: http.StatusNotFound,
+		},
+		{
+			input:    mongo.ErrAuthorHasBooks,
+			expected: http.StatusConflict,
+		},
+		{
+			input:    mongo.ErrBookHasReviews,
+			expected: http.StatusConflict,
+		},
+		{
+			input:    mongo.ErrReservationNotFound,
+			expected: http.StatusNotFound,
+		},
+		{
+			input:    mongo.ErrBookUnavailable,
+			expected: http.StatusConflict,
+		},
+		{
+			input:    apierrors.ErrInvalidReservationState,
+			expected: http.StatusConflict,
+		},
+		{
+			input:    apierrors.ErrInvalidReviewState,
+			expected: http.StatusConflict,
+		},
+		{
+			input:    mongo.ErrReviewConflict,
+			expected: http.StatusConflict,
+		},
+		{
+			input:    apierrors.ErrInvalidPatch,
+			expected: http.StatusUnprocessableEntity,
+		},
+		{
+			input:    apierrors.ErrEmptySearchQuery,
 			expected: http.StatusBadRequest,
 		},
+		{
+			input:    apierrors.ErrRequiredFieldMissing,
+			expected: http.StatusUnprocessableEntity,
+		},
 		{
 			input:    apierrors.ErrEmptyRequestBody,
 			expected: http.StatusBadRequest,
@@ -71,6 +140,23 @@ func TestHandleError(t *testing.T) {
 			input:    apierrors.ErrEmptyReviewID,
 			expected: http.StatusBadRequest,
 		},
+		{
+			input:    auth.ErrMissingCredentials,
+			expected: http.StatusUnauthorized,
+		},
+		{
+			input:    auth.ErrInvalidCredentials,
+			expected: http.StatusUnauthorized,
+		},
+		{
+			input:    auth.ErrForbidden,
+			expected: http.StatusForbidden,
+		},
+		{
+			description: "wrapped error",
+			input:       errors.Wrap(mongo.ErrBookNotFound, "failed to update the book"),
+			expected:    http.StatusNotFound,
+		},
 		{
 			description: "unknown error",
 			input:       errMongoDB,
@@ -96,6 +182,48 @@ func TestHandleError(t *testing.T) {
 
 }
 
+func TestHandleErrorProblem(t *testing.T) {
+	Convey("Given a validation error of a request with a request ID", t, func() {
+		ctx := request.WithRequestId(context.Background(), "abc123")
+		err := apierrors.NewValidationError(apierrors.ErrRequiredFieldMissing, apierrors.FieldError{Field: "title", Rule: apierrors.RuleRequired})
+
+		Convey("When the error is passed to the handleError function", func() {
+			response := httptest.NewRecorder()
+			handleError(ctx, response, err, nil)
+
+			Convey("Then the response is a problem with the code of the error and the invalid fields", func() {
+				So(response.Code, ShouldEqual, http.StatusUnprocessableEntity)
+				So(response.Header().Get("Content-Type"), ShouldEqual, apierrors.ProblemContentType)
+				So(readProblem(t, response), ShouldResemble, apierrors.Problem{
+					Type:      "about:blank",
+					Title:     "Unprocessable Entity",
+					Status:    http.StatusUnprocessableEntity,
+					Detail:    apierrors.ErrRequiredFieldMissing.Error(),
+					Code:      "required_field_missing",
+					RequestID: "abc123",
+					Errors:    []apierrors.FieldError{{Field: "title", Rule: apierrors.RuleRequired}},
+				})
+			})
+		})
+	})
+
+	Convey("Given an unexpected error", t, func() {
+		err := errors.Wrap(errMongoDB, "unexpected error when adding a book")
+
+		Convey("When the error is passed to the handleError function", func() {
+			response := httptest.NewRecorder()
+			handleError(context.Background(), response, err, nil)
+
+			Convey("Then the details of the error are hidden from the response", func() {
+				problem := readProblem(t, response)
+				So(problem.Status, ShouldEqual, http.StatusInternalServerError)
+				So(problem.Code, ShouldEqual, "internal_error")
+				So(problem.Detail, ShouldEqual, internalSeverErrorMessage)
+			})
+		})
+	})
+}
+
 type errReader int
 
 func (errReader) Read([]byte) (int, error) {
@@ -156,3 +284,16 @@ func TestWriteJSONBody(t *testing.T) {
 		})
 	})
 }
+
+func TestBaseURL(t *testing.T) {
+	Convey("Given the URL of the API and its version", t, func() {
+		Convey("Then the links start with the URL and the version prefix", func() {
+			So(BaseURL("http://localhost:8080", "v1"), ShouldEqual, "http://localhost:8080/v1")
+			So(BaseURL("https://api.example.com/", "/v1/"), ShouldEqual, "https://api.example.com/v1")
+		})
+
+		Convey("Then the links start with the URL only if there is no version", func() {
+			So(BaseURL("http://localhost:8080", ""), ShouldEqual, "http://localhost:8080")
+		})
+	})
+}
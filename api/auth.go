@@ -0,0 +1,61 @@
+package api
+
+import (
+	"errors"
+	"github.com/cadmiumcat/books-api/auth"
+	"github.com/cadmiumcat/books-api/models"
+	"net/http"
+)
+
+// authenticate is a middleware that identifies the caller of every request that carries credentials, and adds their
+// identity to the context of the request. Requests without credentials carry on anonymously, and requests with invalid
+// credentials are rejected.
+func (api *API) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		identity, err := api.authenticator.Authenticate(request)
+		if errors.Is(err, auth.ErrMissingCredentials) {
+			next.ServeHTTP(writer, request)
+			return
+		}
+		if err != nil {
+			handleError(request.Context(), writer, err, nil)
+			return
+		}
+
+		next.ServeHTTP(writer, request.WithContext(auth.NewContext(request.Context(), identity)))
+	})
+}
+
+// requireRole only calls the handler if the caller has been authenticated and has at least one of the roles
+func (api *API) requireRole(handler http.HandlerFunc, roles ...auth.Role) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		ctx := request.Context()
+
+		identity, ok := auth.FromContext(ctx)
+		if !ok {
+			handleError(ctx, writer, auth.ErrMissingCredentials, nil)
+			return
+		}
+
+		if !identity.HasAnyRole(roles...) {
+			handleError(ctx, writer, auth.ErrForbidden, nil)
+			return
+		}
+
+		handler(writer, request)
+	}
+}
+
+// canModifyReview returns true if the caller of the request wrote the review, or is a moderator
+func canModifyReview(request *http.Request, review *models.Review) bool {
+	identity, ok := auth.FromContext(request.Context())
+	if !ok {
+		return false
+	}
+
+	if identity.HasRole(auth.Admin) {
+		return true
+	}
+
+	return review.Owner != "" && review.Owner == identity.Subject
+}
@@ -0,0 +1,222 @@
+package api
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/auth"
+	"github.com/cadmiumcat/books-api/config"
+	"github.com/cadmiumcat/books-api/interfaces/mock"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// asCaller returns the request as if it had been authenticated as the given caller
+func asCaller(request *http.Request, subject string, roles ...auth.Role) *http.Request {
+	identity := &auth.Identity{Subject: subject, Roles: roles}
+	return request.WithContext(auth.NewContext(request.Context(), identity))
+}
+
+// authenticatorMock authenticates the callers that send one of the given tokens as their API key
+func authenticatorMock(identities map[string]*auth.Identity) *mock.AuthenticatorMock {
+	return &mock.AuthenticatorMock{
+		AuthenticateFunc: func(r *http.Request) (*auth.Identity, error) {
+			key := r.Header.Get(auth.APIKeyHeader)
+			if key == "" {
+				return nil, auth.ErrMissingCredentials
+			}
+			identity, ok := identities[key]
+			if !ok {
+				return nil, auth.ErrInvalidCredentials
+			}
+			return identity, nil
+		},
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	Convey("Given an API with a librarian and a reviewer", t, func() {
+		mockDataStore := &mock.DataStoreMock{
+			GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+				return &book1, nil
+			},
+			GetReservationsFunc: func(ctx context.Context, bookID string, offset, limit int) ([]models.Reservation, int, error) {
+				return []models.Reservation{}, 0, nil
+			},
+		}
+		authenticator := authenticatorMock(map[string]*auth.Identity{
+			"librarian-key": {Subject: "librarian", Roles: []auth.Role{auth.Librarian}},
+			"reviewer-key":  {Subject: "reviewer", Roles: []auth.Role{auth.Reviewer}},
+		})
+		router := mux.NewRouter()
+		Setup(context.Background(), &config.Configuration{}, router, mockPaginator(), mockDataStore, &mock.SearcherMock{}, &mock.IdempotencyStoreMock{}, authenticator, &mock.HealthCheckerMock{})
+
+		serve := func(method, url, key string) *httptest.ResponseRecorder {
+			request := httptest.NewRequest(method, url, nil)
+			if key != "" {
+				request.Header.Set(auth.APIKeyHeader, key)
+			}
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, request)
+			return response
+		}
+
+		Convey("When an anonymous caller reads a book", func() {
+			response := serve(http.MethodGet, "/books/"+bookID1, "")
+
+			Convey("Then the book is returned", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("When an anonymous caller adds a book", func() {
+			response := serve(http.MethodPost, "/books", "")
+
+			Convey("Then the HTTP response code is 401, with a challenge", func() {
+				So(response.Code, ShouldEqual, http.StatusUnauthorized)
+				So(response.Header().Get("WWW-Authenticate"), ShouldEqual, "Bearer")
+				So(readProblem(t, response).Detail, ShouldEqual, auth.ErrMissingCredentials.Error())
+			})
+		})
+
+		Convey("When a caller with invalid credentials reads a book", func() {
+			response := serve(http.MethodGet, "/books/"+bookID1, "unknown-key")
+
+			Convey("Then the HTTP response code is 401", func() {
+				So(response.Code, ShouldEqual, http.StatusUnauthorized)
+				So(readProblem(t, response).Detail, ShouldEqual, auth.ErrInvalidCredentials.Error())
+			})
+		})
+
+		Convey("When a reviewer adds a book", func() {
+			response := serve(http.MethodPost, "/books", "reviewer-key")
+
+			Convey("Then the HTTP response code is 403", func() {
+				So(response.Code, ShouldEqual, http.StatusForbidden)
+			})
+		})
+
+		Convey("When a librarian adds a book", func() {
+			response := serve(http.MethodPost, "/books", "librarian-key")
+
+			Convey("Then the request reaches the handler, which rejects the empty body", func() {
+				So(response.Code, ShouldEqual, http.StatusBadRequest)
+				So(readProblem(t, response).Detail, ShouldEqual, "empty request body")
+			})
+		})
+
+		Convey("When an anonymous caller, or a caller who is only a reader, reads the reservations of a book", func() {
+			anonymous := []*httptest.ResponseRecorder{
+				serve(http.MethodGet, "/books/"+bookID1+"/reservations", ""),
+				serve(http.MethodGet, "/books/"+bookID1+"/reservations/"+reservationID1, ""),
+			}
+			reader := []*httptest.ResponseRecorder{
+				serve(http.MethodGet, "/books/"+bookID1+"/reservations", "reviewer-key"),
+				serve(http.MethodGet, "/books/"+bookID1+"/reservations/"+reservationID1, "reviewer-key"),
+			}
+
+			Convey("Then the HTTP response code is 401 for the anonymous caller, and 403 for the reader", func() {
+				for _, response := range anonymous {
+					So(response.Code, ShouldEqual, http.StatusUnauthorized)
+				}
+				for _, response := range reader {
+					So(response.Code, ShouldEqual, http.StatusForbidden)
+				}
+				So(mockDataStore.GetReservationsCalls(), ShouldHaveLength, 0)
+				So(mockDataStore.GetReservationCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When a librarian reads the reservations of a book", func() {
+			response := serve(http.MethodGet, "/books/"+bookID1+"/reservations", "librarian-key")
+
+			Convey("Then the reservations are returned", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+				So(mockDataStore.GetReservationsCalls(), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When a librarian moderates a review", func() {
+			response := serve(http.MethodPost, "/admin/books/"+bookID1+"/reviews/"+reviewID1+"/approve", "librarian-key")
+
+			Convey("Then the HTTP response code is 403", func() {
+				So(response.Code, ShouldEqual, http.StatusForbidden)
+			})
+		})
+	})
+}
+
+func TestReviewOwnership(t *testing.T) {
+	Convey("Given a review written by a reviewer", t, func() {
+		mockDataStore := &mock.DataStoreMock{
+			GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+				return &book1, nil
+			},
+			GetReviewFunc: func(ctx context.Context, reviewID string) (*models.Review, error) {
+				return &models.Review{ID: reviewID1, BookID: bookID1, State: models.ReviewApproved, Owner: reviewOwner}, nil
+			},
+			DeleteReviewFunc: func(ctx context.Context, review *models.Review) error {
+				return nil
+			},
+		}
+		api := &API{dataStore: mockDataStore}
+
+		Convey("When another reviewer deletes the review", func() {
+			request := asCaller(reviewRequest(http.MethodDelete, "/books/"+bookID1+"/reviews/"+reviewID1, bookID1, reviewID1), "someone.else", auth.Reviewer)
+			response := httptest.NewRecorder()
+
+			api.deleteReviewHandler(response, request)
+			Convey("Then the HTTP response code is 403", func() {
+				So(response.Code, ShouldEqual, http.StatusForbidden)
+				So(mockDataStore.DeleteReviewCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When another reviewer updates the review", func() {
+			request := asCaller(reviewRequest(http.MethodPut, "/books/"+bookID1+"/reviews/"+reviewID1, bookID1, reviewID1), "someone.else", auth.Reviewer)
+			response := httptest.NewRecorder()
+
+			api.updateReviewHandler(response, request)
+			Convey("Then the HTTP response code is 403", func() {
+				So(response.Code, ShouldEqual, http.StatusForbidden)
+			})
+		})
+
+		Convey("When a moderator deletes the review", func() {
+			request := asCaller(reviewRequest(http.MethodDelete, "/books/"+bookID1+"/reviews/"+reviewID1, bookID1, reviewID1), "moderator", auth.Admin)
+			response := httptest.NewRecorder()
+
+			api.deleteReviewHandler(response, request)
+			Convey("Then the review is deleted", func() {
+				So(response.Code, ShouldEqual, http.StatusNoContent)
+				So(mockDataStore.DeleteReviewCalls(), ShouldHaveLength, 1)
+			})
+		})
+	})
+
+	Convey("Given a reviewer who adds a review", t, func() {
+		mockDataStore := &mock.DataStoreMock{
+			GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+				return &book1, nil
+			},
+			AddReviewFunc: func(ctx context.Context, review *models.Review) error {
+				return nil
+			},
+		}
+		api := &API{dataStore: mockDataStore}
+
+		request := httptest.NewRequest(http.MethodPost, "/books/"+bookID1+"/reviews", strings.NewReader(reviewValid))
+		request = asCaller(mux.SetURLVars(request, map[string]string{"id": bookID1}), reviewOwner, auth.Reviewer)
+		response := httptest.NewRecorder()
+
+		api.addReviewHandler(response, request)
+
+		Convey("Then the review belongs to the reviewer", func() {
+			So(response.Code, ShouldEqual, http.StatusCreated)
+			So(mockDataStore.AddReviewCalls()[0].Review.Owner, ShouldEqual, reviewOwner)
+		})
+	})
+}
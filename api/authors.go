@@ -0,0 +1,297 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"github.com/ONSdigital/log.go/log"
+	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/mongo"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+func (api *API) addAuthorHandler(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+
+	if request.ContentLength == 0 {
+		handleError(ctx, writer, apierrors.ErrEmptyRequestBody, nil)
+		return
+	}
+
+	author := models.NewAuthor()
+	id := author.ID
+	if err := ReadJSONBody(ctx, request.Body, author); err != nil {
+		handleError(ctx, writer, err, nil)
+		return
+	}
+
+	// The ID of an author is generated
+	author.ID = id
+
+	logData := log.Data{"author": author}
+
+	if err := author.Validate(); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	if err := api.dataStore.AddAuthor(ctx, author); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	author.SetLinks(api.baseURL)
+	if err := WriteJSONBody(author, writer, http.StatusCreated); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+	log.Event(ctx, "successfully added author", log.INFO, logData)
+}
+
+func (api *API) getAuthorsHandler(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+	logData := log.Data{}
+
+	offset, limit, err := api.paginator.GetPaginationValues(request)
+	logData["offset"] = offset
+	logData["limit"] = limit
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	q, err := models.AuthorFields.Parse(request.URL.Query())
+	logData["query"] = q
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	cursor, err := api.paginator.GetCursor(request, q)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	authors, totalCount, err := api.dataStore.GetAuthors(ctx, q, cursor, offset, limit)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	page, err := api.paginator.NewPage(request, q, cursor, authors, offset, limit, totalCount)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	for i := range authors {
+		authors[i].SetLinks(api.baseURL)
+	}
+	response := models.AuthorsResponse{
+		Items: authors,
+		Page:  page,
+	}
+
+	if err := WriteJSONBody(response, writer, http.StatusOK); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+	log.Event(ctx, "successfully retrieved list of authors", log.INFO)
+}
+
+func (api *API) getAuthorHandler(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+
+	id := mux.Vars(request)["id"]
+	logData := log.Data{"author_id": id}
+	if id == "" {
+		handleError(ctx, writer, apierrors.ErrEmptyAuthorID, logData)
+		return
+	}
+
+	author, err := api.dataStore.GetAuthor(ctx, id)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	author.SetLinks(api.baseURL)
+	if err := WriteJSONBody(author, writer, http.StatusOK); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+	log.Event(ctx, "successfully retrieved author", log.INFO, logData)
+}
+
+func (api *API) updateAuthorHandler(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+
+	id := mux.Vars(request)["id"]
+	logData := log.Data{"author_id": id}
+	if id == "" {
+		handleError(ctx, writer, apierrors.ErrEmptyAuthorID, logData)
+		return
+	}
+
+	if request.ContentLength == 0 {
+		handleError(ctx, writer, apierrors.ErrEmptyRequestBody, logData)
+		return
+	}
+
+	existing, err := api.dataStore.GetAuthor(ctx, id)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	author := &models.Author{}
+	if err := ReadJSONBody(ctx, request.Body, author); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	// The ID of an author cannot be replaced
+	author.ID = existing.ID
+
+	logData["author"] = author
+
+	if err := author.Validate(); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	if err := api.dataStore.UpdateAuthor(ctx, id, author); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	author.SetLinks(api.baseURL)
+	if err := WriteJSONBody(author, writer, http.StatusOK); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+	log.Event(ctx, "successfully updated author", log.INFO, logData)
+}
+
+func (api *API) deleteAuthorHandler(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+
+	id := mux.Vars(request)["id"]
+	logData := log.Data{"author_id": id}
+	if id == "" {
+		handleError(ctx, writer, apierrors.ErrEmptyAuthorID, logData)
+		return
+	}
+
+	if err := api.dataStore.DeleteAuthor(ctx, id); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+	log.Event(ctx, "successfully deleted author", log.INFO, logData)
+}
+
+// getAuthorBooksHandler writes a page of the books that an author contributed to, in any role.
+// The books can be filtered and sorted like the list of all the books.
+func (api *API) getAuthorBooksHandler(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+
+	id := mux.Vars(request)["id"]
+	logData := log.Data{"author_id": id}
+	if id == "" {
+		handleError(ctx, writer, apierrors.ErrEmptyAuthorID, logData)
+		return
+	}
+
+	offset, limit, err := api.paginator.GetPaginationValues(request)
+	logData["offset"] = offset
+	logData["limit"] = limit
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	q, err := models.BookFields.Parse(request.URL.Query())
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+	q.Filters = append(q.Filters, models.BooksBy(id))
+	logData["query"] = q
+
+	// Confirm that the author exists, so that an unknown author is not mistaken for an author without books
+	if _, err := api.dataStore.GetAuthor(ctx, id); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	cursor, err := api.paginator.GetCursor(request, q)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	books, totalCount, err := api.dataStore.GetBooks(ctx, q, cursor, offset, limit)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	page, err := api.paginator.NewPage(request, q, cursor, books, offset, limit, totalCount)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	for i := range books {
+		books[i].SetLinks(api.baseURL)
+	}
+	response := models.BooksResponse{
+		Items: books,
+		Page:  page,
+	}
+
+	if err := WriteJSONBody(response, writer, http.StatusOK); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+	log.Event(ctx, "successfully retrieved list of books of author", log.INFO, logData)
+}
+
+// resolveAuthors checks that the authors referenced by a book exist, and sets the author of the book to the byline
+// made from their names. A book that does not reference any authors keeps its author.
+// It returns a validation error listing the authors that do not exist.
+func (api *API) resolveAuthors(ctx context.Context, book *models.Book) error {
+	if len(book.Authors) == 0 {
+		return nil
+	}
+
+	var unknown []apierrors.FieldError
+	names := make(map[string]string, len(book.Authors))
+	for i, contributor := range book.Authors {
+		if _, ok := names[contributor.AuthorID]; ok {
+			continue
+		}
+
+		author, err := api.dataStore.GetAuthor(ctx, contributor.AuthorID)
+		if err != nil {
+			if errors.Is(err, mongo.ErrAuthorNotFound) {
+				unknown = append(unknown, apierrors.FieldError{Field: fmt.Sprintf("authors[%d].author_id", i), Rule: apierrors.RuleExists})
+				continue
+			}
+			return err
+		}
+		names[contributor.AuthorID] = author.Name
+	}
+
+	if len(unknown) > 0 {
+		return apierrors.NewValidationError(apierrors.ErrUnknownAuthor, unknown...)
+	}
+
+	book.Author = models.Byline(book.Authors, names)
+	return nil
+}
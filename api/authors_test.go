@@ -0,0 +1,245 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/interfaces/mock"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/mongo"
+	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/cadmiumcat/books-api/query"
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	authorID1 = "pratchett"
+	authorID2 = "gaiman"
+)
+
+var authorNames = map[string]string{
+	authorID1: "Terry Pratchett",
+	authorID2: "Neil Gaiman",
+}
+
+// getAuthor returns the authors in authorNames, and ErrAuthorNotFound for any other ID
+func getAuthor(ctx context.Context, id string) (*models.Author, error) {
+	name, ok := authorNames[id]
+	if !ok {
+		return nil, mongo.ErrAuthorNotFound
+	}
+	return &models.Author{ID: id, Name: name}, nil
+}
+
+func TestAddAuthorHandler(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given a POST request to add an author", t, func() {
+		mockDataStore := &mock.DataStoreMock{
+			AddAuthorFunc: func(ctx context.Context, author *models.Author) error {
+				return nil
+			},
+		}
+		api := &API{dataStore: mockDataStore}
+
+		Convey("When the body contains an author written as surname, forenames", func() {
+			request := httptest.NewRequest(http.MethodPost, "/authors", strings.NewReader(`{"id":"chosen", "name":"Pratchett,  Terry"}`))
+			response := httptest.NewRecorder()
+
+			api.addAuthorHandler(response, request)
+			Convey("Then the HTTP response code is 201", func() {
+				So(response.Code, ShouldEqual, http.StatusCreated)
+			})
+			Convey("And the author is added with its normalised name and the links of its ID", func() {
+				So(mockDataStore.AddAuthorCalls(), ShouldHaveLength, 1)
+				author := mockDataStore.AddAuthorCalls()[0].Author
+				So(author.ID, ShouldNotEqual, "chosen")
+				So(author.Name, ShouldEqual, "Terry Pratchett")
+				So(author.Links.Self, ShouldEqual, "/authors/"+author.ID)
+				So(author.Links.Books, ShouldEqual, "/authors/"+author.ID+"/books")
+			})
+		})
+
+		Convey("When the body contains an author without a name", func() {
+			request := httptest.NewRequest(http.MethodPost, "/authors", strings.NewReader(`{"name":" "}`))
+			response := httptest.NewRecorder()
+
+			api.addAuthorHandler(response, request)
+			Convey("Then the HTTP response code is 422, with the missing field", func() {
+				So(response.Code, ShouldEqual, http.StatusUnprocessableEntity)
+				problem := readProblem(t, response)
+				So(problem.Code, ShouldEqual, "empty_author_name")
+				So(problem.Errors, ShouldResemble, []apierrors.FieldError{{Field: "name", Rule: apierrors.RuleRequired}})
+			})
+			Convey("And the AddAuthor function is not called", func() {
+				So(mockDataStore.AddAuthorCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+}
+
+func TestDeleteAuthorHandler(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given an author that contributed to books", t, func() {
+		mockDataStore := &mock.DataStoreMock{
+			DeleteAuthorFunc: func(ctx context.Context, id string) error {
+				return mongo.ErrAuthorHasBooks
+			},
+		}
+		api := &API{dataStore: mockDataStore}
+
+		Convey("When a DELETE request is sent to /authors/{id}", func() {
+			request := httptest.NewRequest(http.MethodDelete, "/authors/"+authorID1, nil)
+			request = mux.SetURLVars(request, map[string]string{"id": authorID1})
+			response := httptest.NewRecorder()
+
+			api.deleteAuthorHandler(response, request)
+			Convey("Then the HTTP response code is 409", func() {
+				So(response.Code, ShouldEqual, http.StatusConflict)
+				So(readProblem(t, response).Code, ShouldEqual, "author_has_books")
+			})
+		})
+	})
+}
+
+func TestGetAuthorBooksHandler(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given an author that contributed to a book", t, func() {
+		mockDataStore := &mock.DataStoreMock{
+			GetAuthorFunc: getAuthor,
+			GetBooksFunc: func(ctx context.Context, q *query.Query, cursor *pagination.Cursor, offset, limit int) ([]models.Book, int, error) {
+				return []models.Book{book1}, 1, nil
+			},
+		}
+		api := &API{dataStore: mockDataStore, paginator: mockPaginator()}
+
+		Convey("When a GET request is sent to /authors/{id}/books with a filter", func() {
+			request := httptest.NewRequest(http.MethodGet, "/authors/"+authorID1+"/books?language=en", nil)
+			request = mux.SetURLVars(request, map[string]string{"id": authorID1})
+			response := httptest.NewRecorder()
+
+			api.getAuthorBooksHandler(response, request)
+			Convey("Then the HTTP response code is 200", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+			})
+			Convey("And the books are restricted to the books of the author, on top of the filter", func() {
+				So(mockDataStore.GetBooksCalls(), ShouldHaveLength, 1)
+				So(mockDataStore.GetBooksCalls()[0].Q.Filters, ShouldResemble, []query.Filter{
+					{Key: "language", Operator: query.Equals, Value: "en"},
+					models.BooksBy(authorID1),
+				})
+			})
+		})
+
+		Convey("When a GET request is sent to /authors/{id}/books for an unknown author", func() {
+			request := httptest.NewRequest(http.MethodGet, "/authors/unknown/books", nil)
+			request = mux.SetURLVars(request, map[string]string{"id": "unknown"})
+			response := httptest.NewRecorder()
+
+			api.getAuthorBooksHandler(response, request)
+			Convey("Then the HTTP response code is 404", func() {
+				So(response.Code, ShouldEqual, http.StatusNotFound)
+				So(readProblem(t, response).Code, ShouldEqual, "author_not_found")
+			})
+			Convey("And the books are not listed", func() {
+				So(mockDataStore.GetBooksCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+}
+
+func TestBookAuthors(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given a POST request to add a book", t, func() {
+		mockDataStore := &mock.DataStoreMock{
+			GetAuthorFunc: getAuthor,
+			AddBookFunc: func(ctx context.Context, book *models.Book) error {
+				return nil
+			},
+		}
+		api := &API{dataStore: mockDataStore}
+
+		Convey("When the book references existing authors", func() {
+			body := `{"title":"Good Omens", "authors":[{"author_id":"pratchett"}, {"author_id":"gaiman", "role":"Author"}]}`
+			request := httptest.NewRequest(http.MethodPost, "/books", strings.NewReader(body))
+			response := httptest.NewRecorder()
+
+			api.addBookHandler(response, request)
+			Convey("Then the HTTP response code is 201", func() {
+				So(response.Code, ShouldEqual, http.StatusCreated)
+			})
+			Convey("And the book is added with the roles of its authors, and a byline made from their names", func() {
+				So(mockDataStore.AddBookCalls(), ShouldHaveLength, 1)
+				book := mockDataStore.AddBookCalls()[0].Book
+				So(book.Authors, ShouldResemble, []models.Contributor{
+					{AuthorID: authorID1, Role: models.RoleAuthor},
+					{AuthorID: authorID2, Role: models.RoleAuthor},
+				})
+				So(book.Author, ShouldEqual, "Terry Pratchett & Neil Gaiman")
+			})
+		})
+
+		Convey("When the book references an author that does not exist", func() {
+			body := `{"title":"Good Omens", "authors":[{"author_id":"pratchett"}, {"author_id":"unknown"}]}`
+			request := httptest.NewRequest(http.MethodPost, "/books", strings.NewReader(body))
+			response := httptest.NewRecorder()
+
+			api.addBookHandler(response, request)
+			Convey("Then the HTTP response code is 422, with the unknown author", func() {
+				So(response.Code, ShouldEqual, http.StatusUnprocessableEntity)
+				problem := readProblem(t, response)
+				So(problem.Code, ShouldEqual, "unknown_author")
+				So(problem.Errors, ShouldResemble, []apierrors.FieldError{{Field: "authors[1].author_id", Rule: apierrors.RuleExists}})
+			})
+			Convey("And the AddBook function is not called", func() {
+				So(mockDataStore.AddBookCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+
+	Convey("Given a PATCH request that changes the authors of a book", t, func() {
+		mockDataStore := &mock.DataStoreMock{
+			GetAuthorFunc: getAuthor,
+			GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+				return &models.Book{ID: bookID1, Title: "Good Omens", Author: "Terry Pratchett", Authors: []models.Contributor{{AuthorID: authorID1, Role: models.RoleAuthor}}}, nil
+			},
+			PatchBookFunc: func(ctx context.Context, id string, revision int, patch map[string]interface{}) error {
+				return nil
+			},
+		}
+		api := &API{dataStore: mockDataStore}
+
+		Convey("When the patch is sent", func() {
+			body := `{"authors":[{"author_id":"pratchett"}, {"author_id":"gaiman"}]}`
+			request := httptest.NewRequest(http.MethodPatch, "/books/"+bookID1, strings.NewReader(body))
+			request = mux.SetURLVars(request, map[string]string{"id": bookID1})
+			response := httptest.NewRecorder()
+
+			api.patchBookHandler(response, request)
+			Convey("Then the HTTP response code is 200, with the byline of the patched authors", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+				book := models.Book{}
+				So(json.Unmarshal(response.Body.Bytes(), &book), ShouldBeNil)
+				So(book.Author, ShouldEqual, "Terry Pratchett & Neil Gaiman")
+			})
+			Convey("And the byline is patched together with the authors", func() {
+				So(mockDataStore.PatchBookCalls(), ShouldHaveLength, 1)
+				So(mockDataStore.PatchBookCalls()[0].Patch, ShouldResemble, map[string]interface{}{
+					"authors": []models.Contributor{
+						{AuthorID: authorID1, Role: models.RoleAuthor},
+						{AuthorID: authorID2, Role: models.RoleAuthor},
+					},
+					"author": "Terry Pratchett & Neil Gaiman",
+				})
+			})
+		})
+	})
+}
@@ -4,7 +4,6 @@ import (
 	"github.com/ONSdigital/log.go/log"
 	"github.com/cadmiumcat/books-api/apierrors"
 	"github.com/cadmiumcat/books-api/models"
-	"github.com/cadmiumcat/books-api/pagination"
 	"github.com/gorilla/mux"
 	"net/http"
 )
@@ -23,6 +22,9 @@ func (api *API) addBookHandler(writer http.ResponseWriter, request *http.Request
 		return
 	}
 
+	// The rating summary of a book is calculated from its reviews
+	book.Ratings = nil
+
 	logData := log.Data{"book": book}
 
 	err := book.Validate()
@@ -31,8 +33,18 @@ func (api *API) addBookHandler(writer http.ResponseWriter, request *http.Request
 		return
 	}
 
-	api.dataStore.AddBook(ctx, book)
+	if err := api.resolveAuthors(ctx, book); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	if err := api.dataStore.AddBook(ctx, book); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
 
+	book.SetLinks(api.baseURL)
+	writer.Header().Set("ETag", etag(book.Revision))
 	if err := WriteJSONBody(book, writer, http.StatusCreated); err != nil {
 		handleError(ctx, writer, err, logData)
 		return
@@ -51,20 +63,37 @@ func (api *API) getBooksHandler(writer http.ResponseWriter, request *http.Reques
 		return
 	}
 
-	books, totalCount, err := api.dataStore.GetBooks(ctx, offset, limit)
+	q, err := models.BookFields.Parse(request.URL.Query())
+	logData["query"] = q
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	cursor, err := api.paginator.GetCursor(request, q)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	books, totalCount, err := api.dataStore.GetBooks(ctx, q, cursor, offset, limit)
 	if err != nil {
 		handleError(ctx, writer, err, nil)
 		return
 	}
 
+	page, err := api.paginator.NewPage(request, q, cursor, books, offset, limit, totalCount)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	for i := range books {
+		books[i].SetLinks(api.baseURL)
+	}
 	response := models.BooksResponse{
 		Items: books,
-		Page: pagination.Page{
-			Count:      len(books),
-			Offset:     offset,
-			Limit:      limit,
-			TotalCount: totalCount,
-		},
+		Page:  page,
 	}
 
 	if err := WriteJSONBody(response, writer, http.StatusOK); err != nil {
@@ -90,9 +119,187 @@ func (api *API) getBookHandler(writer http.ResponseWriter, request *http.Request
 		return
 	}
 
+	writer.Header().Set("ETag", etag(book.Revision))
+	if notModified(request, book.Revision) {
+		writer.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	book.SetLinks(api.baseURL)
 	if err := WriteJSONBody(book, writer, http.StatusOK); err != nil {
 		handleError(ctx, writer, err, logData)
 		return
 	}
 	log.Event(ctx, "successfully retrieved book", log.INFO, logData)
 }
+
+func (api *API) updateBookHandler(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+
+	id := mux.Vars(request)["id"]
+	logData := log.Data{"book_id": id}
+	if id == "" {
+		handleError(ctx, writer, apierrors.ErrEmptyBookID, logData)
+		return
+	}
+
+	if request.ContentLength == 0 {
+		handleError(ctx, writer, apierrors.ErrEmptyRequestBody, logData)
+		return
+	}
+
+	// Confirm that book exists. If bookID not found, or there's another error, then return
+	existing, err := api.dataStore.GetBook(ctx, id)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	if err := api.checkIfMatch(request, existing.Revision); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	book := &models.Book{}
+	if err := ReadJSONBody(ctx, request.Body, book); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	// The ID and rating summary of a book cannot be replaced, and the book is replaced at the revision that was read
+	book.ID = existing.ID
+	book.Ratings = existing.Ratings
+	book.Revision = existing.Revision
+
+	logData["book"] = book
+
+	if err := book.Validate(); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	if err := api.resolveAuthors(ctx, book); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	if err := api.dataStore.UpdateBook(ctx, id, book); err != nil {
+		handleError(ctx, writer, preconditionError(request, err), logData)
+		return
+	}
+
+	book.Revision++
+	book.SetLinks(api.baseURL)
+	writer.Header().Set("ETag", etag(book.Revision))
+	if err := WriteJSONBody(book, writer, http.StatusOK); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+	log.Event(ctx, "successfully updated book", log.INFO, logData)
+}
+
+func (api *API) patchBookHandler(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+
+	id := mux.Vars(request)["id"]
+	logData := log.Data{"book_id": id}
+	if id == "" {
+		handleError(ctx, writer, apierrors.ErrEmptyBookID, logData)
+		return
+	}
+
+	if request.ContentLength == 0 {
+		handleError(ctx, writer, apierrors.ErrEmptyRequestBody, logData)
+		return
+	}
+
+	// Confirm that book exists. If bookID not found, or there's another error, then return
+	existing, err := api.dataStore.GetBook(ctx, id)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	if err := api.checkIfMatch(request, existing.Revision); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	patch := make(map[string]interface{})
+	if err := ReadJSONBody(ctx, request.Body, &patch); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	logData["patch"] = patch
+
+	book, err := existing.ApplyMergePatch(patch)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	if err := book.Validate(); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	if err := api.resolveAuthors(ctx, book); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	// The patch is stored with the values normalised by the validation, e.g. the ISBN-13 of an ISBN-10,
+	// and with the byline made from the patched authors
+	normalised := book.NormalisedPatch(patch)
+	if _, ok := patch["authors"]; ok {
+		normalised["author"] = book.Author
+	}
+
+	if err := api.dataStore.PatchBook(ctx, id, existing.Revision, normalised); err != nil {
+		handleError(ctx, writer, preconditionError(request, err), logData)
+		return
+	}
+
+	// An empty patch does not change the book
+	if len(normalised) > 0 {
+		book.Revision++
+	}
+	book.SetLinks(api.baseURL)
+	writer.Header().Set("ETag", etag(book.Revision))
+	if err := WriteJSONBody(book, writer, http.StatusOK); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+	log.Event(ctx, "successfully patched book", log.INFO, logData)
+}
+
+func (api *API) deleteBookHandler(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+
+	id := mux.Vars(request)["id"]
+	logData := log.Data{"book_id": id, "cascade_reviews": api.cascadeReviewsOnDelete}
+	if id == "" {
+		handleError(ctx, writer, apierrors.ErrEmptyBookID, logData)
+		return
+	}
+
+	existing, err := api.dataStore.GetBook(ctx, id)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	if err := api.checkIfMatch(request, existing.Revision); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	if err := api.dataStore.DeleteBook(ctx, id, existing.Revision, api.cascadeReviewsOnDelete); err != nil {
+		handleError(ctx, writer, preconditionError(request, err), logData)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+	log.Event(ctx, "successfully deleted book", log.INFO, logData)
+}
@@ -3,17 +3,20 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/cadmiumcat/books-api/apierrors"
 	"github.com/cadmiumcat/books-api/interfaces/mock"
 	"github.com/cadmiumcat/books-api/models"
 	"github.com/cadmiumcat/books-api/mongo"
 	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/cadmiumcat/books-api/query"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	. "github.com/smartystreets/goconvey/convey"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -58,7 +61,7 @@ func TestGetBookHandler(t *testing.T) {
 	Convey("Given an existing book with book id=1", t, func() {
 		mockDataStore := &mock.DataStoreMock{
 			GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
-				return &models.Book{ID: bookID1}, nil
+				return &models.Book{ID: bookID1, Revision: 3}, nil
 			},
 		}
 		api := &API{dataStore: mockDataStore}
@@ -74,11 +77,28 @@ func TestGetBookHandler(t *testing.T) {
 			Convey("Then the HTTP response code is 200", func() {
 				So(response.Code, ShouldEqual, http.StatusOK)
 			})
+			Convey("And the ETag header is the revision of the book", func() {
+				So(response.Header().Get("ETag"), ShouldEqual, `"3"`)
+			})
 			Convey("And the GetBook function is called once", func() {
 				So(mockDataStore.GetBookCalls(), ShouldHaveLength, 1)
 			})
 		})
 
+		Convey("When the If-None-Match header of the request is the ETag of the book", func() {
+			request := httptest.NewRequest(http.MethodGet, "/books/"+bookID1, nil)
+			request.Header.Set("If-None-Match", `"2", W/"3"`)
+			request = mux.SetURLVars(request, map[string]string{"id": bookID1})
+			response := httptest.NewRecorder()
+
+			api.getBookHandler(response, request)
+			Convey("Then the HTTP response code is 304, with the ETag and no body", func() {
+				So(response.Code, ShouldEqual, http.StatusNotModified)
+				So(response.Header().Get("ETag"), ShouldEqual, `"3"`)
+				So(response.Body.Len(), ShouldEqual, 0)
+			})
+		})
+
 	})
 
 	Convey("Given a book that does not exist", t, func() {
@@ -130,7 +150,7 @@ func TestGetBookHandler(t *testing.T) {
 
 			Convey("Then 500 InternalServerError status code is returned", func() {
 				So(response.Code, ShouldEqual, http.StatusInternalServerError)
-				So(response.Body.String(), ShouldEqual, internalSeverErrorMessage)
+				So(readProblem(t, response).Detail, ShouldEqual, internalSeverErrorMessage)
 			})
 		})
 	})
@@ -142,7 +162,7 @@ func TestGetBooksHandler(t *testing.T) {
 	Convey("Given a datastore with no books", t, func() {
 
 		mockDataStore := &mock.DataStoreMock{
-			GetBooksFunc: func(ctx context.Context, offset int, limit int) ([]models.Book, int, error) {
+			GetBooksFunc: func(ctx context.Context, q *query.Query, cursor *pagination.Cursor, offset int, limit int) ([]models.Book, int, error) {
 				return []models.Book{}, 0, nil
 			},
 		}
@@ -185,11 +205,95 @@ func TestGetBooksHandler(t *testing.T) {
 				So(page.Page, ShouldResemble, expectedPage)
 			})
 		})
+
+		Convey("When a http get request is sent to /books with filters and a sort order", func() {
+			request := httptest.NewRequest(http.MethodGet, "/books?author=Bernardine%20Evaristo&title_contains=girl&sort=-title", nil)
+			response := httptest.NewRecorder()
+
+			api.getBooksHandler(response, request)
+			Convey("then the HTTP response code is 200", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+			})
+			Convey("And the GetBooks function is called with the query", func() {
+				So(mockDataStore.GetBooksCalls(), ShouldHaveLength, 1)
+				So(mockDataStore.GetBooksCalls()[0].Q, ShouldResemble, &query.Query{
+					Filters: []query.Filter{
+						{Key: "author", Operator: query.Equals, Value: "Bernardine Evaristo"},
+						{Key: "title", Operator: query.Contains, Value: "girl"},
+					},
+					Sort: []query.Sort{{Key: "title", Descending: true}},
+				})
+			})
+		})
+
+		Convey("When a http get request is sent to /books with a cursor", func() {
+			cursor := &pagination.Cursor{Values: []interface{}{bookID1}}
+			paginator.GetCursorFunc = func(r *http.Request, q *query.Query) (*pagination.Cursor, error) {
+				return cursor, nil
+			}
+			request := httptest.NewRequest(http.MethodGet, "/books?cursor=abc", nil)
+			response := httptest.NewRecorder()
+
+			api.getBooksHandler(response, request)
+			Convey("then the HTTP response code is 200", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+			})
+			Convey("And the GetBooks function is called with the cursor", func() {
+				So(mockDataStore.GetBooksCalls(), ShouldHaveLength, 1)
+				So(mockDataStore.GetBooksCalls()[0].Cursor, ShouldEqual, cursor)
+			})
+			Convey("And the page is built with the cursor", func() {
+				So(paginator.NewPageCalls(), ShouldHaveLength, 1)
+				So(paginator.NewPageCalls()[0].Cursor, ShouldEqual, cursor)
+			})
+		})
+
+		Convey("When a http get request is sent to /books with an invalid cursor", func() {
+			paginator.GetCursorFunc = func(r *http.Request, q *query.Query) (*pagination.Cursor, error) {
+				return nil, pagination.ErrInvalidCursorParameter
+			}
+			request := httptest.NewRequest(http.MethodGet, "/books?cursor=forged", nil)
+			response := httptest.NewRecorder()
+
+			api.getBooksHandler(response, request)
+			Convey("then the HTTP response code is 400", func() {
+				So(response.Code, ShouldEqual, http.StatusBadRequest)
+				So(response.Body.String(), ShouldContainSubstring, pagination.ErrInvalidCursorParameter.Error())
+			})
+			Convey("And the GetBooks function is not called", func() {
+				So(mockDataStore.GetBooksCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When a http get request is sent to /books with an unknown filter", func() {
+			request := httptest.NewRequest(http.MethodGet, "/books?shelf=fantasy", nil)
+			response := httptest.NewRecorder()
+
+			api.getBooksHandler(response, request)
+			Convey("then the HTTP response code is 400", func() {
+				So(response.Code, ShouldEqual, http.StatusBadRequest)
+				So(response.Body.String(), ShouldContainSubstring, query.ErrUnknownQueryParameter.Error())
+			})
+			Convey("And the GetBooks function is not called", func() {
+				So(mockDataStore.GetBooksCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When a http get request is sent to /books sorted by a field that cannot be sorted", func() {
+			request := httptest.NewRequest(http.MethodGet, "/books?sort=synopsis", nil)
+			response := httptest.NewRecorder()
+
+			api.getBooksHandler(response, request)
+			Convey("then the HTTP response code is 400", func() {
+				So(response.Code, ShouldEqual, http.StatusBadRequest)
+				So(response.Body.String(), ShouldContainSubstring, query.ErrInvalidSortParameter.Error())
+			})
+		})
 	})
 
 	Convey("Given a datastore with 2 books", t, func() {
 		mockDataStore := &mock.DataStoreMock{
-			GetBooksFunc: func(ctx context.Context, offset int, limit int) ([]models.Book, int, error) {
+			GetBooksFunc: func(ctx context.Context, q *query.Query, cursor *pagination.Cursor, offset int, limit int) ([]models.Book, int, error) {
 				return []models.Book{book1, book2}, 2, nil
 			},
 		}
@@ -236,7 +340,7 @@ func TestGetBooksHandler(t *testing.T) {
 	Convey("Given a GET request for a list of books", t, func() {
 		Convey("When GetBooks returns an unexpected database error", func() {
 			mockDataStore := &mock.DataStoreMock{
-				GetBooksFunc: func(ctx context.Context, offset int, limit int) ([]models.Book, int, error) {
+				GetBooksFunc: func(ctx context.Context, q *query.Query, cursor *pagination.Cursor, offset int, limit int) ([]models.Book, int, error) {
 					return []models.Book{}, 0, errors.Wrap(errMongoDB, "unexpected error when getting books")
 				},
 			}
@@ -262,7 +366,7 @@ func TestGetBooksHandler(t *testing.T) {
 
 			Convey("And a 500 InternalServerError status code is returned", func() {
 				So(response.Code, ShouldEqual, http.StatusInternalServerError)
-				So(response.Body.String(), ShouldEqual, internalSeverErrorMessage)
+				So(readProblem(t, response).Detail, ShouldEqual, internalSeverErrorMessage)
 			})
 		})
 	})
@@ -308,8 +412,8 @@ func TestAddBookHandler(t *testing.T) {
 			response := httptest.NewRecorder()
 
 			api.addBookHandler(response, request)
-			Convey("Then the HTTP response code is 400", func() {
-				So(response.Code, ShouldEqual, http.StatusBadRequest)
+			Convey("Then the HTTP response code is 422", func() {
+				So(response.Code, ShouldEqual, http.StatusUnprocessableEntity)
 			})
 			Convey("And there AddBook function is not called", func() {
 				So(mockDataStore.AddBookCalls(), ShouldHaveLength, 0)
@@ -335,6 +439,483 @@ func TestAddBookHandler(t *testing.T) {
 				So(mockDataStore.AddBookCalls(), ShouldHaveLength, 1)
 			})
 		})
+
+		Convey("When the body contains a book with an invalid ISBN", func() {
+			api := &API{dataStore: mockDataStore}
+
+			body := strings.NewReader(`{"title":"Girl, Woman, Other", "author":"Bernardine Evaristo", "isbn":"978-0-241-36452-0"}`)
+			request := httptest.NewRequest(http.MethodPost, "/books", body)
+
+			response := httptest.NewRecorder()
+
+			api.addBookHandler(response, request)
+			Convey("Then the HTTP response code is 422, with the invalid field", func() {
+				So(response.Code, ShouldEqual, http.StatusUnprocessableEntity)
+				problem := readProblem(t, response)
+				So(problem.Code, ShouldEqual, "invalid_isbn")
+				So(problem.Errors, ShouldResemble, []apierrors.FieldError{{Field: "isbn", Rule: apierrors.RuleChecksum}})
+			})
+			Convey("And the AddBook function is not called", func() {
+				So(mockDataStore.AddBookCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the body contains a book with the ISBN of another book", func() {
+			mockDataStore.AddBookFunc = func(ctx context.Context, book *models.Book) error {
+				return mongo.ErrDuplicateISBN
+			}
+			api := &API{dataStore: mockDataStore}
+
+			body := strings.NewReader(`{"title":"Kindred", "author":"Octavia E. Butler", "isbn":"0-306-40615-2"}`)
+			request := httptest.NewRequest(http.MethodPost, "/books", body)
+
+			response := httptest.NewRecorder()
+
+			api.addBookHandler(response, request)
+			Convey("Then the HTTP response code is 409", func() {
+				So(response.Code, ShouldEqual, http.StatusConflict)
+				So(readProblem(t, response).Code, ShouldEqual, "duplicate_isbn")
+			})
+			Convey("And the book is added with its normalised ISBN", func() {
+				So(mockDataStore.AddBookCalls(), ShouldHaveLength, 1)
+				So(mockDataStore.AddBookCalls()[0].Book.ISBN, ShouldEqual, "9780306406157")
+			})
+		})
+
+		for _, failure := range writeFailures {
+			failure := failure
+			Convey("When adding the book fails with "+failure.name, func() {
+				mockDataStore.AddBookFunc = func(ctx context.Context, book *models.Book) error {
+					return failure.err
+				}
+				api := &API{dataStore: mockDataStore}
+
+				body := strings.NewReader(`{"title":"Kindred", "author":"Octavia E. Butler"}`)
+				request := httptest.NewRequest(http.MethodPost, "/books", body)
+
+				response := httptest.NewRecorder()
+
+				api.addBookHandler(response, request)
+				Convey(fmt.Sprintf("Then the HTTP response code is %d", failure.status), func() {
+					So(response.Code, ShouldEqual, failure.status)
+					So(readProblem(t, response).Code, ShouldEqual, failure.code)
+					So(response.Header().Get("Retry-After"), ShouldEqual, failure.retryAfter)
+				})
+			})
+		}
+	})
+}
+
+func TestUpdateBookHandler(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given an HTTP PUT request to the /books/{id} endpoint", t, func() {
+
+		Convey("When the book exists and the update is valid", func() {
+			mockDataStore := &mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return &book1, nil
+				},
+				UpdateBookFunc: func(ctx context.Context, id string, book *models.Book) error {
+					return nil
+				},
+			}
+			api := &API{dataStore: mockDataStore}
+
+			body := strings.NewReader(`{"id":"changed", "title":"Kindred", "author":"Octavia E. Butler"}`)
+			request := httptest.NewRequest(http.MethodPut, "/books/"+bookID1, body)
+			request = mux.SetURLVars(request, map[string]string{"id": bookID1})
+			response := httptest.NewRecorder()
+
+			api.updateBookHandler(response, request)
+			Convey("Then the HTTP response code is 200", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+			})
+			Convey("And the UpdateBook function is called once with the new book, keeping the original ID", func() {
+				So(mockDataStore.UpdateBookCalls(), ShouldHaveLength, 1)
+				So(mockDataStore.UpdateBookCalls()[0].ID, ShouldEqual, bookID1)
+				So(mockDataStore.UpdateBookCalls()[0].Book.ID, ShouldEqual, bookID1)
+				So(mockDataStore.UpdateBookCalls()[0].Book.Title, ShouldEqual, "Kindred")
+			})
+			Convey("And the response contains the updated book", func() {
+				payload, err := ioutil.ReadAll(response.Body)
+				So(err, ShouldBeNil)
+				book := models.Book{}
+				So(json.Unmarshal(payload, &book), ShouldBeNil)
+				So(book.ID, ShouldEqual, bookID1)
+				So(book.Author, ShouldEqual, "Octavia E. Butler")
+			})
+		})
+
+		Convey("When the book exists but the update is missing a required field", func() {
+			mockDataStore := &mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return &book1, nil
+				},
+			}
+			api := &API{dataStore: mockDataStore}
+
+			body := strings.NewReader(`{"title":"Kindred"}`)
+			request := httptest.NewRequest(http.MethodPut, "/books/"+bookID1, body)
+			request = mux.SetURLVars(request, map[string]string{"id": bookID1})
+			response := httptest.NewRecorder()
+
+			api.updateBookHandler(response, request)
+			Convey("Then the HTTP response code is 422", func() {
+				So(response.Code, ShouldEqual, http.StatusUnprocessableEntity)
+				So(response.Body.String(), ShouldContainSubstring, apierrors.ErrRequiredFieldMissing.Error())
+			})
+			Convey("And the UpdateBook function is not called", func() {
+				So(mockDataStore.UpdateBookCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the book does not exist", func() {
+			mockDataStore := &mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return nil, mongo.ErrBookNotFound
+				},
+			}
+			api := &API{dataStore: mockDataStore}
+
+			body := strings.NewReader(`{"title":"Kindred", "author":"Octavia E. Butler"}`)
+			request := httptest.NewRequest(http.MethodPut, "/books/"+bookIDNotInStore, body)
+			request = mux.SetURLVars(request, map[string]string{"id": bookIDNotInStore})
+			response := httptest.NewRecorder()
+
+			api.updateBookHandler(response, request)
+			Convey("Then the HTTP response code is 404", func() {
+				So(response.Code, ShouldEqual, http.StatusNotFound)
+				So(response.Body.String(), ShouldContainSubstring, mongo.ErrBookNotFound.Error())
+			})
+			Convey("And the UpdateBook function is not called", func() {
+				So(mockDataStore.UpdateBookCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When there is no request body", func() {
+			mockDataStore := &mock.DataStoreMock{}
+			api := &API{dataStore: mockDataStore}
+
+			request := httptest.NewRequest(http.MethodPut, "/books/"+bookID1, strings.NewReader(``))
+			request = mux.SetURLVars(request, map[string]string{"id": bookID1})
+			response := httptest.NewRecorder()
+
+			api.updateBookHandler(response, request)
+			Convey("Then the HTTP response code is 400", func() {
+				So(response.Code, ShouldEqual, http.StatusBadRequest)
+				So(response.Body.String(), ShouldContainSubstring, apierrors.ErrEmptyRequestBody.Error())
+			})
+			Convey("And the datastore is not called", func() {
+				So(mockDataStore.GetBookCalls(), ShouldHaveLength, 0)
+				So(mockDataStore.UpdateBookCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+}
+
+func TestPatchBookHandler(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given an HTTP PATCH request to the /books/{id} endpoint", t, func() {
+
+		Convey("When the book exists and the patch is valid", func() {
+			mockDataStore := &mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return &models.Book{ID: bookID1, Title: "Kindred", Author: "Octavia Butler", Synopsis: "Time travel"}, nil
+				},
+				PatchBookFunc: func(ctx context.Context, id string, revision int, patch map[string]interface{}) error {
+					return nil
+				},
+			}
+			api := &API{dataStore: mockDataStore, baseURL: "http://localhost:8080/v1"}
+
+			body := strings.NewReader(`{"author":"Octavia E. Butler", "synopsis":null}`)
+			request := httptest.NewRequest(http.MethodPatch, "/books/"+bookID1, body)
+			request = mux.SetURLVars(request, map[string]string{"id": bookID1})
+			response := httptest.NewRecorder()
+
+			api.patchBookHandler(response, request)
+			Convey("Then the HTTP response code is 200", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+			})
+			Convey("And the PatchBook function is called once with the patch", func() {
+				So(mockDataStore.PatchBookCalls(), ShouldHaveLength, 1)
+				So(mockDataStore.PatchBookCalls()[0].ID, ShouldEqual, bookID1)
+				So(mockDataStore.PatchBookCalls()[0].Patch, ShouldResemble, map[string]interface{}{"author": "Octavia E. Butler", "synopsis": nil})
+			})
+			Convey("And the response contains the patched book, with its absolute links", func() {
+				payload, err := ioutil.ReadAll(response.Body)
+				So(err, ShouldBeNil)
+				book := models.Book{}
+				So(json.Unmarshal(payload, &book), ShouldBeNil)
+				So(book, ShouldResemble, models.Book{
+					ID:     bookID1,
+					Title:  "Kindred",
+					Author: "Octavia E. Butler",
+					Links: &models.Link{
+						Self:         "http://localhost:8080/v1/books/" + bookID1,
+						Reservations: "http://localhost:8080/v1/books/" + bookID1 + "/reservations",
+						Reviews:      "http://localhost:8080/v1/books/" + bookID1 + "/reviews",
+					},
+				})
+			})
+		})
+
+		Convey("When the patch removes a required field", func() {
+			mockDataStore := &mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return &book1, nil
+				},
+			}
+			api := &API{dataStore: mockDataStore}
+
+			body := strings.NewReader(`{"title":null}`)
+			request := httptest.NewRequest(http.MethodPatch, "/books/"+bookID1, body)
+			request = mux.SetURLVars(request, map[string]string{"id": bookID1})
+			response := httptest.NewRecorder()
+
+			api.patchBookHandler(response, request)
+			Convey("Then the HTTP response code is 422", func() {
+				So(response.Code, ShouldEqual, http.StatusUnprocessableEntity)
+				So(response.Body.String(), ShouldContainSubstring, apierrors.ErrRequiredFieldMissing.Error())
+			})
+			Convey("And the PatchBook function is not called", func() {
+				So(mockDataStore.PatchBookCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the patch modifies a field that cannot be patched", func() {
+			mockDataStore := &mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return &book1, nil
+				},
+			}
+			api := &API{dataStore: mockDataStore}
+
+			body := strings.NewReader(`{"id":"new-id"}`)
+			request := httptest.NewRequest(http.MethodPatch, "/books/"+bookID1, body)
+			request = mux.SetURLVars(request, map[string]string{"id": bookID1})
+			response := httptest.NewRecorder()
+
+			api.patchBookHandler(response, request)
+			Convey("Then the HTTP response code is 422", func() {
+				So(response.Code, ShouldEqual, http.StatusUnprocessableEntity)
+				So(response.Body.String(), ShouldContainSubstring, apierrors.ErrInvalidPatch.Error())
+			})
+			Convey("And the PatchBook function is not called", func() {
+				So(mockDataStore.PatchBookCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the patch changes the ISBN", func() {
+			mockDataStore := &mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return &book1, nil
+				},
+				PatchBookFunc: func(ctx context.Context, id string, revision int, patch map[string]interface{}) error {
+					return nil
+				},
+			}
+			api := &API{dataStore: mockDataStore}
+
+			body := strings.NewReader(`{"isbn":"0-306-40615-2"}`)
+			request := httptest.NewRequest(http.MethodPatch, "/books/"+bookID1, body)
+			request = mux.SetURLVars(request, map[string]string{"id": bookID1})
+			response := httptest.NewRecorder()
+
+			api.patchBookHandler(response, request)
+			Convey("Then the HTTP response code is 200", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+			})
+			Convey("And the PatchBook function is called with the normalised ISBN", func() {
+				So(mockDataStore.PatchBookCalls(), ShouldHaveLength, 1)
+				So(mockDataStore.PatchBookCalls()[0].Patch, ShouldResemble, map[string]interface{}{"isbn": "9780306406157"})
+			})
+		})
+
+		Convey("When the book does not exist", func() {
+			mockDataStore := &mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return nil, mongo.ErrBookNotFound
+				},
+			}
+			api := &API{dataStore: mockDataStore}
+
+			body := strings.NewReader(`{"title":"Kindred"}`)
+			request := httptest.NewRequest(http.MethodPatch, "/books/"+bookIDNotInStore, body)
+			request = mux.SetURLVars(request, map[string]string{"id": bookIDNotInStore})
+			response := httptest.NewRecorder()
+
+			api.patchBookHandler(response, request)
+			Convey("Then the HTTP response code is 404", func() {
+				So(response.Code, ShouldEqual, http.StatusNotFound)
+			})
+			Convey("And the PatchBook function is not called", func() {
+				So(mockDataStore.PatchBookCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+}
+
+func TestDeleteBookHandler(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given an HTTP DELETE request to the /books/{id} endpoint", t, func() {
+
+		Convey("When the book exists", func() {
+			mockDataStore := &mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return &models.Book{ID: bookID1, Revision: 3}, nil
+				},
+				DeleteBookFunc: func(ctx context.Context, id string, revision int, cascadeReviews bool) error {
+					return nil
+				},
+			}
+			api := &API{dataStore: mockDataStore, cascadeReviewsOnDelete: true}
+
+			request := httptest.NewRequest(http.MethodDelete, "/books/"+bookID1, nil)
+			request = mux.SetURLVars(request, map[string]string{"id": bookID1})
+			response := httptest.NewRecorder()
+
+			api.deleteBookHandler(response, request)
+			Convey("Then the HTTP response code is 204", func() {
+				So(response.Code, ShouldEqual, http.StatusNoContent)
+			})
+			Convey("And the DeleteBook function is called once with the configured review policy", func() {
+				So(mockDataStore.DeleteBookCalls(), ShouldHaveLength, 1)
+				So(mockDataStore.DeleteBookCalls()[0].ID, ShouldEqual, bookID1)
+				So(mockDataStore.DeleteBookCalls()[0].Revision, ShouldEqual, 3)
+				So(mockDataStore.DeleteBookCalls()[0].CascadeReviews, ShouldBeTrue)
+			})
+		})
+
+		Convey("When the book has reviews and they are not deleted in cascade", func() {
+			mockDataStore := &mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return &models.Book{ID: bookID1}, nil
+				},
+				DeleteBookFunc: func(ctx context.Context, id string, revision int, cascadeReviews bool) error {
+					return mongo.ErrBookHasReviews
+				},
+			}
+			api := &API{dataStore: mockDataStore}
+
+			request := httptest.NewRequest(http.MethodDelete, "/books/"+bookID1, nil)
+			request = mux.SetURLVars(request, map[string]string{"id": bookID1})
+			response := httptest.NewRecorder()
+
+			api.deleteBookHandler(response, request)
+			Convey("Then the HTTP response code is 409", func() {
+				So(response.Code, ShouldEqual, http.StatusConflict)
+				So(response.Body.String(), ShouldContainSubstring, mongo.ErrBookHasReviews.Error())
+			})
+			Convey("And the DeleteBook function is called once", func() {
+				So(mockDataStore.DeleteBookCalls(), ShouldHaveLength, 1)
+				So(mockDataStore.DeleteBookCalls()[0].CascadeReviews, ShouldBeFalse)
+			})
+		})
+
+		Convey("When the book does not exist", func() {
+			mockDataStore := &mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return nil, mongo.ErrBookNotFound
+				},
+			}
+			api := &API{dataStore: mockDataStore}
+
+			request := httptest.NewRequest(http.MethodDelete, "/books/"+bookIDNotInStore, nil)
+			request = mux.SetURLVars(request, map[string]string{"id": bookIDNotInStore})
+			response := httptest.NewRecorder()
+
+			api.deleteBookHandler(response, request)
+			Convey("Then the HTTP response code is 404", func() {
+				So(response.Code, ShouldEqual, http.StatusNotFound)
+			})
+			Convey("And the DeleteBook function is not called", func() {
+				So(mockDataStore.DeleteBookCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the If-Match header is not the ETag of the book", func() {
+			mockDataStore := &mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return &models.Book{ID: bookID1, Revision: 3}, nil
+				},
+			}
+			api := &API{dataStore: mockDataStore, requireIfMatch: true}
+
+			request := httptest.NewRequest(http.MethodDelete, "/books/"+bookID1, nil)
+			request.Header.Set("If-Match", `"2"`)
+			request = mux.SetURLVars(request, map[string]string{"id": bookID1})
+			response := httptest.NewRecorder()
+
+			api.deleteBookHandler(response, request)
+			Convey("Then the HTTP response code is 412, and the book is not deleted", func() {
+				So(response.Code, ShouldEqual, http.StatusPreconditionFailed)
+				So(readProblem(t, response).Code, ShouldEqual, "precondition_failed")
+				So(mockDataStore.DeleteBookCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the book changes after the If-Match header is checked", func() {
+			mockDataStore := &mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return &models.Book{ID: bookID1, Revision: 3}, nil
+				},
+				DeleteBookFunc: func(ctx context.Context, id string, revision int, cascadeReviews bool) error {
+					return mongo.ErrBookConflict
+				},
+			}
+			api := &API{dataStore: mockDataStore, requireIfMatch: true}
+
+			request := httptest.NewRequest(http.MethodDelete, "/books/"+bookID1, nil)
+			request.Header.Set("If-Match", `"3"`)
+			request = mux.SetURLVars(request, map[string]string{"id": bookID1})
+			response := httptest.NewRecorder()
+
+			api.deleteBookHandler(response, request)
+			Convey("Then the HTTP response code is 412", func() {
+				So(response.Code, ShouldEqual, http.StatusPreconditionFailed)
+				So(mockDataStore.DeleteBookCalls()[0].Revision, ShouldEqual, 3)
+			})
+		})
+
+		Convey("When the If-Match header is required but missing", func() {
+			mockDataStore := &mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return &models.Book{ID: bookID1, Revision: 3}, nil
+				},
+			}
+			api := &API{dataStore: mockDataStore, requireIfMatch: true}
+
+			request := httptest.NewRequest(http.MethodDelete, "/books/"+bookID1, nil)
+			request = mux.SetURLVars(request, map[string]string{"id": bookID1})
+			response := httptest.NewRecorder()
+
+			api.deleteBookHandler(response, request)
+			Convey("Then the HTTP response code is 428, and the book is not deleted", func() {
+				So(response.Code, ShouldEqual, http.StatusPreconditionRequired)
+				So(readProblem(t, response).Code, ShouldEqual, "precondition_required")
+				So(mockDataStore.DeleteBookCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the {id} is empty", func() {
+			mockDataStore := &mock.DataStoreMock{}
+			api := &API{dataStore: mockDataStore}
+
+			request := httptest.NewRequest(http.MethodDelete, "/books/"+emptyID, nil)
+			response := httptest.NewRecorder()
+
+			api.deleteBookHandler(response, request)
+			Convey("Then the HTTP response code is 400", func() {
+				So(response.Code, ShouldEqual, http.StatusBadRequest)
+			})
+			Convey("And the DeleteBook function is not called", func() {
+				So(mockDataStore.DeleteBookCalls(), ShouldHaveLength, 0)
+			})
+		})
 	})
 }
 
@@ -343,6 +924,12 @@ func mockPaginator() *mock.PaginatorMock {
 		GetPaginationValuesFunc: func(r *http.Request) (int, int, error) {
 			return offset, limit, nil
 		},
+		GetCursorFunc: func(r *http.Request, q *query.Query) (*pagination.Cursor, error) {
+			return nil, nil
+		},
+		NewPageFunc: func(r *http.Request, q *query.Query, cursor *pagination.Cursor, items interface{}, offset, limit, totalCount int) (pagination.Page, error) {
+			return pagination.Page{Count: reflect.ValueOf(items).Len(), Offset: offset, Limit: limit, TotalCount: totalCount}, nil
+		},
 	}
 	return paginator
 }
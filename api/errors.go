@@ -0,0 +1,91 @@
+package api
+
+import (
+	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/auth"
+	"github.com/cadmiumcat/books-api/importer"
+	"github.com/cadmiumcat/books-api/mongo"
+	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/cadmiumcat/books-api/query"
+	"net/http"
+)
+
+// problems maps the errors returned by the handlers to the problems in their responses.
+// Codes are part of the API: once published they must not change.
+var problems = newProblemRegistry()
+
+func newProblemRegistry() *apierrors.Registry {
+	r := apierrors.NewRegistry()
+
+	r.Register(auth.ErrMissingCredentials, http.StatusUnauthorized, "missing_credentials")
+	r.Register(auth.ErrInvalidCredentials, http.StatusUnauthorized, "invalid_credentials")
+	r.Register(auth.ErrForbidden, http.StatusForbidden, "forbidden")
+
+	r.Register(mongo.ErrBookNotFound, http.StatusNotFound, "book_not_found")
+	r.Register(mongo.ErrReviewNotFound, http.StatusNotFound, "review_not_found")
+	r.Register(mongo.ErrReservationNotFound, http.StatusNotFound, "reservation_not_found")
+	r.Register(mongo.ErrAuthorNotFound, http.StatusNotFound, "author_not_found")
+	r.Register(importer.ErrJobNotFound, http.StatusNotFound, "import_job_not_found")
+
+	r.Register(mongo.ErrBookHasReviews, http.StatusConflict, "book_has_reviews")
+	r.Register(mongo.ErrDuplicateISBN, http.StatusConflict, "duplicate_isbn")
+	r.Register(mongo.ErrAuthorHasBooks, http.StatusConflict, "author_has_books")
+	r.Register(mongo.ErrBookUnavailable, http.StatusConflict, "book_unavailable")
+	r.Register(mongo.ErrReservationConflict, http.StatusConflict, "reservation_conflict")
+	r.Register(mongo.ErrReviewConflict, http.StatusConflict, "review_conflict")
+	r.Register(mongo.ErrBookConflict, http.StatusConflict, "book_conflict")
+	r.Register(mongo.ErrDuplicateKey, http.StatusConflict, "duplicate_resource")
+	r.Register(apierrors.ErrInvalidReservationState, http.StatusConflict, "invalid_reservation_state")
+	r.Register(apierrors.ErrInvalidReviewState, http.StatusConflict, "invalid_review_state")
+	r.Register(apierrors.ErrIdempotencyKeyInUse, http.StatusConflict, "idempotency_key_in_use")
+
+	r.Register(apierrors.ErrEmptyRequestBody, http.StatusBadRequest, "empty_request_body")
+	r.Register(apierrors.ErrEmptyBookID, http.StatusBadRequest, "empty_book_id")
+	r.Register(apierrors.ErrEmptyAuthorID, http.StatusBadRequest, "empty_author_id")
+	r.Register(apierrors.ErrEmptyReviewID, http.StatusBadRequest, "empty_review_id")
+	r.Register(apierrors.ErrInvalidReview, http.StatusBadRequest, "invalid_review")
+	r.Register(apierrors.ErrEmptyReservationID, http.StatusBadRequest, "empty_reservation_id")
+	r.Register(apierrors.ErrInvalidReservation, http.StatusBadRequest, "invalid_reservation")
+	r.Register(apierrors.ErrEmptySearchQuery, http.StatusBadRequest, "empty_search_query")
+	r.Register(apierrors.ErrInvalidExportParameter, http.StatusBadRequest, "invalid_export_parameter")
+	r.Register(apierrors.ErrUnableToParseJSON, http.StatusBadRequest, "invalid_json")
+	r.Register(apierrors.ErrInvalidIdempotencyKey, http.StatusBadRequest, "invalid_idempotency_key")
+
+	// Requests that are well formed, but whose fields are not valid
+	r.Register(apierrors.ErrRequiredFieldMissing, http.StatusUnprocessableEntity, "required_field_missing")
+	r.Register(apierrors.ErrInvalidISBN, http.StatusUnprocessableEntity, "invalid_isbn")
+	r.Register(apierrors.ErrInvalidBook, http.StatusUnprocessableEntity, "invalid_book")
+	r.Register(apierrors.ErrEmptyAuthorName, http.StatusUnprocessableEntity, "empty_author_name")
+	r.Register(apierrors.ErrUnknownAuthor, http.StatusUnprocessableEntity, "unknown_author")
+	r.Register(apierrors.ErrEmptyReviewMessage, http.StatusUnprocessableEntity, "empty_review_message")
+	r.Register(apierrors.ErrEmptyReviewUser, http.StatusUnprocessableEntity, "empty_review_user")
+	r.Register(apierrors.ErrLongReviewMessage, http.StatusUnprocessableEntity, "long_review_message")
+	r.Register(apierrors.ErrInvalidRating, http.StatusUnprocessableEntity, "invalid_rating")
+	r.Register(apierrors.ErrInvalidPatch, http.StatusUnprocessableEntity, "invalid_patch")
+	r.Register(apierrors.ErrInvalidReviewPatch, http.StatusUnprocessableEntity, "invalid_review_patch")
+	r.Register(apierrors.ErrEmptyReservationUser, http.StatusUnprocessableEntity, "empty_reservation_user")
+
+	r.Register(apierrors.ErrPreconditionFailed, http.StatusPreconditionFailed, "precondition_failed")
+	r.Register(apierrors.ErrPreconditionRequired, http.StatusPreconditionRequired, "precondition_required")
+	r.Register(apierrors.ErrIdempotencyKeyReused, http.StatusUnprocessableEntity, "idempotency_key_reused")
+	r.Register(mongo.ErrUnavailable, http.StatusServiceUnavailable, "data_store_unavailable")
+
+	r.Register(importer.ErrUnsupportedFormat, http.StatusUnsupportedMediaType, "unsupported_import_format")
+	r.Register(apierrors.ErrImportTooLarge, http.StatusRequestEntityTooLarge, "import_too_large")
+	r.Register(importer.ErrInvalidHeader, http.StatusBadRequest, "invalid_import_header")
+	r.Register(importer.ErrInvalidRow, http.StatusBadRequest, "invalid_import_row")
+
+	r.Register(pagination.ErrInvalidLimitParameter, http.StatusBadRequest, "invalid_limit")
+	r.Register(pagination.ErrInvalidOffsetParameter, http.StatusBadRequest, "invalid_offset")
+	r.Register(pagination.ErrLimitOverMax, http.StatusBadRequest, "limit_over_max")
+	r.Register(pagination.ErrInvalidCursorParameter, http.StatusBadRequest, "invalid_cursor")
+	r.Register(pagination.ErrCursorWithOffset, http.StatusBadRequest, "cursor_with_offset")
+	r.Register(query.ErrUnknownQueryParameter, http.StatusBadRequest, "unknown_query_parameter")
+	r.Register(query.ErrInvalidFilterParameter, http.StatusBadRequest, "invalid_filter")
+	r.Register(query.ErrInvalidSortParameter, http.StatusBadRequest, "invalid_sort")
+
+	// Validation errors that wrap an error without a code of its own
+	r.RegisterType((*apierrors.ValidationError)(nil), http.StatusUnprocessableEntity, "validation_failed")
+
+	return r
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"github.com/ONSdigital/log.go/log"
+	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/auth"
+	"github.com/cadmiumcat/books-api/importer"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/query"
+	"github.com/pkg/errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// exportParameters are the query parameters of an export
+var exportParameters = map[string]bool{"format": true, "reviews": true, "gzip": true}
+
+// An exportRequest is the format of an export, and whether the reviews are embedded and the file is gzipped
+type exportRequest struct {
+	format  string
+	reviews bool
+	gzip    bool
+}
+
+// parseExportRequest returns the export requested by the query parameters. The default format is JSON Lines.
+// It returns ErrInvalidExportParameter if a parameter is unknown or does not have a valid value.
+func parseExportRequest(values url.Values) (exportRequest, error) {
+	export := exportRequest{format: importer.NDJSON}
+	for name := range values {
+		if !exportParameters[name] || len(values[name]) != 1 {
+			return export, apierrors.ErrInvalidExportParameter
+		}
+	}
+
+	var err error
+	if format := values.Get("format"); format != "" {
+		export.format = format
+	}
+	if reviews := values.Get("reviews"); reviews != "" {
+		if export.reviews, err = strconv.ParseBool(reviews); err != nil {
+			return export, apierrors.ErrInvalidExportParameter
+		}
+	}
+	if compress := values.Get("gzip"); compress != "" {
+		if export.gzip, err = strconv.ParseBool(compress); err != nil {
+			return export, apierrors.ErrInvalidExportParameter
+		}
+	}
+
+	if importer.MediaType(export.format) == "" || (export.reviews && export.format != importer.NDJSON) {
+		return export, apierrors.ErrInvalidExportParameter
+	}
+	return export, nil
+}
+
+// connectionKey is the key of the connection of a request in the context of the request
+type connectionKey struct{}
+
+// ConnContext adds the connection of a request to the context of the request. It is the ConnContext of the HTTP
+// server, so that a response streamed for longer than the write timeout of the server can extend its write deadline.
+func ConnContext(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connectionKey{}, conn)
+}
+
+// extendWriteDeadline gives a streamed response another write timeout from now to be written. The deadline is set
+// through the response writer or, as the log middleware of the server wraps the writer without unwrapping it, on the
+// connection of the request. Without a write timeout, there is no deadline to extend.
+func (api *API) extendWriteDeadline(writer http.ResponseWriter, request *http.Request) error {
+	if api.writeTimeout <= 0 {
+		return nil
+	}
+
+	deadline := time.Now().Add(api.writeTimeout)
+	err := http.NewResponseController(writer).SetWriteDeadline(deadline)
+	if errors.Is(err, http.ErrNotSupported) {
+		if conn, ok := request.Context().Value(connectionKey{}).(net.Conn); ok {
+			return conn.SetWriteDeadline(deadline)
+		}
+		return nil
+	}
+	return err
+}
+
+// filename returns the name of the exported file
+func (e exportRequest) filename() string {
+	if e.gzip {
+		return "books." + e.format + ".gz"
+	}
+	return "books." + e.format
+}
+
+// exportBooksHandler streams every book, as a file in one of the formats that can be imported, optionally gzipped.
+// Books exported as JSON Lines can embed their reviews: moderators get the reviews in every state, and anyone else the
+// approved reviews. The books are written as they are read, so the response is not held in memory. Each book extends
+// the write deadline of the response by the write timeout, so that an export can take longer than the write timeout.
+// If the export fails once the response has started, the response is aborted, so that it cannot be mistaken for a
+// complete file.
+func (api *API) exportBooksHandler(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+
+	export, err := parseExportRequest(request.URL.Query())
+	logData := log.Data{"format": export.format, "reviews": export.reviews, "gzip": export.gzip}
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	var reviews *query.Query
+	if export.reviews {
+		reviews = &query.Query{}
+		if identity, ok := auth.FromContext(ctx); !ok || !identity.HasRole(auth.Admin) {
+			reviews.Filters = append(reviews.Filters, models.ApprovedReviews)
+		}
+	}
+
+	// The response starts with the first book, so that an error reading the first book is still a problem response
+	var (
+		file    importer.Writer
+		zipper  *gzip.Writer
+		started bool
+	)
+	start := func() {
+		started = true
+		writer.Header().Set("Content-Type", importer.MediaType(export.format))
+		if export.gzip {
+			writer.Header().Set("Content-Type", "application/gzip")
+		}
+		writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.filename()))
+		writer.WriteHeader(http.StatusOK)
+
+		var output io.Writer = writer
+		if export.gzip {
+			zipper = gzip.NewWriter(writer)
+			output = zipper
+		}
+		// The format has been validated, so the writer cannot fail to be created
+		file, _ = importer.NewWriter(export.format, output)
+	}
+
+	exported := 0
+	err = api.dataStore.ExportBooks(ctx, reviews, func(book *models.Book, bookReviews []models.Review) error {
+		if !started {
+			start()
+		}
+		if err := api.extendWriteDeadline(writer, request); err != nil {
+			return err
+		}
+		exported++
+		return file.Write(book, bookReviews)
+	})
+	logData["exported"] = exported
+	if err != nil {
+		if !started {
+			handleError(ctx, writer, err, logData)
+			return
+		}
+		log.Event(ctx, "failed to export books. The response is aborted", log.ERROR, log.Error(err), logData)
+		panic(http.ErrAbortHandler)
+	}
+
+	if !started {
+		start()
+	}
+	err = file.Flush()
+	if err == nil && zipper != nil {
+		err = zipper.Close()
+	}
+	if err != nil {
+		log.Event(ctx, "failed to export books. The response is aborted", log.ERROR, log.Error(err), logData)
+		panic(http.ErrAbortHandler)
+	}
+	log.Event(ctx, "successfully exported books", log.INFO, logData)
+}
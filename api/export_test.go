@@ -0,0 +1,220 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"github.com/ONSdigital/log.go/log"
+	"github.com/cadmiumcat/books-api/auth"
+	"github.com/cadmiumcat/books-api/interfaces/mock"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/query"
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exportDataStore exports book1 and book2, with bookReview1 as the review of book1
+func exportDataStore() *mock.DataStoreMock {
+	return &mock.DataStoreMock{
+		ExportBooksFunc: func(ctx context.Context, reviews *query.Query, export func(*models.Book, []models.Review) error) error {
+			var bookReviews []models.Review
+			if reviews != nil {
+				bookReviews = []models.Review{bookReview1}
+			}
+			if err := export(&book1, bookReviews); err != nil {
+				return err
+			}
+			return export(&book2, nil)
+		},
+	}
+}
+
+func TestExportBooksHandler(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given a catalogue of books", t, func() {
+		mockDataStore := exportDataStore()
+		api := &API{dataStore: mockDataStore}
+
+		Convey("When it is exported without query parameters", func() {
+			request := asCaller(httptest.NewRequest(http.MethodGet, "/books/export", nil), "librarian", auth.Librarian)
+			response := httptest.NewRecorder()
+
+			api.exportBooksHandler(response, request)
+			Convey("Then every book is returned as JSON Lines, without reviews", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+				So(response.Header().Get("Content-Type"), ShouldEqual, "application/x-ndjson")
+				So(response.Header().Get("Content-Disposition"), ShouldEqual, `attachment; filename="books.ndjson"`)
+
+				lines := strings.Split(strings.TrimSpace(response.Body.String()), "\n")
+				So(lines, ShouldHaveLength, 2)
+
+				var book models.ExportedBook
+				So(json.Unmarshal([]byte(lines[0]), &book), ShouldBeNil)
+				So(book.ID, ShouldEqual, bookID1)
+				So(book.Reviews, ShouldBeEmpty)
+				So(mockDataStore.ExportBooksCalls()[0].Reviews, ShouldBeNil)
+			})
+		})
+
+		Convey("When it is exported with reviews by a librarian", func() {
+			request := asCaller(httptest.NewRequest(http.MethodGet, "/books/export?reviews=true", nil), "librarian", auth.Librarian)
+			response := httptest.NewRecorder()
+
+			api.exportBooksHandler(response, request)
+			Convey("Then the books embed their approved reviews", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+				So(mockDataStore.ExportBooksCalls()[0].Reviews.Filters, ShouldResemble, []query.Filter{models.ApprovedReviews})
+
+				var book models.ExportedBook
+				So(json.NewDecoder(response.Body).Decode(&book), ShouldBeNil)
+				So(book.Reviews, ShouldHaveLength, 1)
+				So(book.Reviews[0].ID, ShouldEqual, reviewID1)
+			})
+		})
+
+		Convey("When it is exported with reviews by an admin", func() {
+			request := asCaller(httptest.NewRequest(http.MethodGet, "/books/export?reviews=true", nil), "admin", auth.Admin)
+			response := httptest.NewRecorder()
+
+			api.exportBooksHandler(response, request)
+			Convey("Then the reviews in every state are exported", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+				So(mockDataStore.ExportBooksCalls()[0].Reviews.Filters, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When it is exported as a gzipped CSV file", func() {
+			request := asCaller(httptest.NewRequest(http.MethodGet, "/books/export?format=csv&gzip=true", nil), "librarian", auth.Librarian)
+			response := httptest.NewRecorder()
+
+			api.exportBooksHandler(response, request)
+			Convey("Then the file is the gzipped CSV of the books", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+				So(response.Header().Get("Content-Type"), ShouldEqual, "application/gzip")
+				So(response.Header().Get("Content-Disposition"), ShouldEqual, `attachment; filename="books.csv.gz"`)
+
+				reader, err := gzip.NewReader(response.Body)
+				So(err, ShouldBeNil)
+				file, err := ioutil.ReadAll(reader)
+				So(err, ShouldBeNil)
+
+				lines := strings.Split(strings.TrimSpace(string(file)), "\n")
+				So(lines, ShouldHaveLength, 3)
+				So(lines[0], ShouldStartWith, "title,author,")
+				So(lines[1], ShouldEqual, `"Girl, Woman, Other",Bernardine Evaristo,,,,,,,,`)
+			})
+		})
+
+		Convey("When reviews are requested in a CSV file", func() {
+			request := asCaller(httptest.NewRequest(http.MethodGet, "/books/export?format=csv&reviews=true", nil), "librarian", auth.Librarian)
+			response := httptest.NewRecorder()
+
+			api.exportBooksHandler(response, request)
+			Convey("Then the HTTP response code is 400, and nothing is exported", func() {
+				So(response.Code, ShouldEqual, http.StatusBadRequest)
+				So(readProblem(t, response).Code, ShouldEqual, "invalid_export_parameter")
+				So(mockDataStore.ExportBooksCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When an unknown format is requested", func() {
+			request := asCaller(httptest.NewRequest(http.MethodGet, "/books/export?format=xml", nil), "librarian", auth.Librarian)
+			response := httptest.NewRecorder()
+
+			api.exportBooksHandler(response, request)
+			Convey("Then the HTTP response code is 400", func() {
+				So(response.Code, ShouldEqual, http.StatusBadRequest)
+				So(readProblem(t, response).Code, ShouldEqual, "invalid_export_parameter")
+			})
+		})
+	})
+
+	Convey("Given a data store that fails before the first book is exported", t, func() {
+		api := &API{dataStore: &mock.DataStoreMock{
+			ExportBooksFunc: func(ctx context.Context, reviews *query.Query, export func(*models.Book, []models.Review) error) error {
+				return errMongoDB
+			},
+		}}
+
+		Convey("When the books are exported", func() {
+			request := asCaller(httptest.NewRequest(http.MethodGet, "/books/export", nil), "librarian", auth.Librarian)
+			response := httptest.NewRecorder()
+
+			api.exportBooksHandler(response, request)
+			Convey("Then the HTTP response code is 500", func() {
+				So(response.Code, ShouldEqual, http.StatusInternalServerError)
+				So(readProblem(t, response).Code, ShouldEqual, "internal_error")
+			})
+		})
+	})
+
+	Convey("Given a data store that fails once the export has started", t, func() {
+		api := &API{dataStore: &mock.DataStoreMock{
+			ExportBooksFunc: func(ctx context.Context, reviews *query.Query, export func(*models.Book, []models.Review) error) error {
+				if err := export(&book1, nil); err != nil {
+					return err
+				}
+				return errMongoDB
+			},
+		}}
+
+		Convey("When the books are exported", func() {
+			request := asCaller(httptest.NewRequest(http.MethodGet, "/books/export", nil), "librarian", auth.Librarian)
+			response := httptest.NewRecorder()
+
+			Convey("Then the response is aborted, so that it is not mistaken for a complete file", func() {
+				So(func() { api.exportBooksHandler(response, request) }, ShouldPanicWith, http.ErrAbortHandler)
+				So(bytes.Count(response.Body.Bytes(), []byte("\n")), ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestExportLongerThanWriteTimeout(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given a server with a write timeout, and a catalogue that takes longer than the timeout to export", t, func() {
+		writeTimeout := 200 * time.Millisecond
+		mockDataStore := &mock.DataStoreMock{
+			ExportBooksFunc: func(ctx context.Context, reviews *query.Query, export func(*models.Book, []models.Review) error) error {
+				for i := 0; i < 4; i++ {
+					time.Sleep(writeTimeout / 2)
+					if err := export(&book1, nil); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		}
+		api := &API{dataStore: mockDataStore, writeTimeout: writeTimeout}
+
+		// The log middleware wraps the response writer as it does in the service
+		server := httptest.NewUnstartedServer(log.Middleware(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			api.exportBooksHandler(writer, asCaller(request, "librarian", auth.Librarian))
+		})))
+		server.Config.WriteTimeout = writeTimeout
+		server.Config.ConnContext = ConnContext
+		server.Start()
+		defer server.Close()
+
+		Convey("When the books are exported", func() {
+			response, err := http.Get(server.URL + "/books/export")
+			So(err, ShouldBeNil)
+			defer response.Body.Close()
+			body, err := ioutil.ReadAll(response.Body)
+
+			Convey("Then the write deadline is extended as the books are written, and every book is returned", func() {
+				So(err, ShouldBeNil)
+				So(response.StatusCode, ShouldEqual, http.StatusOK)
+				So(strings.Split(strings.TrimSpace(string(body)), "\n"), ShouldHaveLength, 4)
+			})
+		})
+	})
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/ONSdigital/log.go/log"
+	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/auth"
+	"github.com/cadmiumcat/books-api/models"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	idempotencyReservationTTL = time.Minute
+	idempotencyWriteTimeout   = 10 * time.Second
+)
+
+// replayedHeaders are the headers of a response that are recorded with it, and sent again when it is replayed
+var replayedHeaders = []string{"Content-Type", "ETag", "Location", "Retry-After", "X-Content-Type-Options"}
+
+// idempotent is a middleware for the handlers that create resources. The response to a request with an
+// Idempotency-Key header is recorded, and replayed to the retries of the request until the key expires, instead of
+// creating the resource again. A key is scoped to the caller, and can only be used for one request: a request with
+// the key of a different request fails with 422, and a retry of a request that is still in progress fails with 409.
+// Server errors are not recorded, so that the request can be retried.
+func (api *API) idempotent(handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		key := request.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			handler(writer, request)
+			return
+		}
+
+		ctx := request.Context()
+		logData := log.Data{"idempotency_key": key}
+		if len(key) > maxIdempotencyKeyLength {
+			handleError(ctx, writer, apierrors.ErrInvalidIdempotencyKey, logData)
+			return
+		}
+
+		body, err := ioutil.ReadAll(request.Body)
+		request.Body.Close()
+		if err != nil {
+			handleError(ctx, writer, apierrors.ErrUnableToReadMessage, logData)
+			return
+		}
+		request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		// A request in progress keeps the key until it completes, or until the reservation expires if it never does
+		record := &models.IdempotencyRecord{
+			ID:          idempotencyRecordID(request, key),
+			RequestHash: requestHash(request, body),
+			ExpiresAt:   time.Now().UTC().Add(idempotencyReservationTTL),
+		}
+		logData["idempotency_record_id"] = record.ID
+
+		existing, err := api.idempotency.ReserveIdempotencyKey(ctx, record)
+		if err != nil {
+			handleError(ctx, writer, err, logData)
+			return
+		}
+		if existing != nil {
+			switch {
+			case existing.RequestHash != record.RequestHash:
+				handleError(ctx, writer, apierrors.ErrIdempotencyKeyReused, logData)
+			case !existing.Completed():
+				handleError(ctx, writer, apierrors.ErrIdempotencyKeyInUse, logData)
+			default:
+				replay(writer, existing)
+				log.Event(ctx, "replayed the response to an idempotent request", log.INFO, logData)
+			}
+			return
+		}
+
+		// A client that times out drops its connection, which cancels the request, and then retries it. The request is
+		// completed and its response recorded regardless, so that the retry is replayed instead of being rejected
+		// while the key is reserved, or adding the resource again once the reservation expires.
+		detached := context.WithoutCancel(ctx)
+		recorder := &responseRecorder{ResponseWriter: writer, status: http.StatusOK}
+		handler(recorder, request.WithContext(detached))
+
+		ctx, cancel := context.WithTimeout(detached, idempotencyWriteTimeout)
+		defer cancel()
+
+		if recorder.status >= http.StatusInternalServerError {
+			if err := api.idempotency.ReleaseIdempotencyKey(ctx, record.ID); err != nil {
+				log.Event(ctx, "failed to release an idempotency key", log.ERROR, log.Error(err), logData)
+			}
+			return
+		}
+
+		record.Status = recorder.status
+		record.Header = http.Header{}
+		for _, name := range replayedHeaders {
+			if values := recorder.Header().Values(name); len(values) > 0 {
+				record.Header[name] = values
+			}
+		}
+		record.Body = recorder.body.Bytes()
+		record.ExpiresAt = time.Now().UTC().Add(api.idempotencyKeyTTL)
+		if err := api.idempotency.CompleteIdempotencyKey(ctx, record); err != nil {
+			log.Event(ctx, "failed to record the response to an idempotent request", log.ERROR, log.Error(err), logData)
+		}
+	}
+}
+
+// idempotencyRecordID returns the ID of the record of an idempotency key, which is only shared by the requests of
+// the same caller
+func idempotencyRecordID(request *http.Request, key string) string {
+	subject := ""
+	if identity, ok := auth.FromContext(request.Context()); ok {
+		subject = identity.Subject
+	}
+	return hash([]byte(subject), []byte(key))
+}
+
+// requestHash identifies a request by its method, path and body, to tell a retry from a different request
+func requestHash(request *http.Request, body []byte) string {
+	return hash([]byte(request.Method), []byte(request.URL.Path), body)
+}
+
+// hash returns the hex encoded SHA-256 of the parts, which are separated so that they cannot run into each other
+func hash(parts ...[]byte) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write(part)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replay writes the recorded response to an idempotent request
+func replay(writer http.ResponseWriter, record *models.IdempotencyRecord) {
+	for name, values := range record.Header {
+		writer.Header().Del(name)
+		for _, value := range values {
+			writer.Header().Add(name, value)
+		}
+	}
+	writer.Header().Set(idempotentReplayedHeader, "true")
+	writer.WriteHeader(record.Status)
+	writer.Write(record.Body)
+}
+
+// responseRecorder records the status and body of a response as it is written
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/auth"
+	"github.com/cadmiumcat/books-api/interfaces/mock"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/mongo"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockIdempotencyStore returns an IdempotencyStore that keeps the records in a map, and that fails to write them with
+// a context that is done, as the data stores do
+func mockIdempotencyStore() *mock.IdempotencyStoreMock {
+	records := map[string]models.IdempotencyRecord{}
+	return &mock.IdempotencyStoreMock{
+		ReserveIdempotencyKeyFunc: func(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+			if existing, ok := records[record.ID]; ok {
+				return &existing, nil
+			}
+			records[record.ID] = *record
+			return nil, nil
+		},
+		CompleteIdempotencyKeyFunc: func(ctx context.Context, record *models.IdempotencyRecord) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			records[record.ID] = *record
+			return nil
+		},
+		ReleaseIdempotencyKeyFunc: func(ctx context.Context, id string) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			delete(records, id)
+			return nil
+		},
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given the handler that adds a book, called with an Idempotency-Key", t, func() {
+		mockDataStore := &mock.DataStoreMock{
+			AddBookFunc: func(ctx context.Context, book *models.Book) error {
+				return nil
+			},
+		}
+		idempotency := mockIdempotencyStore()
+		api := &API{dataStore: mockDataStore, idempotency: idempotency, idempotencyKeyTTL: time.Hour}
+		handler := api.idempotent(api.addBookHandler)
+
+		addBook := func(key, subject, body string) *httptest.ResponseRecorder {
+			request := httptest.NewRequest(http.MethodPost, "/books", strings.NewReader(body))
+			request.Header.Set(idempotencyKeyHeader, key)
+			response := httptest.NewRecorder()
+			handler(response, asCaller(request, subject, auth.Librarian))
+			return response
+		}
+		body := `{"title":"Girl, Woman, Other", "author":"Bernardine Evaristo"}`
+
+		first := addBook("key-1", "librarian", body)
+		So(first.Code, ShouldEqual, http.StatusCreated)
+
+		Convey("When the request is retried", func() {
+			retry := addBook("key-1", "librarian", body)
+
+			Convey("Then the recorded response is replayed, and the book is only added once", func() {
+				So(retry.Code, ShouldEqual, http.StatusCreated)
+				So(retry.Body.String(), ShouldEqual, first.Body.String())
+				So(retry.Header().Get("ETag"), ShouldEqual, first.Header().Get("ETag"))
+				So(retry.Header().Get("Content-Type"), ShouldEqual, first.Header().Get("Content-Type"))
+				So(retry.Header().Get(idempotentReplayedHeader), ShouldEqual, "true")
+				So(mockDataStore.AddBookCalls(), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When the key is used for a different request", func() {
+			response := addBook("key-1", "librarian", `{"title":"Kindred", "author":"Octavia E. Butler"}`)
+
+			Convey("Then the HTTP response code is 422, and no book is added", func() {
+				So(response.Code, ShouldEqual, http.StatusUnprocessableEntity)
+				So(readProblem(t, response).Code, ShouldEqual, "idempotency_key_reused")
+				So(mockDataStore.AddBookCalls(), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When another caller uses the same key", func() {
+			response := addBook("key-1", "another librarian", body)
+
+			Convey("Then the key is not shared, and the book is added", func() {
+				So(response.Code, ShouldEqual, http.StatusCreated)
+				So(response.Header().Get(idempotentReplayedHeader), ShouldBeEmpty)
+				So(mockDataStore.AddBookCalls(), ShouldHaveLength, 2)
+			})
+		})
+
+		Convey("When the client goes away while a request is handled, and retries it", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			mockDataStore.AddBookFunc = func(ctx context.Context, book *models.Book) error {
+				cancel()
+				return ctx.Err()
+			}
+			request := httptest.NewRequest(http.MethodPost, "/books", strings.NewReader(body)).WithContext(ctx)
+			request.Header.Set(idempotencyKeyHeader, "key-2")
+			handler(httptest.NewRecorder(), asCaller(request, "librarian", auth.Librarian))
+
+			retry := addBook("key-2", "librarian", body)
+
+			Convey("Then the book is added, and the retry is replayed instead of adding it again", func() {
+				So(ctx.Err(), ShouldNotBeNil)
+				So(retry.Code, ShouldEqual, http.StatusCreated)
+				So(retry.Header().Get(idempotentReplayedHeader), ShouldEqual, "true")
+				So(mockDataStore.AddBookCalls(), ShouldHaveLength, 2)
+			})
+		})
+
+		Convey("When the key is too long", func() {
+			response := addBook(strings.Repeat("k", maxIdempotencyKeyLength+1), "librarian", body)
+
+			Convey("Then the HTTP response code is 400", func() {
+				So(response.Code, ShouldEqual, http.StatusBadRequest)
+				So(readProblem(t, response).Code, ShouldEqual, "invalid_idempotency_key")
+			})
+		})
+	})
+
+	Convey("Given a request with an Idempotency-Key that is in progress", t, func() {
+		idempotency := &mock.IdempotencyStoreMock{
+			ReserveIdempotencyKeyFunc: func(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+				return &models.IdempotencyRecord{ID: record.ID, RequestHash: record.RequestHash}, nil
+			},
+		}
+		mockDataStore := &mock.DataStoreMock{}
+		api := &API{dataStore: mockDataStore, idempotency: idempotency}
+
+		Convey("When it is retried", func() {
+			request := httptest.NewRequest(http.MethodPost, "/books", strings.NewReader(`{"title":"Kindred"}`))
+			request.Header.Set(idempotencyKeyHeader, "key-1")
+			response := httptest.NewRecorder()
+			api.idempotent(api.addBookHandler)(response, request)
+
+			Convey("Then the HTTP response code is 409, and the book is not added", func() {
+				So(response.Code, ShouldEqual, http.StatusConflict)
+				So(readProblem(t, response).Code, ShouldEqual, "idempotency_key_in_use")
+				So(mockDataStore.AddBookCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+
+	Convey("Given a request with an Idempotency-Key that fails with a server error", t, func() {
+		mockDataStore := &mock.DataStoreMock{
+			AddBookFunc: func(ctx context.Context, book *models.Book) error {
+				return &mongo.WriteError{Kind: mongo.ErrUnavailable, Err: context.DeadlineExceeded}
+			},
+		}
+		idempotency := mockIdempotencyStore()
+		api := &API{dataStore: mockDataStore, idempotency: idempotency, idempotencyKeyTTL: time.Hour}
+
+		request := httptest.NewRequest(http.MethodPost, "/books", strings.NewReader(`{"title":"Kindred", "author":"Octavia E. Butler"}`))
+		request.Header.Set(idempotencyKeyHeader, "key-1")
+		response := httptest.NewRecorder()
+		api.idempotent(api.addBookHandler)(response, request)
+
+		Convey("Then the response is not recorded, and the key is released so that the request can be retried", func() {
+			So(response.Code, ShouldEqual, http.StatusServiceUnavailable)
+			So(idempotency.CompleteIdempotencyKeyCalls(), ShouldHaveLength, 0)
+			So(idempotency.ReleaseIdempotencyKeyCalls(), ShouldHaveLength, 1)
+		})
+	})
+
+	Convey("Given a request without an Idempotency-Key", t, func() {
+		idempotency := &mock.IdempotencyStoreMock{}
+		api := &API{idempotency: idempotency}
+
+		request := httptest.NewRequest(http.MethodPost, "/books", strings.NewReader(``))
+		response := httptest.NewRecorder()
+		api.idempotent(api.addBookHandler)(response, request)
+
+		Convey("Then it is handled without reserving a key", func() {
+			So(response.Code, ShouldEqual, http.StatusBadRequest)
+			So(idempotency.ReserveIdempotencyKeyCalls(), ShouldHaveLength, 0)
+		})
+	})
+}
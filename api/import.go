@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"github.com/ONSdigital/log.go/log"
+	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/importer"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+)
+
+// importBooksHandler imports the books of a CSV or JSON Lines file, streamed from the request body.
+// Imports up to the maximum synchronous size are run while the request waits, and respond with the report of every row.
+// Larger imports, and imports of unknown size, are spooled to a temporary file and run as a background job:
+// the response is the running job, whose status can be followed at the Location of the response.
+// Files larger than the maximum import size are rejected, whether their size is known before they are read or not.
+func (api *API) importBooksHandler(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+
+	format, err := importer.FormatOf(request.Header.Get("Content-Type"))
+	logData := log.Data{"format": format, "content_length": request.ContentLength}
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	if request.ContentLength == 0 {
+		handleError(ctx, writer, apierrors.ErrEmptyRequestBody, logData)
+		return
+	}
+
+	if request.ContentLength > api.importMaxSize {
+		handleError(ctx, writer, apierrors.ErrImportTooLarge, logData)
+		return
+	}
+	request.Body = http.MaxBytesReader(writer, request.Body, api.importMaxSize)
+
+	if request.ContentLength > 0 && request.ContentLength <= api.importMaxSyncSize {
+		rows, err := importer.NewReader(format, request.Body)
+		if err != nil {
+			handleError(ctx, writer, err, logData)
+			return
+		}
+
+		report, err := api.importer.Run(ctx, rows, nil)
+		if err != nil {
+			handleError(ctx, writer, err, logData)
+			return
+		}
+
+		if err := WriteJSONBody(report, writer, http.StatusOK); err != nil {
+			handleError(ctx, writer, err, logData)
+			return
+		}
+		log.Event(ctx, "successfully imported books", log.INFO, logData)
+		return
+	}
+
+	file, err := spool(request.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			err = apierrors.ErrImportTooLarge
+		}
+		handleError(ctx, writer, err, logData)
+		return
+	}
+	removeFile := func() {
+		file.Close()
+		if err := os.Remove(file.Name()); err != nil {
+			log.Event(ctx, "failed to remove the temporary file of an import", log.WARN, log.Error(err), logData)
+		}
+	}
+
+	rows, err := importer.NewReader(format, file)
+	if err != nil {
+		removeFile()
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	job := api.importJobs.Start(format, rows, removeFile)
+	logData["import_job_id"] = job.ID
+
+	job.SetLinks(api.baseURL)
+	writer.Header().Set("Location", job.Links.Self)
+	if err := WriteJSONBody(job, writer, http.StatusAccepted); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+	log.Event(ctx, "successfully started import job", log.INFO, logData)
+}
+
+func (api *API) getImportJobHandler(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+
+	id := mux.Vars(request)["jobID"]
+	logData := log.Data{"import_job_id": id}
+
+	job, err := api.importJobs.Get(id)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	job.SetLinks(api.baseURL)
+	if err := WriteJSONBody(job, writer, http.StatusOK); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+}
+
+// validateImportedBook checks and normalises an imported book, as it is when the book is added on its own
+func (api *API) validateImportedBook(ctx context.Context, book *models.Book) error {
+	if err := book.Validate(); err != nil {
+		return err
+	}
+	return api.resolveAuthors(ctx, book)
+}
+
+// spool copies the body of a request to a temporary file, so that it can be read after the response has been written.
+// The file is rewound, ready to be read.
+func spool(body io.Reader) (*os.File, error) {
+	file, err := ioutil.TempFile("", "books-import-")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.Copy(file, body)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return file, nil
+}
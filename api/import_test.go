@@ -0,0 +1,195 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/cadmiumcat/books-api/config"
+	"github.com/cadmiumcat/books-api/interfaces/mock"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/mongo"
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const importCSV = "title,author,isbn\n" +
+	"Kindred,Octavia E. Butler,\n" +
+	"Kindred,Octavia E. Butler,0-306-40615-2\n" +
+	",Nobody,\n"
+
+// importAPI returns an API that imports books into the data store, running imports larger than maxSyncSize as jobs
+func importAPI(dataStore *mock.DataStoreMock, maxSyncSize int64) *API {
+	cfg := &config.Configuration{ImportConfig: config.ImportConfig{BatchSize: 10, MaxSyncSize: maxSyncSize, MaxSize: 1 << 20, JobRetention: time.Hour}}
+	return Setup(context.Background(), cfg, mux.NewRouter(), &mock.PaginatorMock{}, dataStore, &mock.SearcherMock{}, &mock.IdempotencyStoreMock{}, &mock.AuthenticatorMock{}, &mock.HealthCheckerMock{})
+}
+
+func TestImportBooksHandler(t *testing.T) {
+	t.Parallel()
+
+	dataStore := func() *mock.DataStoreMock {
+		return &mock.DataStoreMock{
+			AddBooksFunc: func(ctx context.Context, books []*models.Book) ([]error, error) {
+				results := make([]error, len(books))
+				for i, book := range books {
+					if book.ISBN != "" {
+						results[i] = mongo.ErrDuplicateISBN
+					}
+				}
+				return results, nil
+			},
+		}
+	}
+
+	Convey("Given a small CSV file", t, func() {
+		mockDataStore := dataStore()
+		api := importAPI(mockDataStore, 1<<20)
+
+		Convey("When it is imported", func() {
+			request := httptest.NewRequest(http.MethodPost, "/books/import", strings.NewReader(importCSV))
+			request.Header.Set("Content-Type", "text/csv")
+			response := httptest.NewRecorder()
+
+			api.importBooksHandler(response, request)
+			Convey("Then the HTTP response code is 200, with the report of every row", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+
+				var report models.ImportReport
+				So(json.Unmarshal(response.Body.Bytes(), &report), ShouldBeNil)
+				So(report.Created, ShouldEqual, 1)
+				So(report.Skipped, ShouldEqual, 1)
+				So(report.Failed, ShouldEqual, 1)
+				So(report.Rows[1].Code, ShouldEqual, "duplicate_isbn")
+				So(report.Rows[2].Code, ShouldEqual, "required_field_missing")
+			})
+			Convey("And the valid books are validated and normalised before they are added", func() {
+				So(mockDataStore.AddBooksCalls(), ShouldHaveLength, 1)
+				So(mockDataStore.AddBooksCalls()[0].Books[1].ISBN, ShouldEqual, "9780306406157")
+			})
+		})
+	})
+
+	Convey("Given a CSV file larger than the maximum synchronous size", t, func() {
+		mockDataStore := dataStore()
+		api := importAPI(mockDataStore, 10)
+
+		Convey("When it is imported", func() {
+			request := httptest.NewRequest(http.MethodPost, "/books/import", strings.NewReader(importCSV))
+			request.Header.Set("Content-Type", "text/csv")
+			response := httptest.NewRecorder()
+
+			api.importBooksHandler(response, request)
+			Convey("Then the HTTP response code is 202, with the location of the running job", func() {
+				So(response.Code, ShouldEqual, http.StatusAccepted)
+
+				var job models.ImportJob
+				So(json.Unmarshal(response.Body.Bytes(), &job), ShouldBeNil)
+				So(job.State, ShouldEqual, models.ImportRunning)
+				So(response.Header().Get("Location"), ShouldEqual, "/books/import/"+job.ID)
+
+				Convey("And the job completes in the background", func() {
+					api.importJobs.Stop(context.Background())
+
+					request := httptest.NewRequest(http.MethodGet, "/books/import/"+job.ID, nil)
+					request = mux.SetURLVars(request, map[string]string{"jobID": job.ID})
+					response := httptest.NewRecorder()
+
+					api.getImportJobHandler(response, request)
+					So(response.Code, ShouldEqual, http.StatusOK)
+					So(json.Unmarshal(response.Body.Bytes(), &job), ShouldBeNil)
+					So(job.State, ShouldEqual, models.ImportCompleted)
+					So(job.Report.Created, ShouldEqual, 1)
+					So(job.Report.Rows, ShouldHaveLength, 3)
+				})
+			})
+		})
+	})
+
+	Convey("Given a CSV file larger than the maximum import size", t, func() {
+		mockDataStore := dataStore()
+		api := importAPI(mockDataStore, 10)
+		api.importMaxSize = int64(len(importCSV)) - 1
+
+		Convey("When it is imported with its size", func() {
+			request := httptest.NewRequest(http.MethodPost, "/books/import", strings.NewReader(importCSV))
+			request.Header.Set("Content-Type", "text/csv")
+			response := httptest.NewRecorder()
+
+			api.importBooksHandler(response, request)
+			Convey("Then the HTTP response code is 413, and no books are added", func() {
+				So(response.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+				So(readProblem(t, response).Code, ShouldEqual, "import_too_large")
+				So(mockDataStore.AddBooksCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When it is imported without its size", func() {
+			request := httptest.NewRequest(http.MethodPost, "/books/import", strings.NewReader(importCSV))
+			request.ContentLength = -1
+			request.Header.Set("Content-Type", "text/csv")
+			response := httptest.NewRecorder()
+
+			api.importBooksHandler(response, request)
+			Convey("Then the file is only read up to the maximum size, and the HTTP response code is 413", func() {
+				So(response.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+				So(readProblem(t, response).Code, ShouldEqual, "import_too_large")
+				api.importJobs.Stop(context.Background())
+				So(mockDataStore.AddBooksCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+
+	Convey("Given a file that is neither CSV nor JSON Lines", t, func() {
+		mockDataStore := dataStore()
+		api := importAPI(mockDataStore, 1<<20)
+
+		Convey("When it is imported", func() {
+			request := httptest.NewRequest(http.MethodPost, "/books/import", strings.NewReader(`[{"title":"Kindred"}]`))
+			request.Header.Set("Content-Type", "application/json")
+			response := httptest.NewRecorder()
+
+			api.importBooksHandler(response, request)
+			Convey("Then the HTTP response code is 415", func() {
+				So(response.Code, ShouldEqual, http.StatusUnsupportedMediaType)
+				So(readProblem(t, response).Code, ShouldEqual, "unsupported_import_format")
+			})
+		})
+	})
+
+	Convey("Given a CSV file with an unknown column", t, func() {
+		mockDataStore := dataStore()
+		api := importAPI(mockDataStore, 1<<20)
+
+		Convey("When it is imported", func() {
+			request := httptest.NewRequest(http.MethodPost, "/books/import", strings.NewReader("title,shelf\nKindred,A3\n"))
+			request.Header.Set("Content-Type", "text/csv")
+			response := httptest.NewRecorder()
+
+			api.importBooksHandler(response, request)
+			Convey("Then the HTTP response code is 400, and no books are added", func() {
+				So(response.Code, ShouldEqual, http.StatusBadRequest)
+				So(readProblem(t, response).Code, ShouldEqual, "invalid_import_header")
+				So(mockDataStore.AddBooksCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+
+	Convey("Given an import job that does not exist", t, func() {
+		api := importAPI(dataStore(), 1<<20)
+
+		Convey("When its status is requested", func() {
+			request := httptest.NewRequest(http.MethodGet, "/books/import/unknown", nil)
+			request = mux.SetURLVars(request, map[string]string{"jobID": "unknown"})
+			response := httptest.NewRecorder()
+
+			api.getImportJobHandler(response, request)
+			Convey("Then the HTTP response code is 404", func() {
+				So(response.Code, ShouldEqual, http.StatusNotFound)
+				So(readProblem(t, response).Code, ShouldEqual, "import_job_not_found")
+			})
+		})
+	})
+}
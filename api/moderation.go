@@ -0,0 +1,65 @@
+package api
+
+import (
+	"github.com/ONSdigital/log.go/log"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/gorilla/mux"
+	"net/http"
+	"time"
+)
+
+func (api *API) getModeratedReviewsHandler(writer http.ResponseWriter, request *http.Request) {
+	api.listReviews(writer, request, true)
+}
+
+func (api *API) getModeratedReviewHandler(writer http.ResponseWriter, request *http.Request) {
+	api.getReview(writer, request, true)
+}
+
+func (api *API) approveReviewHandler(writer http.ResponseWriter, request *http.Request) {
+	api.changeReviewState(writer, request, (*models.Review).Approve)
+}
+
+func (api *API) rejectReviewHandler(writer http.ResponseWriter, request *http.Request) {
+	api.changeReviewState(writer, request, (*models.Review).Reject)
+}
+
+// changeReviewState applies a moderation transition to the review in the request, and stores the result
+func (api *API) changeReviewState(writer http.ResponseWriter, request *http.Request, transition func(*models.Review, time.Time) error) {
+	ctx := request.Context()
+
+	bookID := mux.Vars(request)["id"]
+	reviewID := mux.Vars(request)["reviewID"]
+
+	logData := log.Data{"book_id": bookID, "review_id": reviewID}
+
+	review, err := api.getBookReview(ctx, bookID, reviewID)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	previousState := review.State
+	logData["previous_state"] = previousState
+
+	if err := transition(review, time.Now().UTC()); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	logData["state"] = review.State
+
+	if err := api.dataStore.UpdateReviewState(ctx, review, previousState); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	review.Revision++
+	review.SetLinks(api.baseURL)
+	writer.Header().Set("ETag", etag(review.Revision))
+	if err := WriteJSONBody(review, writer, http.StatusOK); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+	log.Event(ctx, "successfully moderated review", log.INFO, logData)
+}
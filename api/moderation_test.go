@@ -0,0 +1,216 @@
+package api
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/interfaces/mock"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/mongo"
+	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/cadmiumcat/books-api/query"
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func reviewRequest(method, url string, bookID, reviewID string) *http.Request {
+	request := httptest.NewRequest(method, url, nil)
+	return mux.SetURLVars(request, map[string]string{
+		"id":       bookID,
+		"reviewID": reviewID,
+	})
+}
+
+func moderatedMockDataStore(state string) *mock.DataStoreMock {
+	return &mock.DataStoreMock{
+		GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+			return &book1, nil
+		},
+		GetReviewFunc: func(ctx context.Context, reviewID string) (*models.Review, error) {
+			return &models.Review{ID: reviewID1, BookID: bookID1, State: state, Rating: 3, Owner: reviewOwner}, nil
+		},
+		UpdateReviewStateFunc: func(ctx context.Context, review *models.Review, previousState string) error {
+			return nil
+		},
+	}
+}
+
+func TestGetModeratedReviewsHandler(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given a book with reviews in every moderation state", t, func() {
+		mockDataStore := &mock.DataStoreMock{
+			GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+				return &book1, nil
+			},
+			GetReviewsFunc: func(ctx context.Context, bookID string, q *query.Query, cursor *pagination.Cursor, offset, limit int) ([]models.Review, int, error) {
+				return []models.Review{{ID: reviewID1, State: models.ReviewPending}}, 1, nil
+			},
+		}
+		api := &API{dataStore: mockDataStore, paginator: mockPaginator()}
+
+		Convey("When a moderator lists the pending reviews of the book", func() {
+			request := reviewRequest(http.MethodGet, "/admin/books/"+bookID1+"/reviews?state=pending", bookID1, "")
+			response := httptest.NewRecorder()
+
+			api.getModeratedReviewsHandler(response, request)
+			Convey("Then the HTTP response code is 200", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+			})
+			Convey("And the GetReviews function is called with the state filter only", func() {
+				So(mockDataStore.GetReviewsCalls(), ShouldHaveLength, 1)
+				So(mockDataStore.GetReviewsCalls()[0].Q.Filters, ShouldResemble, []query.Filter{
+					{Key: "state", Operator: query.Equals, Value: models.ReviewPending},
+				})
+			})
+		})
+
+		Convey("When a moderator lists every review of the book", func() {
+			request := reviewRequest(http.MethodGet, "/admin/books/"+bookID1+"/reviews", bookID1, "")
+			response := httptest.NewRecorder()
+
+			api.getModeratedReviewsHandler(response, request)
+			Convey("Then the reviews are not restricted to the approved ones", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+				So(mockDataStore.GetReviewsCalls()[0].Q.Filters, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When a reader tries to filter the reviews of the book by state", func() {
+			request := reviewRequest(http.MethodGet, "/books/"+bookID1+"/reviews?state=pending", bookID1, "")
+			response := httptest.NewRecorder()
+
+			api.getReviewsHandler(response, request)
+			Convey("Then the HTTP response code is 400", func() {
+				So(response.Code, ShouldEqual, http.StatusBadRequest)
+				So(mockDataStore.GetReviewsCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+}
+
+func TestGetModeratedReviewHandler(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given a review pending moderation", t, func() {
+		api := &API{dataStore: moderatedMockDataStore(models.ReviewPending)}
+
+		Convey("When a moderator gets the review", func() {
+			request := reviewRequest(http.MethodGet, "/admin/books/"+bookID1+"/reviews/"+reviewID1, bookID1, reviewID1)
+			response := httptest.NewRecorder()
+
+			api.getModeratedReviewHandler(response, request)
+			Convey("Then the HTTP response code is 200", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("When a reader gets the review", func() {
+			request := reviewRequest(http.MethodGet, "/books/"+bookID1+"/reviews/"+reviewID1, bookID1, reviewID1)
+			response := httptest.NewRecorder()
+
+			api.getReviewHandler(response, request)
+			Convey("Then the review is hidden, with a 404 response", func() {
+				So(response.Code, ShouldEqual, http.StatusNotFound)
+				So(readProblem(t, response).Detail, ShouldEqual, "review not found")
+			})
+		})
+	})
+}
+
+func TestApproveReviewHandler(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given an HTTP POST request to the /admin/books/{id}/reviews/{reviewID}/approve endpoint", t, func() {
+
+		Convey("When the review is pending", func() {
+			mockDataStore := moderatedMockDataStore(models.ReviewPending)
+			api := &API{dataStore: mockDataStore}
+
+			request := reviewRequest(http.MethodPost, "/admin/books/"+bookID1+"/reviews/"+reviewID1+"/approve", bookID1, reviewID1)
+			response := httptest.NewRecorder()
+
+			api.approveReviewHandler(response, request)
+			Convey("Then the HTTP response code is 200", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+			})
+			Convey("And the review is stored as approved, if it was still pending", func() {
+				So(mockDataStore.UpdateReviewStateCalls(), ShouldHaveLength, 1)
+				So(mockDataStore.UpdateReviewStateCalls()[0].PreviousState, ShouldEqual, models.ReviewPending)
+				So(mockDataStore.UpdateReviewStateCalls()[0].Review.State, ShouldEqual, models.ReviewApproved)
+			})
+		})
+
+		Convey("When the review has already been approved", func() {
+			mockDataStore := moderatedMockDataStore(models.ReviewApproved)
+			api := &API{dataStore: mockDataStore}
+
+			request := reviewRequest(http.MethodPost, "/admin/books/"+bookID1+"/reviews/"+reviewID1+"/approve", bookID1, reviewID1)
+			response := httptest.NewRecorder()
+
+			api.approveReviewHandler(response, request)
+			Convey("Then the HTTP response code is 409", func() {
+				So(response.Code, ShouldEqual, http.StatusConflict)
+				So(response.Body.String(), ShouldContainSubstring, apierrors.ErrInvalidReviewState.Error())
+			})
+			Convey("And the review is not updated", func() {
+				So(mockDataStore.UpdateReviewStateCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the review belongs to another book", func() {
+			mockDataStore := moderatedMockDataStore(models.ReviewPending)
+			api := &API{dataStore: mockDataStore}
+
+			request := reviewRequest(http.MethodPost, "/admin/books/"+bookID2+"/reviews/"+reviewID1+"/approve", bookID2, reviewID1)
+			response := httptest.NewRecorder()
+
+			api.approveReviewHandler(response, request)
+			Convey("Then the HTTP response code is 404", func() {
+				So(response.Code, ShouldEqual, http.StatusNotFound)
+				So(mockDataStore.UpdateReviewStateCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+}
+
+func TestRejectReviewHandler(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given an HTTP POST request to the /admin/books/{id}/reviews/{reviewID}/reject endpoint", t, func() {
+
+		Convey("When the review has been approved", func() {
+			mockDataStore := moderatedMockDataStore(models.ReviewApproved)
+			api := &API{dataStore: mockDataStore}
+
+			request := reviewRequest(http.MethodPost, "/admin/books/"+bookID1+"/reviews/"+reviewID1+"/reject", bookID1, reviewID1)
+			response := httptest.NewRecorder()
+
+			api.rejectReviewHandler(response, request)
+			Convey("Then the HTTP response code is 200", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+				So(mockDataStore.UpdateReviewStateCalls()[0].PreviousState, ShouldEqual, models.ReviewApproved)
+				So(mockDataStore.UpdateReviewStateCalls()[0].Review.State, ShouldEqual, models.ReviewRejected)
+			})
+		})
+
+		Convey("When the review is moderated by another request", func() {
+			mockDataStore := moderatedMockDataStore(models.ReviewPending)
+			mockDataStore.UpdateReviewStateFunc = func(ctx context.Context, review *models.Review, previousState string) error {
+				return mongo.ErrReviewConflict
+			}
+			api := &API{dataStore: mockDataStore}
+
+			request := reviewRequest(http.MethodPost, "/admin/books/"+bookID1+"/reviews/"+reviewID1+"/reject", bookID1, reviewID1)
+			response := httptest.NewRecorder()
+
+			api.rejectReviewHandler(response, request)
+			Convey("Then the HTTP response code is 409", func() {
+				So(response.Code, ShouldEqual, http.StatusConflict)
+				So(response.Body.String(), ShouldContainSubstring, mongo.ErrReviewConflict.Error())
+			})
+		})
+	})
+}
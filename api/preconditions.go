@@ -0,0 +1,75 @@
+package api
+
+import (
+	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/mongo"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// etag returns the strong entity tag of a revision of a book or review
+func etag(revision int) string {
+	return strconv.Quote(strconv.Itoa(revision))
+}
+
+// checkIfMatch checks the If-Match precondition of a request that changes a book or review at the given revision.
+// It returns ErrPreconditionFailed if the header does not match the revision, and ErrPreconditionRequired if there is
+// no header while it is required.
+func (api *API) checkIfMatch(request *http.Request, revision int) error {
+	ifMatch := request.Header.Values("If-Match")
+	if len(ifMatch) == 0 {
+		if api.requireIfMatch {
+			return apierrors.ErrPreconditionRequired
+		}
+		return nil
+	}
+
+	if !matchesETag(ifMatch, etag(revision), false) {
+		return apierrors.ErrPreconditionFailed
+	}
+	return nil
+}
+
+// notModified returns true if the If-None-Match header of a GET request matches the revision of a book or review,
+// in which case the client already has it
+func notModified(request *http.Request, revision int) bool {
+	ifNoneMatch := request.Header.Values("If-None-Match")
+	return len(ifNoneMatch) > 0 && matchesETag(ifNoneMatch, etag(revision), true)
+}
+
+// matchesETag returns true if the values of an If-Match or If-None-Match header are "*", or list the entity tag.
+// Weak entity tags, e.g. W/"1", only match with the weak comparison of If-None-Match.
+func matchesETag(values []string, tag string, weak bool) bool {
+	for _, value := range values {
+		for _, candidate := range strings.Split(value, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" {
+				return true
+			}
+			if strings.HasPrefix(candidate, "W/") {
+				if !weak {
+					continue
+				}
+				candidate = strings.TrimPrefix(candidate, "W/")
+			}
+			if candidate == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// preconditionError returns the error of a write to the data store. A conflict with another request, i.e. a change
+// since the revision that was read, means that the If-Match precondition of the request no longer holds.
+func preconditionError(request *http.Request, err error) error {
+	if request.Header.Get("If-Match") == "" {
+		return err
+	}
+	if errors.Is(err, mongo.ErrBookConflict) || errors.Is(err, mongo.ErrReviewConflict) {
+		return errors.Wrap(apierrors.ErrPreconditionFailed, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/mongo"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckIfMatch(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given a book or review at revision 3", t, func() {
+		revision := 3
+
+		Convey("When the If-Match header is the ETag of the revision, in a list or as a wildcard", func() {
+			api := &API{requireIfMatch: true}
+
+			Convey("Then the precondition holds", func() {
+				for _, ifMatch := range []string{`"3"`, `"2", "3"`, `*`} {
+					request := httptest.NewRequest(http.MethodPut, "/books/"+bookID1, nil)
+					request.Header.Set("If-Match", ifMatch)
+					So(api.checkIfMatch(request, revision), ShouldBeNil)
+				}
+			})
+		})
+
+		Convey("When the If-Match header is another ETag, or a weak ETag of the revision", func() {
+			api := &API{requireIfMatch: true}
+
+			Convey("Then the precondition fails", func() {
+				for _, ifMatch := range []string{`"2"`, `W/"3"`, `3`} {
+					request := httptest.NewRequest(http.MethodPut, "/books/"+bookID1, nil)
+					request.Header.Set("If-Match", ifMatch)
+					So(api.checkIfMatch(request, revision), ShouldEqual, apierrors.ErrPreconditionFailed)
+				}
+			})
+		})
+
+		Convey("When there is no If-Match header", func() {
+			request := httptest.NewRequest(http.MethodPut, "/books/"+bookID1, nil)
+
+			Convey("Then the precondition is required if the API requires it, and holds otherwise", func() {
+				So((&API{requireIfMatch: true}).checkIfMatch(request, revision), ShouldEqual, apierrors.ErrPreconditionRequired)
+				So((&API{}).checkIfMatch(request, revision), ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestNotModified(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given a book or review at revision 3", t, func() {
+		Convey("Then it is not modified if the If-None-Match header lists its ETag, weak or strong", func() {
+			for _, ifNoneMatch := range []string{`"3"`, `W/"3"`, `"1", "3"`, `*`} {
+				request := httptest.NewRequest(http.MethodGet, "/books/"+bookID1, nil)
+				request.Header.Set("If-None-Match", ifNoneMatch)
+				So(notModified(request, 3), ShouldBeTrue)
+			}
+		})
+
+		Convey("Then it is modified if the If-None-Match header is another ETag, or is missing", func() {
+			request := httptest.NewRequest(http.MethodGet, "/books/"+bookID1, nil)
+			So(notModified(request, 3), ShouldBeFalse)
+
+			request.Header.Set("If-None-Match", `"2"`)
+			So(notModified(request, 3), ShouldBeFalse)
+		})
+	})
+}
+
+func TestPreconditionError(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given a write to the data store that conflicts with another request", t, func() {
+		Convey("When the request has an If-Match header", func() {
+			request := httptest.NewRequest(http.MethodPut, "/books/"+bookID1, nil)
+			request.Header.Set("If-Match", `"3"`)
+
+			Convey("Then the precondition has failed", func() {
+				So(errors.Is(preconditionError(request, mongo.ErrBookConflict), apierrors.ErrPreconditionFailed), ShouldBeTrue)
+				So(errors.Is(preconditionError(request, mongo.ErrReviewConflict), apierrors.ErrPreconditionFailed), ShouldBeTrue)
+			})
+
+			Convey("Then any other error is returned as it is", func() {
+				So(preconditionError(request, mongo.ErrBookNotFound), ShouldEqual, mongo.ErrBookNotFound)
+			})
+		})
+
+		Convey("When the request has no If-Match header", func() {
+			request := httptest.NewRequest(http.MethodPut, "/books/"+bookID1, nil)
+
+			Convey("Then the conflict is returned as it is", func() {
+				So(preconditionError(request, mongo.ErrBookConflict), ShouldEqual, mongo.ErrBookConflict)
+			})
+		})
+	})
+}
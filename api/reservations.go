@@ -0,0 +1,243 @@
+package api
+
+import (
+	"context"
+	"github.com/ONSdigital/log.go/log"
+	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/mongo"
+	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/gorilla/mux"
+	"net/http"
+	"time"
+)
+
+func (api *API) addReservationHandler(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+	bookID := mux.Vars(request)["id"]
+
+	logData := log.Data{"book_id": bookID}
+
+	if bookID == "" {
+		handleError(ctx, writer, apierrors.ErrEmptyBookID, logData)
+		return
+	}
+
+	if request.ContentLength == 0 {
+		handleError(ctx, writer, apierrors.ErrEmptyRequestBody, logData)
+		return
+	}
+
+	// Confirm that book exists. If bookID not found, then a reservation cannot be made!
+	_, err := api.dataStore.GetBook(ctx, bookID)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	input := &models.Reservation{}
+	if err := ReadJSONBody(ctx, request.Body, input); err != nil {
+		handleError(ctx, writer, apierrors.ErrInvalidReservation, logData)
+		return
+	}
+
+	// Only the user can be provided by the client. The state and timestamps are managed by the API
+	reservation := models.NewReservation(bookID)
+	reservation.User = input.User
+
+	logData["reservation"] = reservation
+
+	if err := reservation.Validate(); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	if err := api.dataStore.AddReservation(ctx, reservation); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	reservation.SetLinks(api.baseURL)
+	if err := WriteJSONBody(reservation, writer, http.StatusCreated); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+	log.Event(ctx, "successfully added reservation", log.INFO, logData)
+}
+
+func (api *API) getReservationsHandler(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+	bookID := mux.Vars(request)["id"]
+
+	logData := log.Data{"book_id": bookID}
+
+	offset, limit, err := api.paginator.GetPaginationValues(request)
+	logData["offset"] = offset
+	logData["limit"] = limit
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	if bookID == "" {
+		handleError(ctx, writer, apierrors.ErrEmptyBookID, logData)
+		return
+	}
+
+	// Confirm that book exists. If bookID not found, then do not check for the reservations
+	_, err = api.dataStore.GetBook(ctx, bookID)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	reservations, totalCount, err := api.dataStore.GetReservations(ctx, bookID, offset, limit)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	for i := range reservations {
+		reservations[i].SetLinks(api.baseURL)
+	}
+	response := models.ReservationsResponse{
+		Items: reservations,
+		Page: pagination.Page{
+			Count:      len(reservations),
+			Offset:     offset,
+			Limit:      limit,
+			TotalCount: totalCount,
+		},
+	}
+
+	if err := WriteJSONBody(response, writer, http.StatusOK); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+	log.Event(ctx, "successfully retrieved reservations", log.INFO, logData)
+}
+
+func (api *API) getReservationHandler(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+
+	bookID := mux.Vars(request)["id"]
+	reservationID := mux.Vars(request)["reservationID"]
+
+	logData := log.Data{"book_id": bookID, "reservation_id": reservationID}
+
+	reservation, err := api.getBookReservation(ctx, bookID, reservationID)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	reservation.SetLinks(api.baseURL)
+	if err := WriteJSONBody(reservation, writer, http.StatusOK); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+	log.Event(ctx, "successfully retrieved reservation", log.INFO, logData)
+}
+
+func (api *API) deleteReservationHandler(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+
+	bookID := mux.Vars(request)["id"]
+	reservationID := mux.Vars(request)["reservationID"]
+
+	logData := log.Data{"book_id": bookID, "reservation_id": reservationID}
+
+	reservation, err := api.getBookReservation(ctx, bookID, reservationID)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	// Once a book has been checked out, its reservation is kept as part of the book's history
+	if reservation.State != models.ReservationReserved {
+		handleError(ctx, writer, apierrors.ErrInvalidReservationState, logData)
+		return
+	}
+
+	if err := api.dataStore.DeleteReservation(ctx, reservationID); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+	log.Event(ctx, "successfully deleted reservation", log.INFO, logData)
+}
+
+func (api *API) checkoutReservationHandler(writer http.ResponseWriter, request *http.Request) {
+	api.changeReservationState(writer, request, (*models.Reservation).Checkout)
+}
+
+func (api *API) returnReservationHandler(writer http.ResponseWriter, request *http.Request) {
+	api.changeReservationState(writer, request, (*models.Reservation).Return)
+}
+
+// changeReservationState applies a state transition to the reservation in the request, and stores the result
+func (api *API) changeReservationState(writer http.ResponseWriter, request *http.Request, transition func(*models.Reservation, time.Time) error) {
+	ctx := request.Context()
+
+	bookID := mux.Vars(request)["id"]
+	reservationID := mux.Vars(request)["reservationID"]
+
+	logData := log.Data{"book_id": bookID, "reservation_id": reservationID}
+
+	reservation, err := api.getBookReservation(ctx, bookID, reservationID)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	previousState := reservation.State
+	logData["previous_state"] = previousState
+
+	if err := transition(reservation, time.Now().UTC()); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	logData["state"] = reservation.State
+
+	if err := api.dataStore.UpdateReservation(ctx, reservation, previousState); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	reservation.SetLinks(api.baseURL)
+	if err := WriteJSONBody(reservation, writer, http.StatusOK); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+	log.Event(ctx, "successfully updated reservation", log.INFO, logData)
+}
+
+// getBookReservation returns the reservation with the given ID, as long as both the book and the reservation exist
+// and the reservation belongs to the book.
+func (api *API) getBookReservation(ctx context.Context, bookID, reservationID string) (*models.Reservation, error) {
+	if bookID == "" {
+		return nil, apierrors.ErrEmptyBookID
+	}
+
+	if reservationID == "" {
+		return nil, apierrors.ErrEmptyReservationID
+	}
+
+	// Confirm that book exists. If bookID not found, then do not check for the reservation
+	if _, err := api.dataStore.GetBook(ctx, bookID); err != nil {
+		return nil, err
+	}
+
+	reservation, err := api.dataStore.GetReservation(ctx, reservationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if reservation.BookID != bookID {
+		return nil, mongo.ErrReservationNotFound
+	}
+
+	return reservation, nil
+}
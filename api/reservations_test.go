@@ -0,0 +1,368 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/interfaces/mock"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/mongo"
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	reservationID1   = "r1"
+	reservationValid = `{"user": {"forenames": "name", "surname": "surname"}, "state": "returned"}`
+)
+
+func reservationRequest(method, url string, body string, bookID, reservationID string) *http.Request {
+	request := httptest.NewRequest(method, url, strings.NewReader(body))
+	return mux.SetURLVars(request, map[string]string{
+		"id":            bookID,
+		"reservationID": reservationID,
+	})
+}
+
+func reservedMockDataStore(state string) *mock.DataStoreMock {
+	return &mock.DataStoreMock{
+		GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+			return &book1, nil
+		},
+		GetReservationFunc: func(ctx context.Context, reservationID string) (*models.Reservation, error) {
+			return &models.Reservation{ID: reservationID1, BookID: bookID1, State: state}, nil
+		},
+		UpdateReservationFunc: func(ctx context.Context, reservation *models.Reservation, previousState string) error {
+			return nil
+		},
+		DeleteReservationFunc: func(ctx context.Context, reservationID string) error {
+			return nil
+		},
+	}
+}
+
+func TestAddReservationHandler(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given an HTTP POST request to the /books/{id}/reservations endpoint", t, func() {
+
+		Convey("When the book exists and the reservation is valid", func() {
+			mockDataStore := &mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return &book1, nil
+				},
+				AddReservationFunc: func(ctx context.Context, reservation *models.Reservation) error {
+					return nil
+				},
+			}
+			api := &API{dataStore: mockDataStore}
+
+			request := reservationRequest(http.MethodPost, "/books/"+bookID1+"/reservations", reservationValid, bookID1, "")
+			response := httptest.NewRecorder()
+
+			api.addReservationHandler(response, request)
+			Convey("Then the HTTP response code is 201", func() {
+				So(response.Code, ShouldEqual, http.StatusCreated)
+			})
+			Convey("And the AddReservation function is called once with a reserved reservation for the book", func() {
+				So(mockDataStore.AddReservationCalls(), ShouldHaveLength, 1)
+				reservation := mockDataStore.AddReservationCalls()[0].Reservation
+				So(reservation.BookID, ShouldEqual, bookID1)
+				So(reservation.State, ShouldEqual, models.ReservationReserved)
+				So(reservation.User, ShouldResemble, models.User{Forenames: "name", Surname: "surname"})
+			})
+		})
+
+		Convey("When the reservation has no user", func() {
+			mockDataStore := &mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return &book1, nil
+				},
+			}
+			api := &API{dataStore: mockDataStore}
+
+			request := reservationRequest(http.MethodPost, "/books/"+bookID1+"/reservations", `{}`, bookID1, "")
+			response := httptest.NewRecorder()
+
+			api.addReservationHandler(response, request)
+			Convey("Then the HTTP response code is 422", func() {
+				So(response.Code, ShouldEqual, http.StatusUnprocessableEntity)
+				So(response.Body.String(), ShouldContainSubstring, apierrors.ErrEmptyReservationUser.Error())
+			})
+			Convey("And the AddReservation function is not called", func() {
+				So(mockDataStore.AddReservationCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the book already has an active reservation", func() {
+			mockDataStore := &mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return &book1, nil
+				},
+				AddReservationFunc: func(ctx context.Context, reservation *models.Reservation) error {
+					return mongo.ErrBookUnavailable
+				},
+			}
+			api := &API{dataStore: mockDataStore}
+
+			request := reservationRequest(http.MethodPost, "/books/"+bookID1+"/reservations", reservationValid, bookID1, "")
+			response := httptest.NewRecorder()
+
+			api.addReservationHandler(response, request)
+			Convey("Then the HTTP response code is 409", func() {
+				So(response.Code, ShouldEqual, http.StatusConflict)
+				So(response.Body.String(), ShouldContainSubstring, mongo.ErrBookUnavailable.Error())
+			})
+		})
+
+		Convey("When the book does not exist", func() {
+			mockDataStore := &mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return nil, mongo.ErrBookNotFound
+				},
+			}
+			api := &API{dataStore: mockDataStore}
+
+			request := reservationRequest(http.MethodPost, "/books/"+bookIDNotInStore+"/reservations", reservationValid, bookIDNotInStore, "")
+			response := httptest.NewRecorder()
+
+			api.addReservationHandler(response, request)
+			Convey("Then the HTTP response code is 404", func() {
+				So(response.Code, ShouldEqual, http.StatusNotFound)
+			})
+			Convey("And the AddReservation function is not called", func() {
+				So(mockDataStore.AddReservationCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+}
+
+func TestGetReservationsHandler(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given a book with one reservation", t, func() {
+		mockDataStore := &mock.DataStoreMock{
+			GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+				return &book1, nil
+			},
+			GetReservationsFunc: func(ctx context.Context, bookID string, offset int, limit int) ([]models.Reservation, int, error) {
+				return []models.Reservation{{ID: reservationID1, BookID: bookID1}}, 1, nil
+			},
+		}
+		paginator := mockPaginator()
+		api := &API{dataStore: mockDataStore, paginator: paginator}
+
+		Convey("When a http get request is sent to /books/{id}/reservations", func() {
+			request := reservationRequest(http.MethodGet, "/books/"+bookID1+"/reservations", "", bookID1, "")
+			response := httptest.NewRecorder()
+
+			api.getReservationsHandler(response, request)
+			Convey("Then the HTTP response code is 200", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+			})
+			Convey("And the GetReservations function is called once with the pagination parameters", func() {
+				So(mockDataStore.GetReservationsCalls(), ShouldHaveLength, 1)
+				So(mockDataStore.GetReservationsCalls()[0].BookID, ShouldEqual, bookID1)
+				So(mockDataStore.GetReservationsCalls()[0].Offset, ShouldEqual, offset)
+				So(mockDataStore.GetReservationsCalls()[0].Limit, ShouldEqual, limit)
+			})
+			Convey("And the response contains the paginated reservations", func() {
+				payload, err := ioutil.ReadAll(response.Body)
+				So(err, ShouldBeNil)
+				page := models.ReservationsResponse{}
+				So(json.Unmarshal(payload, &page), ShouldBeNil)
+				So(page.Count, ShouldEqual, 1)
+				So(page.TotalCount, ShouldEqual, 1)
+				So(page.Items[0].ID, ShouldEqual, reservationID1)
+			})
+		})
+	})
+}
+
+func TestGetReservationHandler(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given an HTTP GET request to the /books/{id}/reservations/{reservationID} endpoint", t, func() {
+
+		Convey("When the reservation belongs to the book", func() {
+			mockDataStore := reservedMockDataStore(models.ReservationReserved)
+			api := &API{dataStore: mockDataStore}
+
+			request := reservationRequest(http.MethodGet, "/books/"+bookID1+"/reservations/"+reservationID1, "", bookID1, reservationID1)
+			response := httptest.NewRecorder()
+
+			api.getReservationHandler(response, request)
+			Convey("Then the HTTP response code is 200", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("When the reservation belongs to a different book", func() {
+			mockDataStore := reservedMockDataStore(models.ReservationReserved)
+			api := &API{dataStore: mockDataStore}
+
+			request := reservationRequest(http.MethodGet, "/books/"+bookID2+"/reservations/"+reservationID1, "", bookID2, reservationID1)
+			response := httptest.NewRecorder()
+
+			api.getReservationHandler(response, request)
+			Convey("Then the HTTP response code is 404", func() {
+				So(response.Code, ShouldEqual, http.StatusNotFound)
+				So(response.Body.String(), ShouldContainSubstring, mongo.ErrReservationNotFound.Error())
+			})
+		})
+
+		Convey("When the {reservationID} is empty", func() {
+			mockDataStore := &mock.DataStoreMock{}
+			api := &API{dataStore: mockDataStore}
+
+			request := reservationRequest(http.MethodGet, "/books/"+bookID1+"/reservations/", "", bookID1, emptyID)
+			response := httptest.NewRecorder()
+
+			api.getReservationHandler(response, request)
+			Convey("Then the HTTP response code is 400", func() {
+				So(response.Code, ShouldEqual, http.StatusBadRequest)
+				So(mockDataStore.GetBookCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+}
+
+func TestDeleteReservationHandler(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given an HTTP DELETE request to the /books/{id}/reservations/{reservationID} endpoint", t, func() {
+
+		Convey("When the book has not been checked out", func() {
+			mockDataStore := reservedMockDataStore(models.ReservationReserved)
+			api := &API{dataStore: mockDataStore}
+
+			request := reservationRequest(http.MethodDelete, "/books/"+bookID1+"/reservations/"+reservationID1, "", bookID1, reservationID1)
+			response := httptest.NewRecorder()
+
+			api.deleteReservationHandler(response, request)
+			Convey("Then the HTTP response code is 204", func() {
+				So(response.Code, ShouldEqual, http.StatusNoContent)
+				So(mockDataStore.DeleteReservationCalls(), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When the book has already been checked out", func() {
+			mockDataStore := reservedMockDataStore(models.ReservationCheckedOut)
+			api := &API{dataStore: mockDataStore}
+
+			request := reservationRequest(http.MethodDelete, "/books/"+bookID1+"/reservations/"+reservationID1, "", bookID1, reservationID1)
+			response := httptest.NewRecorder()
+
+			api.deleteReservationHandler(response, request)
+			Convey("Then the HTTP response code is 409", func() {
+				So(response.Code, ShouldEqual, http.StatusConflict)
+				So(mockDataStore.DeleteReservationCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+}
+
+func TestCheckoutReservationHandler(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given an HTTP POST request to the /books/{id}/reservations/{reservationID}/checkout endpoint", t, func() {
+
+		Convey("When the book is reserved", func() {
+			mockDataStore := reservedMockDataStore(models.ReservationReserved)
+			api := &API{dataStore: mockDataStore}
+
+			request := reservationRequest(http.MethodPost, "/books/"+bookID1+"/reservations/"+reservationID1+"/checkout", "", bookID1, reservationID1)
+			response := httptest.NewRecorder()
+
+			api.checkoutReservationHandler(response, request)
+			Convey("Then the HTTP response code is 200", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+			})
+			Convey("And the reservation is stored as checked out, if it was still reserved", func() {
+				So(mockDataStore.UpdateReservationCalls(), ShouldHaveLength, 1)
+				So(mockDataStore.UpdateReservationCalls()[0].PreviousState, ShouldEqual, models.ReservationReserved)
+				So(mockDataStore.UpdateReservationCalls()[0].Reservation.State, ShouldEqual, models.ReservationCheckedOut)
+				So(mockDataStore.UpdateReservationCalls()[0].Reservation.CheckedOutAt, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When the book has already been checked out", func() {
+			mockDataStore := reservedMockDataStore(models.ReservationCheckedOut)
+			api := &API{dataStore: mockDataStore}
+
+			request := reservationRequest(http.MethodPost, "/books/"+bookID1+"/reservations/"+reservationID1+"/checkout", "", bookID1, reservationID1)
+			response := httptest.NewRecorder()
+
+			api.checkoutReservationHandler(response, request)
+			Convey("Then the HTTP response code is 409", func() {
+				So(response.Code, ShouldEqual, http.StatusConflict)
+				So(response.Body.String(), ShouldContainSubstring, apierrors.ErrInvalidReservationState.Error())
+			})
+			Convey("And the reservation is not updated", func() {
+				So(mockDataStore.UpdateReservationCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the reservation is modified by another request", func() {
+			mockDataStore := reservedMockDataStore(models.ReservationReserved)
+			mockDataStore.UpdateReservationFunc = func(ctx context.Context, reservation *models.Reservation, previousState string) error {
+				return mongo.ErrReservationConflict
+			}
+			api := &API{dataStore: mockDataStore}
+
+			request := reservationRequest(http.MethodPost, "/books/"+bookID1+"/reservations/"+reservationID1+"/checkout", "", bookID1, reservationID1)
+			response := httptest.NewRecorder()
+
+			api.checkoutReservationHandler(response, request)
+			Convey("Then the HTTP response code is 409", func() {
+				So(response.Code, ShouldEqual, http.StatusConflict)
+				So(response.Body.String(), ShouldContainSubstring, mongo.ErrReservationConflict.Error())
+			})
+		})
+	})
+}
+
+func TestReturnReservationHandler(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given an HTTP POST request to the /books/{id}/reservations/{reservationID}/return endpoint", t, func() {
+
+		Convey("When the book has been checked out", func() {
+			mockDataStore := reservedMockDataStore(models.ReservationCheckedOut)
+			api := &API{dataStore: mockDataStore}
+
+			request := reservationRequest(http.MethodPost, "/books/"+bookID1+"/reservations/"+reservationID1+"/return", "", bookID1, reservationID1)
+			response := httptest.NewRecorder()
+
+			api.returnReservationHandler(response, request)
+			Convey("Then the HTTP response code is 200", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+			})
+			Convey("And the reservation is stored as returned", func() {
+				So(mockDataStore.UpdateReservationCalls(), ShouldHaveLength, 1)
+				So(mockDataStore.UpdateReservationCalls()[0].PreviousState, ShouldEqual, models.ReservationCheckedOut)
+				So(mockDataStore.UpdateReservationCalls()[0].Reservation.State, ShouldEqual, models.ReservationReturned)
+				So(mockDataStore.UpdateReservationCalls()[0].Reservation.ReturnedAt, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When the book is only reserved", func() {
+			mockDataStore := reservedMockDataStore(models.ReservationReserved)
+			api := &API{dataStore: mockDataStore}
+
+			request := reservationRequest(http.MethodPost, "/books/"+bookID1+"/reservations/"+reservationID1+"/return", "", bookID1, reservationID1)
+			response := httptest.NewRecorder()
+
+			api.returnReservationHandler(response, request)
+			Convey("Then the HTTP response code is 409", func() {
+				So(response.Code, ShouldEqual, http.StatusConflict)
+				So(mockDataStore.UpdateReservationCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+}
@@ -1,10 +1,12 @@
 package api
 
 import (
+	"context"
 	"github.com/ONSdigital/log.go/log"
 	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/auth"
 	"github.com/cadmiumcat/books-api/models"
-	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/cadmiumcat/books-api/mongo"
 	"github.com/gorilla/mux"
 	"net/http"
 )
@@ -33,12 +35,23 @@ func (api *API) addReviewHandler(writer http.ResponseWriter, request *http.Reque
 	}
 
 	review := models.NewReview(bookID)
+	id := review.ID
 
 	if err := ReadJSONBody(ctx, request.Body, review); err != nil {
 		handleError(ctx, writer, apierrors.ErrInvalidReview, logData)
 		return
 	}
 
+	// The ID of a review is generated, and the review belongs to the book in the request.
+	// Every new review is moderated before it is shown to readers
+	review.ID, review.BookID = id, bookID
+	review.State = models.ReviewPending
+
+	// The review belongs to the caller that wrote it
+	if identity, ok := auth.FromContext(ctx); ok {
+		review.Owner = identity.Subject
+	}
+
 	logData["review"] = review
 
 	err = review.Validate()
@@ -47,16 +60,26 @@ func (api *API) addReviewHandler(writer http.ResponseWriter, request *http.Reque
 		return
 	}
 
-	api.dataStore.AddReview(ctx, review)
+	if err := api.dataStore.AddReview(ctx, review); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
 
+	review.SetLinks(api.baseURL)
+	writer.Header().Set("ETag", etag(review.Revision))
 	if err := WriteJSONBody(review, writer, http.StatusCreated); err != nil {
 		handleError(ctx, writer, err, logData)
 		return
 	}
-
 }
 
 func (api *API) getReviewsHandler(writer http.ResponseWriter, request *http.Request) {
+	api.listReviews(writer, request, false)
+}
+
+// listReviews writes a page of the reviews of the book in the request.
+// Readers only get the approved reviews, whereas moderators get the reviews in every state and can filter them by state.
+func (api *API) listReviews(writer http.ResponseWriter, request *http.Request, moderator bool) {
 	ctx := request.Context()
 	bookID := mux.Vars(request)["id"]
 
@@ -70,6 +93,29 @@ func (api *API) getReviewsHandler(writer http.ResponseWriter, request *http.Requ
 		return
 	}
 
+	fields := models.ReviewFields
+	if moderator {
+		fields = models.ModeratedReviewFields
+	}
+
+	q, err := fields.Parse(request.URL.Query())
+	if err != nil {
+		logData["query"] = q
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	if !moderator {
+		q.Filters = append(q.Filters, models.ApprovedReviews)
+	}
+	logData["query"] = q
+
+	cursor, err := api.paginator.GetCursor(request, q)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
 	if bookID == "" {
 		handleError(ctx, writer, apierrors.ErrEmptyBookID, logData)
 		return
@@ -82,20 +128,24 @@ func (api *API) getReviewsHandler(writer http.ResponseWriter, request *http.Requ
 		return
 	}
 
-	reviews, totalCount, err := api.dataStore.GetReviews(ctx, bookID, offset, limit)
+	reviews, totalCount, err := api.dataStore.GetReviews(ctx, bookID, q, cursor, offset, limit)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	page, err := api.paginator.NewPage(request, q, cursor, reviews, offset, limit, totalCount)
 	if err != nil {
 		handleError(ctx, writer, err, logData)
 		return
 	}
 
+	for i := range reviews {
+		reviews[i].SetLinks(api.baseURL)
+	}
 	response := models.ReviewsResponse{
 		Items: reviews,
-		Page: pagination.Page{
-			Count:      len(reviews),
-			Offset:     offset,
-			Limit:      limit,
-			TotalCount: totalCount,
-		},
+		Page:  page,
 	}
 
 	if err := WriteJSONBody(response, writer, http.StatusOK); err != nil {
@@ -107,6 +157,12 @@ func (api *API) getReviewsHandler(writer http.ResponseWriter, request *http.Requ
 }
 
 func (api *API) getReviewHandler(writer http.ResponseWriter, request *http.Request) {
+	api.getReview(writer, request, false)
+}
+
+// getReview writes the review in the request. Readers can only get an approved review, whereas moderators can get a
+// review in any state.
+func (api *API) getReview(writer http.ResponseWriter, request *http.Request, moderator bool) {
 	ctx := request.Context()
 
 	bookID := mux.Vars(request)["id"]
@@ -114,38 +170,71 @@ func (api *API) getReviewHandler(writer http.ResponseWriter, request *http.Reque
 
 	logData := log.Data{"book_id": bookID, "review_id": reviewID}
 
-	if bookID == "" {
-		handleError(ctx, writer, apierrors.ErrEmptyBookID, logData)
+	review, err := api.getBookReview(ctx, bookID, reviewID)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
 		return
 	}
 
-	if reviewID == "" {
-		handleError(ctx, writer, apierrors.ErrEmptyReviewID, logData)
+	// A review that is not approved is hidden from readers
+	if !moderator && !review.IsApproved() {
+		handleError(ctx, writer, mongo.ErrReviewNotFound, logData)
 		return
 	}
 
-	// Confirm that book exists. If bookID not found, then do not check for the review
-	_, err := api.dataStore.GetBook(ctx, bookID)
-	if err != nil {
+	writer.Header().Set("ETag", etag(review.Revision))
+	if notModified(request, review.Revision) {
+		writer.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	review.SetLinks(api.baseURL)
+	if err := WriteJSONBody(review, writer, http.StatusOK); err != nil {
 		handleError(ctx, writer, err, logData)
 		return
 	}
 
-	review, err := api.dataStore.GetReview(ctx, reviewID)
+	log.Event(ctx, "successfully retrieved review", log.INFO, logData)
+}
+
+// updateReviewHandler replaces the message, user and rating of a review, which are validated as they are when a
+// review is added. A rating that is left out is removed.
+func (api *API) updateReviewHandler(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+
+	bookID := mux.Vars(request)["id"]
+	reviewID := mux.Vars(request)["reviewID"]
+
+	logData := log.Data{"book_id": bookID, "review_id": reviewID}
+
+	existing, err := api.reviewToChange(request, bookID, reviewID)
 	if err != nil {
 		handleError(ctx, writer, err, logData)
 		return
 	}
 
-	if err := WriteJSONBody(review, writer, http.StatusOK); err != nil {
-		handleError(ctx, writer, err, logData)
+	if request.ContentLength == 0 {
+		handleError(ctx, writer, apierrors.ErrEmptyRequestBody, logData)
 		return
 	}
 
-	log.Event(ctx, "successfully retrieved review", log.INFO, logData)
+	replacement := &models.Review{}
+	if err := ReadJSONBody(ctx, request.Body, replacement); err != nil {
+		handleError(ctx, writer, apierrors.ErrInvalidReview, logData)
+		return
+	}
+
+	// Only the message, user and rating of the review can be replaced
+	review := *existing
+	review.Message = replacement.Message
+	review.User = replacement.User
+	review.Rating = replacement.Rating
+
+	api.updateReview(writer, request, &review, existing, logData)
 }
 
-func (api *API) updateReviewHandler(writer http.ResponseWriter, request *http.Request) {
+// patchReviewHandler applies a JSON merge patch to the message, user and rating of a review
+func (api *API) patchReviewHandler(writer http.ResponseWriter, request *http.Request) {
 	ctx := request.Context()
 
 	bookID := mux.Vars(request)["id"]
@@ -153,45 +242,137 @@ func (api *API) updateReviewHandler(writer http.ResponseWriter, request *http.Re
 
 	logData := log.Data{"book_id": bookID, "review_id": reviewID}
 
-	if bookID == "" {
-		handleError(ctx, writer, apierrors.ErrEmptyBookID, logData)
+	existing, err := api.reviewToChange(request, bookID, reviewID)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
 		return
 	}
 
-	if reviewID == "" {
-		handleError(ctx, writer, apierrors.ErrEmptyReviewID, logData)
+	if request.ContentLength == 0 {
+		handleError(ctx, writer, apierrors.ErrEmptyRequestBody, logData)
 		return
 	}
 
-	// Confirm that book exists. If bookID not found, or there's another error, then return
-	_, err := api.dataStore.GetBook(ctx, bookID)
+	patch := make(map[string]interface{})
+	if err := ReadJSONBody(ctx, request.Body, &patch); err != nil {
+		handleError(ctx, writer, apierrors.ErrInvalidReview, logData)
+		return
+	}
+
+	logData["patch"] = patch
+
+	// An empty patch does not change the review, which stays in its moderation state
+	if len(patch) == 0 {
+		existing.SetLinks(api.baseURL)
+		writer.Header().Set("ETag", etag(existing.Revision))
+		if err := WriteJSONBody(existing, writer, http.StatusOK); err != nil {
+			handleError(ctx, writer, err, logData)
+		}
+		return
+	}
+
+	review, err := existing.ApplyMergePatch(patch)
 	if err != nil {
 		handleError(ctx, writer, err, logData)
 		return
 	}
 
-	// Confirm that the review exists. If reviewID not found, or there's another error, then return
-	_, err = api.dataStore.GetReview(ctx, reviewID)
-	if err != nil {
+	api.updateReview(writer, request, review, existing, logData)
+}
+
+// updateReview validates a review, and stores it in place of the existing review that was read. The updated review
+// is written as the response.
+func (api *API) updateReview(writer http.ResponseWriter, request *http.Request, review, existing *models.Review, logData log.Data) {
+	ctx := request.Context()
+
+	logData["review"] = review
+
+	if err := review.Validate(); err != nil {
 		handleError(ctx, writer, err, logData)
 		return
 	}
 
-	review := &models.Review{User: models.User{}}
-	if err := ReadJSONBody(ctx, request.Body, review); err != nil {
-		handleError(ctx, writer, apierrors.ErrInvalidReview, logData)
+	updated, err := api.dataStore.UpdateReview(ctx, review, existing)
+	if err != nil {
+		handleError(ctx, writer, preconditionError(request, err), logData)
 		return
 	}
 
-	logData["review"] = review
+	updated.SetLinks(api.baseURL)
+	writer.Header().Set("ETag", etag(updated.Revision))
+	if err := WriteJSONBody(updated, writer, http.StatusOK); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+	log.Event(ctx, "successfully updated review", log.INFO, logData)
+}
+
+func (api *API) deleteReviewHandler(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
 
-	err = api.dataStore.UpdateReview(ctx, reviewID, review)
+	bookID := mux.Vars(request)["id"]
+	reviewID := mux.Vars(request)["reviewID"]
+
+	logData := log.Data{"book_id": bookID, "review_id": reviewID}
+
+	review, err := api.reviewToChange(request, bookID, reviewID)
 	if err != nil {
 		handleError(ctx, writer, err, logData)
 		return
 	}
 
-	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
-	writer.WriteHeader(http.StatusOK)
+	if err := api.dataStore.DeleteReview(ctx, review); err != nil {
+		handleError(ctx, writer, preconditionError(request, err), logData)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+	log.Event(ctx, "successfully deleted review", log.INFO, logData)
+}
+
+// reviewToChange returns the review of a book that the request changes, as long as the caller can modify it and it
+// matches the If-Match precondition of the request
+func (api *API) reviewToChange(request *http.Request, bookID, reviewID string) (*models.Review, error) {
+	review, err := api.getBookReview(request.Context(), bookID, reviewID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canModifyReview(request, review) {
+		return nil, auth.ErrForbidden
+	}
+
+	if err := api.checkIfMatch(request, review.Revision); err != nil {
+		return nil, err
+	}
+
+	return review, nil
+}
+
+// getBookReview returns the review with the given ID, as long as both the book and the review exist
+// and the review belongs to the book.
+func (api *API) getBookReview(ctx context.Context, bookID, reviewID string) (*models.Review, error) {
+	if bookID == "" {
+		return nil, apierrors.ErrEmptyBookID
+	}
+
+	if reviewID == "" {
+		return nil, apierrors.ErrEmptyReviewID
+	}
+
+	// Confirm that book exists. If bookID not found, then do not check for the review
+	if _, err := api.dataStore.GetBook(ctx, bookID); err != nil {
+		return nil, err
+	}
+
+	review, err := api.dataStore.GetReview(ctx, reviewID)
+	if err != nil {
+		return nil, err
+	}
+
+	if review.BookID != bookID {
+		return nil, mongo.ErrReviewNotFound
+	}
 
+	return review, nil
 }
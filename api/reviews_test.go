@@ -3,11 +3,14 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/auth"
 	"github.com/cadmiumcat/books-api/interfaces/mock"
 	"github.com/cadmiumcat/books-api/models"
 	"github.com/cadmiumcat/books-api/mongo"
 	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/cadmiumcat/books-api/query"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	. "github.com/smartystreets/goconvey/convey"
@@ -24,16 +27,19 @@ const (
 	bookID2                   = "2"
 	reviewID1                 = "123"
 	reviewID2                 = "567"
+	reviewOwner               = "avid.reader"
 	emptyID                   = ""
 	bookIDNotInStore          = "notInStore"
 	reviewInvalidMessage      = `{"message": ""}`
 	reviewInvalidUpdate       = `{""`
 	reviewValid               = `{"message": "my review", "user": {"forenames": "name", "surname": "surname"}}`
-	internalSeverErrorMessage = "internal server error\n"
+	internalSeverErrorMessage = "internal server error"
 )
 
 var bookReview1 = models.Review{
-	ID: reviewID1,
+	ID:     reviewID1,
+	BookID: bookID1,
+	State:  models.ReviewApproved,
 	Links: &models.ReviewLink{
 		Book: bookID1,
 	},
@@ -51,7 +57,10 @@ var bookReview2 = models.Review{
 }
 
 var reviewUpdated = models.Review{
-	ID: reviewID1,
+	ID:     reviewID1,
+	BookID: bookID1,
+	State:  models.ReviewApproved,
+	Owner:  reviewOwner,
 	User: models.User{
 		Forenames: "new name",
 		Surname:   "old surname",
@@ -63,12 +72,27 @@ var reviewUpdated = models.Review{
 var reviewUpdate = models.Review{
 	User: models.User{
 		Forenames: "new Name",
+		Surname:   "new surname",
 	},
-	Message: "",
+	Message: "new review",
+	Rating:  4,
 }
 
 var errMongoDB = errors.New("unexpected error in MongoDB")
 
+// writeFailures are the errors of a write to the data store, and the responses they cause
+var writeFailures = []struct {
+	name       string
+	err        error
+	status     int
+	code       string
+	retryAfter string
+}{
+	{"a duplicate key", &mongo.WriteError{Kind: mongo.ErrDuplicateKey, Err: errMongoDB}, http.StatusConflict, "duplicate_resource", ""},
+	{"a timeout", errors.Wrap(&mongo.WriteError{Kind: mongo.ErrUnavailable, Err: context.DeadlineExceeded}, "adding"), http.StatusServiceUnavailable, "data_store_unavailable", retryAfter},
+	{"an unexpected error", errMongoDB, http.StatusInternalServerError, "internal_error", ""},
+}
+
 func marshalJSON(t *testing.T, data interface{}) string {
 	t.Helper()
 	out, err := json.Marshal(data)
@@ -79,6 +103,17 @@ func marshalJSON(t *testing.T, data interface{}) string {
 	return string(out)
 }
 
+// readProblem decodes the problem in the body of an error response
+func readProblem(t *testing.T, response *httptest.ResponseRecorder) apierrors.Problem {
+	t.Helper()
+	var problem apierrors.Problem
+	if err := json.Unmarshal(response.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return problem
+}
+
 func TestGetReviewHandler(t *testing.T) {
 	t.Parallel()
 
@@ -98,7 +133,7 @@ func TestGetReviewHandler(t *testing.T) {
 			api.getReviewHandler(response, request)
 			Convey("Then the HTTP response code is 400", func() {
 				So(response.Code, ShouldEqual, http.StatusBadRequest)
-				So(response.Body.String(), ShouldEqual, "empty review ID in request\n")
+				So(readProblem(t, response).Detail, ShouldEqual, "empty review ID in request")
 			})
 		})
 
@@ -116,7 +151,7 @@ func TestGetReviewHandler(t *testing.T) {
 			api.getReviewHandler(response, request)
 			Convey("Then the HTTP response code is 400", func() {
 				So(response.Code, ShouldEqual, http.StatusBadRequest)
-				So(response.Body.String(), ShouldEqual, "empty book ID in request\n")
+				So(readProblem(t, response).Detail, ShouldEqual, "empty book ID in request")
 			})
 		})
 	})
@@ -184,7 +219,7 @@ func TestGetReviewHandler(t *testing.T) {
 			Convey("And the GetReview function is called once", func() {
 				So(mockDataStore.GetBookCalls(), ShouldHaveLength, 1)
 				So(mockDataStore.GetReviewCalls(), ShouldHaveLength, 1)
-				So(response.Body.String(), ShouldEqual, "review not found\n")
+				So(readProblem(t, response).Detail, ShouldEqual, "review not found")
 			})
 		})
 	})
@@ -215,7 +250,7 @@ func TestGetReviewHandler(t *testing.T) {
 			Convey("And the GetReview function is not called", func() {
 				So(mockDataStore.GetBookCalls(), ShouldHaveLength, 1)
 				So(mockDataStore.GetReviewCalls(), ShouldHaveLength, 0)
-				So(response.Body.String(), ShouldEqual, "book not found\n")
+				So(readProblem(t, response).Detail, ShouldEqual, "book not found")
 			})
 		})
 	})
@@ -242,7 +277,7 @@ func TestGetReviewHandler(t *testing.T) {
 			api.getReviewHandler(response, request)
 			Convey("Then 500 InternalServerError status code is returned", func() {
 				So(response.Code, ShouldEqual, http.StatusInternalServerError)
-				So(response.Body.String(), ShouldEqual, internalSeverErrorMessage)
+				So(readProblem(t, response).Detail, ShouldEqual, internalSeverErrorMessage)
 			})
 		})
 
@@ -270,7 +305,7 @@ func TestGetReviewHandler(t *testing.T) {
 			api.getReviewHandler(response, request)
 			Convey("Then 500 InternalServerError status code is returned", func() {
 				So(response.Code, ShouldEqual, http.StatusInternalServerError)
-				So(response.Body.String(), ShouldEqual, internalSeverErrorMessage)
+				So(readProblem(t, response).Detail, ShouldEqual, internalSeverErrorMessage)
 			})
 		})
 	})
@@ -294,7 +329,7 @@ func TestGetReviewsHandler(t *testing.T) {
 			api.getReviewsHandler(response, request)
 			Convey("Then the HTTP response code is 400", func() {
 				So(response.Code, ShouldEqual, http.StatusBadRequest)
-				So(response.Body.String(), ShouldEqual, "empty book ID in request\n")
+				So(readProblem(t, response).Detail, ShouldEqual, "empty book ID in request")
 			})
 		})
 	})
@@ -304,7 +339,7 @@ func TestGetReviewsHandler(t *testing.T) {
 			GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
 				return &models.Book{ID: bookID1}, nil
 			},
-			GetReviewsFunc: func(ctx context.Context, bookID string, offset int, limit int) ([]models.Review, int, error) {
+			GetReviewsFunc: func(ctx context.Context, bookID string, q *query.Query, cursor *pagination.Cursor, offset int, limit int) ([]models.Review, int, error) {
 				return []models.Review{
 					bookReview1,
 					bookReview2,
@@ -359,7 +394,7 @@ func TestGetReviewsHandler(t *testing.T) {
 			GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
 				return &models.Book{ID: bookID1}, nil
 			},
-			GetReviewsFunc: func(ctx context.Context, bookID string, offset int, limit int) ([]models.Review, int, error) {
+			GetReviewsFunc: func(ctx context.Context, bookID string, q *query.Query, cursor *pagination.Cursor, offset int, limit int) ([]models.Review, int, error) {
 				return []models.Review{}, 0, nil
 			},
 		}
@@ -403,6 +438,42 @@ func TestGetReviewsHandler(t *testing.T) {
 				So(page.Page, ShouldResemble, expectedPage)
 			})
 		})
+
+		Convey("When a HTTP GET request is sent to /books/1/reviews for the newest reviews of a user", func() {
+			request := httptest.NewRequest(http.MethodGet, "/books/"+bookID1+"/reviews?surname=Evaristo&sort=-last_updated", nil)
+			request = mux.SetURLVars(request, map[string]string{"id": bookID1})
+			response := httptest.NewRecorder()
+
+			api.getReviewsHandler(response, request)
+			Convey("Then the HTTP response code is 200", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+			})
+			Convey("And the GetReviews function is called with the query, restricted to the approved reviews", func() {
+				So(mockDataStore.GetReviewsCalls(), ShouldHaveLength, 1)
+				So(mockDataStore.GetReviewsCalls()[0].Q, ShouldResemble, &query.Query{
+					Filters: []query.Filter{
+						{Key: "user.surname", Operator: query.Equals, Value: "Evaristo"},
+						models.ApprovedReviews,
+					},
+					Sort: []query.Sort{{Key: "last_updated", Descending: true}},
+				})
+			})
+		})
+
+		Convey("When a HTTP GET request is sent to /books/1/reviews with an unknown filter", func() {
+			request := httptest.NewRequest(http.MethodGet, "/books/"+bookID1+"/reviews?rating=5", nil)
+			request = mux.SetURLVars(request, map[string]string{"id": bookID1})
+			response := httptest.NewRecorder()
+
+			api.getReviewsHandler(response, request)
+			Convey("Then the HTTP response code is 400", func() {
+				So(response.Code, ShouldEqual, http.StatusBadRequest)
+				So(response.Body.String(), ShouldContainSubstring, query.ErrUnknownQueryParameter.Error())
+			})
+			Convey("And the GetReviews function is not called", func() {
+				So(mockDataStore.GetReviewsCalls(), ShouldHaveLength, 0)
+			})
+		})
 	})
 
 	Convey("Given a GET request for a list of reviews of a book that does not exist", t, func() {
@@ -441,7 +512,7 @@ func TestGetReviewsHandler(t *testing.T) {
 				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
 					return &models.Book{ID: bookID1}, nil
 				},
-				GetReviewsFunc: func(ctx context.Context, bookID string, offset int, limit int) ([]models.Review, int, error) {
+				GetReviewsFunc: func(ctx context.Context, bookID string, q *query.Query, cursor *pagination.Cursor, offset int, limit int) ([]models.Review, int, error) {
 					return []models.Review{}, 0, errors.Wrap(errMongoDB, "unexpected error when getting a review")
 				},
 			}
@@ -458,7 +529,7 @@ func TestGetReviewsHandler(t *testing.T) {
 			api.getReviewsHandler(response, request)
 			Convey("Then the HTTP response code is 500", func() {
 				So(response.Code, ShouldEqual, http.StatusInternalServerError)
-				So(response.Body.String(), ShouldEqual, internalSeverErrorMessage)
+				So(readProblem(t, response).Detail, ShouldEqual, internalSeverErrorMessage)
 			})
 			Convey("And the GetBook and GetReviews functions are called", func() {
 				So(mockDataStore.GetBookCalls(), ShouldHaveLength, 1)
@@ -491,7 +562,7 @@ func TestGetReviewsHandler(t *testing.T) {
 			api.getReviewsHandler(response, request)
 			Convey("Then the HTTP response code is 500", func() {
 				So(response.Code, ShouldEqual, http.StatusInternalServerError)
-				So(response.Body.String(), ShouldEqual, internalSeverErrorMessage)
+				So(readProblem(t, response).Detail, ShouldEqual, internalSeverErrorMessage)
 			})
 			Convey("And the GetReviews function is not called", func() {
 				So(mockDataStore.GetBookCalls(), ShouldHaveLength, 1)
@@ -532,6 +603,60 @@ func TestAddReviewHandler(t *testing.T) {
 			})
 		})
 
+		Convey("When the review claims an ID and another book", func() {
+			mockDataStore := &mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return &book1, nil
+				},
+				AddReviewFunc: func(ctx context.Context, review *models.Review) error {
+					return nil
+				},
+			}
+
+			api := &API{dataStore: mockDataStore, baseURL: "http://localhost:8080/v1"}
+			body := strings.NewReader(`{"id": "chosen", "book_id": "` + bookID2 + `", "message": "my review", "user": {"forenames": "name", "surname": "surname"}}`)
+			request := httptest.NewRequest("POST", "/books/"+bookID1+"/reviews", body)
+			request = mux.SetURLVars(request, map[string]string{"id": bookID1})
+			response := httptest.NewRecorder()
+
+			api.addReviewHandler(response, request)
+			Convey("Then the review is added to the book in the request, with a generated ID", func() {
+				So(response.Code, ShouldEqual, http.StatusCreated)
+				added := mockDataStore.AddReviewCalls()[0].Review
+				So(added.ID, ShouldNotEqual, "chosen")
+				So(added.BookID, ShouldEqual, bookID1)
+			})
+			Convey("And the response contains the absolute links of the review", func() {
+				review := models.Review{}
+				So(json.Unmarshal(response.Body.Bytes(), &review), ShouldBeNil)
+				So(review.Links.Self, ShouldEqual, "http://localhost:8080/v1/books/"+bookID1+"/reviews/"+review.ID)
+				So(review.Links.Book, ShouldEqual, "http://localhost:8080/v1/books/"+bookID1)
+			})
+		})
+
+		Convey("When the review claims to be approved already", func() {
+			mockDataStore := &mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return &book1, nil
+				},
+				AddReviewFunc: func(ctx context.Context, review *models.Review) error {
+					return nil
+				},
+			}
+
+			api := &API{dataStore: mockDataStore}
+			body := strings.NewReader(`{"message": "my review", "user": {"forenames": "name", "surname": "surname"}, "state": "approved"}`)
+			request := httptest.NewRequest("POST", "/books/"+bookID1+"/reviews", body)
+			request = mux.SetURLVars(request, map[string]string{"id": bookID1})
+			response := httptest.NewRecorder()
+
+			api.addReviewHandler(response, request)
+			Convey("Then the review is added pending moderation", func() {
+				So(response.Code, ShouldEqual, http.StatusCreated)
+				So(mockDataStore.AddReviewCalls()[0].Review.State, ShouldEqual, models.ReviewPending)
+			})
+		})
+
 		Convey("When the book exist, but the review is not valid (empty message)", func() {
 			mockDataStore := &mock.DataStoreMock{
 				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
@@ -551,9 +676,37 @@ func TestAddReviewHandler(t *testing.T) {
 			response := httptest.NewRecorder()
 
 			api.addReviewHandler(response, request)
-			Convey("Then the HTTP response is 400", func() {
-				So(response.Code, ShouldEqual, http.StatusBadRequest)
-				So(response.Body.String(), ShouldEqual, "empty review provided. Please enter a message\n")
+			Convey("Then the HTTP response is 422", func() {
+				So(response.Code, ShouldEqual, http.StatusUnprocessableEntity)
+				So(readProblem(t, response).Detail, ShouldEqual, "empty review provided. Please enter a message")
+			})
+		})
+
+		Convey("When the book exists, but the rating of the review is out of range", func() {
+			mockDataStore := &mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return &book1, nil
+				},
+			}
+
+			api := &API{dataStore: mockDataStore}
+			body := strings.NewReader(`{"message":"Too good","user":{"forenames":"Avid","surname":"Reader"},"rating":6}`)
+			request := httptest.NewRequest("POST", "/books/"+bookID1+"/reviews", body)
+
+			expectedUrlVars := map[string]string{"id": bookID1}
+			request = mux.SetURLVars(request, expectedUrlVars)
+			response := httptest.NewRecorder()
+
+			api.addReviewHandler(response, request)
+			Convey("Then the HTTP response is 422, with the invalid field", func() {
+				So(response.Code, ShouldEqual, http.StatusUnprocessableEntity)
+				problem := readProblem(t, response)
+				So(problem.Code, ShouldEqual, "invalid_rating")
+				So(problem.Detail, ShouldEqual, apierrors.ErrInvalidRating.Error())
+				So(problem.Errors, ShouldResemble, []apierrors.FieldError{{Field: "rating", Rule: apierrors.RuleRange}})
+			})
+			Convey("And the AddReview function is not called", func() {
+				So(mockDataStore.AddReviewCalls(), ShouldHaveLength, 0)
 			})
 		})
 
@@ -568,7 +721,7 @@ func TestAddReviewHandler(t *testing.T) {
 			api.addReviewHandler(response, request)
 			Convey("Then the HTTP response code is 400", func() {
 				So(response.Code, ShouldEqual, http.StatusBadRequest)
-				So(response.Body.String(), ShouldEqual, "empty book ID in request\n")
+				So(readProblem(t, response).Detail, ShouldEqual, "empty book ID in request")
 			})
 		})
 
@@ -583,7 +736,7 @@ func TestAddReviewHandler(t *testing.T) {
 			api.addReviewHandler(response, request)
 			Convey("Then the HTTP response code is 400", func() {
 				So(response.Code, ShouldEqual, http.StatusBadRequest)
-				So(response.Body.String(), ShouldEqual, "empty request body\n")
+				So(readProblem(t, response).Detail, ShouldEqual, "empty request body")
 			})
 		})
 
@@ -603,10 +756,36 @@ func TestAddReviewHandler(t *testing.T) {
 			api.addReviewHandler(response, request)
 			Convey("Then the HTTP response is 404", func() {
 				So(response.Code, ShouldEqual, http.StatusNotFound)
-				So(response.Body.String(), ShouldEqual, "book not found\n")
+				So(readProblem(t, response).Detail, ShouldEqual, "book not found")
 			})
 		})
 
+		for _, failure := range writeFailures {
+			failure := failure
+			Convey("When adding the review fails with "+failure.name, func() {
+				mockDataStore := &mock.DataStoreMock{
+					GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+						return &book1, nil
+					},
+					AddReviewFunc: func(ctx context.Context, review *models.Review) error {
+						return failure.err
+					},
+				}
+
+				api := &API{dataStore: mockDataStore}
+				request := httptest.NewRequest("POST", "/books/"+bookID1+"/reviews", strings.NewReader(reviewValid))
+				request = mux.SetURLVars(request, map[string]string{"id": bookID1})
+				response := httptest.NewRecorder()
+
+				api.addReviewHandler(response, request)
+				Convey(fmt.Sprintf("Then the HTTP response code is %d", failure.status), func() {
+					So(response.Code, ShouldEqual, failure.status)
+					So(readProblem(t, response).Code, ShouldEqual, failure.code)
+					So(response.Header().Get("Retry-After"), ShouldEqual, failure.retryAfter)
+				})
+			})
+		}
+
 		Convey("When the request body is invalid", func() {
 			mockDataStore := mock.DataStoreMock{GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
 				return &models.Book{}, nil
@@ -623,7 +802,7 @@ func TestAddReviewHandler(t *testing.T) {
 			api.addReviewHandler(response, request)
 			Convey("Then the HTTP response code is 400", func() {
 				So(response.Code, ShouldEqual, http.StatusBadRequest)
-				So(response.Body.String(), ShouldEqual, "invalid review\n")
+				So(readProblem(t, response).Detail, ShouldEqual, "invalid review")
 			})
 		})
 	})
@@ -640,8 +819,10 @@ func TestUpdateReviewHandler(t *testing.T) {
 				GetReviewFunc: func(ctx context.Context, reviewID string) (*models.Review, error) {
 					return &reviewUpdated, nil
 				},
-				UpdateReviewFunc: func(ctx context.Context, reviewID string, review *models.Review) error {
-					return nil
+				UpdateReviewFunc: func(ctx context.Context, review *models.Review, previous *models.Review) (*models.Review, error) {
+					updated := *review
+					updated.Revision = previous.Revision + 1
+					return &updated, nil
 				},
 			}
 			api := API{dataStore: &mockDataStore}
@@ -654,6 +835,7 @@ func TestUpdateReviewHandler(t *testing.T) {
 				"reviewID": reviewID1,
 			}
 			request = mux.SetURLVars(request, expectedUrlVars)
+			request = asCaller(request, reviewOwner, auth.Reviewer)
 			response := httptest.NewRecorder()
 			api.updateReviewHandler(response, request)
 			Convey("Then the HTTP response code is 200", func() {
@@ -662,6 +844,79 @@ func TestUpdateReviewHandler(t *testing.T) {
 				So(mockDataStore.GetReviewCalls(), ShouldHaveLength, 1)
 				So(mockDataStore.UpdateReviewCalls(), ShouldHaveLength, 1)
 			})
+			Convey("And the review is replaced, and the updated review is returned", func() {
+				call := mockDataStore.UpdateReviewCalls()[0]
+				So(call.Review.Message, ShouldEqual, reviewUpdate.Message)
+				So(call.Review.User, ShouldResemble, reviewUpdate.User)
+				So(call.Review.Rating, ShouldEqual, reviewUpdate.Rating)
+				So(call.Previous, ShouldEqual, &reviewUpdated)
+
+				var review models.Review
+				So(json.Unmarshal(response.Body.Bytes(), &review), ShouldBeNil)
+				So(review.ID, ShouldEqual, reviewID1)
+				So(review.Message, ShouldEqual, reviewUpdate.Message)
+				So(review.Rating, ShouldEqual, reviewUpdate.Rating)
+				So(response.Header().Get("ETag"), ShouldEqual, `"1"`)
+			})
+		})
+
+		Convey("When the replacement has no rating", func() {
+			previous := reviewUpdated
+			previous.Rating = 5
+			mockDataStore := mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return nil, nil
+				},
+				GetReviewFunc: func(ctx context.Context, reviewID string) (*models.Review, error) {
+					return &previous, nil
+				},
+				UpdateReviewFunc: func(ctx context.Context, review *models.Review, previous *models.Review) (*models.Review, error) {
+					return review, nil
+				},
+			}
+			api := API{dataStore: &mockDataStore}
+
+			body := strings.NewReader(reviewValid)
+			request := mux.SetURLVars(httptest.NewRequest("PUT", "/books/"+bookID1+"/reviews/"+reviewID1, body), map[string]string{
+				"id":       bookID1,
+				"reviewID": reviewID1,
+			})
+			request = asCaller(request, reviewOwner, auth.Reviewer)
+			response := httptest.NewRecorder()
+
+			api.updateReviewHandler(response, request)
+			Convey("Then the rating of the review is removed", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+				So(mockDataStore.UpdateReviewCalls()[0].Review.Rating, ShouldEqual, models.NoRating)
+			})
+		})
+
+		Convey("When the message of the replacement is too long", func() {
+			mockDataStore := mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return nil, nil
+				},
+				GetReviewFunc: func(ctx context.Context, reviewID string) (*models.Review, error) {
+					return &reviewUpdated, nil
+				},
+			}
+			api := API{dataStore: &mockDataStore}
+
+			replacement := reviewUpdate
+			replacement.Message = strings.Repeat("a", 201)
+			request := mux.SetURLVars(httptest.NewRequest("PUT", "/books/"+bookID1+"/reviews/"+reviewID1, strings.NewReader(marshalJSON(t, replacement))), map[string]string{
+				"id":       bookID1,
+				"reviewID": reviewID1,
+			})
+			request = asCaller(request, reviewOwner, auth.Reviewer)
+			response := httptest.NewRecorder()
+
+			api.updateReviewHandler(response, request)
+			Convey("Then the HTTP response code is 422, and the review is not updated", func() {
+				So(response.Code, ShouldEqual, http.StatusUnprocessableEntity)
+				So(readProblem(t, response).Detail, ShouldEqual, apierrors.ErrLongReviewMessage.Error())
+				So(mockDataStore.UpdateReviewCalls(), ShouldHaveLength, 0)
+			})
 		})
 
 		Convey("When the book and review exist, but the review update is not valid", func() {
@@ -670,7 +925,7 @@ func TestUpdateReviewHandler(t *testing.T) {
 					return nil, nil
 				},
 				GetReviewFunc: func(ctx context.Context, reviewID string) (*models.Review, error) {
-					return &models.Review{}, nil
+					return &models.Review{BookID: bookID1, Owner: reviewOwner}, nil
 				},
 			}
 			api := API{dataStore: &mockDataStore}
@@ -683,6 +938,7 @@ func TestUpdateReviewHandler(t *testing.T) {
 				"reviewID": reviewID1,
 			}
 			request = mux.SetURLVars(request, expectedUrlVars)
+			request = asCaller(request, reviewOwner, auth.Reviewer)
 			response := httptest.NewRecorder()
 
 			api.updateReviewHandler(response, request)
@@ -690,13 +946,77 @@ func TestUpdateReviewHandler(t *testing.T) {
 				So(response.Code, ShouldEqual, http.StatusBadRequest)
 			})
 			Convey("And it returns an error saying the review is invalid", func() {
-				So(response.Body.String(), ShouldEqual, "invalid review\n")
+				problem := readProblem(t, response)
+				So(problem.Code, ShouldEqual, "invalid_review")
+				So(problem.Detail, ShouldEqual, "invalid review")
 				So(mockDataStore.GetBookCalls(), ShouldHaveLength, 1)
 				So(mockDataStore.GetReviewCalls(), ShouldHaveLength, 1)
 				So(mockDataStore.UpdateReviewCalls(), ShouldHaveLength, 0)
 			})
 		})
 
+		Convey("When the book and review exist, but the rating of the update is out of range", func() {
+			mockDataStore := mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return nil, nil
+				},
+				GetReviewFunc: func(ctx context.Context, reviewID string) (*models.Review, error) {
+					return &reviewUpdated, nil
+				},
+			}
+			api := API{dataStore: &mockDataStore}
+
+			body := strings.NewReader(`{"message": "my review", "user": {"forenames": "name", "surname": "surname"}, "rating": -2}`)
+			request := httptest.NewRequest("PUT", "/books/"+bookID1+"/reviews"+reviewID1, body)
+
+			expectedUrlVars := map[string]string{
+				"id":       bookID1,
+				"reviewID": reviewID1,
+			}
+			request = mux.SetURLVars(request, expectedUrlVars)
+			request = asCaller(request, reviewOwner, auth.Reviewer)
+			response := httptest.NewRecorder()
+
+			api.updateReviewHandler(response, request)
+			Convey("Then the HTTP response code is 422", func() {
+				So(response.Code, ShouldEqual, http.StatusUnprocessableEntity)
+				So(readProblem(t, response).Detail, ShouldEqual, apierrors.ErrInvalidRating.Error())
+				So(mockDataStore.UpdateReviewCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the rating of the review is changed by another request", func() {
+			mockDataStore := mock.DataStoreMock{
+				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
+					return nil, nil
+				},
+				GetReviewFunc: func(ctx context.Context, reviewID string) (*models.Review, error) {
+					return &reviewUpdated, nil
+				},
+				UpdateReviewFunc: func(ctx context.Context, review *models.Review, previous *models.Review) (*models.Review, error) {
+					return nil, mongo.ErrReviewConflict
+				},
+			}
+			api := API{dataStore: &mockDataStore}
+
+			body := strings.NewReader(`{"message": "my review", "user": {"forenames": "name", "surname": "surname"}, "rating": 4}`)
+			request := httptest.NewRequest("PUT", "/books/"+bookID1+"/reviews"+reviewID1, body)
+
+			expectedUrlVars := map[string]string{
+				"id":       bookID1,
+				"reviewID": reviewID1,
+			}
+			request = mux.SetURLVars(request, expectedUrlVars)
+			request = asCaller(request, reviewOwner, auth.Reviewer)
+			response := httptest.NewRecorder()
+
+			api.updateReviewHandler(response, request)
+			Convey("Then the HTTP response code is 409", func() {
+				So(response.Code, ShouldEqual, http.StatusConflict)
+				So(mockDataStore.UpdateReviewCalls()[0].Review.Rating, ShouldEqual, 4)
+			})
+		})
+
 		Convey("When the book does not exist", func() {
 			mockDataStore := mock.DataStoreMock{
 				GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
@@ -713,6 +1033,7 @@ func TestUpdateReviewHandler(t *testing.T) {
 				"reviewID": reviewID1,
 			}
 			request = mux.SetURLVars(request, expectedUrlVars)
+			request = asCaller(request, reviewOwner, auth.Reviewer)
 			response := httptest.NewRecorder()
 
 			api.updateReviewHandler(response, request)
@@ -720,7 +1041,7 @@ func TestUpdateReviewHandler(t *testing.T) {
 				So(response.Code, ShouldEqual, http.StatusNotFound)
 			})
 			Convey("And it returns an error saying the book was not found", func() {
-				So(response.Body.String(), ShouldEqual, "book not found\n")
+				So(readProblem(t, response).Detail, ShouldEqual, "book not found")
 				So(mockDataStore.GetBookCalls(), ShouldHaveLength, 1)
 				So(mockDataStore.GetReviewCalls(), ShouldHaveLength, 0)
 				So(mockDataStore.UpdateReviewCalls(), ShouldHaveLength, 0)
@@ -746,6 +1067,7 @@ func TestUpdateReviewHandler(t *testing.T) {
 				"reviewID": reviewID1,
 			}
 			request = mux.SetURLVars(request, expectedUrlVars)
+			request = asCaller(request, reviewOwner, auth.Reviewer)
 			response := httptest.NewRecorder()
 
 			api.updateReviewHandler(response, request)
@@ -753,7 +1075,7 @@ func TestUpdateReviewHandler(t *testing.T) {
 				So(response.Code, ShouldEqual, http.StatusNotFound)
 			})
 			Convey("And it returns an error saying the review was not found", func() {
-				So(response.Body.String(), ShouldEqual, "review not found\n")
+				So(readProblem(t, response).Detail, ShouldEqual, "review not found")
 				So(mockDataStore.GetBookCalls(), ShouldHaveLength, 1)
 				So(mockDataStore.GetReviewCalls(), ShouldHaveLength, 1)
 				So(mockDataStore.UpdateReviewCalls(), ShouldHaveLength, 0)
@@ -772,6 +1094,7 @@ func TestUpdateReviewHandler(t *testing.T) {
 				"reviewID": reviewID1,
 			}
 			request = mux.SetURLVars(request, expectedUrlVars)
+			request = asCaller(request, reviewOwner, auth.Reviewer)
 			response := httptest.NewRecorder()
 
 			api.updateReviewHandler(response, request)
@@ -779,7 +1102,7 @@ func TestUpdateReviewHandler(t *testing.T) {
 				So(response.Code, ShouldEqual, http.StatusBadRequest)
 			})
 			Convey("And it returns an error saying the book ID is empty", func() {
-				So(response.Body.String(), ShouldEqual, "empty book ID in request\n")
+				So(readProblem(t, response).Detail, ShouldEqual, "empty book ID in request")
 				So(mockDataStore.GetBookCalls(), ShouldHaveLength, 0)
 				So(mockDataStore.GetReviewCalls(), ShouldHaveLength, 0)
 				So(mockDataStore.UpdateReviewCalls(), ShouldHaveLength, 0)
@@ -798,6 +1121,7 @@ func TestUpdateReviewHandler(t *testing.T) {
 				"reviewID": emptyID,
 			}
 			request = mux.SetURLVars(request, expectedUrlVars)
+			request = asCaller(request, reviewOwner, auth.Reviewer)
 			response := httptest.NewRecorder()
 
 			api.updateReviewHandler(response, request)
@@ -805,7 +1129,7 @@ func TestUpdateReviewHandler(t *testing.T) {
 				So(response.Code, ShouldEqual, http.StatusBadRequest)
 			})
 			Convey("And it returns an error saying the review ID is empty", func() {
-				So(response.Body.String(), ShouldEqual, "empty review ID in request\n")
+				So(readProblem(t, response).Detail, ShouldEqual, "empty review ID in request")
 				So(mockDataStore.GetBookCalls(), ShouldHaveLength, 0)
 				So(mockDataStore.GetReviewCalls(), ShouldHaveLength, 0)
 				So(mockDataStore.UpdateReviewCalls(), ShouldHaveLength, 0)
@@ -828,6 +1152,7 @@ func TestUpdateReviewHandler(t *testing.T) {
 				"reviewID": reviewID1,
 			}
 			request = mux.SetURLVars(request, expectedUrlVars)
+			request = asCaller(request, reviewOwner, auth.Reviewer)
 			response := httptest.NewRecorder()
 
 			api.updateReviewHandler(response, request)
@@ -835,7 +1160,7 @@ func TestUpdateReviewHandler(t *testing.T) {
 				So(response.Code, ShouldEqual, http.StatusInternalServerError)
 			})
 			Convey("And it returns an internal server error", func() {
-				So(response.Body.String(), ShouldEqual, "internal server error\n")
+				So(readProblem(t, response).Detail, ShouldEqual, "internal server error")
 				So(mockDataStore.GetBookCalls(), ShouldHaveLength, 1)
 				So(mockDataStore.GetReviewCalls(), ShouldHaveLength, 0)
 				So(mockDataStore.UpdateReviewCalls(), ShouldHaveLength, 0)
@@ -861,6 +1186,7 @@ func TestUpdateReviewHandler(t *testing.T) {
 				"reviewID": reviewID1,
 			}
 			request = mux.SetURLVars(request, expectedUrlVars)
+			request = asCaller(request, reviewOwner, auth.Reviewer)
 			response := httptest.NewRecorder()
 
 			api.updateReviewHandler(response, request)
@@ -868,7 +1194,7 @@ func TestUpdateReviewHandler(t *testing.T) {
 				So(response.Code, ShouldEqual, http.StatusInternalServerError)
 			})
 			Convey("And it returns an internal server error", func() {
-				So(response.Body.String(), ShouldEqual, "internal server error\n")
+				So(readProblem(t, response).Detail, ShouldEqual, "internal server error")
 				So(mockDataStore.GetBookCalls(), ShouldHaveLength, 1)
 				So(mockDataStore.GetReviewCalls(), ShouldHaveLength, 1)
 				So(mockDataStore.UpdateReviewCalls(), ShouldHaveLength, 0)
@@ -876,3 +1202,184 @@ func TestUpdateReviewHandler(t *testing.T) {
 		})
 	})
 }
+
+func TestPatchReviewHandler(t *testing.T) {
+	t.Parallel()
+
+	patchRequest := func(patch string) *http.Request {
+		request := httptest.NewRequest(http.MethodPatch, "/books/"+bookID1+"/reviews/"+reviewID1, strings.NewReader(patch))
+		request = mux.SetURLVars(request, map[string]string{
+			"id":       bookID1,
+			"reviewID": reviewID1,
+		})
+		return asCaller(request, reviewOwner, auth.Reviewer)
+	}
+
+	Convey("Given an HTTP PATCH request to the /books/{id}/reviews/{reviewID} endpoint", t, func() {
+		mockDataStore := moderatedMockDataStore(models.ReviewApproved)
+		mockDataStore.GetReviewFunc = func(ctx context.Context, reviewID string) (*models.Review, error) {
+			review := bookReview1
+			review.Owner = reviewOwner
+			review.Message = "a review"
+			review.Rating = 3
+			return &review, nil
+		}
+		mockDataStore.UpdateReviewFunc = func(ctx context.Context, review *models.Review, previous *models.Review) (*models.Review, error) {
+			updated := *review
+			updated.State = models.ReviewPending
+			updated.Revision = previous.Revision + 1
+			return &updated, nil
+		}
+		api := &API{dataStore: mockDataStore}
+
+		Convey("When the patch changes the message of the review", func() {
+			response := httptest.NewRecorder()
+			api.patchReviewHandler(response, patchRequest(`{"message": "a better review"}`))
+
+			Convey("Then the HTTP response code is 200", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+			})
+			Convey("And only the message is changed", func() {
+				So(mockDataStore.UpdateReviewCalls(), ShouldHaveLength, 1)
+				call := mockDataStore.UpdateReviewCalls()[0]
+				So(call.Review.Message, ShouldEqual, "a better review")
+				So(call.Review.Rating, ShouldEqual, 3)
+				So(call.Review.User, ShouldResemble, bookReview1.User)
+				So(call.Previous.Message, ShouldEqual, "a review")
+			})
+			Convey("And the updated review is returned", func() {
+				var review models.Review
+				So(json.Unmarshal(response.Body.Bytes(), &review), ShouldBeNil)
+				So(review.Message, ShouldEqual, "a better review")
+				So(review.State, ShouldEqual, models.ReviewPending)
+				So(response.Header().Get("ETag"), ShouldEqual, `"1"`)
+			})
+		})
+
+		Convey("When the patch removes the rating of the review", func() {
+			response := httptest.NewRecorder()
+			api.patchReviewHandler(response, patchRequest(`{"message": "a review", "rating": null}`))
+
+			Convey("Then the review is updated without a rating", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+				So(mockDataStore.UpdateReviewCalls()[0].Review.Rating, ShouldEqual, models.NoRating)
+			})
+		})
+
+		Convey("When the patch changes a field that cannot be patched", func() {
+			response := httptest.NewRecorder()
+			api.patchReviewHandler(response, patchRequest(`{"message": "a review", "owner": "someone else"}`))
+
+			Convey("Then the HTTP response code is 422, and the review is not updated", func() {
+				So(response.Code, ShouldEqual, http.StatusUnprocessableEntity)
+				So(readProblem(t, response).Code, ShouldEqual, "invalid_review_patch")
+				So(mockDataStore.UpdateReviewCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the patch is not valid JSON", func() {
+			response := httptest.NewRecorder()
+			api.patchReviewHandler(response, patchRequest(reviewInvalidUpdate))
+
+			Convey("Then the HTTP response code is 400, with the same code as a replacement that is not valid JSON", func() {
+				So(response.Code, ShouldEqual, http.StatusBadRequest)
+				So(readProblem(t, response).Code, ShouldEqual, "invalid_review")
+				So(mockDataStore.UpdateReviewCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the patched review is not valid", func() {
+			response := httptest.NewRecorder()
+			api.patchReviewHandler(response, patchRequest(`{"message": "`+strings.Repeat("a", 201)+`"}`))
+
+			Convey("Then the HTTP response code is 422, and the review is not updated", func() {
+				So(response.Code, ShouldEqual, http.StatusUnprocessableEntity)
+				So(readProblem(t, response).Detail, ShouldEqual, apierrors.ErrLongReviewMessage.Error())
+				So(mockDataStore.UpdateReviewCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the patch is empty", func() {
+			response := httptest.NewRecorder()
+			api.patchReviewHandler(response, patchRequest(`{}`))
+
+			Convey("Then the review is returned as it is, without being updated", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+				So(response.Header().Get("ETag"), ShouldEqual, `"0"`)
+				So(mockDataStore.UpdateReviewCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the caller does not own the review", func() {
+			request := asCaller(patchRequest(`{"message": "a review"}`), "another.reader", auth.Reviewer)
+			response := httptest.NewRecorder()
+			api.patchReviewHandler(response, request)
+
+			Convey("Then the HTTP response code is 403", func() {
+				So(response.Code, ShouldEqual, http.StatusForbidden)
+				So(mockDataStore.UpdateReviewCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+}
+
+func TestDeleteReviewHandler(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given an HTTP DELETE request to the /books/{id}/reviews/{reviewID} endpoint", t, func() {
+
+		Convey("When the review exists", func() {
+			mockDataStore := moderatedMockDataStore(models.ReviewApproved)
+			mockDataStore.DeleteReviewFunc = func(ctx context.Context, review *models.Review) error {
+				return nil
+			}
+			api := &API{dataStore: mockDataStore}
+
+			request := asCaller(reviewRequest(http.MethodDelete, "/books/"+bookID1+"/reviews/"+reviewID1, bookID1, reviewID1), reviewOwner, auth.Reviewer)
+			response := httptest.NewRecorder()
+
+			api.deleteReviewHandler(response, request)
+			Convey("Then the HTTP response code is 204", func() {
+				So(response.Code, ShouldEqual, http.StatusNoContent)
+			})
+			Convey("And the review is deleted as it was read, so that its rating stops counting", func() {
+				So(mockDataStore.DeleteReviewCalls(), ShouldHaveLength, 1)
+				So(mockDataStore.DeleteReviewCalls()[0].Review.ID, ShouldEqual, reviewID1)
+				So(mockDataStore.DeleteReviewCalls()[0].Review.Rating, ShouldEqual, 3)
+			})
+		})
+
+		Convey("When the review does not exist", func() {
+			mockDataStore := moderatedMockDataStore(models.ReviewApproved)
+			mockDataStore.GetReviewFunc = func(ctx context.Context, reviewID string) (*models.Review, error) {
+				return nil, mongo.ErrReviewNotFound
+			}
+			api := &API{dataStore: mockDataStore}
+
+			request := asCaller(reviewRequest(http.MethodDelete, "/books/"+bookID1+"/reviews/"+reviewID1, bookID1, reviewID1), reviewOwner, auth.Reviewer)
+			response := httptest.NewRecorder()
+
+			api.deleteReviewHandler(response, request)
+			Convey("Then the HTTP response code is 404", func() {
+				So(response.Code, ShouldEqual, http.StatusNotFound)
+				So(mockDataStore.DeleteReviewCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the review is modified by another request", func() {
+			mockDataStore := moderatedMockDataStore(models.ReviewApproved)
+			mockDataStore.DeleteReviewFunc = func(ctx context.Context, review *models.Review) error {
+				return mongo.ErrReviewConflict
+			}
+			api := &API{dataStore: mockDataStore}
+
+			request := asCaller(reviewRequest(http.MethodDelete, "/books/"+bookID1+"/reviews/"+reviewID1, bookID1, reviewID1), reviewOwner, auth.Reviewer)
+			response := httptest.NewRecorder()
+
+			api.deleteReviewHandler(response, request)
+			Convey("Then the HTTP response code is 409", func() {
+				So(response.Code, ShouldEqual, http.StatusConflict)
+			})
+		})
+	})
+}
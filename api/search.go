@@ -0,0 +1,54 @@
+package api
+
+import (
+	"github.com/ONSdigital/log.go/log"
+	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/pagination"
+	"net/http"
+	"strings"
+)
+
+func (api *API) searchBooksHandler(writer http.ResponseWriter, request *http.Request) {
+	ctx := request.Context()
+
+	query := strings.TrimSpace(request.URL.Query().Get("q"))
+	logData := log.Data{"query": query}
+	if query == "" {
+		handleError(ctx, writer, apierrors.NewValidationError(apierrors.ErrEmptySearchQuery, apierrors.FieldError{Field: "q", Rule: apierrors.RuleRequired}), logData)
+		return
+	}
+
+	offset, limit, err := api.paginator.GetPaginationValues(request)
+	logData["offset"] = offset
+	logData["limit"] = limit
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	results, totalCount, err := api.searcher.SearchBooks(ctx, query, offset, limit)
+	if err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+
+	for i := range results {
+		results[i].SetLinks(api.baseURL)
+	}
+	response := models.SearchResponse{
+		Items: results,
+		Page: pagination.Page{
+			Count:      len(results),
+			Offset:     offset,
+			Limit:      limit,
+			TotalCount: totalCount,
+		},
+	}
+
+	if err := WriteJSONBody(response, writer, http.StatusOK); err != nil {
+		handleError(ctx, writer, err, logData)
+		return
+	}
+	log.Event(ctx, "successfully searched books", log.INFO, logData)
+}
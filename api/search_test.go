@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/interfaces/mock"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/search"
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSearchBooksHandler(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given an HTTP GET request to the /books/search endpoint", t, func() {
+		index := search.NewIndex()
+		index.Add(book1)
+		index.Add(models.Book{ID: bookID2, Title: "Kindred", Author: "Octavia E. Butler", Synopsis: "A woman travels back in time"})
+
+		Convey("When the query matches some books", func() {
+			api := &API{searcher: index, paginator: mockPaginator()}
+			request := httptest.NewRequest(http.MethodGet, "/books/search?q=woman", nil)
+			response := httptest.NewRecorder()
+
+			api.searchBooksHandler(response, request)
+			Convey("Then the HTTP response code is 200", func() {
+				So(response.Code, ShouldEqual, http.StatusOK)
+			})
+			Convey("And the matching books are returned, most relevant first, with a score and highlights", func() {
+				payload, err := ioutil.ReadAll(response.Body)
+				So(err, ShouldBeNil)
+				results := models.SearchResponse{}
+				So(json.Unmarshal(payload, &results), ShouldBeNil)
+				So(results.TotalCount, ShouldEqual, 2)
+				So(results.Count, ShouldEqual, 2)
+				So(results.Limit, ShouldEqual, limit)
+				So(results.Items[0].ID, ShouldEqual, bookID1)
+				So(results.Items[0].Score, ShouldBeGreaterThan, results.Items[1].Score)
+				So(results.Items[0].Highlights["title"], ShouldEqual, "Girl, <em>Woman</em>, Other")
+				So(results.Items[1].Highlights["synopsis"], ShouldEqual, "A <em>woman</em> travels back in time")
+			})
+		})
+
+		Convey("When the query is empty", func() {
+			searcher := &mock.SearcherMock{}
+			api := &API{searcher: searcher, paginator: mockPaginator()}
+			request := httptest.NewRequest(http.MethodGet, "/books/search?q=%20", nil)
+			response := httptest.NewRecorder()
+
+			api.searchBooksHandler(response, request)
+			Convey("Then the HTTP response code is 400", func() {
+				So(response.Code, ShouldEqual, http.StatusBadRequest)
+				So(response.Body.String(), ShouldContainSubstring, apierrors.ErrEmptySearchQuery.Error())
+			})
+			Convey("And the SearchBooks function is not called", func() {
+				So(searcher.SearchBooksCalls(), ShouldHaveLength, 0)
+			})
+		})
+
+		Convey("When the search fails", func() {
+			searcher := &mock.SearcherMock{
+				SearchBooksFunc: func(ctx context.Context, query string, offset, limit int) ([]models.SearchResult, int, error) {
+					return nil, 0, errMongoDB
+				},
+			}
+			api := &API{searcher: searcher, paginator: mockPaginator()}
+			request := httptest.NewRequest(http.MethodGet, "/books/search?q=woman", nil)
+			response := httptest.NewRecorder()
+
+			api.searchBooksHandler(response, request)
+			Convey("Then the HTTP response code is 500", func() {
+				So(response.Code, ShouldEqual, http.StatusInternalServerError)
+			})
+		})
+	})
+}
@@ -6,15 +6,36 @@ import (
 
 // Error messages for the books-api
 var (
-	ErrInvalidReview        = errors.New("invalid review")
-	ErrEmptyReviewMessage   = errors.New("empty review provided. Please enter a message")
-	ErrEmptyReviewUser      = errors.New("empty forenames/surname provided. Please enter a valid user")
-	ErrLongReviewMessage    = errors.New("review message is too long")
-	ErrEmptyRequestBody     = errors.New("empty request body")
-	ErrEmptyBookID          = errors.New("empty book ID in request")
-	ErrEmptyReviewID        = errors.New("empty review ID in request")
-	ErrUnableToReadMessage  = errors.New("failed to read request body")
-	ErrUnableToParseJSON    = errors.New("failed to parse json body")
-	ErrRequiredFieldMissing = errors.New("invalid book. Missing required field")
-	ErrInternalServer       = errors.New("internal server error")
+	ErrInvalidReview           = errors.New("invalid review")
+	ErrEmptyReviewMessage      = errors.New("empty review provided. Please enter a message")
+	ErrEmptyReviewUser         = errors.New("empty forenames/surname provided. Please enter a valid user")
+	ErrLongReviewMessage       = errors.New("review message is too long")
+	ErrInvalidRating           = errors.New("invalid rating. The rating must be a whole number of stars from 1 to 5")
+	ErrEmptyRequestBody        = errors.New("empty request body")
+	ErrEmptyBookID             = errors.New("empty book ID in request")
+	ErrEmptyReviewID           = errors.New("empty review ID in request")
+	ErrUnableToReadMessage     = errors.New("failed to read request body")
+	ErrUnableToParseJSON       = errors.New("failed to parse json body")
+	ErrRequiredFieldMissing    = errors.New("invalid book. Missing required field")
+	ErrInvalidISBN             = errors.New("invalid ISBN. Please provide a valid ISBN-10 or ISBN-13")
+	ErrInvalidBook             = errors.New("invalid book. Some fields do not have a valid value")
+	ErrEmptyAuthorID           = errors.New("empty author ID in request")
+	ErrEmptyAuthorName         = errors.New("invalid author. Please enter the name of the author")
+	ErrUnknownAuthor           = errors.New("invalid book. The authors must be added before they are referenced by a book")
+	ErrInvalidPatch            = errors.New("invalid patch. Only the title, authors, synopsis and catalogue metadata can be patched, with values of their type")
+	ErrInvalidReviewPatch      = errors.New("invalid patch. Only the message, user and rating of a review can be patched, with values of their type")
+	ErrEmptyReservationID      = errors.New("empty reservation ID in request")
+	ErrInvalidReservation      = errors.New("invalid reservation")
+	ErrEmptyReservationUser    = errors.New("empty forenames/surname provided. Please enter the user making the reservation")
+	ErrInvalidReservationState = errors.New("the reservation cannot change to the requested state")
+	ErrInvalidReviewState      = errors.New("the review cannot change to the requested moderation state")
+	ErrEmptySearchQuery        = errors.New("empty search query. Please provide the words to search in the q query parameter")
+	ErrInvalidExportParameter  = errors.New("invalid export query parameter. The format must be ndjson or csv, reviews and gzip must be true or false, and reviews can only be exported as ndjson")
+	ErrPreconditionFailed      = errors.New("the resource has changed since it was read. Get it again, and use its new ETag in the If-Match header")
+	ErrPreconditionRequired    = errors.New("the If-Match header is required. Use the ETag of the resource that was read")
+	ErrInvalidIdempotencyKey   = errors.New("invalid Idempotency-Key header. The key must be at most 255 characters long")
+	ErrIdempotencyKeyInUse     = errors.New("a request with the same Idempotency-Key is in progress. Retry it once the request has completed")
+	ErrIdempotencyKeyReused    = errors.New("the Idempotency-Key has already been used for a different request. Use a new key for each request")
+	ErrImportTooLarge          = errors.New("the import file is too large. Split it into smaller files, and import each of them")
+	ErrInternalServer          = errors.New("internal server error")
 )
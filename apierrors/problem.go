@@ -0,0 +1,93 @@
+package apierrors
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+)
+
+// ProblemContentType is the media type of the body of error responses (RFC 7807)
+const ProblemContentType = "application/problem+json"
+
+// A Problem is the body of an error response, as described by RFC 7807.
+// The Code identifies the error, and is stable across releases, whereas the Detail is meant for humans and can change.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// A Registry maps errors to the status and code of the problem that they cause.
+// Errors are matched with errors.Is or errors.As, so they can be wrapped with more context.
+type Registry struct {
+	entries []entry
+	unknown entry
+}
+
+type entry struct {
+	matches func(err error) bool
+	status  int
+	code    string
+}
+
+// NewRegistry returns a Registry in which errors that have not been registered are internal server errors
+func NewRegistry() *Registry {
+	return &Registry{unknown: entry{status: http.StatusInternalServerError, code: "internal_error"}}
+}
+
+// Register maps the errors that match target with errors.Is to the status and code
+func (r *Registry) Register(target error, status int, code string) {
+	r.entries = append(r.entries, entry{
+		matches: func(err error) bool { return errors.Is(err, target) },
+		status:  status,
+		code:    code,
+	})
+}
+
+// RegisterType maps the errors that match the type of target with errors.As to the status and code.
+// The target is a value of the error type, e.g. (*ValidationError)(nil).
+func (r *Registry) RegisterType(target error, status int, code string) {
+	errorType := reflect.TypeOf(target)
+	r.entries = append(r.entries, entry{
+		matches: func(err error) bool { return errors.As(err, reflect.New(errorType).Interface()) },
+		status:  status,
+		code:    code,
+	})
+}
+
+// Problem returns the problem caused by an error. The first registered error that matches is used, so specific errors
+// must be registered before the more general ones. The details of errors that have not been registered, or that are
+// server errors, are hidden behind ErrInternalServer.
+func (r *Registry) Problem(err error) *Problem {
+	match := r.unknown
+	for _, e := range r.entries {
+		if e.matches(err) {
+			match = e
+			break
+		}
+	}
+
+	problem := &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(match.status),
+		Status: match.status,
+		Detail: err.Error(),
+		Code:   match.code,
+	}
+
+	if match.status >= http.StatusInternalServerError {
+		problem.Detail = ErrInternalServer.Error()
+		return problem
+	}
+
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		problem.Errors = validationErr.Fields
+	}
+
+	return problem
+}
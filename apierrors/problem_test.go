@@ -0,0 +1,73 @@
+package apierrors
+
+import (
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"testing"
+)
+
+var (
+	errNotFound = errors.New("not found")
+	errTeapot   = errors.New("teapot")
+)
+
+type customError struct{}
+
+func (customError) Error() string { return "custom error" }
+
+func TestRegistry_Problem(t *testing.T) {
+	Convey("Given a registry of errors", t, func() {
+		registry := NewRegistry()
+		registry.Register(errNotFound, http.StatusNotFound, "not_found")
+		registry.RegisterType(customError{}, http.StatusTeapot, "custom")
+		registry.Register(errTeapot, http.StatusTeapot, "teapot")
+
+		Convey("When the problem of a registered error is returned", func() {
+			problem := registry.Problem(errNotFound)
+
+			Convey("Then it has the status and code of the error", func() {
+				So(problem.Status, ShouldEqual, http.StatusNotFound)
+				So(problem.Title, ShouldEqual, "Not Found")
+				So(problem.Code, ShouldEqual, "not_found")
+				So(problem.Detail, ShouldEqual, "not found")
+			})
+		})
+
+		Convey("When the problem of a wrapped error is returned", func() {
+			problem := registry.Problem(errors.Wrap(errNotFound, "failed to get a book"))
+
+			Convey("Then the wrapped error is matched", func() {
+				So(problem.Code, ShouldEqual, "not_found")
+				So(problem.Detail, ShouldEqual, "failed to get a book: not found")
+			})
+		})
+
+		Convey("When the problem of an error of a registered type is returned", func() {
+			problem := registry.Problem(errors.WithMessage(customError{}, "failed"))
+
+			Convey("Then the error is matched by its type", func() {
+				So(problem.Code, ShouldEqual, "custom")
+			})
+		})
+
+		Convey("When the problem of a validation error is returned", func() {
+			problem := registry.Problem(NewValidationError(errTeapot, FieldError{Field: "spout", Rule: RuleRequired}))
+
+			Convey("Then it has the code of the wrapped error and the invalid fields", func() {
+				So(problem.Code, ShouldEqual, "teapot")
+				So(problem.Errors, ShouldResemble, []FieldError{{Field: "spout", Rule: RuleRequired}})
+			})
+		})
+
+		Convey("When the problem of an unknown error is returned", func() {
+			problem := registry.Problem(errors.New("connection refused"))
+
+			Convey("Then it is an internal server error, without details", func() {
+				So(problem.Status, ShouldEqual, http.StatusInternalServerError)
+				So(problem.Code, ShouldEqual, "internal_error")
+				So(problem.Detail, ShouldEqual, ErrInternalServer.Error())
+			})
+		})
+	})
+}
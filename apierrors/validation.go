@@ -0,0 +1,39 @@
+package apierrors
+
+// Validation rules broken by the fields of a request
+const (
+	RuleRequired     = "required"
+	RuleMaxLength    = "max_length"
+	RuleRange        = "range"
+	RuleFormat       = "format"
+	RuleChecksum     = "checksum"
+	RuleExists       = "exists"
+	RuleType         = "type"
+	RuleNotPatchable = "not_patchable"
+)
+
+// A FieldError identifies a field of a request that is not valid, and the rule that it breaks
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+}
+
+// A ValidationError is returned when fields of a request are not valid.
+// It wraps the error that describes the problem as a whole, so that it can still be matched with errors.Is.
+type ValidationError struct {
+	Err    error
+	Fields []FieldError
+}
+
+// NewValidationError returns a ValidationError for the fields that break their rules
+func NewValidationError(err error, fields ...FieldError) *ValidationError {
+	return &ValidationError{Err: err, Fields: fields}
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io/ioutil"
+)
+
+// apiKey is a static API key. Only the SHA-256 hash of the key is configured, so the file does not contain any secret.
+type apiKey struct {
+	Name      string   `json:"name"`
+	KeySHA256 string   `json:"key_sha256"`
+	Roles     []string `json:"roles"`
+	hash      []byte
+}
+
+// loadAPIKeys reads the API keys of a JSON file, which is a list of {"name", "key_sha256", "roles"} objects
+func loadAPIKeys(path string) ([]apiKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the API keys file")
+	}
+
+	var keys []apiKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the API keys file")
+	}
+
+	for i := range keys {
+		if keys[i].Name == "" {
+			return nil, errors.Errorf("API key %d has no name", i)
+		}
+		hash, err := hex.DecodeString(keys[i].KeySHA256)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, errors.Errorf("API key %q has an invalid key_sha256", keys[i].Name)
+		}
+		keys[i].hash = hash
+	}
+
+	return keys, nil
+}
+
+// matches returns true if the key is the API key, in constant time
+func (k apiKey) matches(key string) bool {
+	hash := sha256.Sum256([]byte(key))
+	return subtle.ConstantTimeCompare(hash[:], k.hash) == 1
+}
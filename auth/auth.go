@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"github.com/pkg/errors"
+)
+
+// A Role grants a set of permissions to the caller of the API
+type Role string
+
+// Roles of the callers of the API
+const (
+	// Reader can reserve books. Every caller with a role is a reader.
+	Reader Role = "reader"
+
+	// Reviewer can add reviews, and update or delete the reviews they wrote
+	Reviewer Role = "reviewer"
+
+	// Librarian can add, update and delete books, and manage their reservations
+	Librarian Role = "librarian"
+
+	// Admin can do anything, including moderating reviews
+	Admin Role = "admin"
+)
+
+// Roles are all the known roles. Any other role in the credentials of a caller is ignored.
+var Roles = []Role{Reader, Reviewer, Librarian, Admin}
+
+// Errors returned when authenticating or authorising a request
+var (
+	ErrMissingCredentials = errors.New("authentication required. Please provide a bearer token or an API key")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrForbidden          = errors.New("forbidden. The caller is not allowed to perform this request")
+)
+
+// An Identity is the authenticated caller of a request
+type Identity struct {
+	Subject string
+	Roles   []Role
+}
+
+// HasRole returns true if the Identity has the given role.
+// An admin has every role, and anyone with a role is a reader.
+func (i *Identity) HasRole(role Role) bool {
+	for _, r := range i.Roles {
+		if r == role || r == Admin || role == Reader {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAnyRole returns true if the Identity has at least one of the given roles
+func (i *Identity) HasAnyRole(roles ...Role) bool {
+	for _, role := range roles {
+		if i.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// knownRoles returns the known roles among the given role names, ignoring any other name
+func knownRoles(names []string) []Role {
+	roles := []Role{}
+	for _, name := range names {
+		for _, role := range Roles {
+			if Role(name) == role {
+				roles = append(roles, role)
+			}
+		}
+	}
+	return roles
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of the context that carries the Identity of the caller
+func NewContext(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// FromContext returns the Identity of the caller carried by the context, if the caller has been authenticated
+func FromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(contextKey{}).(*Identity)
+	return identity, ok && identity != nil
+}
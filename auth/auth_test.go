@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"context"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestIdentity_HasRole(t *testing.T) {
+	Convey("Given a reviewer", t, func() {
+		identity := &Identity{Subject: "avid.reader", Roles: []Role{Reviewer}}
+
+		Convey("Then they are a reviewer and a reader, but not a librarian nor an admin", func() {
+			So(identity.HasRole(Reviewer), ShouldBeTrue)
+			So(identity.HasRole(Reader), ShouldBeTrue)
+			So(identity.HasRole(Librarian), ShouldBeFalse)
+			So(identity.HasRole(Admin), ShouldBeFalse)
+			So(identity.HasAnyRole(Librarian, Reviewer), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given an admin", t, func() {
+		identity := &Identity{Subject: "moderator", Roles: []Role{Admin}}
+
+		Convey("Then they have every role", func() {
+			for _, role := range Roles {
+				So(identity.HasRole(role), ShouldBeTrue)
+			}
+		})
+	})
+
+	Convey("Given a caller without any role", t, func() {
+		identity := &Identity{Subject: "nobody", Roles: []Role{}}
+
+		Convey("Then they are not even a reader", func() {
+			So(identity.HasRole(Reader), ShouldBeFalse)
+		})
+	})
+}
+
+func TestContext(t *testing.T) {
+	Convey("Given a context without an identity", t, func() {
+		ctx := context.Background()
+
+		Convey("Then no identity is found", func() {
+			_, ok := FromContext(ctx)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("When an identity is added to the context", func() {
+			identity := &Identity{Subject: "avid.reader"}
+			ctx = NewContext(ctx, identity)
+
+			Convey("Then the identity is found", func() {
+				found, ok := FromContext(ctx)
+				So(ok, ShouldBeTrue)
+				So(found, ShouldEqual, identity)
+			})
+		})
+	})
+}
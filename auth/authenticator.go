@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"crypto"
+	"github.com/cadmiumcat/books-api/config"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+	"net/http"
+	"strings"
+)
+
+// APIKeyHeader is the header of a request that carries a static API key
+const APIKeyHeader = "X-API-Key"
+
+const bearerPrefix = "Bearer "
+
+var (
+	hmacMethods      = []string{"HS256", "HS384", "HS512"}
+	publicKeyMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+)
+
+// An Authenticator identifies the caller of a request from a bearer JWT, or from a static API key.
+// Tokens are verified against a HMAC secret or against the public keys of a local JWKS file, whichever is configured.
+type Authenticator struct {
+	hmacSecret []byte
+	publicKeys map[string]crypto.PublicKey
+	methods    []string
+	issuer     string
+	audience   string
+	rolesClaim string
+	apiKeys    []apiKey
+}
+
+// NewAuthenticator returns an Authenticator configured with the given secret, JWKS file and API keys file.
+// It returns an error if a file cannot be read or is not valid.
+func NewAuthenticator(cfg config.AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{
+		issuer:     cfg.Issuer,
+		audience:   cfg.Audience,
+		rolesClaim: cfg.RolesClaim,
+	}
+
+	if cfg.HMACSecret != "" {
+		a.hmacSecret = []byte(cfg.HMACSecret)
+		a.methods = append(a.methods, hmacMethods...)
+	}
+
+	if cfg.JWKSFile != "" {
+		keys, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		a.publicKeys = keys
+		a.methods = append(a.methods, publicKeyMethods...)
+	}
+
+	if cfg.APIKeysFile != "" {
+		keys, err := loadAPIKeys(cfg.APIKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		a.apiKeys = keys
+	}
+
+	return a, nil
+}
+
+// Authenticate returns the Identity of the caller of the request.
+// It returns ErrMissingCredentials if the request has no credentials, and ErrInvalidCredentials if they cannot be verified.
+func (a *Authenticator) Authenticate(r *http.Request) (*Identity, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return a.authenticateAPIKey(key)
+	}
+
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		return nil, ErrMissingCredentials
+	}
+
+	if !strings.HasPrefix(authorization, bearerPrefix) {
+		return nil, ErrInvalidCredentials
+	}
+
+	return a.authenticateToken(strings.TrimSpace(strings.TrimPrefix(authorization, bearerPrefix)))
+}
+
+func (a *Authenticator) authenticateAPIKey(key string) (*Identity, error) {
+	for _, k := range a.apiKeys {
+		if k.matches(key) {
+			return &Identity{Subject: k.Name, Roles: knownRoles(k.Roles)}, nil
+		}
+	}
+	return nil, ErrInvalidCredentials
+}
+
+func (a *Authenticator) authenticateToken(raw string) (*Identity, error) {
+	if len(a.methods) == 0 {
+		return nil, ErrInvalidCredentials
+	}
+
+	claims := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods(a.methods))
+	if _, err := parser.ParseWithClaims(raw, claims, a.key); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	// Tokens that never expire are not accepted
+	if _, ok := claims["exp"]; !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	if a.issuer != "" && !claims.VerifyIssuer(a.issuer, true) {
+		return nil, ErrInvalidCredentials
+	}
+
+	if a.audience != "" && !claims.VerifyAudience(a.audience, true) {
+		return nil, ErrInvalidCredentials
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &Identity{Subject: subject, Roles: knownRoles(stringsClaim(claims[a.rolesClaim]))}, nil
+}
+
+// key returns the key that verifies the signature of a token. HMAC tokens are verified with the secret, and other tokens
+// with the public key of the JWKS identified by the kid header. A JWKS with a single key does not need the kid header.
+func (a *Authenticator) key(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		return a.hmacSecret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" && len(a.publicKeys) == 1 {
+		for _, key := range a.publicKeys {
+			return key, nil
+		}
+	}
+
+	key, ok := a.publicKeys[kid]
+	if !ok {
+		return nil, errors.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+// stringsClaim returns the strings of a claim that is either a list of strings or a space separated string
+func stringsClaim(claim interface{}) []string {
+	switch value := claim.(type) {
+	case string:
+		return strings.Fields(value)
+	case []interface{}:
+		values := []string{}
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
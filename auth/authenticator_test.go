@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/cadmiumcat/books-api/config"
+	"github.com/golang-jwt/jwt/v4"
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"math/big"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const hmacSecret = "a-secret-that-is-long-enough-for-hs256"
+
+func writeFile(t *testing.T, name string, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return path
+}
+
+func encodeBigInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func authenticate(t *testing.T, a *Authenticator, header, value string) (*Identity, error) {
+	t.Helper()
+	request := httptest.NewRequest("POST", "/books", nil)
+	if header != "" {
+		request.Header.Set(header, value)
+	}
+	return a.Authenticate(request)
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	return signed
+}
+
+func validClaims(roles ...string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   "avid.reader",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iss":   "https://auth.example.com",
+		"aud":   "books-api",
+		"roles": roles,
+	}
+}
+
+func TestAuthenticator_HMAC(t *testing.T) {
+	Convey("Given an authenticator configured with a HMAC secret, an issuer and an audience", t, func() {
+		a, err := NewAuthenticator(config.AuthConfig{
+			HMACSecret: hmacSecret,
+			Issuer:     "https://auth.example.com",
+			Audience:   "books-api",
+			RolesClaim: "roles",
+		})
+		So(err, ShouldBeNil)
+
+		Convey("When a request has a valid token", func() {
+			token := sign(t, jwt.SigningMethodHS256, []byte(hmacSecret), "", validClaims("reviewer", "unknown"))
+			identity, err := authenticate(t, a, "Authorization", "Bearer "+token)
+
+			Convey("Then the caller is identified by the subject, with the known roles of the token", func() {
+				So(err, ShouldBeNil)
+				So(identity.Subject, ShouldEqual, "avid.reader")
+				So(identity.Roles, ShouldResemble, []Role{Reviewer})
+			})
+		})
+
+		Convey("When a request has no credentials", func() {
+			_, err := authenticate(t, a, "", "")
+
+			Convey("Then the credentials are missing", func() {
+				So(err, ShouldEqual, ErrMissingCredentials)
+			})
+		})
+
+		invalid := map[string]string{
+			"signed with another secret": "Bearer " + sign(t, jwt.SigningMethodHS256, []byte("another secret"), "", validClaims("admin")),
+			"expired": "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(hmacSecret), "", jwt.MapClaims{
+				"sub": "avid.reader", "exp": time.Now().Add(-time.Minute).Unix(), "iss": "https://auth.example.com", "aud": "books-api"}),
+			"without an expiry": "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(hmacSecret), "", jwt.MapClaims{
+				"sub": "avid.reader", "iss": "https://auth.example.com", "aud": "books-api"}),
+			"for another audience": "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(hmacSecret), "", jwt.MapClaims{
+				"sub": "avid.reader", "exp": time.Now().Add(time.Hour).Unix(), "iss": "https://auth.example.com", "aud": "another-api"}),
+			"from another issuer": "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(hmacSecret), "", jwt.MapClaims{
+				"sub": "avid.reader", "exp": time.Now().Add(time.Hour).Unix(), "iss": "https://evil.example.com", "aud": "books-api"}),
+			"without a subject": "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(hmacSecret), "", jwt.MapClaims{
+				"exp": time.Now().Add(time.Hour).Unix(), "iss": "https://auth.example.com", "aud": "books-api"}),
+			"unsigned":           "Bearer " + sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", validClaims("admin")),
+			"not a bearer token": "Basic YWRtaW46YWRtaW4=",
+			"not a token at all": "Bearer not-a-token",
+		}
+
+		for description, authorization := range invalid {
+			Convey("When a request has a token "+description, func() {
+				_, err := authenticate(t, a, "Authorization", authorization)
+
+				Convey("Then the credentials are invalid", func() {
+					So(err, ShouldEqual, ErrInvalidCredentials)
+				})
+			})
+		}
+	})
+}
+
+func TestAuthenticator_JWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	Convey("Given an authenticator configured with a JWKS file that has a RSA and an EC key", t, func() {
+		path := writeFile(t, "jwks.json", map[string]interface{}{
+			"keys": []map[string]string{
+				{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": encodeBigInt(rsaKey.N), "e": encodeBigInt(big.NewInt(int64(rsaKey.E)))},
+				{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": encodeBigInt(ecKey.X), "y": encodeBigInt(ecKey.Y)},
+			},
+		})
+		a, err := NewAuthenticator(config.AuthConfig{JWKSFile: path, RolesClaim: "roles"})
+		So(err, ShouldBeNil)
+
+		Convey("When a request has a token signed by the RSA key", func() {
+			token := sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-1", validClaims("librarian"))
+			identity, err := authenticate(t, a, "Authorization", "Bearer "+token)
+
+			Convey("Then the caller is identified", func() {
+				So(err, ShouldBeNil)
+				So(identity.Roles, ShouldResemble, []Role{Librarian})
+			})
+		})
+
+		Convey("When a request has a token signed by the EC key", func() {
+			token := sign(t, jwt.SigningMethodES256, ecKey, "ec-1", validClaims("admin"))
+			identity, err := authenticate(t, a, "Authorization", "Bearer "+token)
+
+			Convey("Then the caller is identified", func() {
+				So(err, ShouldBeNil)
+				So(identity.Roles, ShouldResemble, []Role{Admin})
+			})
+		})
+
+		Convey("When a request has a token signed by an unknown key", func() {
+			token := sign(t, jwt.SigningMethodRS256, rsaKey, "rsa-2", validClaims("librarian"))
+			_, err := authenticate(t, a, "Authorization", "Bearer "+token)
+
+			Convey("Then the credentials are invalid", func() {
+				So(err, ShouldEqual, ErrInvalidCredentials)
+			})
+		})
+
+		Convey("When a request has a HMAC token, which is not configured", func() {
+			token := sign(t, jwt.SigningMethodHS256, []byte(hmacSecret), "rsa-1", validClaims("admin"))
+			_, err := authenticate(t, a, "Authorization", "Bearer "+token)
+
+			Convey("Then the credentials are invalid", func() {
+				So(err, ShouldEqual, ErrInvalidCredentials)
+			})
+		})
+	})
+
+	Convey("Given a JWKS file with an invalid key", t, func() {
+		path := writeFile(t, "jwks.json", map[string]interface{}{
+			"keys": []map[string]string{{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": "AQ", "y": "AQ"}},
+		})
+
+		Convey("When an authenticator is created", func() {
+			_, err := NewAuthenticator(config.AuthConfig{JWKSFile: path})
+
+			Convey("Then an error is returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestAuthenticator_APIKeys(t *testing.T) {
+	Convey("Given an authenticator configured with an API keys file", t, func() {
+		hash := sha256.Sum256([]byte("importer-key"))
+		path := writeFile(t, "keys.json", []map[string]interface{}{
+			{"name": "importer", "key_sha256": hex.EncodeToString(hash[:]), "roles": []string{"librarian"}},
+		})
+		a, err := NewAuthenticator(config.AuthConfig{APIKeysFile: path})
+		So(err, ShouldBeNil)
+
+		Convey("When a request has a known API key", func() {
+			identity, err := authenticate(t, a, APIKeyHeader, "importer-key")
+
+			Convey("Then the caller is identified by the name of the key", func() {
+				So(err, ShouldBeNil)
+				So(identity.Subject, ShouldEqual, "importer")
+				So(identity.Roles, ShouldResemble, []Role{Librarian})
+			})
+		})
+
+		Convey("When a request has an unknown API key", func() {
+			_, err := authenticate(t, a, APIKeyHeader, "guessed-key")
+
+			Convey("Then the credentials are invalid", func() {
+				So(err, ShouldEqual, ErrInvalidCredentials)
+			})
+		})
+
+		Convey("When a request has a bearer token, and no token verification is configured", func() {
+			_, err := authenticate(t, a, "Authorization", "Bearer "+sign(t, jwt.SigningMethodHS256, []byte(hmacSecret), "", validClaims("admin")))
+
+			Convey("Then the credentials are invalid", func() {
+				So(err, ShouldEqual, ErrInvalidCredentials)
+			})
+		})
+	})
+}
@@ -6,20 +6,87 @@ import (
 )
 
 type Configuration struct {
-	BindAddr                   string `envconfig:"BIND_ADDR"`
+	BindAddr                   string        `envconfig:"BIND_ADDR"`
+	APIURL                     string        `envconfig:"API_URL"`
+	APIVersion                 string        `envconfig:"API_VERSION"`
+	HTTPWriteTimeout           time.Duration `envconfig:"HTTP_WRITE_TIMEOUT"`
+	GracefulShutdownTimeout    time.Duration `envconfig:"GRACEFUL_SHUTDOWN_TIMEOUT"`
 	HealthCheckCriticalTimeout time.Duration
 	HealthCheckInterval        time.Duration
+	DataStore                  string `envconfig:"DATA_STORE"`
 	MongoConfig                MongoConfig
-	DefaultMaximumLimit        int `envconfig:"DEFAULT_MAXIMUM_LIMIT"`
-	DefaultLimit               int `envconfig:"DEFAULT_LIMIT"`
-	DefaultOffset              int `envconfig:"DEFAULT_OFFSET"`
+	DefaultMaximumLimit        int           `envconfig:"DEFAULT_MAXIMUM_LIMIT"`
+	DefaultLimit               int           `envconfig:"DEFAULT_LIMIT"`
+	DefaultOffset              int           `envconfig:"DEFAULT_OFFSET"`
+	PaginationCursorSecret     string        `envconfig:"PAGINATION_CURSOR_SECRET" json:"-"`
+	CascadeReviewsOnDelete     bool          `envconfig:"CASCADE_REVIEWS_ON_DELETE"`
+	RequireIfMatch             bool          `envconfig:"REQUIRE_IF_MATCH"`
+	IdempotencyKeyTTL          time.Duration `envconfig:"IDEMPOTENCY_KEY_TTL"`
+	EventProducer              string        `envconfig:"EVENT_PRODUCER"`
+	EventsFile                 string        `envconfig:"EVENTS_FILE"`
+	KafkaConfig                KafkaConfig
+	OutboxConfig               OutboxConfig
+	AuthConfig                 AuthConfig
+	ImportConfig               ImportConfig
+	MigrationsConfig           MigrationsConfig
 }
 
 type MongoConfig struct {
-	BindAddr          string `envconfig:"MONGODB_BIND_ADDR"   json:"-"`
-	Database          string `envconfig:"MONGODB_DATABASE"`
-	BooksCollection   string `envconfig:"MONGODB_BOOKS_COLLECTION"`
-	ReviewsCollection string `envconfig:"MONGODB_REVIEWS_COLLECTION"`
+	BindAddr               string        `envconfig:"MONGODB_BIND_ADDR"   json:"-"`
+	Username               string        `envconfig:"MONGODB_USERNAME"    json:"-"`
+	Password               string        `envconfig:"MONGODB_PASSWORD"    json:"-"`
+	AuthSource             string        `envconfig:"MONGODB_AUTH_SOURCE"`
+	EnableTLS              bool          `envconfig:"MONGODB_ENABLE_TLS"`
+	ConnectTimeout         time.Duration `envconfig:"MONGODB_CONNECT_TIMEOUT"`
+	QueryTimeout           time.Duration `envconfig:"MONGODB_QUERY_TIMEOUT"`
+	MaxPoolSize            uint64        `envconfig:"MONGODB_MAX_POOL_SIZE"`
+	MinPoolSize            uint64        `envconfig:"MONGODB_MIN_POOL_SIZE"`
+	MaxConnIdleTime        time.Duration `envconfig:"MONGODB_MAX_CONN_IDLE_TIME"`
+	Database               string        `envconfig:"MONGODB_DATABASE"`
+	BooksCollection        string        `envconfig:"MONGODB_BOOKS_COLLECTION"`
+	ReviewsCollection      string        `envconfig:"MONGODB_REVIEWS_COLLECTION"`
+	ReservationsCollection string        `envconfig:"MONGODB_RESERVATIONS_COLLECTION"`
+	AuthorsCollection      string        `envconfig:"MONGODB_AUTHORS_COLLECTION"`
+	OutboxCollection       string        `envconfig:"MONGODB_OUTBOX_COLLECTION"`
+	MigrationsCollection   string        `envconfig:"MONGODB_MIGRATIONS_COLLECTION"`
+	LocksCollection        string        `envconfig:"MONGODB_LOCKS_COLLECTION"`
+	IdempotencyCollection  string        `envconfig:"MONGODB_IDEMPOTENCY_COLLECTION"`
+	CountersCollection     string        `envconfig:"MONGODB_COUNTERS_COLLECTION"`
+}
+
+type KafkaConfig struct {
+	Brokers         []string `envconfig:"KAFKA_ADDR"`
+	BookEventsTopic string   `envconfig:"KAFKA_BOOK_EVENTS_TOPIC"`
+}
+
+type OutboxConfig struct {
+	PollInterval            time.Duration `envconfig:"OUTBOX_POLL_INTERVAL"`
+	BatchSize               int           `envconfig:"OUTBOX_BATCH_SIZE"`
+	MinRetryBackoff         time.Duration `envconfig:"OUTBOX_MIN_RETRY_BACKOFF"`
+	MaxRetryBackoff         time.Duration `envconfig:"OUTBOX_MAX_RETRY_BACKOFF"`
+	BacklogWarningThreshold int           `envconfig:"OUTBOX_BACKLOG_WARNING_THRESHOLD"`
+}
+
+type AuthConfig struct {
+	JWKSFile    string `envconfig:"AUTH_JWKS_FILE"`
+	HMACSecret  string `envconfig:"AUTH_HMAC_SECRET" json:"-"`
+	Issuer      string `envconfig:"AUTH_ISSUER"`
+	Audience    string `envconfig:"AUTH_AUDIENCE"`
+	RolesClaim  string `envconfig:"AUTH_ROLES_CLAIM"`
+	APIKeysFile string `envconfig:"AUTH_API_KEYS_FILE"`
+}
+
+type ImportConfig struct {
+	BatchSize    int           `envconfig:"IMPORT_BATCH_SIZE"`
+	MaxSyncSize  int64         `envconfig:"IMPORT_MAX_SYNC_SIZE"`
+	MaxSize      int64         `envconfig:"IMPORT_MAX_SIZE"`
+	JobRetention time.Duration `envconfig:"IMPORT_JOB_RETENTION"`
+}
+
+type MigrationsConfig struct {
+	OnStartup   bool          `envconfig:"MIGRATE_ON_STARTUP"`
+	LockTTL     time.Duration `envconfig:"MIGRATIONS_LOCK_TTL"`
+	LockTimeout time.Duration `envconfig:"MIGRATIONS_LOCK_TIMEOUT"`
 }
 
 var cfg *Configuration
@@ -32,17 +99,74 @@ func Get() (*Configuration, error) {
 
 	cfg = &Configuration{
 		BindAddr:                   ":8080",
+		APIURL:                     "http://localhost:8080",
+		APIVersion:                 "v1",
+		HTTPWriteTimeout:           10 * time.Second,
+		GracefulShutdownTimeout:    5 * time.Second,
 		HealthCheckCriticalTimeout: 90 * time.Second,
 		HealthCheckInterval:        30 * time.Second,
+		DataStore:                  "mongo",
 		MongoConfig: MongoConfig{
-			BindAddr:          "localhost:27017",
-			Database:          "bookStore",
-			BooksCollection:   "books",
-			ReviewsCollection: "reviews",
+			BindAddr:               "localhost:27017",
+			Username:               "",
+			Password:               "",
+			AuthSource:             "admin",
+			EnableTLS:              false,
+			ConnectTimeout:         10 * time.Second,
+			QueryTimeout:           5 * time.Second,
+			MaxPoolSize:            100,
+			MinPoolSize:            0,
+			MaxConnIdleTime:        5 * time.Minute,
+			Database:               "bookStore",
+			BooksCollection:        "books",
+			ReviewsCollection:      "reviews",
+			ReservationsCollection: "reservations",
+			AuthorsCollection:      "authors",
+			OutboxCollection:       "outbox",
+			MigrationsCollection:   "migrations",
+			LocksCollection:        "locks",
+			IdempotencyCollection:  "idempotency_keys",
+			CountersCollection:     "counters",
+		},
+		DefaultMaximumLimit:    1000,
+		DefaultLimit:           20,
+		DefaultOffset:          0,
+		PaginationCursorSecret: "",
+		CascadeReviewsOnDelete: false,
+		RequireIfMatch:         true,
+		IdempotencyKeyTTL:      24 * time.Hour,
+		EventProducer:          "kafka",
+		EventsFile:             "",
+		KafkaConfig: KafkaConfig{
+			Brokers:         []string{"localhost:9092"},
+			BookEventsTopic: "book-events",
+		},
+		OutboxConfig: OutboxConfig{
+			PollInterval:            time.Second,
+			BatchSize:               100,
+			MinRetryBackoff:         time.Second,
+			MaxRetryBackoff:         time.Minute,
+			BacklogWarningThreshold: 1000,
+		},
+		AuthConfig: AuthConfig{
+			JWKSFile:    "",
+			HMACSecret:  "",
+			Issuer:      "",
+			Audience:    "",
+			RolesClaim:  "roles",
+			APIKeysFile: "",
+		},
+		ImportConfig: ImportConfig{
+			BatchSize:    100,
+			MaxSyncSize:  1 << 20,
+			MaxSize:      100 << 20,
+			JobRetention: 24 * time.Hour,
+		},
+		MigrationsConfig: MigrationsConfig{
+			OnStartup:   true,
+			LockTTL:     time.Minute,
+			LockTimeout: 5 * time.Minute,
 		},
-		DefaultMaximumLimit: 1000,
-		DefaultLimit:        20,
-		DefaultOffset:       0,
 	}
 
 	err := envconfig.Process("", cfg)
@@ -15,15 +15,62 @@ func TestConfig(t *testing.T) {
 			cfg, err := Get()
 			Convey("The values should be set to the default values", func() {
 				So(cfg.BindAddr, ShouldEqual, ":8080")
+				So(cfg.APIURL, ShouldEqual, "http://localhost:8080")
+				So(cfg.APIVersion, ShouldEqual, "v1")
+				So(cfg.HTTPWriteTimeout, ShouldEqual, 10*time.Second)
+				So(cfg.GracefulShutdownTimeout, ShouldEqual, 5*time.Second)
+				So(cfg.DataStore, ShouldEqual, "mongo")
 				So(cfg.MongoConfig.BindAddr, ShouldEqual, "localhost:27017")
+				So(cfg.MongoConfig.Username, ShouldBeEmpty)
+				So(cfg.MongoConfig.Password, ShouldBeEmpty)
+				So(cfg.MongoConfig.AuthSource, ShouldEqual, "admin")
+				So(cfg.MongoConfig.EnableTLS, ShouldBeFalse)
+				So(cfg.MongoConfig.ConnectTimeout, ShouldEqual, 10*time.Second)
+				So(cfg.MongoConfig.QueryTimeout, ShouldEqual, 5*time.Second)
+				So(cfg.MongoConfig.MaxPoolSize, ShouldEqual, 100)
+				So(cfg.MongoConfig.MinPoolSize, ShouldEqual, 0)
+				So(cfg.MongoConfig.MaxConnIdleTime, ShouldEqual, 5*time.Minute)
 				So(cfg.MongoConfig.Database, ShouldEqual, "bookStore")
 				So(cfg.MongoConfig.BooksCollection, ShouldEqual, "books")
 				So(cfg.MongoConfig.ReviewsCollection, ShouldEqual, "reviews")
+				So(cfg.MongoConfig.ReservationsCollection, ShouldEqual, "reservations")
+				So(cfg.MongoConfig.AuthorsCollection, ShouldEqual, "authors")
+				So(cfg.MongoConfig.OutboxCollection, ShouldEqual, "outbox")
+				So(cfg.MongoConfig.MigrationsCollection, ShouldEqual, "migrations")
+				So(cfg.MongoConfig.LocksCollection, ShouldEqual, "locks")
+				So(cfg.MongoConfig.IdempotencyCollection, ShouldEqual, "idempotency_keys")
+				So(cfg.MongoConfig.CountersCollection, ShouldEqual, "counters")
 				So(cfg.HealthCheckInterval, ShouldEqual, 30*time.Second)
 				So(cfg.HealthCheckCriticalTimeout, ShouldEqual, 90*time.Second)
 				So(cfg.DefaultMaximumLimit, ShouldEqual, 1000)
 				So(cfg.DefaultLimit, ShouldEqual, 20)
 				So(cfg.DefaultOffset, ShouldEqual, 0)
+				So(cfg.PaginationCursorSecret, ShouldBeEmpty)
+				So(cfg.CascadeReviewsOnDelete, ShouldBeFalse)
+				So(cfg.RequireIfMatch, ShouldBeTrue)
+				So(cfg.IdempotencyKeyTTL, ShouldEqual, 24*time.Hour)
+				So(cfg.EventProducer, ShouldEqual, "kafka")
+				So(cfg.EventsFile, ShouldBeEmpty)
+				So(cfg.KafkaConfig.Brokers, ShouldResemble, []string{"localhost:9092"})
+				So(cfg.KafkaConfig.BookEventsTopic, ShouldEqual, "book-events")
+				So(cfg.OutboxConfig.PollInterval, ShouldEqual, time.Second)
+				So(cfg.OutboxConfig.BatchSize, ShouldEqual, 100)
+				So(cfg.OutboxConfig.MinRetryBackoff, ShouldEqual, time.Second)
+				So(cfg.OutboxConfig.MaxRetryBackoff, ShouldEqual, time.Minute)
+				So(cfg.OutboxConfig.BacklogWarningThreshold, ShouldEqual, 1000)
+				So(cfg.AuthConfig.JWKSFile, ShouldEqual, "")
+				So(cfg.AuthConfig.HMACSecret, ShouldEqual, "")
+				So(cfg.AuthConfig.Issuer, ShouldEqual, "")
+				So(cfg.AuthConfig.Audience, ShouldEqual, "")
+				So(cfg.AuthConfig.RolesClaim, ShouldEqual, "roles")
+				So(cfg.AuthConfig.APIKeysFile, ShouldEqual, "")
+				So(cfg.ImportConfig.BatchSize, ShouldEqual, 100)
+				So(cfg.ImportConfig.MaxSyncSize, ShouldEqual, 1<<20)
+				So(cfg.ImportConfig.MaxSize, ShouldEqual, 100<<20)
+				So(cfg.ImportConfig.JobRetention, ShouldEqual, 24*time.Hour)
+				So(cfg.MigrationsConfig.OnStartup, ShouldBeTrue)
+				So(cfg.MigrationsConfig.LockTTL, ShouldEqual, time.Minute)
+				So(cfg.MigrationsConfig.LockTimeout, ShouldEqual, 5*time.Minute)
 			})
 			Convey("And there should be no errors", func() {
 				So(err, ShouldBeNil)
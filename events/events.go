@@ -0,0 +1,158 @@
+package events
+
+import (
+	"encoding/json"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"time"
+)
+
+// Event types published by the books-api
+const (
+	BookCreated     = "book-created"
+	BookUpdated     = "book-updated"
+	BookDeleted     = "book-deleted"
+	ReviewAdded     = "review-added"
+	ReviewUpdated   = "review-updated"
+	ReviewModerated = "review-moderated"
+	ReviewDeleted   = "review-deleted"
+)
+
+// ErrUnknownEventType represents an error case where there is no schema for an event type
+var ErrUnknownEventType = errors.New("unknown event type")
+
+// An Event is the envelope of every message published by the books-api.
+// The payload is described by the schema identified by the event type and version.
+// The Sequence is set by the data store, and orders the events of the outbox as they were written. It is not published.
+type Event struct {
+	ID         string          `json:"id" bson:"_id"`
+	Sequence   int64           `json:"-" bson:"sequence"`
+	Type       string          `json:"type" bson:"type"`
+	Version    int             `json:"version" bson:"version"`
+	Schema     string          `json:"schema" bson:"schema"`
+	Key        string          `json:"key" bson:"key"`
+	OccurredAt time.Time       `json:"occurred_at" bson:"occurred_at"`
+	Payload    json.RawMessage `json:"payload" bson:"payload"`
+}
+
+// BookPayload is the payload of the book-created and book-updated events
+type BookPayload struct {
+	ID              string               `json:"id"`
+	Title           string               `json:"title"`
+	Author          string               `json:"author"`
+	Authors         []models.Contributor `json:"authors,omitempty"`
+	Synopsis        string               `json:"synopsis,omitempty"`
+	ISBN            string               `json:"isbn,omitempty"`
+	Publisher       string               `json:"publisher,omitempty"`
+	PublicationYear int                  `json:"publication_year,omitempty"`
+	PageCount       int                  `json:"page_count,omitempty"`
+	Language        string               `json:"language,omitempty"`
+	Edition         string               `json:"edition,omitempty"`
+	Genres          []string             `json:"genres,omitempty"`
+}
+
+// BookDeletedPayload is the payload of the book-deleted event
+type BookDeletedPayload struct {
+	ID string `json:"id"`
+}
+
+// ReviewPayload is the payload of the review events
+type ReviewPayload struct {
+	ID          string      `json:"id"`
+	BookID      string      `json:"book_id"`
+	User        models.User `json:"user"`
+	Message     string      `json:"message"`
+	Rating      int         `json:"rating,omitempty"`
+	State       string      `json:"state,omitempty"`
+	LastUpdated time.Time   `json:"last_updated"`
+}
+
+// NewBookCreated returns the event published when a Book is added
+func NewBookCreated(book *models.Book) (*Event, error) {
+	return newEvent(BookCreated, book.ID, newBookPayload(book))
+}
+
+// NewBookUpdated returns the event published when a Book is updated or patched
+func NewBookUpdated(book *models.Book) (*Event, error) {
+	return newEvent(BookUpdated, book.ID, newBookPayload(book))
+}
+
+// NewBookDeleted returns the event published when a Book is deleted. The reviews of the Book that are deleted with it
+// have review-deleted events of their own.
+func NewBookDeleted(bookID string) (*Event, error) {
+	return newEvent(BookDeleted, bookID, BookDeletedPayload{ID: bookID})
+}
+
+// NewReviewAdded returns the event published when a Review is added to a Book
+func NewReviewAdded(review *models.Review) (*Event, error) {
+	return newEvent(ReviewAdded, review.BookID, newReviewPayload(review))
+}
+
+// NewReviewUpdated returns the event published when a Review is updated
+func NewReviewUpdated(review *models.Review) (*Event, error) {
+	return newEvent(ReviewUpdated, review.BookID, newReviewPayload(review))
+}
+
+// NewReviewModerated returns the event published when a moderator approves or rejects a Review
+func NewReviewModerated(review *models.Review) (*Event, error) {
+	return newEvent(ReviewModerated, review.BookID, newReviewPayload(review))
+}
+
+// NewReviewDeleted returns the event published when a Review is deleted. The payload is the deleted Review.
+func NewReviewDeleted(review *models.Review) (*Event, error) {
+	return newEvent(ReviewDeleted, review.BookID, newReviewPayload(review))
+}
+
+func newBookPayload(book *models.Book) BookPayload {
+	return BookPayload{
+		ID:              book.ID,
+		Title:           book.Title,
+		Author:          book.Author,
+		Authors:         book.Authors,
+		Synopsis:        book.Synopsis,
+		ISBN:            book.ISBN,
+		Publisher:       book.Publisher,
+		PublicationYear: book.PublicationYear,
+		PageCount:       book.PageCount,
+		Language:        book.Language,
+		Edition:         book.Edition,
+		Genres:          book.Genres,
+	}
+}
+
+func newReviewPayload(review *models.Review) ReviewPayload {
+	return ReviewPayload{
+		ID:          review.ID,
+		BookID:      review.BookID,
+		User:        review.User,
+		Message:     review.Message,
+		Rating:      review.Rating,
+		State:       review.State,
+		LastUpdated: review.LastUpdated,
+	}
+}
+
+// newEvent wraps a payload in an Event, using the current schema version of the event type.
+// Events are keyed by book ID, so that the events of a book are consumed in order.
+func newEvent(eventType, key string, payload interface{}) (*Event, error) {
+	schema, ok := CurrentSchemas[eventType]
+	if !ok {
+		return nil, ErrUnknownEventType
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal event payload")
+	}
+
+	return &Event{
+		ID:         uuid.NewV4().String(),
+		Type:       eventType,
+		Version:    schema.Version,
+		Schema:     schema.ID(),
+		Key:        key,
+		OccurredAt: time.Now().UTC(),
+		Payload:    data,
+	}, nil
+}
@@ -0,0 +1,107 @@
+package events
+
+import (
+	"encoding/json"
+	"github.com/cadmiumcat/books-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestNewBookCreated(t *testing.T) {
+	Convey("Given a book", t, func() {
+		book := &models.Book{ID: "1", Title: "Kindred", Author: "Octavia E. Butler", ISBN: "9780807083697", Links: &models.Link{Self: "/books/1"}}
+
+		Convey("When a book-created event is created", func() {
+			event, err := NewBookCreated(book)
+			So(err, ShouldBeNil)
+
+			Convey("Then the event is described by the current book-created schema", func() {
+				So(event.ID, ShouldNotBeEmpty)
+				So(event.Type, ShouldEqual, BookCreated)
+				So(event.Version, ShouldEqual, 3)
+				So(event.Schema, ShouldEqual, "books-api/book-created/v3")
+			})
+			Convey("And the event is keyed by the book ID", func() {
+				So(event.Key, ShouldEqual, "1")
+			})
+			Convey("And the payload contains the book details and metadata, without its links", func() {
+				payload := BookPayload{}
+				So(json.Unmarshal(event.Payload, &payload), ShouldBeNil)
+				So(payload, ShouldResemble, BookPayload{ID: "1", Title: "Kindred", Author: "Octavia E. Butler", ISBN: "9780807083697"})
+				So(string(event.Payload), ShouldNotContainSubstring, "links")
+			})
+		})
+	})
+}
+
+func TestNewBookDeleted(t *testing.T) {
+	Convey("When a book-deleted event is created", t, func() {
+		event, err := NewBookDeleted("1")
+		So(err, ShouldBeNil)
+
+		Convey("Then the event is keyed by the book ID, and its payload identifies the book", func() {
+			So(event.Type, ShouldEqual, BookDeleted)
+			So(event.Schema, ShouldEqual, "books-api/book-deleted/v1")
+			So(event.Key, ShouldEqual, "1")
+			So(string(event.Payload), ShouldEqual, `{"id":"1"}`)
+		})
+	})
+}
+
+func TestNewReviewAdded(t *testing.T) {
+	Convey("Given a review for a book", t, func() {
+		review := models.NewReview("1")
+		review.Message = "A classic"
+		review.User = models.User{Forenames: "Avid", Surname: "Reader"}
+		review.Rating = 4
+
+		Convey("When a review-added event is created", func() {
+			event, err := NewReviewAdded(review)
+			So(err, ShouldBeNil)
+
+			Convey("Then the event is keyed by the book ID, not the review ID", func() {
+				So(event.Type, ShouldEqual, ReviewAdded)
+				So(event.Key, ShouldEqual, "1")
+			})
+			Convey("And the payload contains the review", func() {
+				payload := ReviewPayload{}
+				So(json.Unmarshal(event.Payload, &payload), ShouldBeNil)
+				So(payload.ID, ShouldEqual, review.ID)
+				So(payload.Message, ShouldEqual, "A classic")
+				So(payload.Rating, ShouldEqual, 4)
+			})
+			Convey("And the event is described by the schema that includes the rating and moderation state", func() {
+				So(event.Schema, ShouldEqual, "books-api/review-added/v3")
+			})
+		})
+	})
+}
+
+func TestCurrentSchemas(t *testing.T) {
+	Convey("Given several versions of the schema of an event type", t, func() {
+		schemas := []Schema{
+			{Type: BookCreated, Version: 2},
+			{Type: BookCreated, Version: 1},
+			{Type: ReviewAdded, Version: 1},
+		}
+
+		Convey("When the latest schemas are selected", func() {
+			latest := latestSchemas(schemas)
+			Convey("Then the highest version of each event type is used", func() {
+				So(latest, ShouldHaveLength, 2)
+				So(latest[BookCreated].Version, ShouldEqual, 2)
+				So(latest[ReviewAdded].Version, ShouldEqual, 1)
+			})
+		})
+	})
+
+	Convey("Given the published schemas", t, func() {
+		Convey("Then every event type has a current schema with a valid JSON definition", func() {
+			for _, eventType := range []string{BookCreated, BookUpdated, BookDeleted, ReviewAdded, ReviewUpdated, ReviewModerated, ReviewDeleted} {
+				schema, ok := CurrentSchemas[eventType]
+				So(ok, ShouldBeTrue)
+				So(json.Valid([]byte(schema.Definition)), ShouldBeTrue)
+			}
+		})
+	})
+}
@@ -0,0 +1,56 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/segmentio/kafka-go"
+	"strconv"
+)
+
+// KafkaProducer publishes events to a Kafka topic.
+// Events are keyed by book ID, so that all the events of a book are sent to the same partition.
+type KafkaProducer struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaProducer returns a KafkaProducer that publishes events to the given topic.
+func NewKafkaProducer(brokers []string, topic string) *KafkaProducer {
+	return &KafkaProducer{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+		},
+	}
+}
+
+// Publish sends an event to Kafka, and blocks until it has been acknowledged by the brokers.
+func (p *KafkaProducer) Publish(ctx context.Context, event *Event) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal event")
+	}
+
+	message := kafka.Message{
+		Key:   []byte(event.Key),
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: "type", Value: []byte(event.Type)},
+			{Key: "version", Value: []byte(strconv.Itoa(event.Version))},
+			{Key: "schema", Value: []byte(event.Schema)},
+		},
+	}
+
+	if err := p.writer.WriteMessages(ctx, message); err != nil {
+		return errors.Wrap(err, "failed to publish event to kafka")
+	}
+
+	return nil
+}
+
+// Close flushes any pending message and closes the connections to the brokers.
+func (p *KafkaProducer) Close(ctx context.Context) error {
+	return p.writer.Close()
+}
@@ -0,0 +1,89 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"os"
+	"sync"
+)
+
+// LocalProducer publishes events in-process, without a broker. It is intended for tests and local development.
+// Published events are appended to a file as JSON lines if one is provided, and dropped otherwise. A recording
+// producer also keeps them in memory, for tests to read.
+type LocalProducer struct {
+	mu     sync.Mutex
+	record bool
+	events []Event
+	file   *os.File
+}
+
+// NewLocalProducer returns a LocalProducer. If path is not empty, events are appended to that file.
+func NewLocalProducer(path string) (*LocalProducer, error) {
+	return newLocalProducer(path, false)
+}
+
+// NewRecordingProducer returns a LocalProducer that keeps every event it publishes in memory, until it is discarded.
+// It is only meant for tests, which read the events with Events. If path is not empty, events are also appended to
+// that file.
+func NewRecordingProducer(path string) (*LocalProducer, error) {
+	return newLocalProducer(path, true)
+}
+
+func newLocalProducer(path string, record bool) (*LocalProducer, error) {
+	producer := &LocalProducer{record: record}
+
+	if path != "" {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to open events file")
+		}
+		producer.file = file
+	}
+
+	return producer, nil
+}
+
+// Publish appends an event to the events file if there is one, and keeps it in memory if the producer records events.
+func (p *LocalProducer) Publish(ctx context.Context, event *Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.file != nil {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal event")
+		}
+		if _, err := p.file.Write(append(line, '\n')); err != nil {
+			return errors.Wrap(err, "failed to write event to file")
+		}
+	}
+
+	if p.record {
+		p.events = append(p.events, *event)
+	}
+
+	return nil
+}
+
+// Events returns a copy of the events published so far, in the order they were published, if the producer records
+// events. It returns no events otherwise.
+func (p *LocalProducer) Events() []Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	published := make([]Event, len(p.events))
+	copy(published, p.events)
+	return published
+}
+
+// Close closes the events file, if there is one.
+func (p *LocalProducer) Close(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.file == nil {
+		return nil
+	}
+	return p.file.Close()
+}
@@ -0,0 +1,90 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/cadmiumcat/books-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalProducer(t *testing.T) {
+	ctx := context.Background()
+	book := &models.Book{ID: "1", Title: "Kindred", Author: "Octavia E. Butler"}
+
+	Convey("Given a recording producer without a file", t, func() {
+		producer, err := NewRecordingProducer("")
+		So(err, ShouldBeNil)
+
+		Convey("When two events are published", func() {
+			created, _ := NewBookCreated(book)
+			updated, _ := NewBookUpdated(book)
+			So(producer.Publish(ctx, created), ShouldBeNil)
+			So(producer.Publish(ctx, updated), ShouldBeNil)
+
+			Convey("Then both events are kept in memory in the order they were published", func() {
+				published := producer.Events()
+				So(published, ShouldHaveLength, 2)
+				So(published[0].Type, ShouldEqual, BookCreated)
+				So(published[1].Type, ShouldEqual, BookUpdated)
+			})
+			Convey("And the producer can be closed", func() {
+				So(producer.Close(ctx), ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given a local producer without a file", t, func() {
+		producer, err := NewLocalProducer("")
+		So(err, ShouldBeNil)
+
+		Convey("When an event is published", func() {
+			created, _ := NewBookCreated(book)
+			So(producer.Publish(ctx, created), ShouldBeNil)
+
+			Convey("Then it is not kept in memory", func() {
+				So(producer.Events(), ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given a local producer backed by a file", t, func() {
+		path := filepath.Join(t.TempDir(), "events.jsonl")
+		producer, err := NewLocalProducer(path)
+		So(err, ShouldBeNil)
+
+		Convey("When an event is published and the producer is closed", func() {
+			created, _ := NewBookCreated(book)
+			So(producer.Publish(ctx, created), ShouldBeNil)
+			So(producer.Close(ctx), ShouldBeNil)
+
+			Convey("Then the event is appended to the file as a JSON line", func() {
+				file, err := os.Open(path)
+				So(err, ShouldBeNil)
+				defer file.Close()
+
+				scanner := bufio.NewScanner(file)
+				So(scanner.Scan(), ShouldBeTrue)
+				event := Event{}
+				So(json.Unmarshal(scanner.Bytes(), &event), ShouldBeNil)
+				So(event.ID, ShouldEqual, created.ID)
+				So(scanner.Scan(), ShouldBeFalse)
+			})
+		})
+	})
+
+	Convey("Given a file that cannot be opened", t, func() {
+		path := filepath.Join(t.TempDir(), "missing", "events.jsonl")
+
+		Convey("When a local producer is created", func() {
+			producer, err := NewLocalProducer(path)
+			Convey("Then an error is returned", func() {
+				So(producer, ShouldBeNil)
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,183 @@
+package events
+
+import "fmt"
+
+// A Schema describes the payload of a version of an event type, as a JSON schema document.
+type Schema struct {
+	Type       string
+	Version    int
+	Definition string
+}
+
+// ID returns the identifier of the schema, which is published in the envelope of every event.
+func (s Schema) ID() string {
+	return fmt.Sprintf("books-api/%s/v%d", s.Type, s.Version)
+}
+
+var bookSchemaV1 = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["id", "title", "author"],
+  "properties": {
+    "id": {"type": "string"},
+    "title": {"type": "string"},
+    "author": {"type": "string"},
+    "synopsis": {"type": "string"}
+  }
+}`
+
+// bookSchemaV2 adds the catalogue metadata of the book
+var bookSchemaV2 = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["id", "title", "author"],
+  "properties": {
+    "id": {"type": "string"},
+    "title": {"type": "string"},
+    "author": {"type": "string"},
+    "synopsis": {"type": "string"},
+    "isbn": {"type": "string", "pattern": "^97[89][0-9]{10}$"},
+    "publisher": {"type": "string"},
+    "publication_year": {"type": "integer"},
+    "page_count": {"type": "integer", "minimum": 1},
+    "language": {"type": "string", "pattern": "^[a-z]{2}$"},
+    "edition": {"type": "string"},
+    "genres": {"type": "array", "items": {"type": "string"}}
+  }
+}`
+
+// bookSchemaV3 adds the authors that contributed to the book, and their roles
+var bookSchemaV3 = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["id", "title", "author"],
+  "properties": {
+    "id": {"type": "string"},
+    "title": {"type": "string"},
+    "author": {"type": "string"},
+    "authors": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["author_id", "role"],
+        "properties": {
+          "author_id": {"type": "string"},
+          "role": {"type": "string", "enum": ["author", "editor", "translator"]}
+        }
+      }
+    },
+    "synopsis": {"type": "string"},
+    "isbn": {"type": "string", "pattern": "^97[89][0-9]{10}$"},
+    "publisher": {"type": "string"},
+    "publication_year": {"type": "integer"},
+    "page_count": {"type": "integer", "minimum": 1},
+    "language": {"type": "string", "pattern": "^[a-z]{2}$"},
+    "edition": {"type": "string"},
+    "genres": {"type": "array", "items": {"type": "string"}}
+  }
+}`
+
+// bookDeletedSchemaV1 identifies the book that has been deleted
+var bookDeletedSchemaV1 = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["id"],
+  "properties": {
+    "id": {"type": "string"}
+  }
+}`
+
+var reviewSchemaV1 = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["id", "book_id", "user", "message", "last_updated"],
+  "properties": {
+    "id": {"type": "string"},
+    "book_id": {"type": "string"},
+    "user": {
+      "type": "object",
+      "properties": {
+        "forenames": {"type": "string"},
+        "surname": {"type": "string"}
+      }
+    },
+    "message": {"type": "string"},
+    "last_updated": {"type": "string", "format": "date-time"}
+  }
+}`
+
+// reviewSchemaV2 adds the optional rating of the review
+var reviewSchemaV2 = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["id", "book_id", "user", "message", "last_updated"],
+  "properties": {
+    "id": {"type": "string"},
+    "book_id": {"type": "string"},
+    "user": {
+      "type": "object",
+      "properties": {
+        "forenames": {"type": "string"},
+        "surname": {"type": "string"}
+      }
+    },
+    "message": {"type": "string"},
+    "rating": {"type": "integer", "minimum": 1, "maximum": 5},
+    "last_updated": {"type": "string", "format": "date-time"}
+  }
+}`
+
+// reviewSchemaV3 adds the moderation state of the review
+var reviewSchemaV3 = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["id", "book_id", "user", "message", "last_updated"],
+  "properties": {
+    "id": {"type": "string"},
+    "book_id": {"type": "string"},
+    "user": {
+      "type": "object",
+      "properties": {
+        "forenames": {"type": "string"},
+        "surname": {"type": "string"}
+      }
+    },
+    "message": {"type": "string"},
+    "rating": {"type": "integer", "minimum": 1, "maximum": 5},
+    "state": {"type": "string", "enum": ["pending", "approved", "rejected"]},
+    "last_updated": {"type": "string", "format": "date-time"}
+  }
+}`
+
+// Schemas contains every published version of the schema of each event type.
+// Once a version has been published it must not be modified: add a new version instead.
+var Schemas = []Schema{
+	{Type: BookCreated, Version: 1, Definition: bookSchemaV1},
+	{Type: BookUpdated, Version: 1, Definition: bookSchemaV1},
+	{Type: BookCreated, Version: 2, Definition: bookSchemaV2},
+	{Type: BookUpdated, Version: 2, Definition: bookSchemaV2},
+	{Type: BookCreated, Version: 3, Definition: bookSchemaV3},
+	{Type: BookUpdated, Version: 3, Definition: bookSchemaV3},
+	{Type: BookDeleted, Version: 1, Definition: bookDeletedSchemaV1},
+	{Type: ReviewAdded, Version: 1, Definition: reviewSchemaV1},
+	{Type: ReviewUpdated, Version: 1, Definition: reviewSchemaV1},
+	{Type: ReviewAdded, Version: 2, Definition: reviewSchemaV2},
+	{Type: ReviewUpdated, Version: 2, Definition: reviewSchemaV2},
+	{Type: ReviewAdded, Version: 3, Definition: reviewSchemaV3},
+	{Type: ReviewUpdated, Version: 3, Definition: reviewSchemaV3},
+	{Type: ReviewModerated, Version: 1, Definition: reviewSchemaV3},
+	{Type: ReviewDeleted, Version: 1, Definition: reviewSchemaV3},
+}
+
+// CurrentSchemas contains the schema used to publish each event type, i.e. its latest version
+var CurrentSchemas = latestSchemas(Schemas)
+
+func latestSchemas(schemas []Schema) map[string]Schema {
+	latest := make(map[string]Schema)
+	for _, schema := range schemas {
+		if current, ok := latest[schema.Type]; !ok || schema.Version > current.Version {
+			latest[schema.Type] = schema
+		}
+	}
+	return latest
+}
@@ -0,0 +1,144 @@
+package importer
+
+import (
+	"context"
+	"github.com/ONSdigital/log.go/log"
+	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/interfaces"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"sort"
+)
+
+// A Validator checks and normalises a book before it is imported, as it is when the book is added on its own
+type Validator func(ctx context.Context, book *models.Book) error
+
+// An Importer adds the books of a file in batches.
+// A row is skipped if its book conflicts with an existing book, e.g. it has the same ISBN, and fails if it is not valid.
+// The problem of a row that is not created is described with the same code as the response of adding the book on its own.
+type Importer struct {
+	store     interfaces.DataStore
+	validate  Validator
+	problems  *apierrors.Registry
+	batchSize int
+}
+
+// New creates a new instance of Importer that adds up to batchSize books at a time
+func New(store interfaces.DataStore, validate Validator, problems *apierrors.Registry, batchSize int) *Importer {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	return &Importer{
+		store:     store,
+		validate:  validate,
+		problems:  problems,
+		batchSize: batchSize,
+	}
+}
+
+// Run imports the books of the rows, and returns the report of every row.
+// If progress is not nil, it is called with the counts of the rows imported so far after each batch.
+// It returns an error, together with the report of the rows imported so far, if the rows cannot be read,
+// if a batch cannot be added, or if the context is done.
+func (i *Importer) Run(ctx context.Context, rows Rows, progress func(models.ImportReport)) (models.ImportReport, error) {
+	report, err := i.run(ctx, rows, progress)
+
+	// Failed rows are reported as soon as they are read, whereas valid rows are reported once their batch is written
+	sort.Slice(report.Rows, func(a, b int) bool { return report.Rows[a].Row < report.Rows[b].Row })
+
+	return report, err
+}
+
+func (i *Importer) run(ctx context.Context, rows Rows, progress func(models.ImportReport)) (models.ImportReport, error) {
+	var (
+		report    models.ImportReport
+		batch     []*models.Book
+		batchRows []int
+	)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		results, err := i.store.AddBooks(ctx, batch)
+		if err != nil {
+			for _, row := range batchRows {
+				report.Add(i.failed(row, err))
+			}
+			return err
+		}
+
+		for j, book := range batch {
+			report.Add(i.outcome(batchRows[j], book, results[j]))
+		}
+		batch, batchRows = nil, nil
+
+		if progress != nil {
+			progress(models.ImportReport{Created: report.Created, Skipped: report.Skipped, Failed: report.Failed})
+		}
+		return nil
+	}
+
+	for row := 1; ; row++ {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		book, err := rows.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if !IsRowError(err) {
+				log.Event(ctx, "failed to read the rows of an import", log.ERROR, log.Error(err), log.Data{"row": row})
+				return report, errors.Wrap(err, "failed to read the rows of an import")
+			}
+			report.Add(i.failed(row, err))
+			continue
+		}
+
+		if err := i.validate(ctx, book); err != nil {
+			report.Add(i.failed(row, err))
+			continue
+		}
+
+		batch = append(batch, book)
+		batchRows = append(batchRows, row)
+		if len(batch) == i.batchSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	return report, flush()
+}
+
+// outcome returns the outcome of a row whose book has been written with the given error
+func (i *Importer) outcome(row int, book *models.Book, err error) models.ImportRow {
+	if err == nil {
+		return models.ImportRow{Row: row, Status: models.RowCreated, BookID: book.ID}
+	}
+
+	result := i.failed(row, err)
+	if i.problems.Problem(err).Status == http.StatusConflict {
+		result.Status = models.RowSkipped
+	}
+	return result
+}
+
+// failed returns the outcome of a row that cannot be imported because of the error
+func (i *Importer) failed(row int, err error) models.ImportRow {
+	problem := i.problems.Problem(err)
+	return models.ImportRow{
+		Row:    row,
+		Status: models.RowFailed,
+		Code:   problem.Code,
+		Detail: problem.Detail,
+		Errors: problem.Errors,
+	}
+}
@@ -0,0 +1,177 @@
+package importer
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/interfaces/mock"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	errDuplicate = errors.New("duplicate book")
+	errDatabase  = errors.New("database unavailable")
+)
+
+func testProblems() *apierrors.Registry {
+	r := apierrors.NewRegistry()
+	r.Register(errDuplicate, http.StatusConflict, "duplicate_book")
+	r.Register(ErrInvalidRow, http.StatusBadRequest, "invalid_import_row")
+	r.Register(apierrors.ErrRequiredFieldMissing, http.StatusBadRequest, "required_field_missing")
+	return r
+}
+
+func validate(ctx context.Context, book *models.Book) error {
+	return book.Validate()
+}
+
+// addBooks adds every book, except the books titled "Duplicate"
+func addBooks(ctx context.Context, books []*models.Book) ([]error, error) {
+	results := make([]error, len(books))
+	for i, book := range books {
+		if book.Title == "Duplicate" {
+			results[i] = errDuplicate
+		}
+	}
+	return results, nil
+}
+
+const importFile = "title,author\n" +
+	"Kindred,Octavia E. Butler\n" +
+	"Duplicate,Someone\n" +
+	",Nobody\n" +
+	"Good Omens,Terry Pratchett & Neil Gaiman\n" +
+	"Parable of the Sower,Octavia E. Butler,extra\n" +
+	"Dawn,Octavia E. Butler\n"
+
+func TestImporterRun(t *testing.T) {
+	Convey("Given a CSV file with valid, duplicate and invalid rows", t, func() {
+		dataStore := &mock.DataStoreMock{AddBooksFunc: addBooks}
+		importer := New(dataStore, validate, testProblems(), 2)
+
+		rows, err := NewReader(CSV, strings.NewReader(importFile))
+		So(err, ShouldBeNil)
+
+		Convey("When the rows are imported", func() {
+			var progress []models.ImportReport
+			report, err := importer.Run(context.Background(), rows, func(r models.ImportReport) {
+				progress = append(progress, r)
+			})
+			So(err, ShouldBeNil)
+
+			Convey("Then the valid books are added in batches", func() {
+				calls := dataStore.AddBooksCalls()
+				So(calls, ShouldHaveLength, 2)
+				So(calls[0].Books, ShouldHaveLength, 2)
+				So(calls[0].Books[0].Title, ShouldEqual, "Kindred")
+				So(calls[1].Books, ShouldHaveLength, 2)
+				So(calls[1].Books[1].Title, ShouldEqual, "Dawn")
+			})
+
+			Convey("Then the report counts the rows by outcome", func() {
+				So(report.Created, ShouldEqual, 3)
+				So(report.Skipped, ShouldEqual, 1)
+				So(report.Failed, ShouldEqual, 2)
+				So(report.Rows, ShouldHaveLength, 6)
+			})
+
+			Convey("Then a created row has the ID of its book", func() {
+				So(report.Rows[0].Row, ShouldEqual, 1)
+				So(report.Rows[0].Status, ShouldEqual, models.RowCreated)
+				So(report.Rows[0].BookID, ShouldEqual, dataStore.AddBooksCalls()[0].Books[0].ID)
+			})
+
+			Convey("Then a row that conflicts with an existing book is skipped", func() {
+				So(report.Rows[1], ShouldResemble, models.ImportRow{Row: 2, Status: models.RowSkipped, Code: "duplicate_book", Detail: errDuplicate.Error()})
+			})
+
+			Convey("Then the rows that are not valid fail, with their problem", func() {
+				So(report.Rows[2].Row, ShouldEqual, 3)
+				So(report.Rows[2].Status, ShouldEqual, models.RowFailed)
+				So(report.Rows[2].Code, ShouldEqual, "required_field_missing")
+				So(report.Rows[2].Errors, ShouldResemble, []apierrors.FieldError{{Field: "title", Rule: apierrors.RuleRequired}})
+
+				So(report.Rows[4].Row, ShouldEqual, 5)
+				So(report.Rows[4].Code, ShouldEqual, "invalid_import_row")
+			})
+
+			Convey("Then the progress is reported after each batch, without the rows", func() {
+				So(progress, ShouldResemble, []models.ImportReport{
+					{Created: 1, Skipped: 1, Failed: 0},
+					{Created: 3, Skipped: 1, Failed: 2},
+				})
+			})
+		})
+	})
+
+	Convey("Given a data store that cannot add books", t, func() {
+		dataStore := &mock.DataStoreMock{
+			AddBooksFunc: func(ctx context.Context, books []*models.Book) ([]error, error) {
+				return nil, errDatabase
+			},
+		}
+		importer := New(dataStore, validate, testProblems(), 2)
+
+		rows, err := NewReader(CSV, strings.NewReader(importFile))
+		So(err, ShouldBeNil)
+
+		Convey("When the rows are imported", func() {
+			report, err := importer.Run(context.Background(), rows, nil)
+
+			Convey("Then the import stops at the first batch, which fails", func() {
+				So(err, ShouldEqual, errDatabase)
+				So(dataStore.AddBooksCalls(), ShouldHaveLength, 1)
+				So(report.Failed, ShouldEqual, 2)
+				So(report.Rows[0].Code, ShouldEqual, "internal_error")
+			})
+		})
+	})
+}
+
+func TestJobs(t *testing.T) {
+	Convey("Given an import job", t, func() {
+		dataStore := &mock.DataStoreMock{AddBooksFunc: addBooks}
+		jobs := NewJobs(New(dataStore, validate, testProblems(), 2), time.Hour)
+
+		rows, err := NewReader(CSV, strings.NewReader(importFile))
+		So(err, ShouldBeNil)
+
+		done := make(chan struct{})
+		job := jobs.Start(CSV, rows, func() { close(done) })
+
+		Convey("When it is started", func() {
+			Convey("Then it is running", func() {
+				So(job.ID, ShouldNotBeEmpty)
+				So(job.State, ShouldEqual, models.ImportRunning)
+				So(job.Format, ShouldEqual, CSV)
+			})
+		})
+
+		Convey("When it has finished", func() {
+			<-done
+			jobs.Stop(context.Background())
+
+			Convey("Then its status is completed, with the report of every row", func() {
+				finished, err := jobs.Get(job.ID)
+				So(err, ShouldBeNil)
+				So(finished.State, ShouldEqual, models.ImportCompleted)
+				So(finished.FinishedAt, ShouldNotBeNil)
+				So(finished.Report.Created, ShouldEqual, 3)
+				So(finished.Report.Rows, ShouldHaveLength, 6)
+			})
+		})
+
+		Convey("When a job that does not exist is requested", func() {
+			_, err := jobs.Get("unknown")
+
+			Convey("Then it is not found", func() {
+				So(err, ShouldEqual, ErrJobNotFound)
+			})
+		})
+	})
+}
@@ -0,0 +1,130 @@
+package importer
+
+import (
+	"context"
+	"github.com/ONSdigital/log.go/log"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+// ErrJobNotFound represents an error case where an import job does not exist, or has been forgotten
+var ErrJobNotFound = errors.New("import job not found")
+
+// Jobs runs imports in the background, and keeps their status in memory.
+// Finished jobs are forgotten after the retention period, and every job is lost when the service restarts.
+// The jobs are local to the instance of the service that runs them: another instance does not find them.
+type Jobs struct {
+	importer  *Importer
+	retention time.Duration
+
+	mu   sync.Mutex
+	jobs map[string]*models.ImportJob
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewJobs creates a new instance of Jobs that runs the imports with the importer
+func NewJobs(importer *Importer, retention time.Duration) *Jobs {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Jobs{
+		importer:  importer,
+		retention: retention,
+		jobs:      make(map[string]*models.ImportJob),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Start imports the rows of a file in the given format in the background, and returns the running job.
+// The job does not depend on the request that started it. done is called once the rows have been imported.
+func (j *Jobs) Start(format string, rows Rows, done func()) models.ImportJob {
+	job := models.NewImportJob(format)
+
+	j.mu.Lock()
+	j.forgetFinished(time.Now().UTC())
+	j.jobs[job.ID] = job
+	started := *job
+	j.mu.Unlock()
+
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		defer done()
+
+		logData := log.Data{"import_job_id": job.ID, "format": format}
+		log.Event(j.ctx, "import job started", log.INFO, logData)
+
+		report, err := j.importer.Run(j.ctx, rows, func(progress models.ImportReport) {
+			j.mu.Lock()
+			job.Report = progress
+			j.mu.Unlock()
+		})
+
+		j.mu.Lock()
+		defer j.mu.Unlock()
+
+		finishedAt := time.Now().UTC()
+		job.FinishedAt = &finishedAt
+		job.Report = report
+		job.State = models.ImportCompleted
+
+		logData["created"] = report.Created
+		logData["skipped"] = report.Skipped
+		logData["failed"] = report.Failed
+		if err != nil {
+			job.State = models.ImportFailed
+			job.Error = err.Error()
+			log.Event(j.ctx, "import job failed", log.ERROR, log.Error(err), logData)
+			return
+		}
+		log.Event(j.ctx, "import job completed", log.INFO, logData)
+	}()
+
+	return started
+}
+
+// Get returns the current status of a job.
+// It returns ErrJobNotFound if the job does not exist, or if it finished longer than the retention period ago.
+func (j *Jobs) Get(id string) (models.ImportJob, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.forgetFinished(time.Now().UTC())
+
+	job, ok := j.jobs[id]
+	if !ok {
+		return models.ImportJob{}, ErrJobNotFound
+	}
+	return *job, nil
+}
+
+// Stop waits for the running jobs to finish until the context is done. Jobs still running by then are cancelled,
+// and Stop waits for them to finish the batch they are writing.
+func (j *Jobs) Stop(ctx context.Context) {
+	finished := make(chan struct{})
+	go func() {
+		j.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		j.cancel()
+		<-finished
+	}
+	j.cancel()
+}
+
+// forgetFinished removes the jobs that finished longer than the retention period before now. The lock must be held.
+func (j *Jobs) forgetFinished(now time.Time) {
+	for id, job := range j.jobs {
+		if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > j.retention {
+			delete(j.jobs, id)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/pkg/errors"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// Formats of the files that can be imported
+const (
+	CSV    = "csv"
+	NDJSON = "ndjson"
+)
+
+// maxLineSize is the size of the longest line of a JSON Lines file
+const maxLineSize = 1 << 20
+
+var (
+	// ErrUnsupportedFormat represents an error case where the media type of an import is neither CSV nor JSON Lines
+	ErrUnsupportedFormat = errors.New("unsupported import format. Please send text/csv or application/x-ndjson")
+
+	// ErrInvalidHeader represents an error case where the header of a CSV file has unknown or repeated columns
+	ErrInvalidHeader = errors.New("invalid CSV header. The columns must be fields of a book, each of them once")
+
+	// ErrInvalidRow represents an error case where a row cannot be parsed as a book
+	ErrInvalidRow = errors.New("invalid row. It cannot be parsed as a book")
+)
+
+// formats are the formats of the media types that can be imported
+var formats = map[string]string{
+	"text/csv":             CSV,
+	"application/x-ndjson": NDJSON,
+	"application/jsonl":    NDJSON,
+}
+
+// FormatOf returns the format of the media type of an import.
+// It returns ErrUnsupportedFormat if the media type cannot be imported.
+func FormatOf(contentType string) (string, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", ErrUnsupportedFormat
+	}
+
+	format, ok := formats[mediaType]
+	if !ok {
+		return "", ErrUnsupportedFormat
+	}
+	return format, nil
+}
+
+// Rows reads the books of a file, one row at a time, without reading the whole file into memory
+type Rows interface {
+	// Next returns the book of the next row. It returns io.EOF once there are no more rows, and a row error if the
+	// row cannot be parsed, in which case the next row can still be read. Any other error means the file cannot be read.
+	Next() (*models.Book, error)
+}
+
+// NewReader returns the Rows of a file in the given format.
+// It returns an error if the format is not supported, or if the header of a CSV file is not valid.
+func NewReader(format string, r io.Reader) (Rows, error) {
+	switch format {
+	case CSV:
+		return newCSVRows(r)
+	case NDJSON:
+		return newNDJSONRows(r), nil
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// IsRowError returns true if the error only affects the row that returned it
+func IsRowError(err error) bool {
+	var validationErr *apierrors.ValidationError
+	return errors.Is(err, ErrInvalidRow) || errors.As(err, &validationErr)
+}
+
+// A column sets a field of a book from the value of a CSV cell. It returns false if the value is not of the type of the field.
+type column func(book *models.Book, value string) bool
+
+// columns are the columns of a CSV file, named after the fields of a book. The genres are separated by semicolons.
+var columns = map[string]column{
+	"title":            stringColumn(func(b *models.Book, s string) { b.Title = s }),
+	"author":           stringColumn(func(b *models.Book, s string) { b.Author = s }),
+	"synopsis":         stringColumn(func(b *models.Book, s string) { b.Synopsis = s }),
+	"isbn":             stringColumn(func(b *models.Book, s string) { b.ISBN = s }),
+	"publisher":        stringColumn(func(b *models.Book, s string) { b.Publisher = s }),
+	"publication_year": integerColumn(func(b *models.Book, n int) { b.PublicationYear = n }),
+	"page_count":       integerColumn(func(b *models.Book, n int) { b.PageCount = n }),
+	"language":         stringColumn(func(b *models.Book, s string) { b.Language = s }),
+	"edition":          stringColumn(func(b *models.Book, s string) { b.Edition = s }),
+	"genres":           stringColumn(func(b *models.Book, s string) { b.Genres = strings.Split(s, ";") }),
+}
+
+func stringColumn(set func(*models.Book, string)) column {
+	return func(book *models.Book, value string) bool {
+		set(book, value)
+		return true
+	}
+}
+
+func integerColumn(set func(*models.Book, int)) column {
+	return func(book *models.Book, value string) bool {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return false
+		}
+		set(book, n)
+		return true
+	}
+}
+
+// csvRows reads the books of a CSV file with a header naming the field of each column
+type csvRows struct {
+	reader *csv.Reader
+	header []string
+}
+
+func newCSVRows(r io.Reader) (*csvRows, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, apierrors.ErrEmptyRequestBody
+		}
+		return nil, ErrInvalidHeader
+	}
+
+	seen := make(map[string]bool, len(header))
+	names := make([]string, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := columns[name]; !ok || seen[name] {
+			return nil, ErrInvalidHeader
+		}
+		seen[name] = true
+		names[i] = name
+	}
+
+	return &csvRows{reader: reader, header: names}, nil
+}
+
+func (c *csvRows) Next() (*models.Book, error) {
+	record, err := c.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, errors.Wrap(ErrInvalidRow, parseErr.Error())
+		}
+		return nil, err
+	}
+
+	if len(record) != len(c.header) {
+		return nil, errors.Wrap(ErrInvalidRow, "the row does not have a value for every column of the header")
+	}
+
+	book := models.NewBook()
+	var invalid []apierrors.FieldError
+	for i, value := range record {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !columns[c.header[i]](book, value) {
+			invalid = append(invalid, apierrors.FieldError{Field: c.header[i], Rule: apierrors.RuleType})
+		}
+	}
+
+	if len(invalid) > 0 {
+		return nil, apierrors.NewValidationError(apierrors.ErrInvalidBook, invalid...)
+	}
+	return book, nil
+}
+
+// ndjsonRows reads the books of a JSON Lines file, with a book on each line. Blank lines are ignored.
+type ndjsonRows struct {
+	scanner *bufio.Scanner
+}
+
+func newNDJSONRows(r io.Reader) *ndjsonRows {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	return &ndjsonRows{scanner: scanner}
+}
+
+func (n *ndjsonRows) Next() (*models.Book, error) {
+	for n.scanner.Scan() {
+		line := bytes.TrimSpace(n.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		book := models.NewBook()
+		id := book.ID
+		if err := json.Unmarshal(line, book); err != nil {
+			return nil, errors.Wrap(ErrInvalidRow, err.Error())
+		}
+
+		// The ID of an imported book is generated, its links are made when it is served, and its rating summary
+		// is calculated from its reviews
+		book.ID, book.Links, book.Ratings = id, nil, nil
+		return book, nil
+	}
+
+	if err := n.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
@@ -0,0 +1,137 @@
+package importer
+
+import (
+	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, rows Rows) ([]*models.Book, []error) {
+	t.Helper()
+	var (
+		books []*models.Book
+		errs  []error
+	)
+	for {
+		book, err := rows.Next()
+		if err == io.EOF {
+			return books, errs
+		}
+		books = append(books, book)
+		errs = append(errs, err)
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	Convey("Given the media type of an import", t, func() {
+		Convey("Then CSV and JSON Lines are supported, with parameters", func() {
+			format, err := FormatOf("text/csv; charset=utf-8")
+			So(err, ShouldBeNil)
+			So(format, ShouldEqual, CSV)
+
+			format, err = FormatOf("application/x-ndjson")
+			So(err, ShouldBeNil)
+			So(format, ShouldEqual, NDJSON)
+		})
+
+		Convey("Then any other media type is not supported", func() {
+			_, err := FormatOf("application/json")
+			So(err, ShouldEqual, ErrUnsupportedFormat)
+
+			_, err = FormatOf("")
+			So(err, ShouldEqual, ErrUnsupportedFormat)
+		})
+	})
+}
+
+func TestCSVRows(t *testing.T) {
+	Convey("Given a CSV file with a header", t, func() {
+		file := "Title,author, publication_year,genres\n" +
+			"Kindred,Octavia E. Butler,1979,science fiction;classics\n" +
+			"\"Good Omens\",\"Pratchett, Terry\",nineteen ninety,\n" +
+			"Parable of the Sower,Octavia E. Butler\n"
+
+		rows, err := NewReader(CSV, strings.NewReader(file))
+		So(err, ShouldBeNil)
+
+		Convey("When the rows are read", func() {
+			books, errs := readAll(t, rows)
+			So(books, ShouldHaveLength, 3)
+
+			Convey("Then the book of a valid row is returned, with a new ID", func() {
+				So(errs[0], ShouldBeNil)
+				So(books[0].ID, ShouldNotBeEmpty)
+				So(books[0].Links, ShouldBeNil)
+				So(books[0].Title, ShouldEqual, "Kindred")
+				So(books[0].Author, ShouldEqual, "Octavia E. Butler")
+				So(books[0].PublicationYear, ShouldEqual, 1979)
+				So(books[0].Genres, ShouldResemble, []string{"science fiction", "classics"})
+			})
+
+			Convey("Then a row with a value of the wrong type is a validation error of the column", func() {
+				var validationErr *apierrors.ValidationError
+				So(errors.As(errs[1], &validationErr), ShouldBeTrue)
+				So(validationErr.Fields, ShouldResemble, []apierrors.FieldError{{Field: "publication_year", Rule: apierrors.RuleType}})
+				So(IsRowError(errs[1]), ShouldBeTrue)
+			})
+
+			Convey("Then a row without a value for every column is an invalid row", func() {
+				So(errors.Is(errs[2], ErrInvalidRow), ShouldBeTrue)
+				So(IsRowError(errs[2]), ShouldBeTrue)
+			})
+		})
+	})
+
+	Convey("Given a CSV file with an unknown column", t, func() {
+		_, err := NewReader(CSV, strings.NewReader("title,shelf\nKindred,A3\n"))
+
+		Convey("Then the header is not valid", func() {
+			So(err, ShouldEqual, ErrInvalidHeader)
+		})
+	})
+
+	Convey("Given a CSV file with a repeated column", t, func() {
+		_, err := NewReader(CSV, strings.NewReader("title,Title\nKindred,Kindred\n"))
+
+		Convey("Then the header is not valid", func() {
+			So(err, ShouldEqual, ErrInvalidHeader)
+		})
+	})
+}
+
+func TestNDJSONRows(t *testing.T) {
+	Convey("Given a JSON Lines file with blank lines", t, func() {
+		file := `{"id":"chosen","title":"Kindred","author":"Octavia E. Butler","rating_summary":{"count":3}}` + "\n" +
+			"\n" +
+			`{"title":"Good Omens",` + "\n" +
+			`{"title":"Parable of the Sower","author":"Octavia E. Butler"}`
+
+		rows, err := NewReader(NDJSON, strings.NewReader(file))
+		So(err, ShouldBeNil)
+
+		Convey("When the rows are read", func() {
+			books, errs := readAll(t, rows)
+
+			Convey("Then there is a row for every line that is not blank", func() {
+				So(books, ShouldHaveLength, 3)
+				So(errs[0], ShouldBeNil)
+				So(errs[2], ShouldBeNil)
+				So(books[2].Title, ShouldEqual, "Parable of the Sower")
+			})
+
+			Convey("Then the ID of a book is generated, and its links and rating summary are ignored", func() {
+				So(books[0].ID, ShouldNotEqual, "chosen")
+				So(books[0].Links, ShouldBeNil)
+				So(books[0].Ratings, ShouldBeNil)
+			})
+
+			Convey("Then a line that is not valid JSON is an invalid row", func() {
+				So(errors.Is(errs[1], ErrInvalidRow), ShouldBeTrue)
+			})
+		})
+	})
+}
@@ -0,0 +1,119 @@
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"github.com/cadmiumcat/books-api/models"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// mediaTypes are the media types of the formats, as they are served
+var mediaTypes = map[string]string{
+	CSV:    "text/csv; charset=utf-8",
+	NDJSON: "application/x-ndjson",
+}
+
+// MediaType returns the media type of a format, or an empty string if the format is not supported
+func MediaType(format string) string {
+	return mediaTypes[format]
+}
+
+// A Writer writes books to a file in one of the formats that can be imported, so that an export of a catalogue can
+// be imported into another one
+type Writer interface {
+	// Write writes a book, with its reviews. The reviews are only written in the JSON Lines format.
+	Write(book *models.Book, reviews []models.Review) error
+
+	// Flush writes any buffered data, and returns any error that occurred while writing
+	Flush() error
+}
+
+// NewWriter returns a Writer of a file in the given format.
+// It returns ErrUnsupportedFormat if the format is not supported.
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case CSV:
+		return newCSVWriter(w), nil
+	case NDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}, nil
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// An exportColumn is a column of an exported CSV file, with the value of the field of a book that it is named after
+type exportColumn struct {
+	name  string
+	value func(book *models.Book) string
+}
+
+// exportColumns are the columns of an exported CSV file, which are the columns that can be imported
+var exportColumns = []exportColumn{
+	{"title", func(b *models.Book) string { return b.Title }},
+	{"author", func(b *models.Book) string { return b.Author }},
+	{"synopsis", func(b *models.Book) string { return b.Synopsis }},
+	{"isbn", func(b *models.Book) string { return b.ISBN }},
+	{"publisher", func(b *models.Book) string { return b.Publisher }},
+	{"publication_year", func(b *models.Book) string { return integerCell(b.PublicationYear) }},
+	{"page_count", func(b *models.Book) string { return integerCell(b.PageCount) }},
+	{"language", func(b *models.Book) string { return b.Language }},
+	{"edition", func(b *models.Book) string { return b.Edition }},
+	{"genres", func(b *models.Book) string { return strings.Join(b.Genres, ";") }},
+}
+
+// integerCell returns the cell of an optional integer field, which is empty if the field is not set
+func integerCell(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// csvWriter writes the books to a CSV file, with a header naming the field of each column
+type csvWriter struct {
+	writer *csv.Writer
+	record []string
+	err    error
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	c := &csvWriter{writer: csv.NewWriter(w), record: make([]string, len(exportColumns))}
+	for i, column := range exportColumns {
+		c.record[i] = column.name
+	}
+	c.err = c.writer.Write(c.record)
+	return c
+}
+
+func (c *csvWriter) Write(book *models.Book, reviews []models.Review) error {
+	if c.err != nil {
+		return c.err
+	}
+	for i, column := range exportColumns {
+		c.record[i] = column.value(book)
+	}
+	return c.writer.Write(c.record)
+}
+
+func (c *csvWriter) Flush() error {
+	if c.err != nil {
+		return c.err
+	}
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// ndjsonWriter writes the books to a JSON Lines file, with a book and its reviews on each line
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(book *models.Book, reviews []models.Review) error {
+	return n.encoder.Encode(models.ExportedBook{Book: book, Reviews: reviews})
+}
+
+func (n *ndjsonWriter) Flush() error {
+	return nil
+}
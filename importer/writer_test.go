@@ -0,0 +1,85 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/cadmiumcat/books-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+	"strings"
+	"testing"
+)
+
+var exportedBook = &models.Book{
+	ID:              "1",
+	Title:           "Good Omens",
+	Author:          "Terry Pratchett & Neil Gaiman",
+	Synopsis:        "The world ends on a Saturday. Next Saturday, in fact",
+	ISBN:            "9780575048003",
+	PublicationYear: 1990,
+	Genres:          []string{"fantasy", "comedy"},
+}
+
+func TestCSVWriter(t *testing.T) {
+	Convey("Given a book exported as CSV", t, func() {
+		var file bytes.Buffer
+		writer, err := NewWriter(CSV, &file)
+		So(err, ShouldBeNil)
+
+		So(writer.Write(exportedBook, nil), ShouldBeNil)
+		So(writer.Flush(), ShouldBeNil)
+
+		Convey("Then the file has a header, and a row with the fields of the book", func() {
+			lines := strings.Split(file.String(), "\n")
+			So(lines[0], ShouldEqual, "title,author,synopsis,isbn,publisher,publication_year,page_count,language,edition,genres")
+			So(lines[1], ShouldEqual, `Good Omens,Terry Pratchett & Neil Gaiman,"The world ends on a Saturday. Next Saturday, in fact",9780575048003,,1990,,,,fantasy;comedy`)
+		})
+
+		Convey("Then the file can be imported", func() {
+			rows, err := NewReader(CSV, &file)
+			So(err, ShouldBeNil)
+
+			imported, errs := readAll(t, rows)
+			So(errs, ShouldResemble, []error{nil})
+			So(imported[0].Title, ShouldEqual, exportedBook.Title)
+			So(imported[0].Synopsis, ShouldEqual, exportedBook.Synopsis)
+			So(imported[0].PublicationYear, ShouldEqual, exportedBook.PublicationYear)
+			So(imported[0].Genres, ShouldResemble, exportedBook.Genres)
+		})
+	})
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	Convey("Given books exported as JSON Lines, with their reviews", t, func() {
+		review := models.Review{ID: "review", Message: "Wonderful", State: models.ReviewApproved}
+
+		var file bytes.Buffer
+		writer, err := NewWriter(NDJSON, &file)
+		So(err, ShouldBeNil)
+
+		So(writer.Write(exportedBook, []models.Review{review}), ShouldBeNil)
+		So(writer.Write(&models.Book{ID: "2", Title: "Kindred", Author: "Octavia E. Butler"}, []models.Review{}), ShouldBeNil)
+		So(writer.Flush(), ShouldBeNil)
+
+		Convey("Then each book is on its own line, with its reviews", func() {
+			lines := strings.Split(strings.TrimSpace(file.String()), "\n")
+			So(lines, ShouldHaveLength, 2)
+
+			var book models.ExportedBook
+			So(json.Unmarshal([]byte(lines[0]), &book), ShouldBeNil)
+			So(book.ID, ShouldEqual, exportedBook.ID)
+			So(book.ISBN, ShouldEqual, exportedBook.ISBN)
+			So(book.Reviews, ShouldHaveLength, 1)
+			So(book.Reviews[0].Message, ShouldEqual, review.Message)
+
+			So(lines[1], ShouldNotContainSubstring, "reviews")
+		})
+	})
+
+	Convey("Given a format that cannot be exported", t, func() {
+		_, err := NewWriter("xml", &bytes.Buffer{})
+
+		Convey("Then it is not supported", func() {
+			So(err, ShouldEqual, ErrUnsupportedFormat)
+		})
+	})
+}
@@ -1,20 +1,173 @@
 package initialiser
 
 import (
+	"context"
 	dpHttp "github.com/ONSdigital/dp-net/http"
+	"github.com/ONSdigital/log.go/log"
 	"github.com/cadmiumcat/books-api/api"
+	"github.com/cadmiumcat/books-api/config"
+	"github.com/cadmiumcat/books-api/events"
 	"github.com/cadmiumcat/books-api/interfaces"
+	"github.com/cadmiumcat/books-api/memory"
+	"github.com/cadmiumcat/books-api/mongo"
 	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
 	"net/http"
+	"os"
+	"time"
 )
 
+var (
+	// ErrUnknownEventProducer represents an error case where the configured event producer is not supported
+	ErrUnknownEventProducer = errors.New("unknown event producer. Use kafka or local")
+
+	// ErrUnknownDataStore represents an error case where the configured data store is not supported
+	ErrUnknownDataStore = errors.New("unknown data store. Use mongo or memory")
+
+	// ErrShutdownTimeout represents an error case where the service did not shut down within the graceful shutdown timeout
+	ErrShutdownTimeout = errors.New("the service did not shut down within the graceful shutdown timeout")
+
+	// ErrShutdown represents an error case where a dependency of the service failed to stop or close during shut down
+	ErrShutdown = errors.New("failed to shut down the service cleanly")
+)
+
+// Service is the API, together with the server that serves it and the dependencies whose lifecycle it manages.
+// The dependencies that are not set are ignored when the service shuts down.
 type Service struct {
-	Server interfaces.HTTPServer
-	Router *mux.Router
-	API    *api.API
+	Server          interfaces.HTTPServer
+	Router          *mux.Router
+	API             *api.API
+	EventProducer   interfaces.EventProducer
+	DataStore       interfaces.DataStore
+	HealthCheck     interfaces.HealthChecker
+	Relay           interfaces.Relay
+	ShutdownTimeout time.Duration
+}
+
+// Run serves the API until a signal is received, or until the server fails, and then shuts the service down.
+// It returns an error if the server fails, or if the service does not shut down cleanly.
+func (svc *Service) Run(ctx context.Context, signals <-chan os.Signal) error {
+	serverErrors := make(chan error, 1)
+	go func() {
+		if err := svc.Server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			serverErrors <- err
+		}
+	}()
+
+	select {
+	case signal := <-signals:
+		log.Event(ctx, "os signal received, shutting down", log.INFO, log.Data{"signal": signal.String()})
+	case err := <-serverErrors:
+		log.Event(ctx, "http server failed, shutting down", log.ERROR, log.Error(err))
+		svc.Shutdown(ctx)
+		return err
+	}
+
+	return svc.Shutdown(ctx)
+}
+
+// Shutdown stops the health check, stops accepting connections and drains the requests in flight, stops the import
+// jobs and the relay of the outbox, and closes the event producer and the data store, all within the shutdown timeout.
+// It returns ErrShutdownTimeout if the timeout expires first, and ErrShutdown if any of the steps fails.
+func (svc *Service) Shutdown(ctx context.Context) error {
+	log.Event(ctx, "shutting down the service", log.INFO, log.Data{"timeout": svc.ShutdownTimeout.String()})
+
+	ctx, cancel := context.WithTimeout(ctx, svc.ShutdownTimeout)
+	defer cancel()
+
+	var hasErrors bool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		// The health check depends on everything else, so it is stopped first
+		if svc.HealthCheck != nil {
+			svc.HealthCheck.Stop()
+		}
+
+		// The requests in flight are drained before the connections they use are closed
+		if svc.Server != nil {
+			if err := svc.Server.Shutdown(ctx); err != nil {
+				hasErrors = true
+				log.Event(ctx, "failed to shut down the http server", log.ERROR, log.Error(err))
+			}
+		}
+
+		if svc.API != nil {
+			svc.API.Close(ctx)
+		}
+
+		if svc.Relay != nil {
+			svc.Relay.Stop()
+		}
+
+		if svc.EventProducer != nil {
+			if err := svc.EventProducer.Close(ctx); err != nil {
+				hasErrors = true
+				log.Event(ctx, "failed to close the event producer", log.ERROR, log.Error(err))
+			}
+		}
+
+		if svc.DataStore != nil {
+			if err := svc.DataStore.Close(ctx); err != nil {
+				hasErrors = true
+				log.Event(ctx, "failed to close the data store", log.ERROR, log.Error(err))
+			}
+		}
+	}()
+
+	// The steps still running when the timeout expires are abandoned, as the process is about to exit
+	var timedOut bool
+	select {
+	case <-done:
+		timedOut = ctx.Err() != nil
+	case <-ctx.Done():
+		timedOut = true
+	}
+
+	if timedOut {
+		log.Event(ctx, "shutdown timed out", log.ERROR, log.Error(ErrShutdownTimeout))
+		return ErrShutdownTimeout
+	}
+	if hasErrors {
+		return ErrShutdown
+	}
+
+	log.Event(ctx, "the service shut down cleanly", log.INFO)
+	return nil
 }
 
-func GetHTTPServer(bindAddr string, router http.Handler) interfaces.HTTPServer {
+// GetHTTPServer returns the HTTP server of the router. The write timeout bounds the time taken to write a response,
+// apart from the responses that extend their write deadline as they are streamed.
+// The server does not handle the OS signals itself, as the Service shuts it down.
+func GetHTTPServer(bindAddr string, writeTimeout time.Duration, router http.Handler) interfaces.HTTPServer {
 	httpServer := dpHttp.NewServer(bindAddr, router)
+	httpServer.WriteTimeout = writeTimeout
+	httpServer.ConnContext = api.ConnContext
+	httpServer.HandleOSSignals = false
 	return httpServer
 }
+
+// GetEventProducer returns the event producer selected in the configuration
+func GetEventProducer(cfg *config.Configuration) (interfaces.EventProducer, error) {
+	switch cfg.EventProducer {
+	case "kafka":
+		return events.NewKafkaProducer(cfg.KafkaConfig.Brokers, cfg.KafkaConfig.BookEventsTopic), nil
+	case "local":
+		return events.NewLocalProducer(cfg.EventsFile)
+	default:
+		return nil, ErrUnknownEventProducer
+	}
+}
+
+// GetDataStore returns the data store selected in the configuration, which must be initialised before it is used
+func GetDataStore(cfg *config.Configuration) (interfaces.Store, error) {
+	switch cfg.DataStore {
+	case "mongo":
+		return &mongo.Mongo{}, nil
+	case "memory":
+		return memory.New(), nil
+	default:
+		return nil, ErrUnknownDataStore
+	}
+}
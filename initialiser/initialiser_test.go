@@ -0,0 +1,177 @@
+package initialiser
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/config"
+	"github.com/cadmiumcat/books-api/interfaces/mock"
+	"github.com/cadmiumcat/books-api/memory"
+	"github.com/cadmiumcat/books-api/mongo"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+var errClose = errors.New("failed to close")
+
+// serverMock serves until it is shut down, and then waits for the requests in flight for the given drain time
+func serverMock(drain time.Duration) *mock.HTTPServerMock {
+	closed := make(chan struct{})
+	var once sync.Once
+	return &mock.HTTPServerMock{
+		ListenAndServeFunc: func() error {
+			<-closed
+			return http.ErrServerClosed
+		},
+		ShutdownFunc: func(ctx context.Context) error {
+			once.Do(func() { close(closed) })
+			select {
+			case <-time.After(drain):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	}
+}
+
+// service returns a Service whose dependencies record the order in which they are stopped
+func service(server *mock.HTTPServerMock) (*Service, *[]string) {
+	var (
+		mu      sync.Mutex
+		stopped []string
+	)
+	stop := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = append(stopped, name)
+	}
+
+	return &Service{
+		Server:      server,
+		HealthCheck: &mock.HealthCheckerMock{StopFunc: func() { stop("health check") }},
+		Relay:       &mock.RelayMock{StopFunc: func() { stop("relay") }},
+		EventProducer: &mock.EventProducerMock{CloseFunc: func(ctx context.Context) error {
+			stop("event producer")
+			return nil
+		}},
+		DataStore: &mock.DataStoreMock{CloseFunc: func(ctx context.Context) error {
+			stop("data store")
+			return nil
+		}},
+		ShutdownTimeout: time.Second,
+	}, &stopped
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given a running service", t, func() {
+		server := serverMock(0)
+		svc, stopped := service(server)
+
+		signals := make(chan os.Signal, 1)
+		result := make(chan error, 1)
+		go func() { result <- svc.Run(context.Background(), signals) }()
+
+		Convey("When it receives a SIGTERM", func() {
+			signals <- syscall.SIGTERM
+			err := <-result
+
+			Convey("Then it shuts down cleanly", func() {
+				So(err, ShouldBeNil)
+				So(server.ShutdownCalls(), ShouldHaveLength, 1)
+			})
+
+			Convey("And the health check is stopped first, and the data store is closed last", func() {
+				So(*stopped, ShouldResemble, []string{"health check", "relay", "event producer", "data store"})
+			})
+		})
+	})
+
+	Convey("Given a service whose server fails", t, func() {
+		server := serverMock(0)
+		server.ListenAndServeFunc = func() error {
+			return errors.New("address already in use")
+		}
+		svc, stopped := service(server)
+
+		Convey("When it is run", func() {
+			err := svc.Run(context.Background(), make(chan os.Signal))
+
+			Convey("Then the error of the server is returned, and the service is shut down", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "address already in use")
+				So(*stopped, ShouldHaveLength, 4)
+			})
+		})
+	})
+}
+
+func TestShutdown(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given a service whose requests in flight take longer than the shutdown timeout to drain", t, func() {
+		svc, _ := service(serverMock(time.Minute))
+		svc.ShutdownTimeout = 10 * time.Millisecond
+
+		Convey("When it is shut down", func() {
+			err := svc.Shutdown(context.Background())
+
+			Convey("Then the shutdown times out", func() {
+				So(err, ShouldEqual, ErrShutdownTimeout)
+			})
+		})
+	})
+
+	Convey("Given a service whose data store fails to close", t, func() {
+		svc, stopped := service(serverMock(0))
+		svc.DataStore = &mock.DataStoreMock{CloseFunc: func(ctx context.Context) error {
+			return errClose
+		}}
+
+		Convey("When it is shut down", func() {
+			err := svc.Shutdown(context.Background())
+
+			Convey("Then the other dependencies are still stopped, and the shutdown fails", func() {
+				So(err, ShouldEqual, ErrShutdown)
+				So(*stopped, ShouldResemble, []string{"health check", "relay", "event producer"})
+			})
+		})
+	})
+
+	Convey("Given a service without dependencies", t, func() {
+		svc := &Service{ShutdownTimeout: time.Second}
+
+		Convey("When it is shut down", func() {
+			err := svc.Shutdown(context.Background())
+
+			Convey("Then it shuts down cleanly", func() {
+				So(err, ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestGetDataStore(t *testing.T) {
+	Convey("Given the data store selected in the configuration", t, func() {
+		Convey("Then MongoDB and the in-memory data store are supported", func() {
+			dataStore, err := GetDataStore(&config.Configuration{DataStore: "mongo"})
+			So(err, ShouldBeNil)
+			So(dataStore, ShouldHaveSameTypeAs, &mongo.Mongo{})
+
+			dataStore, err = GetDataStore(&config.Configuration{DataStore: "memory"})
+			So(err, ShouldBeNil)
+			So(dataStore, ShouldHaveSameTypeAs, &memory.Store{})
+		})
+
+		Convey("Then any other data store is not supported", func() {
+			_, err := GetDataStore(&config.Configuration{DataStore: "postgres"})
+			So(err, ShouldEqual, ErrUnknownDataStore)
+		})
+	})
+}
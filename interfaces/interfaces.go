@@ -3,13 +3,25 @@ package interfaces
 import (
 	"context"
 	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/cadmiumcat/books-api/auth"
 	"github.com/cadmiumcat/books-api/config"
+	"github.com/cadmiumcat/books-api/events"
 	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/cadmiumcat/books-api/query"
 	"net/http"
+	"time"
 )
 
 //go:generate moq -out mock/paginator.go -pkg mock . Paginator
 //go:generate moq -out mock/datastore.go -pkg mock . DataStore
+//go:generate moq -out mock/searcher.go -pkg mock . Searcher
+//go:generate moq -out mock/authenticator.go -pkg mock . Authenticator
+//go:generate moq -out mock/eventproducer.go -pkg mock . EventProducer
+//go:generate moq -out mock/outbox.go -pkg mock . Outbox
+//go:generate moq -out mock/migrationstore.go -pkg mock . MigrationStore
+//go:generate moq -out mock/idempotencystore.go -pkg mock . IdempotencyStore
+//go:generate moq -out mock/relay.go -pkg mock . Relay
 //go:generate moq -out mock/healthcheck.go -pkg mock . HealthChecker
 //go:generate moq -out mock/server.go -pkg mock . HTTPServer
 //go:generate moq -out mock/initaliser.go -pkg mock . Initialiser
@@ -17,19 +29,99 @@ import (
 // Paginator defines the required methods from the paginator package
 type Paginator interface {
 	GetPaginationValues(r *http.Request) (offset int, limit int, err error)
+	GetCursor(r *http.Request, q *query.Query) (*pagination.Cursor, error)
+	NewPage(r *http.Request, q *query.Query, cursor *pagination.Cursor, items interface{}, offset, limit, totalCount int) (pagination.Page, error)
 }
 
-// DataStore implements the methods required to interact with the database
+// DataStore implements the methods required to interact with the database.
+// A write that the database rejects, or cannot complete in time, returns a *mongo.WriteError, whose kind is
+// mongo.ErrDuplicateKey or mongo.ErrUnavailable.
+// Books and reviews are only changed or removed at the revision that was read, and their revision is incremented
+// every time they change.
 type DataStore interface {
 	Init(config.MongoConfig) (err error)
 	Close(ctx context.Context) (err error)
 	AddBook(ctx context.Context, book *models.Book) (err error)
+	AddBooks(ctx context.Context, books []*models.Book) ([]error, error)
 	GetBook(ctx context.Context, id string) (*models.Book, error)
-	GetBooks(ctx context.Context, offset, limit int) ([]models.Book, int, error)
+	GetBooks(ctx context.Context, q *query.Query, cursor *pagination.Cursor, offset, limit int) ([]models.Book, int, error)
+	UpdateBook(ctx context.Context, id string, book *models.Book) (err error)
+	PatchBook(ctx context.Context, id string, revision int, patch map[string]interface{}) (err error)
+	DeleteBook(ctx context.Context, id string, revision int, cascadeReviews bool) (err error)
+	ExportBooks(ctx context.Context, reviews *query.Query, export func(book *models.Book, reviews []models.Review) error) (err error)
+	AddAuthor(ctx context.Context, author *models.Author) (err error)
+	GetAuthor(ctx context.Context, id string) (*models.Author, error)
+	GetAuthors(ctx context.Context, q *query.Query, cursor *pagination.Cursor, offset, limit int) ([]models.Author, int, error)
+	UpdateAuthor(ctx context.Context, id string, author *models.Author) (err error)
+	DeleteAuthor(ctx context.Context, id string) (err error)
 	GetReview(ctx context.Context, reviewID string) (*models.Review, error)
-	GetReviews(ctx context.Context, bookID string, offset, limit int) ([]models.Review, int, error)
+	GetReviews(ctx context.Context, bookID string, q *query.Query, cursor *pagination.Cursor, offset, limit int) ([]models.Review, int, error)
 	AddReview(ctx context.Context, review *models.Review) (err error)
-	UpdateReview(ctx context.Context, reviewID string, review *models.Review) (err error)
+	UpdateReview(ctx context.Context, review *models.Review, previous *models.Review) (*models.Review, error)
+	UpdateReviewState(ctx context.Context, review *models.Review, previousState string) (err error)
+	DeleteReview(ctx context.Context, review *models.Review) (err error)
+	AddReservation(ctx context.Context, reservation *models.Reservation) (err error)
+	GetReservation(ctx context.Context, reservationID string) (*models.Reservation, error)
+	GetReservations(ctx context.Context, bookID string, offset, limit int) ([]models.Reservation, int, error)
+	UpdateReservation(ctx context.Context, reservation *models.Reservation, previousState string) (err error)
+	DeleteReservation(ctx context.Context, reservationID string) (err error)
+}
+
+// Store is a DataStore that can also search the books, that holds the outbox of the events of the changes it stores,
+// and that records the responses to the requests made with an idempotency key
+type Store interface {
+	DataStore
+	Searcher
+	Outbox
+	IdempotencyStore
+}
+
+// Searcher finds the books that match a full-text query, ranked by relevance
+type Searcher interface {
+	SearchBooks(ctx context.Context, query string, offset, limit int) ([]models.SearchResult, int, error)
+}
+
+// Authenticator identifies the caller of a request
+type Authenticator interface {
+	Authenticate(r *http.Request) (*auth.Identity, error)
+}
+
+// EventProducer publishes the events that describe changes to books and reviews
+type EventProducer interface {
+	Publish(ctx context.Context, event *events.Event) (err error)
+	Close(ctx context.Context) (err error)
+}
+
+// Outbox holds the events that have been stored together with the changes they describe, until they are published
+type Outbox interface {
+	GetPendingEvents(ctx context.Context, limit int) ([]events.Event, error)
+	DeletePublishedEvent(ctx context.Context, eventID string) (err error)
+	CountPendingEvents(ctx context.Context) (int, error)
+}
+
+// MigrationStore records the migrations applied to a data store, and holds the lock that lets a single instance
+// of the service migrate it at a time
+type MigrationStore interface {
+	GetAppliedMigrations(ctx context.Context) ([]models.AppliedMigration, error)
+	AddAppliedMigration(ctx context.Context, migration *models.AppliedMigration) (err error)
+	DeleteAppliedMigration(ctx context.Context, version int) (err error)
+	AcquireMigrationLock(ctx context.Context, owner string, ttl time.Duration) (err error)
+	ReleaseMigrationLock(ctx context.Context, owner string) (err error)
+}
+
+// IdempotencyStore records the requests made with an idempotency key, and the responses they were given.
+// A key is reserved by the first request that uses it, until it expires: the reservation of a key that is already
+// reserved returns the record of the request that reserved it instead.
+type IdempotencyStore interface {
+	ReserveIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) (err error)
+	ReleaseIdempotencyKey(ctx context.Context, id string) (err error)
+}
+
+// Relay publishes the events of the outbox in the background, until it is stopped
+type Relay interface {
+	Start(ctx context.Context)
+	Stop()
 }
 
 // HealthChecker defines the required methods from Healthcheck
@@ -42,8 +134,9 @@ type HealthChecker interface {
 
 type HTTPServer interface {
 	ListenAndServe() error
+	Shutdown(ctx context.Context) error
 }
 
 type Initialiser interface {
-	GetHTTPServer(BindAddr string, router http.Handler) HTTPServer
+	GetHTTPServer(BindAddr string, writeTimeout time.Duration, router http.Handler) HTTPServer
 }
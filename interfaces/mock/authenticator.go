@@ -0,0 +1,76 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mock
+
+import (
+	"github.com/cadmiumcat/books-api/auth"
+	"github.com/cadmiumcat/books-api/interfaces"
+	"net/http"
+	"sync"
+)
+
+// Ensure, that AuthenticatorMock does implement interfaces.Authenticator.
+// If this is not the case, regenerate this file with moq.
+var _ interfaces.Authenticator = &AuthenticatorMock{}
+
+// AuthenticatorMock is a mock implementation of interfaces.Authenticator.
+//
+//     func TestSomethingThatUsesAuthenticator(t *testing.T) {
+//
+//         // make and configure a mocked interfaces.Authenticator
+//         mockedAuthenticator := &AuthenticatorMock{
+//             AuthenticateFunc: func(r *http.Request) (*auth.Identity, error) {
+// 	               panic("mock out the Authenticate method")
+//             },
+//         }
+//
+//         // use mockedAuthenticator in code that requires interfaces.Authenticator
+//         // and then make assertions.
+//
+//     }
+type AuthenticatorMock struct {
+	// AuthenticateFunc mocks the Authenticate method.
+	AuthenticateFunc func(r *http.Request) (*auth.Identity, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Authenticate holds details about calls to the Authenticate method.
+		Authenticate []struct {
+			// R is the r argument value.
+			R *http.Request
+		}
+	}
+	lockAuthenticate sync.RWMutex
+}
+
+// Authenticate calls AuthenticateFunc.
+func (mock *AuthenticatorMock) Authenticate(r *http.Request) (*auth.Identity, error) {
+	if mock.AuthenticateFunc == nil {
+		panic("AuthenticatorMock.AuthenticateFunc: method is nil but Authenticator.Authenticate was just called")
+	}
+	callInfo := struct {
+		R *http.Request
+	}{
+		R: r,
+	}
+	mock.lockAuthenticate.Lock()
+	mock.calls.Authenticate = append(mock.calls.Authenticate, callInfo)
+	mock.lockAuthenticate.Unlock()
+	return mock.AuthenticateFunc(r)
+}
+
+// AuthenticateCalls gets all the calls that were made to Authenticate.
+// Check the length with:
+//     len(mockedAuthenticator.AuthenticateCalls())
+func (mock *AuthenticatorMock) AuthenticateCalls() []struct {
+	R *http.Request
+} {
+	var calls []struct {
+		R *http.Request
+	}
+	mock.lockAuthenticate.RLock()
+	calls = mock.calls.Authenticate
+	mock.lockAuthenticate.RUnlock()
+	return calls
+}
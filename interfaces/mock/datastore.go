@@ -8,6 +8,8 @@ import (
 	"github.com/cadmiumcat/books-api/config"
 	"github.com/cadmiumcat/books-api/interfaces"
 	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/cadmiumcat/books-api/query"
 	"sync"
 )
 
@@ -21,33 +23,84 @@ var _ interfaces.DataStore = &DataStoreMock{}
 //
 //         // make and configure a mocked interfaces.DataStore
 //         mockedDataStore := &DataStoreMock{
+//             AddAuthorFunc: func(ctx context.Context, author *models.Author) error {
+// 	               panic("mock out the AddAuthor method")
+//             },
 //             AddBookFunc: func(ctx context.Context, book *models.Book) error {
 // 	               panic("mock out the AddBook method")
 //             },
+//             AddBooksFunc: func(ctx context.Context, books []*models.Book) ([]error, error) {
+// 	               panic("mock out the AddBooks method")
+//             },
+//             AddReservationFunc: func(ctx context.Context, reservation *models.Reservation) error {
+// 	               panic("mock out the AddReservation method")
+//             },
 //             AddReviewFunc: func(ctx context.Context, review *models.Review) error {
 // 	               panic("mock out the AddReview method")
 //             },
 //             CloseFunc: func(ctx context.Context) error {
 // 	               panic("mock out the Close method")
 //             },
+//             DeleteAuthorFunc: func(ctx context.Context, id string) error {
+// 	               panic("mock out the DeleteAuthor method")
+//             },
+//             DeleteBookFunc: func(ctx context.Context, id string, revision int, cascadeReviews bool) error {
+// 	               panic("mock out the DeleteBook method")
+//             },
+//             DeleteReservationFunc: func(ctx context.Context, reservationID string) error {
+// 	               panic("mock out the DeleteReservation method")
+//             },
+//             DeleteReviewFunc: func(ctx context.Context, review *models.Review) error {
+// 	               panic("mock out the DeleteReview method")
+//             },
+//             ExportBooksFunc: func(ctx context.Context, reviews *query.Query, export func(book *models.Book, reviews []models.Review) error) error {
+// 	               panic("mock out the ExportBooks method")
+//             },
+//             GetAuthorFunc: func(ctx context.Context, id string) (*models.Author, error) {
+// 	               panic("mock out the GetAuthor method")
+//             },
+//             GetAuthorsFunc: func(ctx context.Context, q *query.Query, cursor *pagination.Cursor, offset int, limit int) ([]models.Author, int, error) {
+// 	               panic("mock out the GetAuthors method")
+//             },
 //             GetBookFunc: func(ctx context.Context, id string) (*models.Book, error) {
 // 	               panic("mock out the GetBook method")
 //             },
-//             GetBooksFunc: func(ctx context.Context, offset int, limit int) ([]models.Book, int, error) {
+//             GetBooksFunc: func(ctx context.Context, q *query.Query, cursor *pagination.Cursor, offset int, limit int) ([]models.Book, int, error) {
 // 	               panic("mock out the GetBooks method")
 //             },
+//             GetReservationFunc: func(ctx context.Context, reservationID string) (*models.Reservation, error) {
+// 	               panic("mock out the GetReservation method")
+//             },
+//             GetReservationsFunc: func(ctx context.Context, bookID string, offset int, limit int) ([]models.Reservation, int, error) {
+// 	               panic("mock out the GetReservations method")
+//             },
 //             GetReviewFunc: func(ctx context.Context, reviewID string) (*models.Review, error) {
 // 	               panic("mock out the GetReview method")
 //             },
-//             GetReviewsFunc: func(ctx context.Context, bookID string, offset int, limit int) ([]models.Review, int, error) {
+//             GetReviewsFunc: func(ctx context.Context, bookID string, q *query.Query, cursor *pagination.Cursor, offset int, limit int) ([]models.Review, int, error) {
 // 	               panic("mock out the GetReviews method")
 //             },
 //             InitFunc: func(in1 config.MongoConfig) error {
 // 	               panic("mock out the Init method")
 //             },
-//             UpdateReviewFunc: func(ctx context.Context, reviewID string, review *models.Review) error {
+//             PatchBookFunc: func(ctx context.Context, id string, revision int, patch map[string]interface{}) error {
+// 	               panic("mock out the PatchBook method")
+//             },
+//             UpdateAuthorFunc: func(ctx context.Context, id string, author *models.Author) error {
+// 	               panic("mock out the UpdateAuthor method")
+//             },
+//             UpdateBookFunc: func(ctx context.Context, id string, book *models.Book) error {
+// 	               panic("mock out the UpdateBook method")
+//             },
+//             UpdateReservationFunc: func(ctx context.Context, reservation *models.Reservation, previousState string) error {
+// 	               panic("mock out the UpdateReservation method")
+//             },
+//             UpdateReviewFunc: func(ctx context.Context, review *models.Review, previous *models.Review) (*models.Review, error) {
 // 	               panic("mock out the UpdateReview method")
 //             },
+//             UpdateReviewStateFunc: func(ctx context.Context, review *models.Review, previousState string) error {
+// 	               panic("mock out the UpdateReviewState method")
+//             },
 //         }
 //
 //         // use mockedDataStore in code that requires interfaces.DataStore
@@ -55,35 +108,93 @@ var _ interfaces.DataStore = &DataStoreMock{}
 //
 //     }
 type DataStoreMock struct {
+	// AddAuthorFunc mocks the AddAuthor method.
+	AddAuthorFunc func(ctx context.Context, author *models.Author) error
+
 	// AddBookFunc mocks the AddBook method.
 	AddBookFunc func(ctx context.Context, book *models.Book) error
 
+	// AddBooksFunc mocks the AddBooks method.
+	AddBooksFunc func(ctx context.Context, books []*models.Book) ([]error, error)
+
+	// AddReservationFunc mocks the AddReservation method.
+	AddReservationFunc func(ctx context.Context, reservation *models.Reservation) error
+
 	// AddReviewFunc mocks the AddReview method.
 	AddReviewFunc func(ctx context.Context, review *models.Review) error
 
 	// CloseFunc mocks the Close method.
 	CloseFunc func(ctx context.Context) error
 
+	// DeleteAuthorFunc mocks the DeleteAuthor method.
+	DeleteAuthorFunc func(ctx context.Context, id string) error
+
+	// DeleteBookFunc mocks the DeleteBook method.
+	DeleteBookFunc func(ctx context.Context, id string, revision int, cascadeReviews bool) error
+
+	// DeleteReservationFunc mocks the DeleteReservation method.
+	DeleteReservationFunc func(ctx context.Context, reservationID string) error
+
+	// DeleteReviewFunc mocks the DeleteReview method.
+	DeleteReviewFunc func(ctx context.Context, review *models.Review) error
+
+	// ExportBooksFunc mocks the ExportBooks method.
+	ExportBooksFunc func(ctx context.Context, reviews *query.Query, export func(book *models.Book, reviews []models.Review) error) error
+
+	// GetAuthorFunc mocks the GetAuthor method.
+	GetAuthorFunc func(ctx context.Context, id string) (*models.Author, error)
+
+	// GetAuthorsFunc mocks the GetAuthors method.
+	GetAuthorsFunc func(ctx context.Context, q *query.Query, cursor *pagination.Cursor, offset int, limit int) ([]models.Author, int, error)
+
 	// GetBookFunc mocks the GetBook method.
 	GetBookFunc func(ctx context.Context, id string) (*models.Book, error)
 
 	// GetBooksFunc mocks the GetBooks method.
-	GetBooksFunc func(ctx context.Context, offset int, limit int) ([]models.Book, int, error)
+	GetBooksFunc func(ctx context.Context, q *query.Query, cursor *pagination.Cursor, offset int, limit int) ([]models.Book, int, error)
+
+	// GetReservationFunc mocks the GetReservation method.
+	GetReservationFunc func(ctx context.Context, reservationID string) (*models.Reservation, error)
+
+	// GetReservationsFunc mocks the GetReservations method.
+	GetReservationsFunc func(ctx context.Context, bookID string, offset int, limit int) ([]models.Reservation, int, error)
 
 	// GetReviewFunc mocks the GetReview method.
 	GetReviewFunc func(ctx context.Context, reviewID string) (*models.Review, error)
 
 	// GetReviewsFunc mocks the GetReviews method.
-	GetReviewsFunc func(ctx context.Context, bookID string, offset int, limit int) ([]models.Review, int, error)
+	GetReviewsFunc func(ctx context.Context, bookID string, q *query.Query, cursor *pagination.Cursor, offset int, limit int) ([]models.Review, int, error)
 
 	// InitFunc mocks the Init method.
 	InitFunc func(in1 config.MongoConfig) error
 
+	// PatchBookFunc mocks the PatchBook method.
+	PatchBookFunc func(ctx context.Context, id string, revision int, patch map[string]interface{}) error
+
+	// UpdateAuthorFunc mocks the UpdateAuthor method.
+	UpdateAuthorFunc func(ctx context.Context, id string, author *models.Author) error
+
+	// UpdateBookFunc mocks the UpdateBook method.
+	UpdateBookFunc func(ctx context.Context, id string, book *models.Book) error
+
+	// UpdateReservationFunc mocks the UpdateReservation method.
+	UpdateReservationFunc func(ctx context.Context, reservation *models.Reservation, previousState string) error
+
 	// UpdateReviewFunc mocks the UpdateReview method.
-	UpdateReviewFunc func(ctx context.Context, reviewID string, review *models.Review) error
+	UpdateReviewFunc func(ctx context.Context, review *models.Review, previous *models.Review) (*models.Review, error)
+
+	// UpdateReviewStateFunc mocks the UpdateReviewState method.
+	UpdateReviewStateFunc func(ctx context.Context, review *models.Review, previousState string) error
 
 	// calls tracks calls to the methods.
 	calls struct {
+		// AddAuthor holds details about calls to the AddAuthor method.
+		AddAuthor []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Author is the author argument value.
+			Author *models.Author
+		}
 		// AddBook holds details about calls to the AddBook method.
 		AddBook []struct {
 			// Ctx is the ctx argument value.
@@ -91,6 +202,20 @@ type DataStoreMock struct {
 			// Book is the book argument value.
 			Book *models.Book
 		}
+		// AddBooks holds details about calls to the AddBooks method.
+		AddBooks []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Books is the books argument value.
+			Books []*models.Book
+		}
+		// AddReservation holds details about calls to the AddReservation method.
+		AddReservation []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Reservation is the reservation argument value.
+			Reservation *models.Reservation
+		}
 		// AddReview holds details about calls to the AddReview method.
 		AddReview []struct {
 			// Ctx is the ctx argument value.
@@ -103,6 +228,67 @@ type DataStoreMock struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
 		}
+		// DeleteAuthor holds details about calls to the DeleteAuthor method.
+		DeleteAuthor []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+		}
+		// DeleteBook holds details about calls to the DeleteBook method.
+		DeleteBook []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+			// Revision is the revision argument value.
+			Revision int
+			// CascadeReviews is the cascadeReviews argument value.
+			CascadeReviews bool
+		}
+		// DeleteReservation holds details about calls to the DeleteReservation method.
+		DeleteReservation []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ReservationID is the reservationID argument value.
+			ReservationID string
+		}
+		// DeleteReview holds details about calls to the DeleteReview method.
+		DeleteReview []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Review is the review argument value.
+			Review *models.Review
+		}
+		// ExportBooks holds details about calls to the ExportBooks method.
+		ExportBooks []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Reviews is the reviews argument value.
+			Reviews *query.Query
+			// Export is the export argument value.
+			Export func(book *models.Book, reviews []models.Review) error
+		}
+		// GetAuthor holds details about calls to the GetAuthor method.
+		GetAuthor []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+		}
+		// GetAuthors holds details about calls to the GetAuthors method.
+		GetAuthors []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Q is the q argument value.
+			Q *query.Query
+			// Cursor is the cursor argument value.
+			Cursor *pagination.Cursor
+			// Offset is the offset argument value.
+			Offset int
+			// Limit is the limit argument value.
+			Limit int
+		}
 		// GetBook holds details about calls to the GetBook method.
 		GetBook []struct {
 			// Ctx is the ctx argument value.
@@ -114,6 +300,28 @@ type DataStoreMock struct {
 		GetBooks []struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
+			// Q is the q argument value.
+			Q *query.Query
+			// Cursor is the cursor argument value.
+			Cursor *pagination.Cursor
+			// Offset is the offset argument value.
+			Offset int
+			// Limit is the limit argument value.
+			Limit int
+		}
+		// GetReservation holds details about calls to the GetReservation method.
+		GetReservation []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ReservationID is the reservationID argument value.
+			ReservationID string
+		}
+		// GetReservations holds details about calls to the GetReservations method.
+		GetReservations []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// BookID is the bookID argument value.
+			BookID string
 			// Offset is the offset argument value.
 			Offset int
 			// Limit is the limit argument value.
@@ -132,6 +340,10 @@ type DataStoreMock struct {
 			Ctx context.Context
 			// BookID is the bookID argument value.
 			BookID string
+			// Q is the q argument value.
+			Q *query.Query
+			// Cursor is the cursor argument value.
+			Cursor *pagination.Cursor
 			// Offset is the offset argument value.
 			Offset int
 			// Limit is the limit argument value.
@@ -142,25 +354,124 @@ type DataStoreMock struct {
 			// In1 is the in1 argument value.
 			In1 config.MongoConfig
 		}
+		// PatchBook holds details about calls to the PatchBook method.
+		PatchBook []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+			// Revision is the revision argument value.
+			Revision int
+			// Patch is the patch argument value.
+			Patch map[string]interface{}
+		}
+		// UpdateAuthor holds details about calls to the UpdateAuthor method.
+		UpdateAuthor []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+			// Author is the author argument value.
+			Author *models.Author
+		}
+		// UpdateBook holds details about calls to the UpdateBook method.
+		UpdateBook []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+			// Book is the book argument value.
+			Book *models.Book
+		}
+		// UpdateReservation holds details about calls to the UpdateReservation method.
+		UpdateReservation []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Reservation is the reservation argument value.
+			Reservation *models.Reservation
+			// PreviousState is the previousState argument value.
+			PreviousState string
+		}
 		// UpdateReview holds details about calls to the UpdateReview method.
 		UpdateReview []struct {
 			// Ctx is the ctx argument value.
 			Ctx context.Context
-			// ReviewID is the reviewID argument value.
-			ReviewID string
 			// Review is the review argument value.
 			Review *models.Review
+			// Previous is the previous argument value.
+			Previous *models.Review
 		}
+		// UpdateReviewState holds details about calls to the UpdateReviewState method.
+		UpdateReviewState []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Review is the review argument value.
+			Review *models.Review
+			// PreviousState is the previousState argument value.
+			PreviousState string
+		}
+	}
+	lockAddAuthor         sync.RWMutex
+	lockAddBook           sync.RWMutex
+	lockAddBooks          sync.RWMutex
+	lockAddReservation    sync.RWMutex
+	lockAddReview         sync.RWMutex
+	lockClose             sync.RWMutex
+	lockDeleteAuthor      sync.RWMutex
+	lockDeleteBook        sync.RWMutex
+	lockDeleteReservation sync.RWMutex
+	lockDeleteReview      sync.RWMutex
+	lockExportBooks       sync.RWMutex
+	lockGetAuthor         sync.RWMutex
+	lockGetAuthors        sync.RWMutex
+	lockGetBook           sync.RWMutex
+	lockGetBooks          sync.RWMutex
+	lockGetReservation    sync.RWMutex
+	lockGetReservations   sync.RWMutex
+	lockGetReview         sync.RWMutex
+	lockGetReviews        sync.RWMutex
+	lockInit              sync.RWMutex
+	lockPatchBook         sync.RWMutex
+	lockUpdateAuthor      sync.RWMutex
+	lockUpdateBook        sync.RWMutex
+	lockUpdateReservation sync.RWMutex
+	lockUpdateReview      sync.RWMutex
+	lockUpdateReviewState sync.RWMutex
+}
+
+// AddAuthor calls AddAuthorFunc.
+func (mock *DataStoreMock) AddAuthor(ctx context.Context, author *models.Author) error {
+	if mock.AddAuthorFunc == nil {
+		panic("DataStoreMock.AddAuthorFunc: method is nil but DataStore.AddAuthor was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Author *models.Author
+	}{
+		Ctx:    ctx,
+		Author: author,
+	}
+	mock.lockAddAuthor.Lock()
+	mock.calls.AddAuthor = append(mock.calls.AddAuthor, callInfo)
+	mock.lockAddAuthor.Unlock()
+	return mock.AddAuthorFunc(ctx, author)
+}
+
+// AddAuthorCalls gets all the calls that were made to AddAuthor.
+// Check the length with:
+//     len(mockedDataStore.AddAuthorCalls())
+func (mock *DataStoreMock) AddAuthorCalls() []struct {
+	Ctx    context.Context
+	Author *models.Author
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Author *models.Author
 	}
-	lockAddBook      sync.RWMutex
-	lockAddReview    sync.RWMutex
-	lockClose        sync.RWMutex
-	lockGetBook      sync.RWMutex
-	lockGetBooks     sync.RWMutex
-	lockGetReview    sync.RWMutex
-	lockGetReviews   sync.RWMutex
-	lockInit         sync.RWMutex
-	lockUpdateReview sync.RWMutex
+	mock.lockAddAuthor.RLock()
+	calls = mock.calls.AddAuthor
+	mock.lockAddAuthor.RUnlock()
+	return calls
 }
 
 // AddBook calls AddBookFunc.
@@ -198,6 +509,76 @@ func (mock *DataStoreMock) AddBookCalls() []struct {
 	return calls
 }
 
+// AddBooks calls AddBooksFunc.
+func (mock *DataStoreMock) AddBooks(ctx context.Context, books []*models.Book) ([]error, error) {
+	if mock.AddBooksFunc == nil {
+		panic("DataStoreMock.AddBooksFunc: method is nil but DataStore.AddBooks was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Books []*models.Book
+	}{
+		Ctx:   ctx,
+		Books: books,
+	}
+	mock.lockAddBooks.Lock()
+	mock.calls.AddBooks = append(mock.calls.AddBooks, callInfo)
+	mock.lockAddBooks.Unlock()
+	return mock.AddBooksFunc(ctx, books)
+}
+
+// AddBooksCalls gets all the calls that were made to AddBooks.
+// Check the length with:
+//     len(mockedDataStore.AddBooksCalls())
+func (mock *DataStoreMock) AddBooksCalls() []struct {
+	Ctx   context.Context
+	Books []*models.Book
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Books []*models.Book
+	}
+	mock.lockAddBooks.RLock()
+	calls = mock.calls.AddBooks
+	mock.lockAddBooks.RUnlock()
+	return calls
+}
+
+// AddReservation calls AddReservationFunc.
+func (mock *DataStoreMock) AddReservation(ctx context.Context, reservation *models.Reservation) error {
+	if mock.AddReservationFunc == nil {
+		panic("DataStoreMock.AddReservationFunc: method is nil but DataStore.AddReservation was just called")
+	}
+	callInfo := struct {
+		Ctx         context.Context
+		Reservation *models.Reservation
+	}{
+		Ctx:         ctx,
+		Reservation: reservation,
+	}
+	mock.lockAddReservation.Lock()
+	mock.calls.AddReservation = append(mock.calls.AddReservation, callInfo)
+	mock.lockAddReservation.Unlock()
+	return mock.AddReservationFunc(ctx, reservation)
+}
+
+// AddReservationCalls gets all the calls that were made to AddReservation.
+// Check the length with:
+//     len(mockedDataStore.AddReservationCalls())
+func (mock *DataStoreMock) AddReservationCalls() []struct {
+	Ctx         context.Context
+	Reservation *models.Reservation
+} {
+	var calls []struct {
+		Ctx         context.Context
+		Reservation *models.Reservation
+	}
+	mock.lockAddReservation.RLock()
+	calls = mock.calls.AddReservation
+	mock.lockAddReservation.RUnlock()
+	return calls
+}
+
 // AddReview calls AddReviewFunc.
 func (mock *DataStoreMock) AddReview(ctx context.Context, review *models.Review) error {
 	if mock.AddReviewFunc == nil {
@@ -264,6 +645,275 @@ func (mock *DataStoreMock) CloseCalls() []struct {
 	return calls
 }
 
+// DeleteAuthor calls DeleteAuthorFunc.
+func (mock *DataStoreMock) DeleteAuthor(ctx context.Context, id string) error {
+	if mock.DeleteAuthorFunc == nil {
+		panic("DataStoreMock.DeleteAuthorFunc: method is nil but DataStore.DeleteAuthor was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  string
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	mock.lockDeleteAuthor.Lock()
+	mock.calls.DeleteAuthor = append(mock.calls.DeleteAuthor, callInfo)
+	mock.lockDeleteAuthor.Unlock()
+	return mock.DeleteAuthorFunc(ctx, id)
+}
+
+// DeleteAuthorCalls gets all the calls that were made to DeleteAuthor.
+// Check the length with:
+//     len(mockedDataStore.DeleteAuthorCalls())
+func (mock *DataStoreMock) DeleteAuthorCalls() []struct {
+	Ctx context.Context
+	ID  string
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  string
+	}
+	mock.lockDeleteAuthor.RLock()
+	calls = mock.calls.DeleteAuthor
+	mock.lockDeleteAuthor.RUnlock()
+	return calls
+}
+
+// DeleteBook calls DeleteBookFunc.
+func (mock *DataStoreMock) DeleteBook(ctx context.Context, id string, revision int, cascadeReviews bool) error {
+	if mock.DeleteBookFunc == nil {
+		panic("DataStoreMock.DeleteBookFunc: method is nil but DataStore.DeleteBook was just called")
+	}
+	callInfo := struct {
+		Ctx            context.Context
+		ID             string
+		Revision       int
+		CascadeReviews bool
+	}{
+		Ctx:            ctx,
+		ID:             id,
+		Revision:       revision,
+		CascadeReviews: cascadeReviews,
+	}
+	mock.lockDeleteBook.Lock()
+	mock.calls.DeleteBook = append(mock.calls.DeleteBook, callInfo)
+	mock.lockDeleteBook.Unlock()
+	return mock.DeleteBookFunc(ctx, id, revision, cascadeReviews)
+}
+
+// DeleteBookCalls gets all the calls that were made to DeleteBook.
+// Check the length with:
+//     len(mockedDataStore.DeleteBookCalls())
+func (mock *DataStoreMock) DeleteBookCalls() []struct {
+	Ctx            context.Context
+	ID             string
+	Revision       int
+	CascadeReviews bool
+} {
+	var calls []struct {
+		Ctx            context.Context
+		ID             string
+		Revision       int
+		CascadeReviews bool
+	}
+	mock.lockDeleteBook.RLock()
+	calls = mock.calls.DeleteBook
+	mock.lockDeleteBook.RUnlock()
+	return calls
+}
+
+// DeleteReservation calls DeleteReservationFunc.
+func (mock *DataStoreMock) DeleteReservation(ctx context.Context, reservationID string) error {
+	if mock.DeleteReservationFunc == nil {
+		panic("DataStoreMock.DeleteReservationFunc: method is nil but DataStore.DeleteReservation was just called")
+	}
+	callInfo := struct {
+		Ctx           context.Context
+		ReservationID string
+	}{
+		Ctx:           ctx,
+		ReservationID: reservationID,
+	}
+	mock.lockDeleteReservation.Lock()
+	mock.calls.DeleteReservation = append(mock.calls.DeleteReservation, callInfo)
+	mock.lockDeleteReservation.Unlock()
+	return mock.DeleteReservationFunc(ctx, reservationID)
+}
+
+// DeleteReservationCalls gets all the calls that were made to DeleteReservation.
+// Check the length with:
+//     len(mockedDataStore.DeleteReservationCalls())
+func (mock *DataStoreMock) DeleteReservationCalls() []struct {
+	Ctx           context.Context
+	ReservationID string
+} {
+	var calls []struct {
+		Ctx           context.Context
+		ReservationID string
+	}
+	mock.lockDeleteReservation.RLock()
+	calls = mock.calls.DeleteReservation
+	mock.lockDeleteReservation.RUnlock()
+	return calls
+}
+
+// DeleteReview calls DeleteReviewFunc.
+func (mock *DataStoreMock) DeleteReview(ctx context.Context, review *models.Review) error {
+	if mock.DeleteReviewFunc == nil {
+		panic("DataStoreMock.DeleteReviewFunc: method is nil but DataStore.DeleteReview was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Review *models.Review
+	}{
+		Ctx:    ctx,
+		Review: review,
+	}
+	mock.lockDeleteReview.Lock()
+	mock.calls.DeleteReview = append(mock.calls.DeleteReview, callInfo)
+	mock.lockDeleteReview.Unlock()
+	return mock.DeleteReviewFunc(ctx, review)
+}
+
+// DeleteReviewCalls gets all the calls that were made to DeleteReview.
+// Check the length with:
+//     len(mockedDataStore.DeleteReviewCalls())
+func (mock *DataStoreMock) DeleteReviewCalls() []struct {
+	Ctx    context.Context
+	Review *models.Review
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Review *models.Review
+	}
+	mock.lockDeleteReview.RLock()
+	calls = mock.calls.DeleteReview
+	mock.lockDeleteReview.RUnlock()
+	return calls
+}
+
+// ExportBooks calls ExportBooksFunc.
+func (mock *DataStoreMock) ExportBooks(ctx context.Context, reviews *query.Query, export func(book *models.Book, reviews []models.Review) error) error {
+	if mock.ExportBooksFunc == nil {
+		panic("DataStoreMock.ExportBooksFunc: method is nil but DataStore.ExportBooks was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Reviews *query.Query
+		Export  func(book *models.Book, reviews []models.Review) error
+	}{
+		Ctx:     ctx,
+		Reviews: reviews,
+		Export:  export,
+	}
+	mock.lockExportBooks.Lock()
+	mock.calls.ExportBooks = append(mock.calls.ExportBooks, callInfo)
+	mock.lockExportBooks.Unlock()
+	return mock.ExportBooksFunc(ctx, reviews, export)
+}
+
+// ExportBooksCalls gets all the calls that were made to ExportBooks.
+// Check the length with:
+//     len(mockedDataStore.ExportBooksCalls())
+func (mock *DataStoreMock) ExportBooksCalls() []struct {
+	Ctx     context.Context
+	Reviews *query.Query
+	Export  func(book *models.Book, reviews []models.Review) error
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Reviews *query.Query
+		Export  func(book *models.Book, reviews []models.Review) error
+	}
+	mock.lockExportBooks.RLock()
+	calls = mock.calls.ExportBooks
+	mock.lockExportBooks.RUnlock()
+	return calls
+}
+
+// GetAuthor calls GetAuthorFunc.
+func (mock *DataStoreMock) GetAuthor(ctx context.Context, id string) (*models.Author, error) {
+	if mock.GetAuthorFunc == nil {
+		panic("DataStoreMock.GetAuthorFunc: method is nil but DataStore.GetAuthor was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  string
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	mock.lockGetAuthor.Lock()
+	mock.calls.GetAuthor = append(mock.calls.GetAuthor, callInfo)
+	mock.lockGetAuthor.Unlock()
+	return mock.GetAuthorFunc(ctx, id)
+}
+
+// GetAuthorCalls gets all the calls that were made to GetAuthor.
+// Check the length with:
+//     len(mockedDataStore.GetAuthorCalls())
+func (mock *DataStoreMock) GetAuthorCalls() []struct {
+	Ctx context.Context
+	ID  string
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  string
+	}
+	mock.lockGetAuthor.RLock()
+	calls = mock.calls.GetAuthor
+	mock.lockGetAuthor.RUnlock()
+	return calls
+}
+
+// GetAuthors calls GetAuthorsFunc.
+func (mock *DataStoreMock) GetAuthors(ctx context.Context, q *query.Query, cursor *pagination.Cursor, offset int, limit int) ([]models.Author, int, error) {
+	if mock.GetAuthorsFunc == nil {
+		panic("DataStoreMock.GetAuthorsFunc: method is nil but DataStore.GetAuthors was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Q      *query.Query
+		Cursor *pagination.Cursor
+		Offset int
+		Limit  int
+	}{
+		Ctx:    ctx,
+		Q:      q,
+		Cursor: cursor,
+		Offset: offset,
+		Limit:  limit,
+	}
+	mock.lockGetAuthors.Lock()
+	mock.calls.GetAuthors = append(mock.calls.GetAuthors, callInfo)
+	mock.lockGetAuthors.Unlock()
+	return mock.GetAuthorsFunc(ctx, q, cursor, offset, limit)
+}
+
+// GetAuthorsCalls gets all the calls that were made to GetAuthors.
+// Check the length with:
+//     len(mockedDataStore.GetAuthorsCalls())
+func (mock *DataStoreMock) GetAuthorsCalls() []struct {
+	Ctx    context.Context
+	Q      *query.Query
+	Cursor *pagination.Cursor
+	Offset int
+	Limit  int
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Q      *query.Query
+		Cursor *pagination.Cursor
+		Offset int
+		Limit  int
+	}
+	mock.lockGetAuthors.RLock()
+	calls = mock.calls.GetAuthors
+	mock.lockGetAuthors.RUnlock()
+	return calls
+}
+
 // GetBook calls GetBookFunc.
 func (mock *DataStoreMock) GetBook(ctx context.Context, id string) (*models.Book, error) {
 	if mock.GetBookFunc == nil {
@@ -300,23 +950,27 @@ func (mock *DataStoreMock) GetBookCalls() []struct {
 }
 
 // GetBooks calls GetBooksFunc.
-func (mock *DataStoreMock) GetBooks(ctx context.Context, offset int, limit int) ([]models.Book, int, error) {
+func (mock *DataStoreMock) GetBooks(ctx context.Context, q *query.Query, cursor *pagination.Cursor, offset int, limit int) ([]models.Book, int, error) {
 	if mock.GetBooksFunc == nil {
 		panic("DataStoreMock.GetBooksFunc: method is nil but DataStore.GetBooks was just called")
 	}
 	callInfo := struct {
 		Ctx    context.Context
+		Q      *query.Query
+		Cursor *pagination.Cursor
 		Offset int
 		Limit  int
 	}{
 		Ctx:    ctx,
+		Q:      q,
+		Cursor: cursor,
 		Offset: offset,
 		Limit:  limit,
 	}
 	mock.lockGetBooks.Lock()
 	mock.calls.GetBooks = append(mock.calls.GetBooks, callInfo)
 	mock.lockGetBooks.Unlock()
-	return mock.GetBooksFunc(ctx, offset, limit)
+	return mock.GetBooksFunc(ctx, q, cursor, offset, limit)
 }
 
 // GetBooksCalls gets all the calls that were made to GetBooks.
@@ -324,11 +978,15 @@ func (mock *DataStoreMock) GetBooks(ctx context.Context, offset int, limit int)
 //     len(mockedDataStore.GetBooksCalls())
 func (mock *DataStoreMock) GetBooksCalls() []struct {
 	Ctx    context.Context
+	Q      *query.Query
+	Cursor *pagination.Cursor
 	Offset int
 	Limit  int
 } {
 	var calls []struct {
 		Ctx    context.Context
+		Q      *query.Query
+		Cursor *pagination.Cursor
 		Offset int
 		Limit  int
 	}
@@ -338,6 +996,84 @@ func (mock *DataStoreMock) GetBooksCalls() []struct {
 	return calls
 }
 
+// GetReservation calls GetReservationFunc.
+func (mock *DataStoreMock) GetReservation(ctx context.Context, reservationID string) (*models.Reservation, error) {
+	if mock.GetReservationFunc == nil {
+		panic("DataStoreMock.GetReservationFunc: method is nil but DataStore.GetReservation was just called")
+	}
+	callInfo := struct {
+		Ctx           context.Context
+		ReservationID string
+	}{
+		Ctx:           ctx,
+		ReservationID: reservationID,
+	}
+	mock.lockGetReservation.Lock()
+	mock.calls.GetReservation = append(mock.calls.GetReservation, callInfo)
+	mock.lockGetReservation.Unlock()
+	return mock.GetReservationFunc(ctx, reservationID)
+}
+
+// GetReservationCalls gets all the calls that were made to GetReservation.
+// Check the length with:
+//     len(mockedDataStore.GetReservationCalls())
+func (mock *DataStoreMock) GetReservationCalls() []struct {
+	Ctx           context.Context
+	ReservationID string
+} {
+	var calls []struct {
+		Ctx           context.Context
+		ReservationID string
+	}
+	mock.lockGetReservation.RLock()
+	calls = mock.calls.GetReservation
+	mock.lockGetReservation.RUnlock()
+	return calls
+}
+
+// GetReservations calls GetReservationsFunc.
+func (mock *DataStoreMock) GetReservations(ctx context.Context, bookID string, offset int, limit int) ([]models.Reservation, int, error) {
+	if mock.GetReservationsFunc == nil {
+		panic("DataStoreMock.GetReservationsFunc: method is nil but DataStore.GetReservations was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		BookID string
+		Offset int
+		Limit  int
+	}{
+		Ctx:    ctx,
+		BookID: bookID,
+		Offset: offset,
+		Limit:  limit,
+	}
+	mock.lockGetReservations.Lock()
+	mock.calls.GetReservations = append(mock.calls.GetReservations, callInfo)
+	mock.lockGetReservations.Unlock()
+	return mock.GetReservationsFunc(ctx, bookID, offset, limit)
+}
+
+// GetReservationsCalls gets all the calls that were made to GetReservations.
+// Check the length with:
+//     len(mockedDataStore.GetReservationsCalls())
+func (mock *DataStoreMock) GetReservationsCalls() []struct {
+	Ctx    context.Context
+	BookID string
+	Offset int
+	Limit  int
+} {
+	var calls []struct {
+		Ctx    context.Context
+		BookID string
+		Offset int
+		Limit  int
+	}
+	mock.lockGetReservations.RLock()
+	calls = mock.calls.GetReservations
+	mock.lockGetReservations.RUnlock()
+	return calls
+}
+
 // GetReview calls GetReviewFunc.
 func (mock *DataStoreMock) GetReview(ctx context.Context, reviewID string) (*models.Review, error) {
 	if mock.GetReviewFunc == nil {
@@ -374,25 +1110,29 @@ func (mock *DataStoreMock) GetReviewCalls() []struct {
 }
 
 // GetReviews calls GetReviewsFunc.
-func (mock *DataStoreMock) GetReviews(ctx context.Context, bookID string, offset int, limit int) ([]models.Review, int, error) {
+func (mock *DataStoreMock) GetReviews(ctx context.Context, bookID string, q *query.Query, cursor *pagination.Cursor, offset int, limit int) ([]models.Review, int, error) {
 	if mock.GetReviewsFunc == nil {
 		panic("DataStoreMock.GetReviewsFunc: method is nil but DataStore.GetReviews was just called")
 	}
 	callInfo := struct {
 		Ctx    context.Context
 		BookID string
+		Q      *query.Query
+		Cursor *pagination.Cursor
 		Offset int
 		Limit  int
 	}{
 		Ctx:    ctx,
 		BookID: bookID,
+		Q:      q,
+		Cursor: cursor,
 		Offset: offset,
 		Limit:  limit,
 	}
 	mock.lockGetReviews.Lock()
 	mock.calls.GetReviews = append(mock.calls.GetReviews, callInfo)
 	mock.lockGetReviews.Unlock()
-	return mock.GetReviewsFunc(ctx, bookID, offset, limit)
+	return mock.GetReviewsFunc(ctx, bookID, q, cursor, offset, limit)
 }
 
 // GetReviewsCalls gets all the calls that were made to GetReviews.
@@ -401,12 +1141,16 @@ func (mock *DataStoreMock) GetReviews(ctx context.Context, bookID string, offset
 func (mock *DataStoreMock) GetReviewsCalls() []struct {
 	Ctx    context.Context
 	BookID string
+	Q      *query.Query
+	Cursor *pagination.Cursor
 	Offset int
 	Limit  int
 } {
 	var calls []struct {
 		Ctx    context.Context
 		BookID string
+		Q      *query.Query
+		Cursor *pagination.Cursor
 		Offset int
 		Limit  int
 	}
@@ -447,24 +1191,184 @@ func (mock *DataStoreMock) InitCalls() []struct {
 	return calls
 }
 
+// PatchBook calls PatchBookFunc.
+func (mock *DataStoreMock) PatchBook(ctx context.Context, id string, revision int, patch map[string]interface{}) error {
+	if mock.PatchBookFunc == nil {
+		panic("DataStoreMock.PatchBookFunc: method is nil but DataStore.PatchBook was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		ID       string
+		Revision int
+		Patch    map[string]interface{}
+	}{
+		Ctx:      ctx,
+		ID:       id,
+		Revision: revision,
+		Patch:    patch,
+	}
+	mock.lockPatchBook.Lock()
+	mock.calls.PatchBook = append(mock.calls.PatchBook, callInfo)
+	mock.lockPatchBook.Unlock()
+	return mock.PatchBookFunc(ctx, id, revision, patch)
+}
+
+// PatchBookCalls gets all the calls that were made to PatchBook.
+// Check the length with:
+//     len(mockedDataStore.PatchBookCalls())
+func (mock *DataStoreMock) PatchBookCalls() []struct {
+	Ctx      context.Context
+	ID       string
+	Revision int
+	Patch    map[string]interface{}
+} {
+	var calls []struct {
+		Ctx      context.Context
+		ID       string
+		Revision int
+		Patch    map[string]interface{}
+	}
+	mock.lockPatchBook.RLock()
+	calls = mock.calls.PatchBook
+	mock.lockPatchBook.RUnlock()
+	return calls
+}
+
+// UpdateAuthor calls UpdateAuthorFunc.
+func (mock *DataStoreMock) UpdateAuthor(ctx context.Context, id string, author *models.Author) error {
+	if mock.UpdateAuthorFunc == nil {
+		panic("DataStoreMock.UpdateAuthorFunc: method is nil but DataStore.UpdateAuthor was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		ID     string
+		Author *models.Author
+	}{
+		Ctx:    ctx,
+		ID:     id,
+		Author: author,
+	}
+	mock.lockUpdateAuthor.Lock()
+	mock.calls.UpdateAuthor = append(mock.calls.UpdateAuthor, callInfo)
+	mock.lockUpdateAuthor.Unlock()
+	return mock.UpdateAuthorFunc(ctx, id, author)
+}
+
+// UpdateAuthorCalls gets all the calls that were made to UpdateAuthor.
+// Check the length with:
+//     len(mockedDataStore.UpdateAuthorCalls())
+func (mock *DataStoreMock) UpdateAuthorCalls() []struct {
+	Ctx    context.Context
+	ID     string
+	Author *models.Author
+} {
+	var calls []struct {
+		Ctx    context.Context
+		ID     string
+		Author *models.Author
+	}
+	mock.lockUpdateAuthor.RLock()
+	calls = mock.calls.UpdateAuthor
+	mock.lockUpdateAuthor.RUnlock()
+	return calls
+}
+
+// UpdateBook calls UpdateBookFunc.
+func (mock *DataStoreMock) UpdateBook(ctx context.Context, id string, book *models.Book) error {
+	if mock.UpdateBookFunc == nil {
+		panic("DataStoreMock.UpdateBookFunc: method is nil but DataStore.UpdateBook was just called")
+	}
+	callInfo := struct {
+		Ctx  context.Context
+		ID   string
+		Book *models.Book
+	}{
+		Ctx:  ctx,
+		ID:   id,
+		Book: book,
+	}
+	mock.lockUpdateBook.Lock()
+	mock.calls.UpdateBook = append(mock.calls.UpdateBook, callInfo)
+	mock.lockUpdateBook.Unlock()
+	return mock.UpdateBookFunc(ctx, id, book)
+}
+
+// UpdateBookCalls gets all the calls that were made to UpdateBook.
+// Check the length with:
+//     len(mockedDataStore.UpdateBookCalls())
+func (mock *DataStoreMock) UpdateBookCalls() []struct {
+	Ctx  context.Context
+	ID   string
+	Book *models.Book
+} {
+	var calls []struct {
+		Ctx  context.Context
+		ID   string
+		Book *models.Book
+	}
+	mock.lockUpdateBook.RLock()
+	calls = mock.calls.UpdateBook
+	mock.lockUpdateBook.RUnlock()
+	return calls
+}
+
+// UpdateReservation calls UpdateReservationFunc.
+func (mock *DataStoreMock) UpdateReservation(ctx context.Context, reservation *models.Reservation, previousState string) error {
+	if mock.UpdateReservationFunc == nil {
+		panic("DataStoreMock.UpdateReservationFunc: method is nil but DataStore.UpdateReservation was just called")
+	}
+	callInfo := struct {
+		Ctx           context.Context
+		Reservation   *models.Reservation
+		PreviousState string
+	}{
+		Ctx:           ctx,
+		Reservation:   reservation,
+		PreviousState: previousState,
+	}
+	mock.lockUpdateReservation.Lock()
+	mock.calls.UpdateReservation = append(mock.calls.UpdateReservation, callInfo)
+	mock.lockUpdateReservation.Unlock()
+	return mock.UpdateReservationFunc(ctx, reservation, previousState)
+}
+
+// UpdateReservationCalls gets all the calls that were made to UpdateReservation.
+// Check the length with:
+//     len(mockedDataStore.UpdateReservationCalls())
+func (mock *DataStoreMock) UpdateReservationCalls() []struct {
+	Ctx           context.Context
+	Reservation   *models.Reservation
+	PreviousState string
+} {
+	var calls []struct {
+		Ctx           context.Context
+		Reservation   *models.Reservation
+		PreviousState string
+	}
+	mock.lockUpdateReservation.RLock()
+	calls = mock.calls.UpdateReservation
+	mock.lockUpdateReservation.RUnlock()
+	return calls
+}
+
 // UpdateReview calls UpdateReviewFunc.
-func (mock *DataStoreMock) UpdateReview(ctx context.Context, reviewID string, review *models.Review) error {
+func (mock *DataStoreMock) UpdateReview(ctx context.Context, review *models.Review, previous *models.Review) (*models.Review, error) {
 	if mock.UpdateReviewFunc == nil {
 		panic("DataStoreMock.UpdateReviewFunc: method is nil but DataStore.UpdateReview was just called")
 	}
 	callInfo := struct {
 		Ctx      context.Context
-		ReviewID string
 		Review   *models.Review
+		Previous *models.Review
 	}{
 		Ctx:      ctx,
-		ReviewID: reviewID,
 		Review:   review,
+		Previous: previous,
 	}
 	mock.lockUpdateReview.Lock()
 	mock.calls.UpdateReview = append(mock.calls.UpdateReview, callInfo)
 	mock.lockUpdateReview.Unlock()
-	return mock.UpdateReviewFunc(ctx, reviewID, review)
+	return mock.UpdateReviewFunc(ctx, review, previous)
 }
 
 // UpdateReviewCalls gets all the calls that were made to UpdateReview.
@@ -472,16 +1376,55 @@ func (mock *DataStoreMock) UpdateReview(ctx context.Context, reviewID string, re
 //     len(mockedDataStore.UpdateReviewCalls())
 func (mock *DataStoreMock) UpdateReviewCalls() []struct {
 	Ctx      context.Context
-	ReviewID string
 	Review   *models.Review
+	Previous *models.Review
 } {
 	var calls []struct {
 		Ctx      context.Context
-		ReviewID string
 		Review   *models.Review
+		Previous *models.Review
 	}
 	mock.lockUpdateReview.RLock()
 	calls = mock.calls.UpdateReview
 	mock.lockUpdateReview.RUnlock()
 	return calls
 }
+
+// UpdateReviewState calls UpdateReviewStateFunc.
+func (mock *DataStoreMock) UpdateReviewState(ctx context.Context, review *models.Review, previousState string) error {
+	if mock.UpdateReviewStateFunc == nil {
+		panic("DataStoreMock.UpdateReviewStateFunc: method is nil but DataStore.UpdateReviewState was just called")
+	}
+	callInfo := struct {
+		Ctx           context.Context
+		Review        *models.Review
+		PreviousState string
+	}{
+		Ctx:           ctx,
+		Review:        review,
+		PreviousState: previousState,
+	}
+	mock.lockUpdateReviewState.Lock()
+	mock.calls.UpdateReviewState = append(mock.calls.UpdateReviewState, callInfo)
+	mock.lockUpdateReviewState.Unlock()
+	return mock.UpdateReviewStateFunc(ctx, review, previousState)
+}
+
+// UpdateReviewStateCalls gets all the calls that were made to UpdateReviewState.
+// Check the length with:
+//     len(mockedDataStore.UpdateReviewStateCalls())
+func (mock *DataStoreMock) UpdateReviewStateCalls() []struct {
+	Ctx           context.Context
+	Review        *models.Review
+	PreviousState string
+} {
+	var calls []struct {
+		Ctx           context.Context
+		Review        *models.Review
+		PreviousState string
+	}
+	mock.lockUpdateReviewState.RLock()
+	calls = mock.calls.UpdateReviewState
+	mock.lockUpdateReviewState.RUnlock()
+	return calls
+}
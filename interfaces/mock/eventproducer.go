@@ -0,0 +1,125 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mock
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/events"
+	"github.com/cadmiumcat/books-api/interfaces"
+	"sync"
+)
+
+// Ensure, that EventProducerMock does implement interfaces.EventProducer.
+// If this is not the case, regenerate this file with moq.
+var _ interfaces.EventProducer = &EventProducerMock{}
+
+// EventProducerMock is a mock implementation of interfaces.EventProducer.
+//
+//     func TestSomethingThatUsesEventProducer(t *testing.T) {
+//
+//         // make and configure a mocked interfaces.EventProducer
+//         mockedEventProducer := &EventProducerMock{
+//             CloseFunc: func(ctx context.Context) error {
+// 	               panic("mock out the Close method")
+//             },
+//             PublishFunc: func(ctx context.Context, event *events.Event) error {
+// 	               panic("mock out the Publish method")
+//             },
+//         }
+//
+//         // use mockedEventProducer in code that requires interfaces.EventProducer
+//         // and then make assertions.
+//
+//     }
+type EventProducerMock struct {
+	// CloseFunc mocks the Close method.
+	CloseFunc func(ctx context.Context) error
+
+	// PublishFunc mocks the Publish method.
+	PublishFunc func(ctx context.Context, event *events.Event) error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Close holds details about calls to the Close method.
+		Close []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// Publish holds details about calls to the Publish method.
+		Publish []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Event is the event argument value.
+			Event *events.Event
+		}
+	}
+	lockClose   sync.RWMutex
+	lockPublish sync.RWMutex
+}
+
+// Close calls CloseFunc.
+func (mock *EventProducerMock) Close(ctx context.Context) error {
+	if mock.CloseFunc == nil {
+		panic("EventProducerMock.CloseFunc: method is nil but EventProducer.Close was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockClose.Lock()
+	mock.calls.Close = append(mock.calls.Close, callInfo)
+	mock.lockClose.Unlock()
+	return mock.CloseFunc(ctx)
+}
+
+// CloseCalls gets all the calls that were made to Close.
+// Check the length with:
+//     len(mockedEventProducer.CloseCalls())
+func (mock *EventProducerMock) CloseCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockClose.RLock()
+	calls = mock.calls.Close
+	mock.lockClose.RUnlock()
+	return calls
+}
+
+// Publish calls PublishFunc.
+func (mock *EventProducerMock) Publish(ctx context.Context, event *events.Event) error {
+	if mock.PublishFunc == nil {
+		panic("EventProducerMock.PublishFunc: method is nil but EventProducer.Publish was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Event *events.Event
+	}{
+		Ctx:   ctx,
+		Event: event,
+	}
+	mock.lockPublish.Lock()
+	mock.calls.Publish = append(mock.calls.Publish, callInfo)
+	mock.lockPublish.Unlock()
+	return mock.PublishFunc(ctx, event)
+}
+
+// PublishCalls gets all the calls that were made to Publish.
+// Check the length with:
+//     len(mockedEventProducer.PublishCalls())
+func (mock *EventProducerMock) PublishCalls() []struct {
+	Ctx   context.Context
+	Event *events.Event
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Event *events.Event
+	}
+	mock.lockPublish.RLock()
+	calls = mock.calls.Publish
+	mock.lockPublish.RUnlock()
+	return calls
+}
@@ -0,0 +1,180 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mock
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/interfaces"
+	"github.com/cadmiumcat/books-api/models"
+	"sync"
+)
+
+// Ensure, that IdempotencyStoreMock does implement interfaces.IdempotencyStore.
+// If this is not the case, regenerate this file with moq.
+var _ interfaces.IdempotencyStore = &IdempotencyStoreMock{}
+
+// IdempotencyStoreMock is a mock implementation of interfaces.IdempotencyStore.
+//
+//     func TestSomethingThatUsesIdempotencyStore(t *testing.T) {
+//
+//         // make and configure a mocked interfaces.IdempotencyStore
+//         mockedIdempotencyStore := &IdempotencyStoreMock{
+//             CompleteIdempotencyKeyFunc: func(ctx context.Context, record *models.IdempotencyRecord) error {
+// 	               panic("mock out the CompleteIdempotencyKey method")
+//             },
+//             ReleaseIdempotencyKeyFunc: func(ctx context.Context, id string) error {
+// 	               panic("mock out the ReleaseIdempotencyKey method")
+//             },
+//             ReserveIdempotencyKeyFunc: func(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+// 	               panic("mock out the ReserveIdempotencyKey method")
+//             },
+//         }
+//
+//         // use mockedIdempotencyStore in code that requires interfaces.IdempotencyStore
+//         // and then make assertions.
+//
+//     }
+type IdempotencyStoreMock struct {
+	// CompleteIdempotencyKeyFunc mocks the CompleteIdempotencyKey method.
+	CompleteIdempotencyKeyFunc func(ctx context.Context, record *models.IdempotencyRecord) error
+
+	// ReleaseIdempotencyKeyFunc mocks the ReleaseIdempotencyKey method.
+	ReleaseIdempotencyKeyFunc func(ctx context.Context, id string) error
+
+	// ReserveIdempotencyKeyFunc mocks the ReserveIdempotencyKey method.
+	ReserveIdempotencyKeyFunc func(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// CompleteIdempotencyKey holds details about calls to the CompleteIdempotencyKey method.
+		CompleteIdempotencyKey []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Record is the record argument value.
+			Record *models.IdempotencyRecord
+		}
+		// ReleaseIdempotencyKey holds details about calls to the ReleaseIdempotencyKey method.
+		ReleaseIdempotencyKey []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+		}
+		// ReserveIdempotencyKey holds details about calls to the ReserveIdempotencyKey method.
+		ReserveIdempotencyKey []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Record is the record argument value.
+			Record *models.IdempotencyRecord
+		}
+	}
+	lockCompleteIdempotencyKey sync.RWMutex
+	lockReleaseIdempotencyKey  sync.RWMutex
+	lockReserveIdempotencyKey  sync.RWMutex
+}
+
+// CompleteIdempotencyKey calls CompleteIdempotencyKeyFunc.
+func (mock *IdempotencyStoreMock) CompleteIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) error {
+	if mock.CompleteIdempotencyKeyFunc == nil {
+		panic("IdempotencyStoreMock.CompleteIdempotencyKeyFunc: method is nil but IdempotencyStore.CompleteIdempotencyKey was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Record *models.IdempotencyRecord
+	}{
+		Ctx:    ctx,
+		Record: record,
+	}
+	mock.lockCompleteIdempotencyKey.Lock()
+	mock.calls.CompleteIdempotencyKey = append(mock.calls.CompleteIdempotencyKey, callInfo)
+	mock.lockCompleteIdempotencyKey.Unlock()
+	return mock.CompleteIdempotencyKeyFunc(ctx, record)
+}
+
+// CompleteIdempotencyKeyCalls gets all the calls that were made to CompleteIdempotencyKey.
+// Check the length with:
+//     len(mockedIdempotencyStore.CompleteIdempotencyKeyCalls())
+func (mock *IdempotencyStoreMock) CompleteIdempotencyKeyCalls() []struct {
+	Ctx    context.Context
+	Record *models.IdempotencyRecord
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Record *models.IdempotencyRecord
+	}
+	mock.lockCompleteIdempotencyKey.RLock()
+	calls = mock.calls.CompleteIdempotencyKey
+	mock.lockCompleteIdempotencyKey.RUnlock()
+	return calls
+}
+
+// ReleaseIdempotencyKey calls ReleaseIdempotencyKeyFunc.
+func (mock *IdempotencyStoreMock) ReleaseIdempotencyKey(ctx context.Context, id string) error {
+	if mock.ReleaseIdempotencyKeyFunc == nil {
+		panic("IdempotencyStoreMock.ReleaseIdempotencyKeyFunc: method is nil but IdempotencyStore.ReleaseIdempotencyKey was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  string
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	mock.lockReleaseIdempotencyKey.Lock()
+	mock.calls.ReleaseIdempotencyKey = append(mock.calls.ReleaseIdempotencyKey, callInfo)
+	mock.lockReleaseIdempotencyKey.Unlock()
+	return mock.ReleaseIdempotencyKeyFunc(ctx, id)
+}
+
+// ReleaseIdempotencyKeyCalls gets all the calls that were made to ReleaseIdempotencyKey.
+// Check the length with:
+//     len(mockedIdempotencyStore.ReleaseIdempotencyKeyCalls())
+func (mock *IdempotencyStoreMock) ReleaseIdempotencyKeyCalls() []struct {
+	Ctx context.Context
+	ID  string
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  string
+	}
+	mock.lockReleaseIdempotencyKey.RLock()
+	calls = mock.calls.ReleaseIdempotencyKey
+	mock.lockReleaseIdempotencyKey.RUnlock()
+	return calls
+}
+
+// ReserveIdempotencyKey calls ReserveIdempotencyKeyFunc.
+func (mock *IdempotencyStoreMock) ReserveIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	if mock.ReserveIdempotencyKeyFunc == nil {
+		panic("IdempotencyStoreMock.ReserveIdempotencyKeyFunc: method is nil but IdempotencyStore.ReserveIdempotencyKey was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Record *models.IdempotencyRecord
+	}{
+		Ctx:    ctx,
+		Record: record,
+	}
+	mock.lockReserveIdempotencyKey.Lock()
+	mock.calls.ReserveIdempotencyKey = append(mock.calls.ReserveIdempotencyKey, callInfo)
+	mock.lockReserveIdempotencyKey.Unlock()
+	return mock.ReserveIdempotencyKeyFunc(ctx, record)
+}
+
+// ReserveIdempotencyKeyCalls gets all the calls that were made to ReserveIdempotencyKey.
+// Check the length with:
+//     len(mockedIdempotencyStore.ReserveIdempotencyKeyCalls())
+func (mock *IdempotencyStoreMock) ReserveIdempotencyKeyCalls() []struct {
+	Ctx    context.Context
+	Record *models.IdempotencyRecord
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Record *models.IdempotencyRecord
+	}
+	mock.lockReserveIdempotencyKey.RLock()
+	calls = mock.calls.ReserveIdempotencyKey
+	mock.lockReserveIdempotencyKey.RUnlock()
+	return calls
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/cadmiumcat/books-api/interfaces"
 	"net/http"
 	"sync"
+	"time"
 )
 
 // Ensure, that InitialiserMock does implement interfaces.Initialiser.
@@ -19,7 +20,7 @@ var _ interfaces.Initialiser = &InitialiserMock{}
 //
 //         // make and configure a mocked interfaces.Initialiser
 //         mockedInitialiser := &InitialiserMock{
-//             GetHTTPServerFunc: func(BindAddr string, router http.Handler) interfaces.HTTPServer {
+//             GetHTTPServerFunc: func(BindAddr string, writeTimeout time.Duration, router http.Handler) interfaces.HTTPServer {
 // 	               panic("mock out the GetHTTPServer method")
 //             },
 //         }
@@ -30,7 +31,7 @@ var _ interfaces.Initialiser = &InitialiserMock{}
 //     }
 type InitialiserMock struct {
 	// GetHTTPServerFunc mocks the GetHTTPServer method.
-	GetHTTPServerFunc func(BindAddr string, router http.Handler) interfaces.HTTPServer
+	GetHTTPServerFunc func(BindAddr string, writeTimeout time.Duration, router http.Handler) interfaces.HTTPServer
 
 	// calls tracks calls to the methods.
 	calls struct {
@@ -38,6 +39,8 @@ type InitialiserMock struct {
 		GetHTTPServer []struct {
 			// BindAddr is the BindAddr argument value.
 			BindAddr string
+			// WriteTimeout is the writeTimeout argument value.
+			WriteTimeout time.Duration
 			// Router is the router argument value.
 			Router http.Handler
 		}
@@ -46,33 +49,37 @@ type InitialiserMock struct {
 }
 
 // GetHTTPServer calls GetHTTPServerFunc.
-func (mock *InitialiserMock) GetHTTPServer(BindAddr string, router http.Handler) interfaces.HTTPServer {
+func (mock *InitialiserMock) GetHTTPServer(BindAddr string, writeTimeout time.Duration, router http.Handler) interfaces.HTTPServer {
 	if mock.GetHTTPServerFunc == nil {
 		panic("InitialiserMock.GetHTTPServerFunc: method is nil but Initialiser.GetHTTPServer was just called")
 	}
 	callInfo := struct {
-		BindAddr string
-		Router   http.Handler
+		BindAddr     string
+		WriteTimeout time.Duration
+		Router       http.Handler
 	}{
-		BindAddr: BindAddr,
-		Router:   router,
+		BindAddr:     BindAddr,
+		WriteTimeout: writeTimeout,
+		Router:       router,
 	}
 	mock.lockGetHTTPServer.Lock()
 	mock.calls.GetHTTPServer = append(mock.calls.GetHTTPServer, callInfo)
 	mock.lockGetHTTPServer.Unlock()
-	return mock.GetHTTPServerFunc(BindAddr, router)
+	return mock.GetHTTPServerFunc(BindAddr, writeTimeout, router)
 }
 
 // GetHTTPServerCalls gets all the calls that were made to GetHTTPServer.
 // Check the length with:
 //     len(mockedInitialiser.GetHTTPServerCalls())
 func (mock *InitialiserMock) GetHTTPServerCalls() []struct {
-	BindAddr string
-	Router   http.Handler
+	BindAddr     string
+	WriteTimeout time.Duration
+	Router       http.Handler
 } {
 	var calls []struct {
-		BindAddr string
-		Router   http.Handler
+		BindAddr     string
+		WriteTimeout time.Duration
+		Router       http.Handler
 	}
 	mock.lockGetHTTPServer.RLock()
 	calls = mock.calls.GetHTTPServer
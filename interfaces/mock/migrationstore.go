@@ -0,0 +1,279 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mock
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/interfaces"
+	"github.com/cadmiumcat/books-api/models"
+	"sync"
+	"time"
+)
+
+// Ensure, that MigrationStoreMock does implement interfaces.MigrationStore.
+// If this is not the case, regenerate this file with moq.
+var _ interfaces.MigrationStore = &MigrationStoreMock{}
+
+// MigrationStoreMock is a mock implementation of interfaces.MigrationStore.
+//
+//     func TestSomethingThatUsesMigrationStore(t *testing.T) {
+//
+//         // make and configure a mocked interfaces.MigrationStore
+//         mockedMigrationStore := &MigrationStoreMock{
+//             AcquireMigrationLockFunc: func(ctx context.Context, owner string, ttl time.Duration) error {
+// 	               panic("mock out the AcquireMigrationLock method")
+//             },
+//             AddAppliedMigrationFunc: func(ctx context.Context, migration *models.AppliedMigration) error {
+// 	               panic("mock out the AddAppliedMigration method")
+//             },
+//             DeleteAppliedMigrationFunc: func(ctx context.Context, version int) error {
+// 	               panic("mock out the DeleteAppliedMigration method")
+//             },
+//             GetAppliedMigrationsFunc: func(ctx context.Context) ([]models.AppliedMigration, error) {
+// 	               panic("mock out the GetAppliedMigrations method")
+//             },
+//             ReleaseMigrationLockFunc: func(ctx context.Context, owner string) error {
+// 	               panic("mock out the ReleaseMigrationLock method")
+//             },
+//         }
+//
+//         // use mockedMigrationStore in code that requires interfaces.MigrationStore
+//         // and then make assertions.
+//
+//     }
+type MigrationStoreMock struct {
+	// AcquireMigrationLockFunc mocks the AcquireMigrationLock method.
+	AcquireMigrationLockFunc func(ctx context.Context, owner string, ttl time.Duration) error
+
+	// AddAppliedMigrationFunc mocks the AddAppliedMigration method.
+	AddAppliedMigrationFunc func(ctx context.Context, migration *models.AppliedMigration) error
+
+	// DeleteAppliedMigrationFunc mocks the DeleteAppliedMigration method.
+	DeleteAppliedMigrationFunc func(ctx context.Context, version int) error
+
+	// GetAppliedMigrationsFunc mocks the GetAppliedMigrations method.
+	GetAppliedMigrationsFunc func(ctx context.Context) ([]models.AppliedMigration, error)
+
+	// ReleaseMigrationLockFunc mocks the ReleaseMigrationLock method.
+	ReleaseMigrationLockFunc func(ctx context.Context, owner string) error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// AcquireMigrationLock holds details about calls to the AcquireMigrationLock method.
+		AcquireMigrationLock []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Owner is the owner argument value.
+			Owner string
+			// TTL is the ttl argument value.
+			TTL time.Duration
+		}
+		// AddAppliedMigration holds details about calls to the AddAppliedMigration method.
+		AddAppliedMigration []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Migration is the migration argument value.
+			Migration *models.AppliedMigration
+		}
+		// DeleteAppliedMigration holds details about calls to the DeleteAppliedMigration method.
+		DeleteAppliedMigration []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Version is the version argument value.
+			Version int
+		}
+		// GetAppliedMigrations holds details about calls to the GetAppliedMigrations method.
+		GetAppliedMigrations []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// ReleaseMigrationLock holds details about calls to the ReleaseMigrationLock method.
+		ReleaseMigrationLock []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Owner is the owner argument value.
+			Owner string
+		}
+	}
+	lockAcquireMigrationLock   sync.RWMutex
+	lockAddAppliedMigration    sync.RWMutex
+	lockDeleteAppliedMigration sync.RWMutex
+	lockGetAppliedMigrations   sync.RWMutex
+	lockReleaseMigrationLock   sync.RWMutex
+}
+
+// AcquireMigrationLock calls AcquireMigrationLockFunc.
+func (mock *MigrationStoreMock) AcquireMigrationLock(ctx context.Context, owner string, ttl time.Duration) error {
+	if mock.AcquireMigrationLockFunc == nil {
+		panic("MigrationStoreMock.AcquireMigrationLockFunc: method is nil but MigrationStore.AcquireMigrationLock was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Owner string
+		TTL   time.Duration
+	}{
+		Ctx:   ctx,
+		Owner: owner,
+		TTL:   ttl,
+	}
+	mock.lockAcquireMigrationLock.Lock()
+	mock.calls.AcquireMigrationLock = append(mock.calls.AcquireMigrationLock, callInfo)
+	mock.lockAcquireMigrationLock.Unlock()
+	return mock.AcquireMigrationLockFunc(ctx, owner, ttl)
+}
+
+// AcquireMigrationLockCalls gets all the calls that were made to AcquireMigrationLock.
+// Check the length with:
+//     len(mockedMigrationStore.AcquireMigrationLockCalls())
+func (mock *MigrationStoreMock) AcquireMigrationLockCalls() []struct {
+	Ctx   context.Context
+	Owner string
+	TTL   time.Duration
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Owner string
+		TTL   time.Duration
+	}
+	mock.lockAcquireMigrationLock.RLock()
+	calls = mock.calls.AcquireMigrationLock
+	mock.lockAcquireMigrationLock.RUnlock()
+	return calls
+}
+
+// AddAppliedMigration calls AddAppliedMigrationFunc.
+func (mock *MigrationStoreMock) AddAppliedMigration(ctx context.Context, migration *models.AppliedMigration) error {
+	if mock.AddAppliedMigrationFunc == nil {
+		panic("MigrationStoreMock.AddAppliedMigrationFunc: method is nil but MigrationStore.AddAppliedMigration was just called")
+	}
+	callInfo := struct {
+		Ctx       context.Context
+		Migration *models.AppliedMigration
+	}{
+		Ctx:       ctx,
+		Migration: migration,
+	}
+	mock.lockAddAppliedMigration.Lock()
+	mock.calls.AddAppliedMigration = append(mock.calls.AddAppliedMigration, callInfo)
+	mock.lockAddAppliedMigration.Unlock()
+	return mock.AddAppliedMigrationFunc(ctx, migration)
+}
+
+// AddAppliedMigrationCalls gets all the calls that were made to AddAppliedMigration.
+// Check the length with:
+//     len(mockedMigrationStore.AddAppliedMigrationCalls())
+func (mock *MigrationStoreMock) AddAppliedMigrationCalls() []struct {
+	Ctx       context.Context
+	Migration *models.AppliedMigration
+} {
+	var calls []struct {
+		Ctx       context.Context
+		Migration *models.AppliedMigration
+	}
+	mock.lockAddAppliedMigration.RLock()
+	calls = mock.calls.AddAppliedMigration
+	mock.lockAddAppliedMigration.RUnlock()
+	return calls
+}
+
+// DeleteAppliedMigration calls DeleteAppliedMigrationFunc.
+func (mock *MigrationStoreMock) DeleteAppliedMigration(ctx context.Context, version int) error {
+	if mock.DeleteAppliedMigrationFunc == nil {
+		panic("MigrationStoreMock.DeleteAppliedMigrationFunc: method is nil but MigrationStore.DeleteAppliedMigration was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		Version int
+	}{
+		Ctx:     ctx,
+		Version: version,
+	}
+	mock.lockDeleteAppliedMigration.Lock()
+	mock.calls.DeleteAppliedMigration = append(mock.calls.DeleteAppliedMigration, callInfo)
+	mock.lockDeleteAppliedMigration.Unlock()
+	return mock.DeleteAppliedMigrationFunc(ctx, version)
+}
+
+// DeleteAppliedMigrationCalls gets all the calls that were made to DeleteAppliedMigration.
+// Check the length with:
+//     len(mockedMigrationStore.DeleteAppliedMigrationCalls())
+func (mock *MigrationStoreMock) DeleteAppliedMigrationCalls() []struct {
+	Ctx     context.Context
+	Version int
+} {
+	var calls []struct {
+		Ctx     context.Context
+		Version int
+	}
+	mock.lockDeleteAppliedMigration.RLock()
+	calls = mock.calls.DeleteAppliedMigration
+	mock.lockDeleteAppliedMigration.RUnlock()
+	return calls
+}
+
+// GetAppliedMigrations calls GetAppliedMigrationsFunc.
+func (mock *MigrationStoreMock) GetAppliedMigrations(ctx context.Context) ([]models.AppliedMigration, error) {
+	if mock.GetAppliedMigrationsFunc == nil {
+		panic("MigrationStoreMock.GetAppliedMigrationsFunc: method is nil but MigrationStore.GetAppliedMigrations was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockGetAppliedMigrations.Lock()
+	mock.calls.GetAppliedMigrations = append(mock.calls.GetAppliedMigrations, callInfo)
+	mock.lockGetAppliedMigrations.Unlock()
+	return mock.GetAppliedMigrationsFunc(ctx)
+}
+
+// GetAppliedMigrationsCalls gets all the calls that were made to GetAppliedMigrations.
+// Check the length with:
+//     len(mockedMigrationStore.GetAppliedMigrationsCalls())
+func (mock *MigrationStoreMock) GetAppliedMigrationsCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockGetAppliedMigrations.RLock()
+	calls = mock.calls.GetAppliedMigrations
+	mock.lockGetAppliedMigrations.RUnlock()
+	return calls
+}
+
+// ReleaseMigrationLock calls ReleaseMigrationLockFunc.
+func (mock *MigrationStoreMock) ReleaseMigrationLock(ctx context.Context, owner string) error {
+	if mock.ReleaseMigrationLockFunc == nil {
+		panic("MigrationStoreMock.ReleaseMigrationLockFunc: method is nil but MigrationStore.ReleaseMigrationLock was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Owner string
+	}{
+		Ctx:   ctx,
+		Owner: owner,
+	}
+	mock.lockReleaseMigrationLock.Lock()
+	mock.calls.ReleaseMigrationLock = append(mock.calls.ReleaseMigrationLock, callInfo)
+	mock.lockReleaseMigrationLock.Unlock()
+	return mock.ReleaseMigrationLockFunc(ctx, owner)
+}
+
+// ReleaseMigrationLockCalls gets all the calls that were made to ReleaseMigrationLock.
+// Check the length with:
+//     len(mockedMigrationStore.ReleaseMigrationLockCalls())
+func (mock *MigrationStoreMock) ReleaseMigrationLockCalls() []struct {
+	Ctx   context.Context
+	Owner string
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Owner string
+	}
+	mock.lockReleaseMigrationLock.RLock()
+	calls = mock.calls.ReleaseMigrationLock
+	mock.lockReleaseMigrationLock.RUnlock()
+	return calls
+}
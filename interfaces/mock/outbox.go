@@ -0,0 +1,174 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mock
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/events"
+	"github.com/cadmiumcat/books-api/interfaces"
+	"sync"
+)
+
+// Ensure, that OutboxMock does implement interfaces.Outbox.
+// If this is not the case, regenerate this file with moq.
+var _ interfaces.Outbox = &OutboxMock{}
+
+// OutboxMock is a mock implementation of interfaces.Outbox.
+//
+//     func TestSomethingThatUsesOutbox(t *testing.T) {
+//
+//         // make and configure a mocked interfaces.Outbox
+//         mockedOutbox := &OutboxMock{
+//             CountPendingEventsFunc: func(ctx context.Context) (int, error) {
+// 	               panic("mock out the CountPendingEvents method")
+//             },
+//             DeletePublishedEventFunc: func(ctx context.Context, eventID string) error {
+// 	               panic("mock out the DeletePublishedEvent method")
+//             },
+//             GetPendingEventsFunc: func(ctx context.Context, limit int) ([]events.Event, error) {
+// 	               panic("mock out the GetPendingEvents method")
+//             },
+//         }
+//
+//         // use mockedOutbox in code that requires interfaces.Outbox
+//         // and then make assertions.
+//
+//     }
+type OutboxMock struct {
+	// CountPendingEventsFunc mocks the CountPendingEvents method.
+	CountPendingEventsFunc func(ctx context.Context) (int, error)
+
+	// DeletePublishedEventFunc mocks the DeletePublishedEvent method.
+	DeletePublishedEventFunc func(ctx context.Context, eventID string) error
+
+	// GetPendingEventsFunc mocks the GetPendingEvents method.
+	GetPendingEventsFunc func(ctx context.Context, limit int) ([]events.Event, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// CountPendingEvents holds details about calls to the CountPendingEvents method.
+		CountPendingEvents []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// DeletePublishedEvent holds details about calls to the DeletePublishedEvent method.
+		DeletePublishedEvent []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// EventID is the eventID argument value.
+			EventID string
+		}
+		// GetPendingEvents holds details about calls to the GetPendingEvents method.
+		GetPendingEvents []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Limit is the limit argument value.
+			Limit int
+		}
+	}
+	lockCountPendingEvents   sync.RWMutex
+	lockDeletePublishedEvent sync.RWMutex
+	lockGetPendingEvents     sync.RWMutex
+}
+
+// CountPendingEvents calls CountPendingEventsFunc.
+func (mock *OutboxMock) CountPendingEvents(ctx context.Context) (int, error) {
+	if mock.CountPendingEventsFunc == nil {
+		panic("OutboxMock.CountPendingEventsFunc: method is nil but Outbox.CountPendingEvents was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockCountPendingEvents.Lock()
+	mock.calls.CountPendingEvents = append(mock.calls.CountPendingEvents, callInfo)
+	mock.lockCountPendingEvents.Unlock()
+	return mock.CountPendingEventsFunc(ctx)
+}
+
+// CountPendingEventsCalls gets all the calls that were made to CountPendingEvents.
+// Check the length with:
+//     len(mockedOutbox.CountPendingEventsCalls())
+func (mock *OutboxMock) CountPendingEventsCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockCountPendingEvents.RLock()
+	calls = mock.calls.CountPendingEvents
+	mock.lockCountPendingEvents.RUnlock()
+	return calls
+}
+
+// DeletePublishedEvent calls DeletePublishedEventFunc.
+func (mock *OutboxMock) DeletePublishedEvent(ctx context.Context, eventID string) error {
+	if mock.DeletePublishedEventFunc == nil {
+		panic("OutboxMock.DeletePublishedEventFunc: method is nil but Outbox.DeletePublishedEvent was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		EventID string
+	}{
+		Ctx:     ctx,
+		EventID: eventID,
+	}
+	mock.lockDeletePublishedEvent.Lock()
+	mock.calls.DeletePublishedEvent = append(mock.calls.DeletePublishedEvent, callInfo)
+	mock.lockDeletePublishedEvent.Unlock()
+	return mock.DeletePublishedEventFunc(ctx, eventID)
+}
+
+// DeletePublishedEventCalls gets all the calls that were made to DeletePublishedEvent.
+// Check the length with:
+//     len(mockedOutbox.DeletePublishedEventCalls())
+func (mock *OutboxMock) DeletePublishedEventCalls() []struct {
+	Ctx     context.Context
+	EventID string
+} {
+	var calls []struct {
+		Ctx     context.Context
+		EventID string
+	}
+	mock.lockDeletePublishedEvent.RLock()
+	calls = mock.calls.DeletePublishedEvent
+	mock.lockDeletePublishedEvent.RUnlock()
+	return calls
+}
+
+// GetPendingEvents calls GetPendingEventsFunc.
+func (mock *OutboxMock) GetPendingEvents(ctx context.Context, limit int) ([]events.Event, error) {
+	if mock.GetPendingEventsFunc == nil {
+		panic("OutboxMock.GetPendingEventsFunc: method is nil but Outbox.GetPendingEvents was just called")
+	}
+	callInfo := struct {
+		Ctx   context.Context
+		Limit int
+	}{
+		Ctx:   ctx,
+		Limit: limit,
+	}
+	mock.lockGetPendingEvents.Lock()
+	mock.calls.GetPendingEvents = append(mock.calls.GetPendingEvents, callInfo)
+	mock.lockGetPendingEvents.Unlock()
+	return mock.GetPendingEventsFunc(ctx, limit)
+}
+
+// GetPendingEventsCalls gets all the calls that were made to GetPendingEvents.
+// Check the length with:
+//     len(mockedOutbox.GetPendingEventsCalls())
+func (mock *OutboxMock) GetPendingEventsCalls() []struct {
+	Ctx   context.Context
+	Limit int
+} {
+	var calls []struct {
+		Ctx   context.Context
+		Limit int
+	}
+	mock.lockGetPendingEvents.RLock()
+	calls = mock.calls.GetPendingEvents
+	mock.lockGetPendingEvents.RUnlock()
+	return calls
+}
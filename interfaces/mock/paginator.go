@@ -5,6 +5,8 @@ package mock
 
 import (
 	"github.com/cadmiumcat/books-api/interfaces"
+	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/cadmiumcat/books-api/query"
 	"net/http"
 	"sync"
 )
@@ -19,9 +21,15 @@ var _ interfaces.Paginator = &PaginatorMock{}
 //
 //         // make and configure a mocked interfaces.Paginator
 //         mockedPaginator := &PaginatorMock{
+//             GetCursorFunc: func(r *http.Request, q *query.Query) (*pagination.Cursor, error) {
+// 	               panic("mock out the GetCursor method")
+//             },
 //             GetPaginationValuesFunc: func(r *http.Request) (int, int, error) {
 // 	               panic("mock out the GetPaginationValues method")
 //             },
+//             NewPageFunc: func(r *http.Request, q *query.Query, cursor *pagination.Cursor, items interface{}, offset int, limit int, totalCount int) (pagination.Page, error) {
+// 	               panic("mock out the NewPage method")
+//             },
 //         }
 //
 //         // use mockedPaginator in code that requires interfaces.Paginator
@@ -29,18 +37,85 @@ var _ interfaces.Paginator = &PaginatorMock{}
 //
 //     }
 type PaginatorMock struct {
+	// GetCursorFunc mocks the GetCursor method.
+	GetCursorFunc func(r *http.Request, q *query.Query) (*pagination.Cursor, error)
+
 	// GetPaginationValuesFunc mocks the GetPaginationValues method.
 	GetPaginationValuesFunc func(r *http.Request) (int, int, error)
 
+	// NewPageFunc mocks the NewPage method.
+	NewPageFunc func(r *http.Request, q *query.Query, cursor *pagination.Cursor, items interface{}, offset int, limit int, totalCount int) (pagination.Page, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
+		// GetCursor holds details about calls to the GetCursor method.
+		GetCursor []struct {
+			// R is the r argument value.
+			R *http.Request
+			// Q is the q argument value.
+			Q *query.Query
+		}
 		// GetPaginationValues holds details about calls to the GetPaginationValues method.
 		GetPaginationValues []struct {
 			// R is the r argument value.
 			R *http.Request
 		}
+		// NewPage holds details about calls to the NewPage method.
+		NewPage []struct {
+			// R is the r argument value.
+			R *http.Request
+			// Q is the q argument value.
+			Q *query.Query
+			// Cursor is the cursor argument value.
+			Cursor *pagination.Cursor
+			// Items is the items argument value.
+			Items interface{}
+			// Offset is the offset argument value.
+			Offset int
+			// Limit is the limit argument value.
+			Limit int
+			// TotalCount is the totalCount argument value.
+			TotalCount int
+		}
 	}
+	lockGetCursor           sync.RWMutex
 	lockGetPaginationValues sync.RWMutex
+	lockNewPage             sync.RWMutex
+}
+
+// GetCursor calls GetCursorFunc.
+func (mock *PaginatorMock) GetCursor(r *http.Request, q *query.Query) (*pagination.Cursor, error) {
+	if mock.GetCursorFunc == nil {
+		panic("PaginatorMock.GetCursorFunc: method is nil but Paginator.GetCursor was just called")
+	}
+	callInfo := struct {
+		R *http.Request
+		Q *query.Query
+	}{
+		R: r,
+		Q: q,
+	}
+	mock.lockGetCursor.Lock()
+	mock.calls.GetCursor = append(mock.calls.GetCursor, callInfo)
+	mock.lockGetCursor.Unlock()
+	return mock.GetCursorFunc(r, q)
+}
+
+// GetCursorCalls gets all the calls that were made to GetCursor.
+// Check the length with:
+//     len(mockedPaginator.GetCursorCalls())
+func (mock *PaginatorMock) GetCursorCalls() []struct {
+	R *http.Request
+	Q *query.Query
+} {
+	var calls []struct {
+		R *http.Request
+		Q *query.Query
+	}
+	mock.lockGetCursor.RLock()
+	calls = mock.calls.GetCursor
+	mock.lockGetCursor.RUnlock()
+	return calls
 }
 
 // GetPaginationValues calls GetPaginationValuesFunc.
@@ -73,3 +148,58 @@ func (mock *PaginatorMock) GetPaginationValuesCalls() []struct {
 	mock.lockGetPaginationValues.RUnlock()
 	return calls
 }
+
+// NewPage calls NewPageFunc.
+func (mock *PaginatorMock) NewPage(r *http.Request, q *query.Query, cursor *pagination.Cursor, items interface{}, offset int, limit int, totalCount int) (pagination.Page, error) {
+	if mock.NewPageFunc == nil {
+		panic("PaginatorMock.NewPageFunc: method is nil but Paginator.NewPage was just called")
+	}
+	callInfo := struct {
+		R          *http.Request
+		Q          *query.Query
+		Cursor     *pagination.Cursor
+		Items      interface{}
+		Offset     int
+		Limit      int
+		TotalCount int
+	}{
+		R:          r,
+		Q:          q,
+		Cursor:     cursor,
+		Items:      items,
+		Offset:     offset,
+		Limit:      limit,
+		TotalCount: totalCount,
+	}
+	mock.lockNewPage.Lock()
+	mock.calls.NewPage = append(mock.calls.NewPage, callInfo)
+	mock.lockNewPage.Unlock()
+	return mock.NewPageFunc(r, q, cursor, items, offset, limit, totalCount)
+}
+
+// NewPageCalls gets all the calls that were made to NewPage.
+// Check the length with:
+//     len(mockedPaginator.NewPageCalls())
+func (mock *PaginatorMock) NewPageCalls() []struct {
+	R          *http.Request
+	Q          *query.Query
+	Cursor     *pagination.Cursor
+	Items      interface{}
+	Offset     int
+	Limit      int
+	TotalCount int
+} {
+	var calls []struct {
+		R          *http.Request
+		Q          *query.Query
+		Cursor     *pagination.Cursor
+		Items      interface{}
+		Offset     int
+		Limit      int
+		TotalCount int
+	}
+	mock.lockNewPage.RLock()
+	calls = mock.calls.NewPage
+	mock.lockNewPage.RUnlock()
+	return calls
+}
@@ -0,0 +1,111 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mock
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/interfaces"
+	"sync"
+)
+
+// Ensure, that RelayMock does implement interfaces.Relay.
+// If this is not the case, regenerate this file with moq.
+var _ interfaces.Relay = &RelayMock{}
+
+// RelayMock is a mock implementation of interfaces.Relay.
+//
+//     func TestSomethingThatUsesRelay(t *testing.T) {
+//
+//         // make and configure a mocked interfaces.Relay
+//         mockedRelay := &RelayMock{
+//             StartFunc: func(ctx context.Context)  {
+// 	               panic("mock out the Start method")
+//             },
+//             StopFunc: func()  {
+// 	               panic("mock out the Stop method")
+//             },
+//         }
+//
+//         // use mockedRelay in code that requires interfaces.Relay
+//         // and then make assertions.
+//
+//     }
+type RelayMock struct {
+	// StartFunc mocks the Start method.
+	StartFunc func(ctx context.Context)
+
+	// StopFunc mocks the Stop method.
+	StopFunc func()
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// Start holds details about calls to the Start method.
+		Start []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// Stop holds details about calls to the Stop method.
+		Stop []struct {
+		}
+	}
+	lockStart sync.RWMutex
+	lockStop  sync.RWMutex
+}
+
+// Start calls StartFunc.
+func (mock *RelayMock) Start(ctx context.Context) {
+	if mock.StartFunc == nil {
+		panic("RelayMock.StartFunc: method is nil but Relay.Start was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockStart.Lock()
+	mock.calls.Start = append(mock.calls.Start, callInfo)
+	mock.lockStart.Unlock()
+	mock.StartFunc(ctx)
+}
+
+// StartCalls gets all the calls that were made to Start.
+// Check the length with:
+//     len(mockedRelay.StartCalls())
+func (mock *RelayMock) StartCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockStart.RLock()
+	calls = mock.calls.Start
+	mock.lockStart.RUnlock()
+	return calls
+}
+
+// Stop calls StopFunc.
+func (mock *RelayMock) Stop() {
+	if mock.StopFunc == nil {
+		panic("RelayMock.StopFunc: method is nil but Relay.Stop was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockStop.Lock()
+	mock.calls.Stop = append(mock.calls.Stop, callInfo)
+	mock.lockStop.Unlock()
+	mock.StopFunc()
+}
+
+// StopCalls gets all the calls that were made to Stop.
+// Check the length with:
+//     len(mockedRelay.StopCalls())
+func (mock *RelayMock) StopCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockStop.RLock()
+	calls = mock.calls.Stop
+	mock.lockStop.RUnlock()
+	return calls
+}
@@ -0,0 +1,94 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mock
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/interfaces"
+	"github.com/cadmiumcat/books-api/models"
+	"sync"
+)
+
+// Ensure, that SearcherMock does implement interfaces.Searcher.
+// If this is not the case, regenerate this file with moq.
+var _ interfaces.Searcher = &SearcherMock{}
+
+// SearcherMock is a mock implementation of interfaces.Searcher.
+//
+//     func TestSomethingThatUsesSearcher(t *testing.T) {
+//
+//         // make and configure a mocked interfaces.Searcher
+//         mockedSearcher := &SearcherMock{
+//             SearchBooksFunc: func(ctx context.Context, query string, offset int, limit int) ([]models.SearchResult, int, error) {
+// 	               panic("mock out the SearchBooks method")
+//             },
+//         }
+//
+//         // use mockedSearcher in code that requires interfaces.Searcher
+//         // and then make assertions.
+//
+//     }
+type SearcherMock struct {
+	// SearchBooksFunc mocks the SearchBooks method.
+	SearchBooksFunc func(ctx context.Context, query string, offset int, limit int) ([]models.SearchResult, int, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// SearchBooks holds details about calls to the SearchBooks method.
+		SearchBooks []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Query is the query argument value.
+			Query string
+			// Offset is the offset argument value.
+			Offset int
+			// Limit is the limit argument value.
+			Limit int
+		}
+	}
+	lockSearchBooks sync.RWMutex
+}
+
+// SearchBooks calls SearchBooksFunc.
+func (mock *SearcherMock) SearchBooks(ctx context.Context, query string, offset int, limit int) ([]models.SearchResult, int, error) {
+	if mock.SearchBooksFunc == nil {
+		panic("SearcherMock.SearchBooksFunc: method is nil but Searcher.SearchBooks was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Query  string
+		Offset int
+		Limit  int
+	}{
+		Ctx:    ctx,
+		Query:  query,
+		Offset: offset,
+		Limit:  limit,
+	}
+	mock.lockSearchBooks.Lock()
+	mock.calls.SearchBooks = append(mock.calls.SearchBooks, callInfo)
+	mock.lockSearchBooks.Unlock()
+	return mock.SearchBooksFunc(ctx, query, offset, limit)
+}
+
+// SearchBooksCalls gets all the calls that were made to SearchBooks.
+// Check the length with:
+//     len(mockedSearcher.SearchBooksCalls())
+func (mock *SearcherMock) SearchBooksCalls() []struct {
+	Ctx    context.Context
+	Query  string
+	Offset int
+	Limit  int
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Query  string
+		Offset int
+		Limit  int
+	}
+	mock.lockSearchBooks.RLock()
+	calls = mock.calls.SearchBooks
+	mock.lockSearchBooks.RUnlock()
+	return calls
+}
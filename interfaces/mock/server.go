@@ -4,6 +4,7 @@
 package mock
 
 import (
+	"context"
 	"github.com/cadmiumcat/books-api/interfaces"
 	"sync"
 )
@@ -21,6 +22,9 @@ var _ interfaces.HTTPServer = &HTTPServerMock{}
 //             ListenAndServeFunc: func() error {
 // 	               panic("mock out the ListenAndServe method")
 //             },
+//             ShutdownFunc: func(ctx context.Context) error {
+// 	               panic("mock out the Shutdown method")
+//             },
 //         }
 //
 //         // use mockedHTTPServer in code that requires interfaces.HTTPServer
@@ -31,13 +35,22 @@ type HTTPServerMock struct {
 	// ListenAndServeFunc mocks the ListenAndServe method.
 	ListenAndServeFunc func() error
 
+	// ShutdownFunc mocks the Shutdown method.
+	ShutdownFunc func(ctx context.Context) error
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// ListenAndServe holds details about calls to the ListenAndServe method.
 		ListenAndServe []struct {
 		}
+		// Shutdown holds details about calls to the Shutdown method.
+		Shutdown []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
 	}
 	lockListenAndServe sync.RWMutex
+	lockShutdown       sync.RWMutex
 }
 
 // ListenAndServe calls ListenAndServeFunc.
@@ -65,3 +78,34 @@ func (mock *HTTPServerMock) ListenAndServeCalls() []struct {
 	mock.lockListenAndServe.RUnlock()
 	return calls
 }
+
+// Shutdown calls ShutdownFunc.
+func (mock *HTTPServerMock) Shutdown(ctx context.Context) error {
+	if mock.ShutdownFunc == nil {
+		panic("HTTPServerMock.ShutdownFunc: method is nil but HTTPServer.Shutdown was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockShutdown.Lock()
+	mock.calls.Shutdown = append(mock.calls.Shutdown, callInfo)
+	mock.lockShutdown.Unlock()
+	return mock.ShutdownFunc(ctx)
+}
+
+// ShutdownCalls gets all the calls that were made to Shutdown.
+// Check the length with:
+//     len(mockedHTTPServer.ShutdownCalls())
+func (mock *HTTPServerMock) ShutdownCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockShutdown.RLock()
+	calls = mock.calls.Shutdown
+	mock.lockShutdown.RUnlock()
+	return calls
+}
@@ -4,15 +4,20 @@ import (
 	"context"
 	"errors"
 	dpHealthCheck "github.com/ONSdigital/dp-healthcheck/healthcheck"
-	dpMongoDB "github.com/ONSdigital/dp-mongodb/health"
 	"github.com/ONSdigital/log.go/log"
 	"github.com/cadmiumcat/books-api/api"
+	"github.com/cadmiumcat/books-api/auth"
 	"github.com/cadmiumcat/books-api/config"
 	"github.com/cadmiumcat/books-api/initialiser"
+	"github.com/cadmiumcat/books-api/metrics"
+	"github.com/cadmiumcat/books-api/migrations"
 	"github.com/cadmiumcat/books-api/mongo"
+	"github.com/cadmiumcat/books-api/outbox"
 	"github.com/cadmiumcat/books-api/pagination"
 	"github.com/gorilla/mux"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 const serviceName = "books-api"
@@ -50,54 +55,152 @@ func main() {
 
 	hc := dpHealthCheck.New(versionInfo, cfg.HealthCheckCriticalTimeout, cfg.HealthCheckInterval)
 
-	// Initialise database
-	mongodb := &mongo.Mongo{}
-	err = mongodb.Init(cfg.MongoConfig)
+	// Initialise the data store
+	dataStore, err := initialiser.GetDataStore(cfg)
 	if err != nil {
-		log.Event(ctx, "failed to initialise mongo", log.FATAL, log.Error(err))
+		log.Event(ctx, "failed to initialise the data store", log.FATAL, log.Error(err), log.Data{"data_store": cfg.DataStore})
 		os.Exit(1)
 	}
 
-	databaseCollectionBuilder := make(map[dpMongoDB.Database][]dpMongoDB.Collection)
-	databaseCollectionBuilder[(dpMongoDB.Database)(mongodb.Database)] =
-		[]dpMongoDB.Collection{
-			(dpMongoDB.Collection)(mongodb.BooksCollection),
-			(dpMongoDB.Collection)(mongodb.ReviewsCollection),
+	if err := dataStore.Init(cfg.MongoConfig); err != nil {
+		log.Event(ctx, "failed to initialise the data store", log.FATAL, log.Error(err), log.Data{"data_store": cfg.DataStore})
+		os.Exit(1)
+	}
+
+	// mongodb is nil if the data is kept in memory
+	mongodb, _ := dataStore.(*mongo.Mongo)
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(ctx, cfg, mongodb, os.Args[2:]))
+	}
+
+	if mongodb != nil {
+		if err := migrateOnStartup(ctx, cfg.MigrationsConfig, mongodb); err != nil {
+			log.Event(ctx, "failed to migrate the database", log.FATAL, log.Error(err))
+			os.Exit(1)
 		}
+	} else {
+		log.Event(ctx, "the data is kept in memory, and will be lost when the service stops", log.WARN, log.Data{"data_store": cfg.DataStore})
+	}
+
+	// Every operation of the data store is timed, whichever part of the service makes it
+	serviceMetrics := metrics.New()
+	store := serviceMetrics.Store(dataStore)
 
-	mongoClient := dpMongoDB.NewClientWithCollections(mongodb.Session.Copy(), databaseCollectionBuilder)
+	svc := initialiser.Service{
+		DataStore:       store,
+		HealthCheck:     &hc,
+		ShutdownTimeout: cfg.GracefulShutdownTimeout,
+	}
+	svc.EventProducer, err = initialiser.GetEventProducer(cfg)
+	if err != nil {
+		log.Event(ctx, "failed to initialise the event producer", log.FATAL, log.Error(err), log.Data{"event_producer": cfg.EventProducer})
+		os.Exit(1)
+	}
+
+	// Publish the events written to the outbox
+	relay := outbox.NewRelay(store, svc.EventProducer, cfg.OutboxConfig)
+	svc.Relay = relay
 
 	// Add API checks
-	if err := registerCheckers(ctx, &hc, mongoClient); err != nil {
+	if err := registerCheckers(ctx, &hc, serviceMetrics, mongodb, relay); err != nil {
 		log.Event(ctx, err.Error(), log.FATAL, log.Error(err))
 		os.Exit(1)
 	}
 	hc.Start(ctx)
+	relay.Start(ctx)
 
 	// Initialise server
-	svc := initialiser.Service{}
 	router := mux.NewRouter()
-	svc.Server = initialiser.GetHTTPServer(cfg.BindAddr, router)
+	serviceMetrics.Instrument(router)
+	router.Handle("/metrics", serviceMetrics.Handler()).Methods("GET")
+	svc.Server = initialiser.GetHTTPServer(cfg.BindAddr, cfg.HTTPWriteTimeout, router)
 
-	paginator := pagination.NewPaginator(cfg.DefaultLimit, cfg.DefaultOffset, cfg.DefaultMaximumLimit)
+	paginator, err := pagination.NewPaginator(cfg.DefaultLimit, cfg.DefaultOffset, cfg.DefaultMaximumLimit, cfg.PaginationCursorSecret, api.BaseURL(cfg.APIURL, cfg.APIVersion))
+	if err != nil {
+		log.Event(ctx, "failed to initialise the paginator", log.FATAL, log.Error(err))
+		os.Exit(1)
+	}
 
-	svc.API = api.Setup(ctx, cfg.BindAddr, router, paginator, mongodb, &hc)
+	authenticator, err := auth.NewAuthenticator(cfg.AuthConfig)
+	if err != nil {
+		log.Event(ctx, "failed to initialise the authenticator", log.FATAL, log.Error(err))
+		os.Exit(1)
+	}
+
+	svc.API = api.Setup(ctx, cfg, router, serviceMetrics.Paginator(paginator), store, store, store, authenticator, &hc)
 
-	svc.Server.ListenAndServe()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 
-	hc.Stop()
+	if err := svc.Run(ctx, signals); err != nil {
+		log.Event(ctx, "the service did not shut down cleanly", log.FATAL, log.Error(err))
+		os.Exit(1)
+	}
 }
 
-// registerCheckers adds the checkers for the provided clients to the health check object
-func registerCheckers(ctx context.Context, hc *dpHealthCheck.HealthCheck, mongoClient *dpMongoDB.Client) error {
+// runMigrate runs the migrate subcommand against the mongo data store, and returns the exit code of the service
+func runMigrate(ctx context.Context, cfg *config.Configuration, mongodb *mongo.Mongo, args []string) int {
+	defer func() {
+		if mongodb != nil {
+			mongodb.Close(ctx)
+		}
+	}()
+
+	if mongodb == nil {
+		log.Event(ctx, "migrations only apply to the mongo data store", log.FATAL, log.Data{"data_store": cfg.DataStore})
+		return 1
+	}
+
+	migrator, err := migrations.NewMigrator(mongodb, mongodb.Migrations(), cfg.MigrationsConfig)
+	if err != nil {
+		log.Event(ctx, "failed to initialise the migrator", log.FATAL, log.Error(err))
+		return 1
+	}
+
+	if err := migrate(ctx, migrator, args, os.Stdout); err != nil {
+		log.Event(ctx, "failed to migrate the database", log.FATAL, log.Error(err), log.Data{"args": args})
+		return 1
+	}
+	return 0
+}
+
+// migrateOnStartup applies the pending migrations of the mongo data store if MIGRATE_ON_STARTUP is set.
+// Otherwise, it warns if any migration is pending, as the service may not work until they are applied.
+func migrateOnStartup(ctx context.Context, cfg config.MigrationsConfig, mongodb *mongo.Mongo) error {
+	migrator, err := migrations.NewMigrator(mongodb, mongodb.Migrations(), cfg)
+	if err != nil {
+		return err
+	}
+
+	if cfg.OnStartup {
+		return migrator.Up(ctx, 0)
+	}
+
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		log.Event(ctx, "migrations are pending: run the migrate up command", log.WARN, log.Data{"pending": pending})
+	}
+	return nil
+}
+
+// registerCheckers adds the checkers for the provided clients to the health check object, and records their states in
+// the metrics. MongoDB is not checked if there is no mongo data store, as the data is kept in memory.
+func registerCheckers(ctx context.Context, hc *dpHealthCheck.HealthCheck, serviceMetrics *metrics.Metrics, mongodb *mongo.Mongo, relay *outbox.Relay) error {
 	var hasErrors bool
-	mongoHealth := dpMongoDB.CheckMongoClient{
-		Client:      *mongoClient,
-		Healthcheck: mongoClient.Healthcheck,
+	if mongodb != nil {
+		if err := hc.AddCheck("mongoDB", serviceMetrics.Checker("mongoDB", mongodb.Checker)); err != nil {
+			hasErrors = true
+			log.Event(ctx, "error adding mongoDB checker", log.FATAL, log.Error(err))
+		}
 	}
-	if err := hc.AddCheck("mongoDB", mongoHealth.Checker); err != nil {
+
+	if err := hc.AddCheck("outbox", serviceMetrics.Checker("outbox", relay.Checker)); err != nil {
 		hasErrors = true
-		log.Event(ctx, "error adding mongoDB checker", log.FATAL, log.Error(err))
+		log.Event(ctx, "error adding outbox checker", log.FATAL, log.Error(err))
 	}
 
 	if hasErrors {
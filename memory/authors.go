@@ -0,0 +1,113 @@
+package memory
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/mongo"
+	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/cadmiumcat/books-api/query"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// AddAuthor adds an Author
+func (s *Store) AddAuthor(ctx context.Context, author *models.Author) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.authors.insert(author) {
+		return errors.Wrap(errDuplicateID, "unexpected error when adding an author")
+	}
+
+	return nil
+}
+
+// GetAuthor returns a models.Author for a given ID.
+// It returns an error if the Author is not found
+func (s *Store) GetAuthor(ctx context.Context, ID string) (*models.Author, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var author models.Author
+	if !s.authors.get(ID, &author) {
+		return nil, mongo.ErrAuthorNotFound
+	}
+
+	return &author, nil
+}
+
+// GetAuthors returns the authors that match the filters of the query, sorted as requested by the query.
+// The page starts at the cursor if one is provided, and at the offset otherwise.
+func (s *Store) GetAuthors(ctx context.Context, q *query.Query, cursor *pagination.Cursor, offset, limit int) ([]models.Author, int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	page, totalCount := s.authors.page(nil, q, cursor, offset, limit)
+
+	var authors []models.Author
+	for _, document := range page {
+		var author models.Author
+		fromDocument(document, &author)
+		authors = append(authors, author)
+	}
+
+	return authors, totalCount, nil
+}
+
+// UpdateAuthor renames an existing Author, and refreshes the author of the books it contributed to.
+// It returns an error if the Author is not found
+func (s *Store) UpdateAuthor(ctx context.Context, ID string, author *models.Author) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.authors.documents[ID]; !ok {
+		return mongo.ErrAuthorNotFound
+	}
+	s.authors.set(ID, bson.M{"name": author.Name})
+
+	// The author of each book is made from the current names of its contributors
+	for _, document := range s.books.find(contributedBy(ID)) {
+		var book models.Book
+		fromDocument(document, &book)
+
+		names := make(map[string]string)
+		for _, contributor := range book.Authors {
+			var contributorAuthor models.Author
+			s.authors.get(contributor.AuthorID, &contributorAuthor)
+			names[contributor.AuthorID] = contributorAuthor.Name
+		}
+		s.books.set(book.ID, bson.M{"author": models.Byline(book.Authors, names), "revision": book.Revision + 1})
+		s.reindex(book.ID)
+	}
+
+	return nil
+}
+
+// DeleteAuthor removes an Author.
+// It returns an error if the Author is not found, or if it contributed to any books
+func (s *Store) DeleteAuthor(ctx context.Context, ID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.books.count(contributedBy(ID)) > 0 {
+		return mongo.ErrAuthorHasBooks
+	}
+
+	if !s.authors.remove(ID) {
+		return mongo.ErrAuthorNotFound
+	}
+
+	return nil
+}
+
+// contributedBy matches the books that an author contributed to
+func contributedBy(authorID string) func(document bson.M) bool {
+	return func(document bson.M) bool {
+		for _, value := range values(document, "authors.author_id") {
+			if value == authorID {
+				return true
+			}
+		}
+		return false
+	}
+}
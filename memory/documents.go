@@ -0,0 +1,401 @@
+package memory
+
+import (
+	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/cadmiumcat/books-api/query"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"strings"
+)
+
+// A collection holds documents by ID, as they would be stored by MongoDB: items are marshalled with their bson tags,
+// so that the fields omitted from a document, the names of the fields and the types of their values are the same.
+// Documents are copied in and out of the collection, so that callers never share them.
+type collection struct {
+	documents map[string]bson.M
+	inserted  map[string]int64
+	next      int64
+}
+
+func newCollection() *collection {
+	return &collection{
+		documents: make(map[string]bson.M),
+		inserted:  make(map[string]int64),
+	}
+}
+
+// toDocument returns the document of an item, as it would be stored
+func toDocument(item interface{}) bson.M {
+	data, err := bson.Marshal(item)
+	if err != nil {
+		panic(err)
+	}
+	document := bson.M{}
+	if err := bson.Unmarshal(data, document); err != nil {
+		panic(err)
+	}
+	return document
+}
+
+// fromDocument unmarshals a copy of a document into an item
+func fromDocument(document bson.M, item interface{}) {
+	data, err := bson.Marshal(document)
+	if err != nil {
+		panic(err)
+	}
+	if err := bson.Unmarshal(data, item); err != nil {
+		panic(err)
+	}
+}
+
+// insert stores an item. It returns false if there is already a document with the ID of the item.
+func (c *collection) insert(item interface{}) bool {
+	document := toDocument(item)
+	id, _ := document["_id"].(string)
+	if _, ok := c.documents[id]; ok {
+		return false
+	}
+
+	c.documents[id] = document
+	c.inserted[id] = c.next
+	c.next++
+	return true
+}
+
+// get unmarshals the document with the given ID into item. It returns false if there is no such document.
+func (c *collection) get(id string, item interface{}) bool {
+	document, ok := c.documents[id]
+	if !ok {
+		return false
+	}
+	fromDocument(document, item)
+	return true
+}
+
+// replace replaces the document with the ID of the item, keeping its position in the natural order
+func (c *collection) replace(item interface{}) {
+	document := toDocument(item)
+	id, _ := document["_id"].(string)
+	c.documents[id] = document
+}
+
+func (c *collection) remove(id string) bool {
+	if _, ok := c.documents[id]; !ok {
+		return false
+	}
+	delete(c.documents, id)
+	delete(c.inserted, id)
+	return true
+}
+
+// set sets the fields of a document, by dotted path, creating the embedded documents on the way
+func (c *collection) set(id string, fields bson.M) {
+	document := c.documents[id]
+	for path, value := range fields {
+		names := strings.Split(path, ".")
+		embedded := document
+		for _, name := range names[:len(names)-1] {
+			next, ok := embedded[name].(bson.M)
+			if !ok {
+				next = bson.M{}
+				embedded[name] = next
+			}
+			embedded = next
+		}
+		embedded[names[len(names)-1]] = toDocument(bson.M{"v": value})["v"]
+	}
+}
+
+// unset removes the fields of a document
+func (c *collection) unset(id string, fields ...string) {
+	for _, field := range fields {
+		delete(c.documents[id], field)
+	}
+}
+
+// revision returns the revision of a document
+func revision(document bson.M) int {
+	n, _ := number(document["revision"])
+	return int(n)
+}
+
+// increment adds the increments to the numeric fields of a document, by dotted path. Missing fields start at zero.
+func (c *collection) increment(id string, increments map[string]int) {
+	fields := bson.M{}
+	for path, increment := range increments {
+		current, _ := number(field(c.documents[id], path))
+		fields[path] = int(current) + increment
+	}
+	c.set(id, fields)
+}
+
+// find returns the documents that match, in their natural order, which is the order in which they were inserted
+func (c *collection) find(match func(document bson.M) bool) []bson.M {
+	var found []bson.M
+	for _, document := range c.documents {
+		if match == nil || match(document) {
+			found = append(found, document)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return c.inserted[found[i]["_id"].(string)] < c.inserted[found[j]["_id"].(string)]
+	})
+	return found
+}
+
+// count returns the number of documents that match
+func (c *collection) count(match func(document bson.M) bool) int {
+	count := 0
+	for _, document := range c.documents {
+		if match == nil || match(document) {
+			count++
+		}
+	}
+	return count
+}
+
+// page returns a page of the documents that match and the filters of the query, sorted as requested by the query,
+// and the total number of documents that match. The page starts at the cursor if one is provided, and at the offset
+// otherwise, as it does in the mongo package. No documents are returned if the limit is not positive.
+func (c *collection) page(match func(document bson.M) bool, q *query.Query, cursor *pagination.Cursor, offset, limit int) ([]bson.M, int) {
+	found := c.find(func(document bson.M) bool {
+		return (match == nil || match(document)) && matchesQuery(document, q)
+	})
+	totalCount := len(found)
+	if limit <= 0 {
+		return nil, totalCount
+	}
+
+	if cursor != nil {
+		keys := pagination.SortKeys(q)
+		var after []bson.M
+		for _, document := range found {
+			if cursor.IsStart() || isAfter(document, keys, cursor) {
+				after = append(after, document)
+			}
+		}
+		sortDocuments(after, keys, cursor.Backward)
+		if len(after) > limit {
+			after = after[:limit]
+		}
+		if cursor.Backward {
+			for i, j := 0, len(after)-1; i < j; i, j = i+1, j-1 {
+				after[i], after[j] = after[j], after[i]
+			}
+		}
+		return after, totalCount
+	}
+
+	if q != nil && len(q.Sort) > 0 {
+		sortDocuments(found, append(append([]query.Sort{}, q.Sort...), query.Sort{Key: "_id"}), false)
+	}
+	if offset > len(found) {
+		offset = len(found)
+	}
+	found = found[offset:]
+	if len(found) > limit {
+		found = found[:limit]
+	}
+	return found, totalCount
+}
+
+// matchesQuery returns true if a document matches all the filters of a query.
+// A filter on an array matches if any of its elements does, as it does in MongoDB.
+func matchesQuery(document bson.M, q *query.Query) bool {
+	if q == nil {
+		return true
+	}
+
+	for _, filter := range q.Filters {
+		matched := false
+		for _, value := range values(document, filter.Key) {
+			s, ok := value.(string)
+			if !ok {
+				continue
+			}
+			if filter.Operator == query.Contains {
+				matched = strings.Contains(strings.ToLower(s), strings.ToLower(filter.Value))
+			} else {
+				matched = s == filter.Value
+			}
+			if matched {
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// values returns the values of a field of a document, by dotted path, going through the elements of the arrays
+// on the way. An array is returned along with its elements.
+func values(value interface{}, path string) []interface{} {
+	if path == "" {
+		if array, ok := value.(bson.A); ok {
+			return append([]interface{}{value}, array...)
+		}
+		return []interface{}{value}
+	}
+
+	name, rest := path, ""
+	if i := strings.Index(path, "."); i >= 0 {
+		name, rest = path[:i], path[i+1:]
+	}
+
+	switch v := value.(type) {
+	case bson.M:
+		embedded, ok := v[name]
+		if !ok {
+			return nil
+		}
+		return values(embedded, rest)
+	case bson.A:
+		var found []interface{}
+		for _, element := range v {
+			found = append(found, values(element, path)...)
+		}
+		return found
+	}
+	return nil
+}
+
+// field returns the value of a field of a document, by dotted path through embedded documents, or nil if it is missing
+func field(document bson.M, path string) interface{} {
+	var value interface{} = document
+	for _, name := range strings.Split(path, ".") {
+		embedded, ok := value.(bson.M)
+		if !ok {
+			return nil
+		}
+		value = embedded[name]
+	}
+	return value
+}
+
+// sortDocuments sorts documents by the keys. The order of each key is reversed if reverse is true.
+func sortDocuments(documents []bson.M, keys []query.Sort, reverse bool) {
+	sort.SliceStable(documents, func(i, j int) bool {
+		for _, key := range keys {
+			order := compare(field(documents[i], key.Key), field(documents[j], key.Key))
+			if order == 0 {
+				continue
+			}
+			if key.Descending != reverse {
+				return order > 0
+			}
+			return order < 0
+		}
+		return false
+	})
+}
+
+// isAfter returns true if a document is after (or, for a backward cursor, before) the position of the cursor.
+// For a sort key (k1, k2, _id), the documents after (v1, v2, id) are those with k1 > v1, or k1 = v1 and k2 > v2,
+// or k1 = v1 and k2 = v2 and _id > id, where > is < for the fields sorted in descending order.
+// As in MongoDB, a value is only greater or less than a value of the same type, except that a missing value is null,
+// which is less than any other value, as it is sorted first.
+func isAfter(document bson.M, keys []query.Sort, cursor *pagination.Cursor) bool {
+	for i, key := range keys {
+		value, position := field(document, key.Key), cursor.Values[i]
+		if rank(value) != rank(position) && value != nil && position != nil {
+			return false
+		}
+
+		order := compare(value, position)
+		if key.Descending != cursor.Backward {
+			order = -order
+		}
+		if order > 0 {
+			return true
+		}
+		if order < 0 {
+			return false
+		}
+	}
+	return false
+}
+
+// rank returns the position of the type of a value in the order of the types of MongoDB.
+// A missing value is null.
+func rank(value interface{}) int {
+	switch value.(type) {
+	case nil:
+		return 1
+	case int, int32, int64, float64:
+		return 2
+	case string:
+		return 3
+	case bson.M:
+		return 4
+	case bson.A:
+		return 5
+	case bool:
+		return 8
+	case primitive.DateTime:
+		return 9
+	default:
+		return 10
+	}
+}
+
+// number returns the value of a number, whatever its type
+func number(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// compare returns -1, 0 or 1 if a is less than, equal to, or greater than b, in the order of MongoDB
+func compare(a, b interface{}) int {
+	if ra, rb := rank(a), rank(b); ra != rb {
+		if ra < rb {
+			return -1
+		}
+		return 1
+	}
+
+	switch va := a.(type) {
+	case string:
+		return strings.Compare(va, b.(string))
+	case primitive.DateTime:
+		vb := b.(primitive.DateTime)
+		switch {
+		case va < vb:
+			return -1
+		case va > vb:
+			return 1
+		}
+		return 0
+	case bool:
+		vb := b.(bool)
+		switch {
+		case va == vb:
+			return 0
+		case !va:
+			return -1
+		}
+		return 1
+	}
+
+	if na, ok := number(a); ok {
+		nb, _ := number(b)
+		switch {
+		case na < nb:
+			return -1
+		case na > nb:
+			return 1
+		}
+	}
+	return 0
+}
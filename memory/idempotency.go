@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/models"
+	"time"
+)
+
+// ReserveIdempotencyKey stores the record of a request made with an idempotency key, unless the key is reserved by
+// a record that has not expired, in which case that record is returned. It returns nil if the key is reserved for
+// this request. The expired records are removed first, as MongoDB would remove them.
+func (s *Store) ReserveIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now().UTC()
+	for _, document := range s.idempotency.find(nil) {
+		var expired models.IdempotencyRecord
+		fromDocument(document, &expired)
+		if !expired.ExpiresAt.After(now) {
+			s.idempotency.remove(expired.ID)
+		}
+	}
+
+	var existing models.IdempotencyRecord
+	if s.idempotency.get(record.ID, &existing) {
+		return &existing, nil
+	}
+	s.idempotency.insert(record)
+
+	return nil, nil
+}
+
+// CompleteIdempotencyKey records the response to the request that reserved an idempotency key, and when it expires
+func (s *Store) CompleteIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.idempotency.documents[record.ID]; ok {
+		s.idempotency.replace(record)
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey removes the record of a request made with an idempotency key, so that the key can be used again
+func (s *Store) ReleaseIdempotencyKey(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.idempotency.remove(id)
+
+	return nil
+}
@@ -0,0 +1,396 @@
+package memory
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/config"
+	"github.com/cadmiumcat/books-api/events"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/mongo"
+	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/cadmiumcat/books-api/query"
+	"github.com/cadmiumcat/books-api/search"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"sort"
+	"sync"
+)
+
+// errDuplicateID represents an error case where a document is added with the ID of an existing document.
+// Like the duplicate key error of MongoDB, it is a mongo.ErrDuplicateKey write error.
+var errDuplicateID = &mongo.WriteError{Kind: mongo.ErrDuplicateKey, Err: errors.New("a document with the same ID already exists")}
+
+// Store is a data store that keeps the books, reviews, reservations, authors, pending events and idempotency records in memory.
+// It behaves like the mongo package, returning the same errors, so that the service can run without a database,
+// e.g. in development and in tests. Everything it holds is lost when the service stops.
+type Store struct {
+	mutex          sync.RWMutex
+	books          *collection
+	reviews        *collection
+	reservations   *collection
+	authors        *collection
+	outbox         *collection
+	outboxSequence int64
+	idempotency    *collection
+	index          *search.Index
+}
+
+// New creates a new, empty, instance of Store
+func New() *Store {
+	return &Store{
+		books:        newCollection(),
+		reviews:      newCollection(),
+		reservations: newCollection(),
+		authors:      newCollection(),
+		outbox:       newCollection(),
+		idempotency:  newCollection(),
+		index:        search.NewIndex(),
+	}
+}
+
+// Init does nothing, as there is no database to connect to
+func (s *Store) Init(config.MongoConfig) error {
+	return nil
+}
+
+// Close does nothing, as there is no database to disconnect from
+func (s *Store) Close(ctx context.Context) error {
+	return nil
+}
+
+// AddBook adds a Book, and stores a book-created event in the outbox.
+// It returns an error if another Book has the same ISBN
+func (s *Store) AddBook(ctx context.Context, book *models.Book) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.hasISBN(book.ID, book.ISBN) {
+		return mongo.ErrDuplicateISBN
+	}
+
+	event, err := events.NewBookCreated(book)
+	if err != nil {
+		return errors.Wrap(err, "unexpected error when adding a book")
+	}
+
+	if _, ok := s.books.documents[book.ID]; ok {
+		return errors.Wrap(errDuplicateID, "unexpected error when adding a book")
+	}
+	s.books.insert(book)
+	s.reindex(book.ID)
+	s.insertEvent(event)
+
+	return nil
+}
+
+// AddBooks adds a batch of Books, and stores their book-created events in the outbox.
+// A Book with the ISBN of an existing Book, or of a previous Book of the batch, is not added.
+// It returns the error of each Book, which is nil if the Book is added and ErrDuplicateISBN if it is not,
+// or an error if the batch cannot be added, in which case none of its Books are.
+func (s *Store) AddBooks(ctx context.Context, books []*models.Book) ([]error, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	results := make([]error, len(books))
+	existing := make(map[string]bool)
+	var added []*events.Event
+	for i, book := range books {
+		if book.ISBN != "" {
+			if existing[book.ISBN] || s.hasISBN("", book.ISBN) {
+				results[i] = mongo.ErrDuplicateISBN
+				continue
+			}
+			existing[book.ISBN] = true
+		}
+
+		if _, ok := s.books.documents[book.ID]; ok {
+			return nil, errors.Wrap(errDuplicateID, "unexpected error when adding books")
+		}
+
+		event, err := events.NewBookCreated(book)
+		if err != nil {
+			return nil, errors.Wrap(err, "unexpected error when adding books")
+		}
+		added = append(added, event)
+	}
+
+	for i, book := range books {
+		if results[i] == nil {
+			s.books.insert(book)
+			s.reindex(book.ID)
+		}
+	}
+	for _, event := range added {
+		s.insertEvent(event)
+	}
+
+	return results, nil
+}
+
+// GetBook returns a models.Book for a given ID.
+// It returns an error if the Book is not found
+func (s *Store) GetBook(ctx context.Context, ID string) (*models.Book, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var book models.Book
+	if !s.books.get(ID, &book) {
+		return nil, mongo.ErrBookNotFound
+	}
+	updateAverages(&book)
+
+	return &book, nil
+}
+
+// GetBooks returns the existing []models.Book that match the filters of the query, sorted as requested by the query.
+// The page starts at the cursor if one is provided, and at the offset otherwise.
+func (s *Store) GetBooks(ctx context.Context, q *query.Query, cursor *pagination.Cursor, offset, limit int) ([]models.Book, int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	page, totalCount := s.books.page(nil, q, cursor, offset, limit)
+
+	var books []models.Book
+	for _, document := range page {
+		var book models.Book
+		fromDocument(document, &book)
+		updateAverages(&book)
+		books = append(books, book)
+	}
+
+	return books, totalCount, nil
+}
+
+// UpdateBook replaces the title, authors and metadata of an existing Book, as long as it is still at the revision of book,
+// and stores a book-updated event in the outbox. The revision of the Book is incremented.
+// It returns an error if the Book is not found, if it has been changed since that revision, or if another Book has
+// the same ISBN
+func (s *Store) UpdateBook(ctx context.Context, ID string, book *models.Book) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.hasISBN(ID, book.ISBN) {
+		return mongo.ErrDuplicateISBN
+	}
+
+	event, err := events.NewBookUpdated(book)
+	if err != nil {
+		return errors.Wrap(err, "unexpected error when updating a book")
+	}
+
+	if err := s.checkBookRevision(ID, book.Revision); err != nil {
+		return err
+	}
+
+	// Optional fields that are empty are removed, as they are omitted when a book is inserted
+	updated := toDocument(book)
+	sets := bson.M{"title": book.Title, "author": book.Author, "revision": book.Revision + 1}
+	for _, field := range []string{"synopsis", "isbn", "publisher", "publication_year", "page_count", "language", "edition", "genres", "authors"} {
+		if value, ok := updated[field]; ok {
+			sets[field] = value
+			continue
+		}
+		s.books.unset(ID, field)
+	}
+	s.books.set(ID, sets)
+	s.reindex(ID)
+	s.insertEvent(event)
+
+	return nil
+}
+
+// PatchBook applies a JSON merge patch to an existing Book, as long as it is still at the given revision, and stores
+// a book-updated event in the outbox. Fields with a nil value in the patch are removed from the Book, and the revision
+// of the Book is incremented.
+// It returns an error if the Book is not found, if it has been changed since that revision, or if the patch gives
+// the Book the ISBN of another Book
+func (s *Store) PatchBook(ctx context.Context, ID string, revision int, patch map[string]interface{}) error {
+	if len(patch) == 0 {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.checkBookRevision(ID, revision); err != nil {
+		return err
+	}
+
+	var book models.Book
+	s.books.get(ID, &book)
+
+	patched, err := book.ApplyMergePatch(patch)
+	if err != nil {
+		return err
+	}
+
+	if s.hasISBN(ID, patched.ISBN) {
+		return mongo.ErrDuplicateISBN
+	}
+
+	event, err := events.NewBookUpdated(patched)
+	if err != nil {
+		return errors.Wrap(err, "unexpected error when patching a book")
+	}
+
+	// The values of the patch are stored as they are, as they would be by MongoDB
+	sets := bson.M{"revision": revision + 1}
+	for field, value := range patch {
+		if value == nil {
+			s.books.unset(ID, field)
+			continue
+		}
+		sets[field] = value
+	}
+	s.books.set(ID, sets)
+	s.reindex(ID)
+	s.insertEvent(event)
+
+	return nil
+}
+
+// DeleteBook removes a Book, as long as it is still at the given revision.
+// If cascadeReviews is true, the reviews of the Book are removed as well. Otherwise, a Book with reviews is not removed.
+// A book-deleted event, together with a review-deleted event for every review removed with the Book, is stored in the outbox.
+// It returns an error if the Book is not found, if it has been changed since that revision, or if it has reviews and
+// cascadeReviews is false
+func (s *Store) DeleteBook(ctx context.Context, ID string, revision int, cascadeReviews bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	bookReviews := reviewsOf(ID)
+	if !cascadeReviews && s.reviews.count(bookReviews) > 0 {
+		return mongo.ErrBookHasReviews
+	}
+
+	if err := s.checkBookRevision(ID, revision); err != nil {
+		return err
+	}
+
+	var deleted []*events.Event
+	for _, document := range s.reviews.find(bookReviews) {
+		var review models.Review
+		fromDocument(document, &review)
+		event, err := events.NewReviewDeleted(&review)
+		if err != nil {
+			return errors.Wrap(err, "unexpected error when deleting a book")
+		}
+		deleted = append(deleted, event)
+	}
+	event, err := events.NewBookDeleted(ID)
+	if err != nil {
+		return errors.Wrap(err, "unexpected error when deleting a book")
+	}
+	deleted = append(deleted, event)
+
+	s.books.remove(ID)
+	s.reindex(ID)
+	for _, document := range s.reviews.find(bookReviews) {
+		s.reviews.remove(document["_id"].(string))
+	}
+	for _, event := range deleted {
+		s.insertEvent(event)
+	}
+
+	return nil
+}
+
+// ExportBooks calls export with every book, in the order of their IDs. Each book is read separately, so that the
+// store is not locked while the books are exported.
+// If reviews is not nil, each book is exported with its reviews that match the filters of the reviews query.
+// It stops at the first error returned by export, and returns it.
+func (s *Store) ExportBooks(ctx context.Context, reviews *query.Query, export func(book *models.Book, reviews []models.Review) error) error {
+	s.mutex.RLock()
+	var ids []string
+	for id := range s.books.documents {
+		ids = append(ids, id)
+	}
+	s.mutex.RUnlock()
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		book, bookReviews, ok := s.exportedBook(id, reviews)
+		if !ok {
+			continue
+		}
+
+		if err := export(book, bookReviews); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exportedBook returns a book and, if reviews is not nil, its reviews that match the filters of the reviews query.
+// It returns false if the book has been removed.
+func (s *Store) exportedBook(id string, reviews *query.Query) (*models.Book, []models.Review, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var book models.Book
+	if !s.books.get(id, &book) {
+		return nil, nil, false
+	}
+	updateAverages(&book)
+
+	if reviews == nil {
+		return &book, nil, true
+	}
+
+	bookReviews := []models.Review{}
+	found := s.reviews.find(func(document bson.M) bool {
+		return reviewsOf(id)(document) && matchesQuery(document, reviews)
+	})
+	sortDocuments(found, []query.Sort{{Key: "_id"}}, false)
+	for _, document := range found {
+		var review models.Review
+		fromDocument(document, &review)
+		bookReviews = append(bookReviews, review)
+	}
+
+	return &book, bookReviews, true
+}
+
+// checkBookRevision checks that a book is still at the revision that was read.
+// It returns ErrBookNotFound if the book has been removed, and ErrBookConflict if it has been changed.
+func (s *Store) checkBookRevision(bookID string, bookRevision int) error {
+	document, ok := s.books.documents[bookID]
+	if !ok {
+		return mongo.ErrBookNotFound
+	}
+	if revision(document) != bookRevision {
+		return mongo.ErrBookConflict
+	}
+	return nil
+}
+
+// hasISBN returns true if a book other than the one with the given ID already has the ISBN
+func (s *Store) hasISBN(bookID, isbn string) bool {
+	if isbn == "" {
+		return false
+	}
+
+	return s.books.count(func(document bson.M) bool {
+		return document["isbn"] == isbn && document["_id"] != bookID
+	}) > 0
+}
+
+// reviewsOf matches the reviews of a book
+func reviewsOf(bookID string) func(document bson.M) bool {
+	return func(document bson.M) bool {
+		return document["book_id"] == bookID
+	}
+}
+
+// updateAverages calculates the average rating of books, as it is not stored
+func updateAverages(books ...*models.Book) {
+	for _, book := range books {
+		if book.Ratings != nil {
+			book.Ratings.UpdateAverage()
+		}
+	}
+}
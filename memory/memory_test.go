@@ -0,0 +1,55 @@
+package memory
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/interfaces"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/storetest"
+	. "github.com/smartystreets/goconvey/convey"
+	"sync"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) interfaces.DataStore {
+		return New()
+	})
+}
+
+func TestStoreConcurrency(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given a book in the store", t, func() {
+		store := New()
+		book := models.NewBook()
+		book.Title = "Kindred"
+		book.Author = "Octavia E. Butler"
+		So(store.AddBook(ctx, book), ShouldBeNil)
+
+		Convey("When approved reviews are added to it concurrently", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					review := models.NewReview(book.ID)
+					review.State = models.ReviewApproved
+					review.Rating = 4
+					store.AddReview(ctx, review)
+				}()
+			}
+			wg.Wait()
+
+			Convey("Then every rating is counted in the rating summary of the book", func() {
+				stored, err := store.GetBook(ctx, book.ID)
+				So(err, ShouldBeNil)
+				So(stored.Ratings.Count, ShouldEqual, 50)
+				So(stored.Ratings.Histogram["4"], ShouldEqual, 50)
+
+				_, totalCount, err := store.GetReviews(ctx, book.ID, nil, nil, 0, 0)
+				So(err, ShouldBeNil)
+				So(totalCount, ShouldEqual, 50)
+			})
+		})
+	})
+}
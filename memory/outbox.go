@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/events"
+	"github.com/cadmiumcat/books-api/query"
+)
+
+// insertEvent numbers an event after the events written before it, as the mongo package does, and stores it in the outbox
+func (s *Store) insertEvent(event *events.Event) {
+	s.outboxSequence++
+	event.Sequence = s.outboxSequence
+	s.outbox.insert(event)
+}
+
+// GetPendingEvents returns the oldest events in the outbox that have not been published yet, in the order in which
+// they were written. All of them are returned if limit is 0.
+func (s *Store) GetPendingEvents(ctx context.Context, limit int) ([]events.Event, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	found := s.outbox.find(nil)
+	sortDocuments(found, []query.Sort{{Key: "sequence"}}, false)
+	if limit > 0 && len(found) > limit {
+		found = found[:limit]
+	}
+
+	pending := []events.Event{}
+	for _, document := range found {
+		var event events.Event
+		fromDocument(document, &event)
+		pending = append(pending, event)
+	}
+
+	return pending, nil
+}
+
+// DeletePublishedEvent removes an event from the outbox once it has been published.
+func (s *Store) DeletePublishedEvent(ctx context.Context, eventID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.outbox.remove(eventID)
+
+	return nil
+}
+
+// CountPendingEvents returns the number of events in the outbox that have not been published yet.
+func (s *Store) CountPendingEvents(ctx context.Context) (int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.outbox.count(nil), nil
+}
@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/mongo"
+	"github.com/cadmiumcat/books-api/query"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// AddReservation adds a Reservation for a Book.
+// It returns an error if the Book already has an active reservation
+func (s *Store) AddReservation(ctx context.Context, reservation *models.Reservation) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.books.documents[reservation.BookID]; !ok {
+		return mongo.ErrBookNotFound
+	}
+
+	active := s.reservations.count(func(document bson.M) bool {
+		state := document["state"]
+		return document["book_id"] == reservation.BookID && (state == models.ReservationReserved || state == models.ReservationCheckedOut)
+	})
+	if active > 0 {
+		return mongo.ErrBookUnavailable
+	}
+
+	if !s.reservations.insert(reservation) {
+		return errors.Wrap(errDuplicateID, "unexpected error when adding a reservation")
+	}
+
+	return nil
+}
+
+// GetReservation returns a models.Reservation for a given reservationID.
+// It returns an error if the reservation is not found.
+func (s *Store) GetReservation(ctx context.Context, reservationID string) (*models.Reservation, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var reservation models.Reservation
+	if !s.reservations.get(reservationID, &reservation) {
+		return nil, mongo.ErrReservationNotFound
+	}
+
+	return &reservation, nil
+}
+
+// GetReservations returns the reservations of a Book, oldest first.
+func (s *Store) GetReservations(ctx context.Context, bookID string, offset, limit int) ([]models.Reservation, int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	found := s.reservations.find(func(document bson.M) bool {
+		return document["book_id"] == bookID
+	})
+	totalCount := len(found)
+
+	reservations := []models.Reservation{}
+	if limit <= 0 {
+		return reservations, totalCount, nil
+	}
+
+	sortDocuments(found, []query.Sort{{Key: "reserved_at"}}, false)
+	if offset > len(found) {
+		offset = len(found)
+	}
+	found = found[offset:]
+	if len(found) > limit {
+		found = found[:limit]
+	}
+
+	for _, document := range found {
+		var reservation models.Reservation
+		fromDocument(document, &reservation)
+		reservations = append(reservations, reservation)
+	}
+
+	return reservations, totalCount, nil
+}
+
+// UpdateReservation stores the new state of a Reservation, as long as it has not changed from previousState.
+// It returns an error if the Reservation is not found in previousState
+func (s *Store) UpdateReservation(ctx context.Context, reservation *models.Reservation, previousState string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	document, ok := s.reservations.documents[reservation.ID]
+	if !ok || document["state"] != previousState {
+		return mongo.ErrReservationConflict
+	}
+	s.reservations.replace(reservation)
+
+	return nil
+}
+
+// DeleteReservation removes a Reservation that has not been checked out yet.
+// It returns an error if the Reservation is not found in the reserved state
+func (s *Store) DeleteReservation(ctx context.Context, reservationID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	document, ok := s.reservations.documents[reservationID]
+	if !ok || document["state"] != models.ReservationReserved {
+		return mongo.ErrReservationConflict
+	}
+	s.reservations.remove(reservationID)
+
+	return nil
+}
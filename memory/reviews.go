@@ -0,0 +1,219 @@
+package memory
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/events"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/mongo"
+	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/cadmiumcat/books-api/query"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+const ratingSummaryField = "rating_summary"
+
+// GetReview returns a models.Review for a given reviewID.
+// It returns an error if the review is not found.
+func (s *Store) GetReview(ctx context.Context, reviewID string) (*models.Review, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var review models.Review
+	if !s.reviews.get(reviewID, &review) {
+		return nil, mongo.ErrReviewNotFound
+	}
+
+	return &review, nil
+}
+
+// GetReviews returns the reviews of a book that match the filters of the query, sorted as requested by the query.
+// The page starts at the cursor if one is provided, and at the offset otherwise.
+func (s *Store) GetReviews(ctx context.Context, bookID string, q *query.Query, cursor *pagination.Cursor, offset, limit int) ([]models.Review, int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	page, totalCount := s.reviews.page(reviewsOf(bookID), q, cursor, offset, limit)
+
+	var reviews []models.Review
+	for _, document := range page {
+		var review models.Review
+		fromDocument(document, &review)
+		reviews = append(reviews, review)
+	}
+
+	return reviews, totalCount, nil
+}
+
+// AddReview adds a Review to a Book, and stores a review-added event in the outbox.
+// The rating of an approved Review is counted in the rating summary of the Book.
+// It returns an error if the Book is not found
+func (s *Store) AddReview(ctx context.Context, review *models.Review) error {
+	event, err := events.NewReviewAdded(review)
+	if err != nil {
+		return errors.Wrap(err, "unexpected error when adding a review")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.reviews.documents[review.ID]; ok {
+		return errors.Wrap(errDuplicateID, "unexpected error when adding a review")
+	}
+	if _, ok := s.books.documents[review.BookID]; !ok {
+		return mongo.ErrBookNotFound
+	}
+
+	s.reviews.insert(review)
+	s.insertEvent(event)
+	s.countRating(review.BookID, models.NoRating, review.CountedRating())
+
+	return nil
+}
+
+// UpdateReview replaces the message, user and rating of a Review, as long as it is still as previous was read, and
+// stores a review-updated event in the outbox. A Review without a rating loses its rating. An updated review goes back
+// to pending, and has to be approved again by a moderator: its rating stops counting in the rating summary of the Book.
+// The revision of the Review is incremented, and the Review is returned as it is after the update.
+// It returns an error if the review is not found, if it has been changed by another request, or if the rating summary
+// of its Book cannot be updated
+func (s *Store) UpdateReview(ctx context.Context, review *models.Review, previous *models.Review) (*models.Review, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.checkCountedRating(previous); err != nil {
+		return nil, err
+	}
+
+	var updated models.Review
+	s.reviews.get(previous.ID, &updated)
+	updated.Message = review.Message
+	updated.User = review.User
+	updated.Rating = review.Rating
+	updated.State = models.ReviewPending
+	updated.LastUpdated = time.Now().UTC()
+	updated.Revision++
+
+	// The event describes the whole review after the update has been applied
+	event, err := events.NewReviewUpdated(&updated)
+	if err != nil {
+		return nil, errors.Wrap(err, "unexpected error when updating a review")
+	}
+
+	if !s.canCountRating(updated.BookID, previous.CountedRating(), updated.CountedRating()) {
+		return nil, mongo.ErrReviewConflict
+	}
+
+	s.reviews.replace(&updated)
+	s.insertEvent(event)
+	s.countRating(updated.BookID, previous.CountedRating(), updated.CountedRating())
+
+	return &updated, nil
+}
+
+// UpdateReviewState stores the new moderation state of a Review, as long as it has not changed from previousState,
+// and stores a review-moderated event in the outbox. The rating of the Review starts or stops counting in the rating
+// summary of the Book when the Review is approved or stops being approved.
+// It returns an error if the Review is not found in previousState
+func (s *Store) UpdateReviewState(ctx context.Context, review *models.Review, previousState string) error {
+	previous := *review
+	previous.State = previousState
+
+	event, err := events.NewReviewModerated(review)
+	if err != nil {
+		return errors.Wrap(err, "unexpected error when moderating a review")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.checkCountedRating(&previous); err != nil {
+		return err
+	}
+	if !s.canCountRating(review.BookID, previous.CountedRating(), review.CountedRating()) {
+		return mongo.ErrReviewConflict
+	}
+
+	s.reviews.set(review.ID, bson.M{"state": review.State, "last_updated": review.LastUpdated, "revision": review.Revision + 1})
+	s.insertEvent(event)
+	s.countRating(review.BookID, previous.CountedRating(), review.CountedRating())
+
+	return nil
+}
+
+// DeleteReview removes a Review, and stores a review-deleted event in the outbox.
+// The rating of an approved Review stops counting in the rating summary of the Book.
+// It returns an error if the review is not found, or if it has been changed by another request since it was read
+func (s *Store) DeleteReview(ctx context.Context, review *models.Review) error {
+	event, err := events.NewReviewDeleted(review)
+	if err != nil {
+		return errors.Wrap(err, "unexpected error when deleting a review")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.checkCountedRating(review); err != nil {
+		return err
+	}
+	if !s.canCountRating(review.BookID, review.CountedRating(), models.NoRating) {
+		return mongo.ErrReviewConflict
+	}
+
+	s.reviews.remove(review.ID)
+	s.insertEvent(event)
+	s.countRating(review.BookID, review.CountedRating(), models.NoRating)
+
+	return nil
+}
+
+// checkCountedRating checks that the revision, and so the rating and moderation state, of a review are still the ones that were read,
+// so that its rating is not counted twice in the summary of its book when the review is modified concurrently.
+// It returns ErrReviewNotFound if the review has been removed, and ErrReviewConflict if it has been changed.
+func (s *Store) checkCountedRating(review *models.Review) error {
+	document, ok := s.reviews.documents[review.ID]
+	if !ok {
+		return mongo.ErrReviewNotFound
+	}
+
+	rating, hasRating := document["rating"]
+	if revision(document) != review.Revision || document["state"] != review.State {
+		return mongo.ErrReviewConflict
+	}
+	if review.Rating == models.NoRating && hasRating {
+		return mongo.ErrReviewConflict
+	}
+	if review.Rating != models.NoRating {
+		if n, ok := number(rating); !ok || int(n) != review.Rating {
+			return mongo.ErrReviewConflict
+		}
+	}
+
+	return nil
+}
+
+// canCountRating returns false if the rating summary of a book has to change, but the book does not exist
+func (s *Store) canCountRating(bookID string, previous, current int) bool {
+	if len(models.RatingChanges(previous, current)) == 0 {
+		return true
+	}
+	_, ok := s.books.documents[bookID]
+	return ok
+}
+
+// countRating updates the rating summary of a book when the rating of one of its reviews changes from previous to current.
+// As the book changes, its revision is incremented.
+func (s *Store) countRating(bookID string, previous, current int) {
+	changes := models.RatingChanges(previous, current)
+	if len(changes) == 0 {
+		return
+	}
+
+	increments := map[string]int{"revision": 1}
+	for field, change := range changes {
+		increments[ratingSummaryField+"."+field] = change
+	}
+	s.books.increment(bookID, increments)
+	s.reindex(bookID)
+}
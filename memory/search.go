@@ -0,0 +1,25 @@
+package memory
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/models"
+)
+
+// SearchBooks returns the books matching the query, from the most to the least relevant, and the total number of matches.
+// The relevance score is calculated by the index of the books, which ranks them like the MongoDB text index.
+func (s *Store) SearchBooks(ctx context.Context, query string, offset, limit int) ([]models.SearchResult, int, error) {
+	return s.index.SearchBooks(ctx, query, offset, limit)
+}
+
+// reindex updates the search index with the current version of a book, or removes the book from the index if it has
+// been deleted. It is called with the mutex locked, after every change to a book, so that the index is kept up to date
+// instead of being built for every search.
+func (s *Store) reindex(ID string) {
+	var book models.Book
+	if !s.books.get(ID, &book) {
+		s.index.Remove(ID)
+		return
+	}
+	updateAverages(&book)
+	s.index.Add(book)
+}
@@ -0,0 +1,224 @@
+package metrics
+
+import (
+	"context"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/cadmiumcat/books-api/interfaces"
+	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/cadmiumcat/books-api/query"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const namespace = "books_api"
+
+// unknownRoute labels the requests that did not match the template of a route
+const unknownRoute = "unknown"
+
+// healthStatuses are the states of a health check, each of which has a time series
+var healthStatuses = []string{healthcheck.StatusOK, healthcheck.StatusWarning, healthcheck.StatusCritical}
+
+// Metrics holds the Prometheus metrics of the service, in a registry of its own
+type Metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	storeDuration   *prometheus.HistogramVec
+	storeErrors     *prometheus.CounterVec
+	pageLimit       prometheus.Histogram
+	pageOffset      prometheus.Histogram
+	healthStatus    *prometheus.GaugeVec
+}
+
+// New returns the metrics of the service, together with the metrics of the Go runtime and of the process
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests, by method, route template and status code.",
+		}, []string{"method", "route", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to handle HTTP requests, by method and route template.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		storeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "datastore_operation_duration_seconds",
+			Help:      "Time taken by the operations of the data store, by operation.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+		}, []string{"operation"}),
+		storeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "datastore_operation_errors_total",
+			Help:      "Number of operations of the data store that returned an error, by operation and kind of error.",
+		}, []string{"operation", "error"}),
+		pageLimit: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "pagination_limit",
+			Help:      "Limits of the pages requested from the paginated endpoints.",
+			Buckets:   []float64{1, 5, 10, 20, 50, 100, 200, 500, 1000},
+		}),
+		pageOffset: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "pagination_offset",
+			Help:      "Offsets of the pages requested from the paginated endpoints.",
+			Buckets:   []float64{0, 10, 100, 1000, 10000, 100000},
+		}),
+		healthStatus: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "health_check_status",
+			Help:      "State of the health checks: 1 for the status that a check last reported, 0 for the others.",
+		}, []string{"check", "status"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.storeDuration,
+		m.storeErrors,
+		m.pageLimit,
+		m.pageOffset,
+		m.healthStatus,
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Instrument measures every request that the router serves: the requests that match one of its routes, with
+// Middleware, as well as the requests that match no route or none of its methods, whose route is unknown
+func (m *Metrics) Instrument(router *mux.Router) {
+	router.Use(m.Middleware)
+
+	notFound := router.NotFoundHandler
+	if notFound == nil {
+		notFound = http.NotFoundHandler()
+	}
+	router.NotFoundHandler = m.Middleware(notFound)
+
+	methodNotAllowed := router.MethodNotAllowedHandler
+	if methodNotAllowed == nil {
+		methodNotAllowed = http.HandlerFunc(methodNotAllowedHandler)
+	}
+	router.MethodNotAllowedHandler = m.Middleware(methodNotAllowed)
+}
+
+// methodNotAllowedHandler replies with a 405, as the router does when it has no handler of its own for it
+func methodNotAllowedHandler(writer http.ResponseWriter, request *http.Request) {
+	writer.WriteHeader(http.StatusMethodNotAllowed)
+}
+
+// Middleware is a router middleware that counts and times the requests by the template of the route they matched,
+// e.g. /books/{id}, so that the requests for different books share their time series.
+// A request is recorded even if its handler panics, e.g. to abort the response: with the status that was written,
+// or as a 500 if the handler had not written anything.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		route := unknownRoute
+		if current := mux.CurrentRoute(request); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		recorder := &statusRecorder{ResponseWriter: writer, status: http.StatusOK}
+		start := time.Now()
+		completed := false
+		defer func() {
+			status := recorder.status
+			if !completed && !recorder.written {
+				status = http.StatusInternalServerError
+			}
+			m.requestDuration.WithLabelValues(request.Method, route).Observe(time.Since(start).Seconds())
+			m.requests.WithLabelValues(request.Method, route, strconv.Itoa(status)).Inc()
+		}()
+
+		next.ServeHTTP(recorder, request)
+		completed = true
+	})
+}
+
+// Checker returns a health checker that records the status that the checker reports, every time it runs
+func (m *Metrics) Checker(name string, checker healthcheck.Checker) healthcheck.Checker {
+	return func(ctx context.Context, state *healthcheck.CheckState) error {
+		err := checker(ctx, state)
+
+		current := state.Status()
+		for _, status := range healthStatuses {
+			value := 0.0
+			if status == current {
+				value = 1
+			}
+			m.healthStatus.WithLabelValues(name, status).Set(value)
+		}
+
+		return err
+	}
+}
+
+// Paginator returns a paginator that records the limit and offset of every page that is requested
+func (m *Metrics) Paginator(paginator interfaces.Paginator) interfaces.Paginator {
+	return &instrumentedPaginator{paginator: paginator, metrics: m}
+}
+
+type instrumentedPaginator struct {
+	paginator interfaces.Paginator
+	metrics   *Metrics
+}
+
+func (p *instrumentedPaginator) GetPaginationValues(r *http.Request) (offset int, limit int, err error) {
+	offset, limit, err = p.paginator.GetPaginationValues(r)
+	if err == nil {
+		p.metrics.pageOffset.Observe(float64(offset))
+		p.metrics.pageLimit.Observe(float64(limit))
+	}
+	return offset, limit, err
+}
+
+func (p *instrumentedPaginator) GetCursor(r *http.Request, q *query.Query) (*pagination.Cursor, error) {
+	return p.paginator.GetCursor(r, q)
+}
+
+func (p *instrumentedPaginator) NewPage(r *http.Request, q *query.Query, cursor *pagination.Cursor, items interface{}, offset, limit, totalCount int) (pagination.Page, error) {
+	return p.paginator.NewPage(r, q, cursor, items, offset, limit, totalCount)
+}
+
+// statusRecorder records the status code of a response as it is written, and whether anything was written
+type statusRecorder struct {
+	http.ResponseWriter
+	status  int
+	written bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.written {
+		r.status = status
+		r.written = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(data []byte) (int, error) {
+	r.written = true
+	return r.ResponseWriter.Write(data)
+}
+
+// Unwrap returns the original response writer, so that http.ResponseController can reach it
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
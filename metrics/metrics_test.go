@@ -0,0 +1,214 @@
+package metrics
+
+import (
+	"context"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/cadmiumcat/books-api/interfaces/mock"
+	"github.com/cadmiumcat/books-api/memory"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/mongo"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given a router whose requests are measured", t, func() {
+		m := New()
+		router := mux.NewRouter()
+		router.Use(m.Middleware)
+		router.HandleFunc("/books/{id}", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+		router.HandleFunc("/books/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}).Methods("DELETE")
+
+		Convey("When books are requested by their id", func() {
+			for _, id := range []string{"1", "2", "3"} {
+				router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/books/"+id, nil))
+			}
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/books/1", nil))
+
+			Convey("Then the requests are counted by the template of their route, and by status code", func() {
+				So(testutil.ToFloat64(m.requests.WithLabelValues(http.MethodGet, "/books/{id}", "200")), ShouldEqual, 3)
+				So(testutil.ToFloat64(m.requests.WithLabelValues(http.MethodDelete, "/books/{id}", "500")), ShouldEqual, 1)
+				So(testutil.CollectAndCount(m.requests), ShouldEqual, 2)
+			})
+
+			Convey("And they are timed by the template of their route", func() {
+				So(testutil.CollectAndCount(m.requestDuration), ShouldEqual, 2)
+			})
+
+			Convey("And the metrics are served", func() {
+				response := httptest.NewRecorder()
+				m.Handler().ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+				body, _ := ioutil.ReadAll(response.Body)
+
+				So(response.Code, ShouldEqual, http.StatusOK)
+				So(string(body), ShouldContainSubstring, `books_api_http_requests_total{code="200",method="GET",route="/books/{id}"} 3`)
+				So(string(body), ShouldContainSubstring, "go_goroutines")
+			})
+		})
+	})
+}
+
+func TestInstrument(t *testing.T) {
+	t.Parallel()
+
+	// serve serves the request, and recovers from the panic of a handler as the HTTP server does
+	serve := func(router *mux.Router, request *http.Request) {
+		defer func() {
+			recover()
+		}()
+		router.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	Convey("Given an instrumented router", t, func() {
+		m := New()
+		router := mux.NewRouter()
+		m.Instrument(router)
+		router.HandleFunc("/books/{id}", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+		router.HandleFunc("/books/export", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			panic(http.ErrAbortHandler)
+		}).Methods("POST")
+		router.HandleFunc("/books/import", func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		}).Methods("POST")
+
+		Convey("When a request matches no route", func() {
+			serve(router, httptest.NewRequest(http.MethodGet, "/magazines", nil))
+
+			Convey("Then it is counted as a 404 of an unknown route", func() {
+				So(testutil.ToFloat64(m.requests.WithLabelValues(http.MethodGet, unknownRoute, "404")), ShouldEqual, 1)
+				So(testutil.CollectAndCount(m.requestDuration), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When a request matches a route, but none of its methods", func() {
+			serve(router, httptest.NewRequest(http.MethodDelete, "/books/1", nil))
+
+			Convey("Then it is counted as a 405 of an unknown route", func() {
+				So(testutil.ToFloat64(m.requests.WithLabelValues(http.MethodDelete, unknownRoute, "405")), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When a handler aborts its response after writing its status", func() {
+			serve(router, httptest.NewRequest(http.MethodPost, "/books/export", nil))
+
+			Convey("Then the request is counted with the status that was written", func() {
+				So(testutil.ToFloat64(m.requests.WithLabelValues(http.MethodPost, "/books/export", "200")), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When a handler aborts its response before writing anything", func() {
+			serve(router, httptest.NewRequest(http.MethodPost, "/books/import", nil))
+
+			Convey("Then the request is counted as a 500", func() {
+				So(testutil.ToFloat64(m.requests.WithLabelValues(http.MethodPost, "/books/import", "500")), ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given a data store whose operations are measured", t, func() {
+		m := New()
+		store := m.Store(memory.New())
+		ctx := context.Background()
+
+		Convey("When a book is added, and books that do not exist are read", func() {
+			So(store.AddBook(ctx, &models.Book{ID: "1", Title: "Kindred", Author: "Octavia E. Butler"}), ShouldBeNil)
+			_, err := store.GetBook(ctx, "2")
+			So(err, ShouldEqual, mongo.ErrBookNotFound)
+			_, err = store.GetBook(ctx, "3")
+			So(err, ShouldEqual, mongo.ErrBookNotFound)
+
+			Convey("Then every operation is timed", func() {
+				So(testutil.CollectAndCount(m.storeDuration), ShouldEqual, 2)
+			})
+
+			Convey("And the errors are counted by operation and kind", func() {
+				So(testutil.ToFloat64(m.storeErrors.WithLabelValues("GetBook", "not_found")), ShouldEqual, 2)
+				So(testutil.CollectAndCount(m.storeErrors), ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestErrorKind(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given the errors of the data store", t, func() {
+		Convey("Then they are classified by their kind", func() {
+			So(errorKind(&mongo.WriteError{Kind: mongo.ErrDuplicateKey, Err: errors.New("E11000")}), ShouldEqual, "duplicate_key")
+			So(errorKind(errors.Wrap(&mongo.WriteError{Kind: mongo.ErrUnavailable, Err: context.DeadlineExceeded}, "adding")), ShouldEqual, "unavailable")
+			So(errorKind(mongo.ErrReviewNotFound), ShouldEqual, "not_found")
+			So(errorKind(mongo.ErrBookConflict), ShouldEqual, "conflict")
+			So(errorKind(errors.New("connection reset")), ShouldEqual, "unexpected")
+		})
+	})
+}
+
+func TestPaginator(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given a paginator whose pages are measured", t, func() {
+		m := New()
+		paginator := m.Paginator(&mock.PaginatorMock{
+			GetPaginationValuesFunc: func(r *http.Request) (int, int, error) {
+				return 40, 20, nil
+			},
+		})
+
+		Convey("When the pagination values of a request are read", func() {
+			offset, limit, err := paginator.GetPaginationValues(httptest.NewRequest(http.MethodGet, "/books?offset=40&limit=20", nil))
+
+			Convey("Then they are returned, and their distribution is recorded", func() {
+				So(err, ShouldBeNil)
+				So(offset, ShouldEqual, 40)
+				So(limit, ShouldEqual, 20)
+				So(testutil.CollectAndCount(m.pageOffset), ShouldEqual, 1)
+				So(testutil.CollectAndCount(m.pageLimit), ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestChecker(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given a health check whose state is recorded", t, func() {
+		m := New()
+		status := healthcheck.StatusOK
+		checker := m.Checker("mongoDB", func(ctx context.Context, state *healthcheck.CheckState) error {
+			return state.Update(status, "", 0)
+		})
+		state := healthcheck.NewCheckState("mongoDB")
+
+		Convey("When the check runs", func() {
+			So(checker(context.Background(), state), ShouldBeNil)
+
+			Convey("Then only its status is set", func() {
+				So(testutil.ToFloat64(m.healthStatus.WithLabelValues("mongoDB", healthcheck.StatusOK)), ShouldEqual, 1)
+				So(testutil.ToFloat64(m.healthStatus.WithLabelValues("mongoDB", healthcheck.StatusCritical)), ShouldEqual, 0)
+			})
+
+			Convey("And when it runs again with another status, the status changes", func() {
+				status = healthcheck.StatusCritical
+				So(checker(context.Background(), state), ShouldBeNil)
+
+				So(testutil.ToFloat64(m.healthStatus.WithLabelValues("mongoDB", healthcheck.StatusOK)), ShouldEqual, 0)
+				So(testutil.ToFloat64(m.healthStatus.WithLabelValues("mongoDB", healthcheck.StatusCritical)), ShouldEqual, 1)
+			})
+		})
+	})
+}
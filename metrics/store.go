@@ -0,0 +1,232 @@
+package metrics
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/config"
+	"github.com/cadmiumcat/books-api/events"
+	"github.com/cadmiumcat/books-api/interfaces"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/mongo"
+	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/cadmiumcat/books-api/query"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// notFoundErrors are the errors of the data store for a resource that does not exist
+var notFoundErrors = []error{mongo.ErrBookNotFound, mongo.ErrReviewNotFound, mongo.ErrAuthorNotFound, mongo.ErrReservationNotFound}
+
+// conflictErrors are the errors of the data store for a change that conflicts with the state of a resource
+var conflictErrors = []error{
+	mongo.ErrBookConflict, mongo.ErrReviewConflict, mongo.ErrReservationConflict, mongo.ErrBookHasReviews,
+	mongo.ErrAuthorHasBooks, mongo.ErrBookUnavailable, mongo.ErrDuplicateISBN,
+}
+
+// Store returns a data store that times every operation of the store, and counts the operations that fail by the
+// kind of their error. The store is not initialised or closed by the metrics.
+func (m *Metrics) Store(store interfaces.Store) interfaces.Store {
+	return &instrumentedStore{store: store, metrics: m}
+}
+
+type instrumentedStore struct {
+	store   interfaces.Store
+	metrics *Metrics
+}
+
+// observe records the duration of an operation that started at the given time, and its error if any.
+// It is deferred with a pointer to the error that the operation returns.
+func (s *instrumentedStore) observe(operation string, start time.Time, err *error) {
+	s.metrics.storeDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if *err != nil {
+		s.metrics.storeErrors.WithLabelValues(operation, errorKind(*err)).Inc()
+	}
+}
+
+// errorKind classifies an error of the data store, so that the errors can be counted without labelling them with
+// their messages
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, mongo.ErrDuplicateKey):
+		return "duplicate_key"
+	case errors.Is(err, mongo.ErrUnavailable), errors.Is(err, context.DeadlineExceeded):
+		return "unavailable"
+	case isAny(err, notFoundErrors):
+		return "not_found"
+	case isAny(err, conflictErrors):
+		return "conflict"
+	default:
+		return "unexpected"
+	}
+}
+
+func isAny(err error, targets []error) bool {
+	for _, target := range targets {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *instrumentedStore) Init(cfg config.MongoConfig) error {
+	return s.store.Init(cfg)
+}
+
+func (s *instrumentedStore) Close(ctx context.Context) error {
+	return s.store.Close(ctx)
+}
+
+func (s *instrumentedStore) AddBook(ctx context.Context, book *models.Book) (err error) {
+	defer s.observe("AddBook", time.Now(), &err)
+	return s.store.AddBook(ctx, book)
+}
+
+func (s *instrumentedStore) AddBooks(ctx context.Context, books []*models.Book) (bookErrors []error, err error) {
+	defer s.observe("AddBooks", time.Now(), &err)
+	return s.store.AddBooks(ctx, books)
+}
+
+func (s *instrumentedStore) GetBook(ctx context.Context, id string) (book *models.Book, err error) {
+	defer s.observe("GetBook", time.Now(), &err)
+	return s.store.GetBook(ctx, id)
+}
+
+func (s *instrumentedStore) GetBooks(ctx context.Context, q *query.Query, cursor *pagination.Cursor, offset, limit int) (books []models.Book, totalCount int, err error) {
+	defer s.observe("GetBooks", time.Now(), &err)
+	return s.store.GetBooks(ctx, q, cursor, offset, limit)
+}
+
+func (s *instrumentedStore) UpdateBook(ctx context.Context, id string, book *models.Book) (err error) {
+	defer s.observe("UpdateBook", time.Now(), &err)
+	return s.store.UpdateBook(ctx, id, book)
+}
+
+func (s *instrumentedStore) PatchBook(ctx context.Context, id string, revision int, patch map[string]interface{}) (err error) {
+	defer s.observe("PatchBook", time.Now(), &err)
+	return s.store.PatchBook(ctx, id, revision, patch)
+}
+
+func (s *instrumentedStore) DeleteBook(ctx context.Context, id string, revision int, cascadeReviews bool) (err error) {
+	defer s.observe("DeleteBook", time.Now(), &err)
+	return s.store.DeleteBook(ctx, id, revision, cascadeReviews)
+}
+
+func (s *instrumentedStore) ExportBooks(ctx context.Context, reviews *query.Query, export func(book *models.Book, reviews []models.Review) error) (err error) {
+	defer s.observe("ExportBooks", time.Now(), &err)
+	return s.store.ExportBooks(ctx, reviews, export)
+}
+
+func (s *instrumentedStore) AddAuthor(ctx context.Context, author *models.Author) (err error) {
+	defer s.observe("AddAuthor", time.Now(), &err)
+	return s.store.AddAuthor(ctx, author)
+}
+
+func (s *instrumentedStore) GetAuthor(ctx context.Context, id string) (author *models.Author, err error) {
+	defer s.observe("GetAuthor", time.Now(), &err)
+	return s.store.GetAuthor(ctx, id)
+}
+
+func (s *instrumentedStore) GetAuthors(ctx context.Context, q *query.Query, cursor *pagination.Cursor, offset, limit int) (authors []models.Author, totalCount int, err error) {
+	defer s.observe("GetAuthors", time.Now(), &err)
+	return s.store.GetAuthors(ctx, q, cursor, offset, limit)
+}
+
+func (s *instrumentedStore) UpdateAuthor(ctx context.Context, id string, author *models.Author) (err error) {
+	defer s.observe("UpdateAuthor", time.Now(), &err)
+	return s.store.UpdateAuthor(ctx, id, author)
+}
+
+func (s *instrumentedStore) DeleteAuthor(ctx context.Context, id string) (err error) {
+	defer s.observe("DeleteAuthor", time.Now(), &err)
+	return s.store.DeleteAuthor(ctx, id)
+}
+
+func (s *instrumentedStore) GetReview(ctx context.Context, reviewID string) (review *models.Review, err error) {
+	defer s.observe("GetReview", time.Now(), &err)
+	return s.store.GetReview(ctx, reviewID)
+}
+
+func (s *instrumentedStore) GetReviews(ctx context.Context, bookID string, q *query.Query, cursor *pagination.Cursor, offset, limit int) (reviews []models.Review, totalCount int, err error) {
+	defer s.observe("GetReviews", time.Now(), &err)
+	return s.store.GetReviews(ctx, bookID, q, cursor, offset, limit)
+}
+
+func (s *instrumentedStore) AddReview(ctx context.Context, review *models.Review) (err error) {
+	defer s.observe("AddReview", time.Now(), &err)
+	return s.store.AddReview(ctx, review)
+}
+
+func (s *instrumentedStore) UpdateReview(ctx context.Context, review *models.Review, previous *models.Review) (updated *models.Review, err error) {
+	defer s.observe("UpdateReview", time.Now(), &err)
+	return s.store.UpdateReview(ctx, review, previous)
+}
+
+func (s *instrumentedStore) UpdateReviewState(ctx context.Context, review *models.Review, previousState string) (err error) {
+	defer s.observe("UpdateReviewState", time.Now(), &err)
+	return s.store.UpdateReviewState(ctx, review, previousState)
+}
+
+func (s *instrumentedStore) DeleteReview(ctx context.Context, review *models.Review) (err error) {
+	defer s.observe("DeleteReview", time.Now(), &err)
+	return s.store.DeleteReview(ctx, review)
+}
+
+func (s *instrumentedStore) AddReservation(ctx context.Context, reservation *models.Reservation) (err error) {
+	defer s.observe("AddReservation", time.Now(), &err)
+	return s.store.AddReservation(ctx, reservation)
+}
+
+func (s *instrumentedStore) GetReservation(ctx context.Context, reservationID string) (reservation *models.Reservation, err error) {
+	defer s.observe("GetReservation", time.Now(), &err)
+	return s.store.GetReservation(ctx, reservationID)
+}
+
+func (s *instrumentedStore) GetReservations(ctx context.Context, bookID string, offset, limit int) (reservations []models.Reservation, totalCount int, err error) {
+	defer s.observe("GetReservations", time.Now(), &err)
+	return s.store.GetReservations(ctx, bookID, offset, limit)
+}
+
+func (s *instrumentedStore) UpdateReservation(ctx context.Context, reservation *models.Reservation, previousState string) (err error) {
+	defer s.observe("UpdateReservation", time.Now(), &err)
+	return s.store.UpdateReservation(ctx, reservation, previousState)
+}
+
+func (s *instrumentedStore) DeleteReservation(ctx context.Context, reservationID string) (err error) {
+	defer s.observe("DeleteReservation", time.Now(), &err)
+	return s.store.DeleteReservation(ctx, reservationID)
+}
+
+func (s *instrumentedStore) SearchBooks(ctx context.Context, query string, offset, limit int) (results []models.SearchResult, totalCount int, err error) {
+	defer s.observe("SearchBooks", time.Now(), &err)
+	return s.store.SearchBooks(ctx, query, offset, limit)
+}
+
+func (s *instrumentedStore) GetPendingEvents(ctx context.Context, limit int) (pending []events.Event, err error) {
+	defer s.observe("GetPendingEvents", time.Now(), &err)
+	return s.store.GetPendingEvents(ctx, limit)
+}
+
+func (s *instrumentedStore) DeletePublishedEvent(ctx context.Context, eventID string) (err error) {
+	defer s.observe("DeletePublishedEvent", time.Now(), &err)
+	return s.store.DeletePublishedEvent(ctx, eventID)
+}
+
+func (s *instrumentedStore) CountPendingEvents(ctx context.Context) (count int, err error) {
+	defer s.observe("CountPendingEvents", time.Now(), &err)
+	return s.store.CountPendingEvents(ctx)
+}
+
+func (s *instrumentedStore) ReserveIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) (existing *models.IdempotencyRecord, err error) {
+	defer s.observe("ReserveIdempotencyKey", time.Now(), &err)
+	return s.store.ReserveIdempotencyKey(ctx, record)
+}
+
+func (s *instrumentedStore) CompleteIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) (err error) {
+	defer s.observe("CompleteIdempotencyKey", time.Now(), &err)
+	return s.store.CompleteIdempotencyKey(ctx, record)
+}
+
+func (s *instrumentedStore) ReleaseIdempotencyKey(ctx context.Context, id string) (err error) {
+	defer s.observe("ReleaseIdempotencyKey", time.Now(), &err)
+	return s.store.ReleaseIdempotencyKey(ctx, id)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/cadmiumcat/books-api/migrations"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = `usage: books-api migrate <command>
+
+commands:
+  status          list the migrations, and whether they have been applied
+  up [version]    apply the pending migrations, up to and including version if given
+  down [version]  roll back the latest migration, or every migration newer than version if given`
+
+var errUsage = errors.New(migrateUsage)
+
+// migrate runs the migrate subcommand with the given arguments, writing its output to out
+func migrate(ctx context.Context, migrator *migrations.Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errUsage
+	}
+
+	version, err := migrationVersion(args[1:])
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "status":
+		if len(args) > 1 {
+			return errUsage
+		}
+		return printStatus(ctx, migrator, out)
+	case "up":
+		return migrator.Up(ctx, version)
+	case "down":
+		if len(args) == 1 {
+			version = migrations.OneStep
+		}
+		return migrator.Down(ctx, version)
+	default:
+		return errUsage
+	}
+}
+
+// migrationVersion parses the optional version argument, which is 0 if there is none
+func migrationVersion(args []string) (int, error) {
+	if len(args) == 0 {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(args[0])
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("invalid migration version %q\n%s", args[0], migrateUsage)
+	}
+	return version, nil
+}
+
+// printStatus writes a table of the migrations, and the time each one was applied
+func printStatus(ctx context.Context, migrator *migrations.Migrator, out io.Writer) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tAPPLIED AT\tDESCRIPTION")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.Applied {
+			appliedAt = status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, appliedAt, status.Description)
+	}
+	return w.Flush()
+}
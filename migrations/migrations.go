@@ -0,0 +1,320 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"github.com/ONSdigital/log.go/log"
+	"github.com/cadmiumcat/books-api/config"
+	"github.com/cadmiumcat/books-api/interfaces"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"os"
+	"sort"
+	"time"
+)
+
+// OneStep is the target of Down that rolls back the latest applied migration only
+const OneStep = -1
+
+var (
+	// ErrLocked is returned by a MigrationStore when another instance holds the migration lock
+	ErrLocked = errors.New("the migrations are locked by another instance")
+	// ErrIrreversible is returned when a migration that cannot be rolled back would have to be
+	ErrIrreversible = errors.New("the migration cannot be rolled back")
+	// ErrUnknownVersion is returned when the target version is not a registered migration
+	ErrUnknownVersion = errors.New("no migration has this version")
+)
+
+// Migration changes the indexes or the shape of the documents of a data store. Up applies the change, and Down,
+// if not nil, reverts it. Both must be safe to run again after a failure part way through.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context) error
+	Down        func(ctx context.Context) error
+}
+
+// Status is a registered migration, and whether it has been applied
+type Status struct {
+	Version     int
+	Description string
+	Applied     bool
+	AppliedAt   *time.Time
+}
+
+// Migrator applies and rolls back the migrations of a data store, in the order of their versions.
+// The applied migrations are recorded in the store, and a lock held in the store ensures that only
+// one instance of the service migrates it at a time.
+type Migrator struct {
+	store      interfaces.MigrationStore
+	migrations []Migration
+	cfg        config.MigrationsConfig
+	owner      string
+}
+
+// NewMigrator creates a new instance of Migrator.
+// It returns an error if the lock TTL is not positive, if a migration has no Up function, or if two migrations
+// have the same version.
+func NewMigrator(store interfaces.MigrationStore, migrations []Migration, cfg config.MigrationsConfig) (*Migrator, error) {
+	if cfg.LockTTL <= 0 {
+		return nil, errors.New("the migration lock TTL must be positive")
+	}
+
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+
+	for i, migration := range sorted {
+		if migration.Version <= 0 {
+			return nil, fmt.Errorf("migration %q must have a positive version", migration.Description)
+		}
+		if migration.Up == nil {
+			return nil, fmt.Errorf("migration %d has no up function", migration.Version)
+		}
+		if i > 0 && sorted[i-1].Version == migration.Version {
+			return nil, fmt.Errorf("migration %d is registered twice", migration.Version)
+		}
+	}
+
+	hostname, _ := os.Hostname()
+
+	return &Migrator{
+		store:      store,
+		migrations: sorted,
+		cfg:        cfg,
+		owner:      fmt.Sprintf("%s/%s", hostname, uuid.NewV4().String()),
+	}, nil
+}
+
+// Status returns every registered migration, in the order of their versions, with the time it was applied if it was
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Description: migration.Description}
+		if appliedMigration, ok := applied[migration.Version]; ok {
+			appliedAt := appliedMigration.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Pending returns the number of registered migrations that have not been applied
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, status := range statuses {
+		if !status.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// Up applies the migrations that have not been applied, up to and including the target version, or all of them
+// if the target is 0. Each migration is recorded as soon as it succeeds, so Up carries on from the first migration
+// that failed when it runs again.
+func (m *Migrator) Up(ctx context.Context, target int) error {
+	if target != 0 && m.find(target) == nil {
+		return ErrUnknownVersion
+	}
+
+	return m.locked(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if target != 0 && migration.Version > target {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+
+			logData := log.Data{"version": migration.Version, "description": migration.Description}
+			log.Event(ctx, "applying migration", log.INFO, logData)
+
+			if err := migration.Up(ctx); err != nil {
+				log.Event(ctx, "failed to apply migration", log.ERROR, log.Error(err), logData)
+				return errors.Wrapf(err, "failed to apply migration %d", migration.Version)
+			}
+
+			record := &models.AppliedMigration{
+				Version:     migration.Version,
+				Description: migration.Description,
+				AppliedAt:   time.Now().UTC(),
+			}
+			if err := m.store.AddAppliedMigration(ctx, record); err != nil {
+				log.Event(ctx, "failed to record an applied migration", log.ERROR, log.Error(err), logData)
+				return errors.Wrapf(err, "failed to record migration %d", migration.Version)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Down rolls back the applied migrations that are newer than the target version, newest first, or only the latest
+// applied migration if the target is OneStep. Nothing is rolled back if any of them is irreversible.
+func (m *Migrator) Down(ctx context.Context, target int) error {
+	if target > 0 && m.find(target) == nil {
+		return ErrUnknownVersion
+	}
+
+	return m.locked(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+
+		var rollback []Migration
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if migration.Version <= target {
+				break
+			}
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == nil {
+				log.Event(ctx, ErrIrreversible.Error(), log.ERROR, log.Data{"version": migration.Version, "description": migration.Description})
+				return errors.Wrapf(ErrIrreversible, "migration %d", migration.Version)
+			}
+			rollback = append(rollback, migration)
+			if target == OneStep {
+				break
+			}
+		}
+
+		for _, migration := range rollback {
+			logData := log.Data{"version": migration.Version, "description": migration.Description}
+			log.Event(ctx, "rolling back migration", log.INFO, logData)
+
+			if err := migration.Down(ctx); err != nil {
+				log.Event(ctx, "failed to roll back migration", log.ERROR, log.Error(err), logData)
+				return errors.Wrapf(err, "failed to roll back migration %d", migration.Version)
+			}
+
+			if err := m.store.DeleteAppliedMigration(ctx, migration.Version); err != nil {
+				log.Event(ctx, "failed to remove the record of a rolled back migration", log.ERROR, log.Error(err), logData)
+				return errors.Wrapf(err, "failed to remove the record of migration %d", migration.Version)
+			}
+		}
+
+		return nil
+	})
+}
+
+// applied returns the applied migrations by version. Applied migrations that are not registered, e.g. because they
+// were applied by a newer version of the service, are logged and otherwise ignored.
+func (m *Migrator) applied(ctx context.Context) (map[int]models.AppliedMigration, error) {
+	appliedMigrations, err := m.store.GetAppliedMigrations(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the applied migrations")
+	}
+
+	applied := make(map[int]models.AppliedMigration, len(appliedMigrations))
+	for _, appliedMigration := range appliedMigrations {
+		if m.find(appliedMigration.Version) == nil {
+			log.Event(ctx, "an applied migration is not registered", log.WARN, log.Data{
+				"version":     appliedMigration.Version,
+				"description": appliedMigration.Description,
+			})
+		}
+		applied[appliedMigration.Version] = appliedMigration
+	}
+
+	return applied, nil
+}
+
+// find returns the registered migration with the given version, or nil if there is none
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// locked runs migrate while holding the migration lock. The lock is waited for until LockTimeout, and is refreshed
+// while migrate runs so that it does not expire under a long migration. If this instance dies, the lock expires after
+// LockTTL and another instance can take it.
+func (m *Migrator) locked(ctx context.Context, migrate func() error) error {
+	if err := m.acquire(ctx); err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(m.cfg.LockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := m.store.AcquireMigrationLock(ctx, m.owner, m.cfg.LockTTL); err != nil {
+					log.Event(ctx, "failed to refresh the migration lock", log.WARN, log.Error(err), log.Data{"owner": m.owner})
+				}
+			}
+		}
+	}()
+
+	err := migrate()
+
+	close(stop)
+	<-done
+	if releaseErr := m.store.ReleaseMigrationLock(ctx, m.owner); releaseErr != nil {
+		log.Event(ctx, "failed to release the migration lock", log.WARN, log.Error(releaseErr), log.Data{"owner": m.owner})
+	}
+
+	return err
+}
+
+// acquire takes the migration lock, polling while another instance holds it, until LockTimeout has passed
+func (m *Migrator) acquire(ctx context.Context) error {
+	deadline := time.Now().Add(m.cfg.LockTimeout)
+	wait := m.cfg.LockTTL / 10
+
+	for {
+		err := m.store.AcquireMigrationLock(ctx, m.owner, m.cfg.LockTTL)
+		if err != ErrLocked {
+			if err != nil {
+				return errors.Wrap(err, "failed to acquire the migration lock")
+			}
+			return nil
+		}
+
+		if time.Now().Add(wait).After(deadline) {
+			log.Event(ctx, "timed out waiting for the migration lock", log.ERROR, log.Data{"owner": m.owner, "lock_timeout": m.cfg.LockTimeout.String()})
+			return ErrLocked
+		}
+
+		log.Event(ctx, "waiting for the migration lock held by another instance", log.INFO, log.Data{"owner": m.owner})
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
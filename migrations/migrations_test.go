@@ -0,0 +1,455 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/cadmiumcat/books-api/config"
+	"github.com/cadmiumcat/books-api/interfaces/mock"
+	"github.com/cadmiumcat/books-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	errMigration = errors.New("migration failed")
+
+	testConfig = config.MigrationsConfig{
+		LockTTL:     30 * time.Millisecond,
+		LockTimeout: 50 * time.Millisecond,
+	}
+)
+
+// memoryStore is a MigrationStore that records the applied migrations in memory, and the calls made to it
+type memoryStore struct {
+	mu      sync.Mutex
+	applied map[int]models.AppliedMigration
+	calls   []string
+}
+
+func newMemoryStore(versions ...int) (*memoryStore, *mock.MigrationStoreMock) {
+	store := &memoryStore{applied: make(map[int]models.AppliedMigration)}
+	for _, version := range versions {
+		store.applied[version] = models.AppliedMigration{Version: version, AppliedAt: time.Now()}
+	}
+
+	return store, &mock.MigrationStoreMock{
+		GetAppliedMigrationsFunc: func(ctx context.Context) ([]models.AppliedMigration, error) {
+			store.mu.Lock()
+			defer store.mu.Unlock()
+			applied := []models.AppliedMigration{}
+			for _, migration := range store.applied {
+				applied = append(applied, migration)
+			}
+			return applied, nil
+		},
+		AddAppliedMigrationFunc: func(ctx context.Context, migration *models.AppliedMigration) error {
+			store.record("add", migration.Version)
+			store.mu.Lock()
+			defer store.mu.Unlock()
+			store.applied[migration.Version] = *migration
+			return nil
+		},
+		DeleteAppliedMigrationFunc: func(ctx context.Context, version int) error {
+			store.record("delete", version)
+			store.mu.Lock()
+			defer store.mu.Unlock()
+			delete(store.applied, version)
+			return nil
+		},
+		AcquireMigrationLockFunc: func(ctx context.Context, owner string, ttl time.Duration) error {
+			return nil
+		},
+		ReleaseMigrationLockFunc: func(ctx context.Context, owner string) error {
+			return nil
+		},
+	}
+}
+
+func (s *memoryStore) record(call string, version int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, fmt.Sprintf("%s %d", call, version))
+}
+
+func (s *memoryStore) versions() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	versions := []int{}
+	for version := 1; version <= 9; version++ {
+		if _, ok := s.applied[version]; ok {
+			versions = append(versions, version)
+		}
+	}
+	return versions
+}
+
+// testMigrations returns migrations 1 to 3, where migration 2 is irreversible if irreversible is true.
+// The migrations that run are recorded in ran.
+func testMigrations(ran *[]string, irreversible bool) []Migration {
+	migration := func(version int, name string) Migration {
+		return Migration{
+			Version:     version,
+			Description: name,
+			Up: func(ctx context.Context) error {
+				*ran = append(*ran, "up "+name)
+				return nil
+			},
+			Down: func(ctx context.Context) error {
+				*ran = append(*ran, "down "+name)
+				return nil
+			},
+		}
+	}
+
+	migrations := []Migration{migration(3, "three"), migration(1, "one"), migration(2, "two")}
+	if irreversible {
+		migrations[2].Down = nil
+	}
+	return migrations
+}
+
+func TestNewMigrator(t *testing.T) {
+	_, store := newMemoryStore()
+	up := func(ctx context.Context) error { return nil }
+
+	Convey("Given migrations with the same version", t, func() {
+		migrations := []Migration{{Version: 1, Up: up}, {Version: 1, Up: up}}
+
+		Convey("Then the migrator is not created", func() {
+			migrator, err := NewMigrator(store, migrations, testConfig)
+			So(migrator, ShouldBeNil)
+			So(err.Error(), ShouldEqual, "migration 1 is registered twice")
+		})
+	})
+
+	Convey("Given a migration without an up function", t, func() {
+		migrations := []Migration{{Version: 1}}
+
+		Convey("Then the migrator is not created", func() {
+			migrator, err := NewMigrator(store, migrations, testConfig)
+			So(migrator, ShouldBeNil)
+			So(err.Error(), ShouldEqual, "migration 1 has no up function")
+		})
+	})
+
+	Convey("Given a migration without a positive version", t, func() {
+		migrations := []Migration{{Version: 0, Description: "zero", Up: up}}
+
+		Convey("Then the migrator is not created", func() {
+			migrator, err := NewMigrator(store, migrations, testConfig)
+			So(migrator, ShouldBeNil)
+			So(err.Error(), ShouldEqual, `migration "zero" must have a positive version`)
+		})
+	})
+
+	Convey("Given a lock TTL that is not positive", t, func() {
+		Convey("Then the migrator is not created", func() {
+			migrator, err := NewMigrator(store, []Migration{{Version: 1, Up: up}}, config.MigrationsConfig{})
+			So(migrator, ShouldBeNil)
+			So(err.Error(), ShouldEqual, "the migration lock TTL must be positive")
+		})
+	})
+}
+
+func TestStatus(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given a store where migration 1 and an unknown migration are applied", t, func() {
+		var ran []string
+		_, store := newMemoryStore(1, 7)
+		migrator, err := NewMigrator(store, testMigrations(&ran, false), testConfig)
+		So(err, ShouldBeNil)
+
+		Convey("When the status is read", func() {
+			statuses, err := migrator.Status(ctx)
+
+			Convey("Then the registered migrations are listed in order", func() {
+				So(err, ShouldBeNil)
+				So(statuses, ShouldHaveLength, 3)
+				So(statuses[0].Version, ShouldEqual, 1)
+				So(statuses[0].Applied, ShouldBeTrue)
+				So(statuses[0].AppliedAt, ShouldNotBeNil)
+				So(statuses[1].Version, ShouldEqual, 2)
+				So(statuses[1].Applied, ShouldBeFalse)
+				So(statuses[1].AppliedAt, ShouldBeNil)
+				So(statuses[2].Version, ShouldEqual, 3)
+				So(statuses[2].Applied, ShouldBeFalse)
+			})
+
+			Convey("And no migration is run", func() {
+				So(ran, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When the pending migrations are counted", func() {
+			pending, err := migrator.Pending(ctx)
+
+			Convey("Then the unapplied migrations are counted", func() {
+				So(err, ShouldBeNil)
+				So(pending, ShouldEqual, 2)
+			})
+		})
+	})
+
+	Convey("Given a store that fails", t, func() {
+		_, store := newMemoryStore()
+		store.GetAppliedMigrationsFunc = func(ctx context.Context) ([]models.AppliedMigration, error) {
+			return nil, errMigration
+		}
+		migrator, err := NewMigrator(store, testMigrations(&[]string{}, false), testConfig)
+		So(err, ShouldBeNil)
+
+		Convey("Then the status cannot be read", func() {
+			statuses, err := migrator.Status(ctx)
+			So(statuses, ShouldBeNil)
+			So(errors.Is(err, errMigration), ShouldBeTrue)
+		})
+	})
+}
+
+func TestUp(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given a store where migration 1 is applied", t, func() {
+		var ran []string
+		memory, store := newMemoryStore(1)
+		migrator, err := NewMigrator(store, testMigrations(&ran, false), testConfig)
+		So(err, ShouldBeNil)
+
+		Convey("When the migrations are applied", func() {
+			err := migrator.Up(ctx, 0)
+
+			Convey("Then the pending migrations are applied and recorded in order", func() {
+				So(err, ShouldBeNil)
+				So(ran, ShouldResemble, []string{"up two", "up three"})
+				So(memory.calls, ShouldResemble, []string{"add 2", "add 3"})
+				So(memory.versions(), ShouldResemble, []int{1, 2, 3})
+			})
+
+			Convey("And the lock is held by the migrator while they are applied", func() {
+				So(store.AcquireMigrationLockCalls(), ShouldHaveLength, 1)
+				So(store.AcquireMigrationLockCalls()[0].Owner, ShouldEqual, migrator.owner)
+				So(store.AcquireMigrationLockCalls()[0].TTL, ShouldEqual, testConfig.LockTTL)
+				So(store.ReleaseMigrationLockCalls(), ShouldHaveLength, 1)
+				So(store.ReleaseMigrationLockCalls()[0].Owner, ShouldEqual, migrator.owner)
+			})
+		})
+
+		Convey("When the migrations are applied up to a version", func() {
+			err := migrator.Up(ctx, 2)
+
+			Convey("Then the later migrations are not applied", func() {
+				So(err, ShouldBeNil)
+				So(ran, ShouldResemble, []string{"up two"})
+				So(memory.versions(), ShouldResemble, []int{1, 2})
+			})
+		})
+
+		Convey("When the migrations are applied up to an unknown version", func() {
+			err := migrator.Up(ctx, 4)
+
+			Convey("Then no migration is applied", func() {
+				So(err, ShouldEqual, ErrUnknownVersion)
+				So(ran, ShouldBeEmpty)
+				So(store.AcquireMigrationLockCalls(), ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given a migration that fails", t, func() {
+		var ran []string
+		memory, store := newMemoryStore()
+		migrations := testMigrations(&ran, false)
+		migrations[2].Up = func(ctx context.Context) error {
+			return errMigration
+		}
+		migrator, err := NewMigrator(store, migrations, testConfig)
+		So(err, ShouldBeNil)
+
+		Convey("When the migrations are applied", func() {
+			err := migrator.Up(ctx, 0)
+
+			Convey("Then the migrations before it are applied, and the later ones are not", func() {
+				So(errors.Is(err, errMigration), ShouldBeTrue)
+				So(ran, ShouldResemble, []string{"up one"})
+				So(memory.versions(), ShouldResemble, []int{1})
+			})
+
+			Convey("And the lock is released", func() {
+				So(store.ReleaseMigrationLockCalls(), ShouldHaveLength, 1)
+			})
+		})
+	})
+
+	Convey("Given a lock held by another instance", t, func() {
+		var ran []string
+		_, store := newMemoryStore()
+		store.AcquireMigrationLockFunc = func(ctx context.Context, owner string, ttl time.Duration) error {
+			return ErrLocked
+		}
+		migrator, err := NewMigrator(store, testMigrations(&ran, false), testConfig)
+		So(err, ShouldBeNil)
+
+		Convey("When the migrations are applied", func() {
+			err := migrator.Up(ctx, 0)
+
+			Convey("Then the lock is waited for until the lock timeout", func() {
+				So(err, ShouldEqual, ErrLocked)
+				So(len(store.AcquireMigrationLockCalls()), ShouldBeGreaterThan, 1)
+			})
+
+			Convey("And no migration is applied", func() {
+				So(ran, ShouldBeEmpty)
+				So(store.ReleaseMigrationLockCalls(), ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given a lock that is released by another instance", t, func() {
+		var ran []string
+		_, store := newMemoryStore()
+		attempts := 0
+		store.AcquireMigrationLockFunc = func(ctx context.Context, owner string, ttl time.Duration) error {
+			attempts++
+			if attempts == 1 {
+				return ErrLocked
+			}
+			return nil
+		}
+		migrator, err := NewMigrator(store, testMigrations(&ran, false), testConfig)
+		So(err, ShouldBeNil)
+
+		Convey("When the migrations are applied", func() {
+			err := migrator.Up(ctx, 0)
+
+			Convey("Then they are applied once the lock is taken", func() {
+				So(err, ShouldBeNil)
+				So(ran, ShouldResemble, []string{"up one", "up two", "up three"})
+			})
+		})
+	})
+
+	Convey("Given a migration that takes longer than the lock TTL", t, func() {
+		_, store := newMemoryStore()
+		migrations := []Migration{{
+			Version: 1,
+			Up: func(ctx context.Context) error {
+				time.Sleep(2 * testConfig.LockTTL)
+				return nil
+			},
+		}}
+		migrator, err := NewMigrator(store, migrations, testConfig)
+		So(err, ShouldBeNil)
+
+		Convey("When the migrations are applied", func() {
+			err := migrator.Up(ctx, 0)
+
+			Convey("Then the lock is refreshed while it runs", func() {
+				So(err, ShouldBeNil)
+				So(len(store.AcquireMigrationLockCalls()), ShouldBeGreaterThan, 1)
+			})
+		})
+	})
+}
+
+func TestDown(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given a store where every migration is applied", t, func() {
+		var ran []string
+		memory, store := newMemoryStore(1, 2, 3)
+		migrator, err := NewMigrator(store, testMigrations(&ran, false), testConfig)
+		So(err, ShouldBeNil)
+
+		Convey("When one step is rolled back", func() {
+			err := migrator.Down(ctx, OneStep)
+
+			Convey("Then only the latest migration is rolled back", func() {
+				So(err, ShouldBeNil)
+				So(ran, ShouldResemble, []string{"down three"})
+				So(memory.calls, ShouldResemble, []string{"delete 3"})
+				So(memory.versions(), ShouldResemble, []int{1, 2})
+			})
+
+			Convey("And the lock is held while it is rolled back", func() {
+				So(store.AcquireMigrationLockCalls(), ShouldHaveLength, 1)
+				So(store.ReleaseMigrationLockCalls(), ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When the migrations are rolled back to a version", func() {
+			err := migrator.Down(ctx, 1)
+
+			Convey("Then the later migrations are rolled back, newest first", func() {
+				So(err, ShouldBeNil)
+				So(ran, ShouldResemble, []string{"down three", "down two"})
+				So(memory.versions(), ShouldResemble, []int{1})
+			})
+		})
+
+		Convey("When every migration is rolled back", func() {
+			err := migrator.Down(ctx, 0)
+
+			Convey("Then no migration remains applied", func() {
+				So(err, ShouldBeNil)
+				So(ran, ShouldResemble, []string{"down three", "down two", "down one"})
+				So(memory.versions(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When the migrations are rolled back to an unknown version", func() {
+			err := migrator.Down(ctx, 4)
+
+			Convey("Then no migration is rolled back", func() {
+				So(err, ShouldEqual, ErrUnknownVersion)
+				So(ran, ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given an irreversible migration", t, func() {
+		var ran []string
+		memory, store := newMemoryStore(1, 2, 3)
+		migrator, err := NewMigrator(store, testMigrations(&ran, true), testConfig)
+		So(err, ShouldBeNil)
+
+		Convey("When the migrations are rolled back past it", func() {
+			err := migrator.Down(ctx, 1)
+
+			Convey("Then nothing is rolled back", func() {
+				So(errors.Is(err, ErrIrreversible), ShouldBeTrue)
+				So(ran, ShouldBeEmpty)
+				So(memory.versions(), ShouldResemble, []int{1, 2, 3})
+			})
+		})
+
+		Convey("When only the migrations after it are rolled back", func() {
+			err := migrator.Down(ctx, 2)
+
+			Convey("Then they are rolled back", func() {
+				So(err, ShouldBeNil)
+				So(ran, ShouldResemble, []string{"down three"})
+			})
+		})
+	})
+
+	Convey("Given a migration that is not applied", t, func() {
+		var ran []string
+		memory, store := newMemoryStore(1, 2)
+		migrator, err := NewMigrator(store, testMigrations(&ran, false), testConfig)
+		So(err, ShouldBeNil)
+
+		Convey("When one step is rolled back", func() {
+			err := migrator.Down(ctx, OneStep)
+
+			Convey("Then the latest applied migration is rolled back", func() {
+				So(err, ShouldBeNil)
+				So(ran, ShouldResemble, []string{"down two"})
+				So(memory.versions(), ShouldResemble, []int{1})
+			})
+		})
+	})
+}
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"fmt"
+	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/cadmiumcat/books-api/query"
+	uuid "github.com/satori/go.uuid"
+	"strings"
+)
+
+// Roles of the contributors of a Book
+const (
+	RoleAuthor     = "author"
+	RoleEditor     = "editor"
+	RoleTranslator = "translator"
+)
+
+// An Author is a person who contributes to books, as an author, editor or translator
+type Author struct {
+	ID    string       `json:"id" bson:"_id"`
+	Name  string       `json:"name" bson:"name"`
+	Links *AuthorLinks `json:"links,omitempty" bson:"-"`
+}
+
+// AuthorLinks are the links of an Author to itself and to its books
+type AuthorLinks struct {
+	Self  string `json:"self"`
+	Books string `json:"books"`
+}
+
+// Validate checks that the Author has a name, and normalises it.
+// It returns a validation error if the name is not provided.
+func (a *Author) Validate() error {
+	a.Name = NormaliseAuthorName(a.Name)
+	if a.Name == "" {
+		return apierrors.NewValidationError(apierrors.ErrEmptyAuthorName, apierrors.FieldError{Field: "name", Rule: apierrors.RuleRequired})
+	}
+
+	return nil
+}
+
+// NewAuthor returns an Author structure with a new ID
+func NewAuthor() *Author {
+	return &Author{
+		ID: uuid.NewV4().String(),
+	}
+}
+
+// SetLinks sets the links of an Author to itself and to its books, relative to the base URL of the API
+func (a *Author) SetLinks(baseURL string) {
+	a.Links = &AuthorLinks{
+		Self:  fmt.Sprintf("%s/authors/%s", baseURL, a.ID),
+		Books: fmt.Sprintf("%s/authors/%s/books", baseURL, a.ID),
+	}
+}
+
+// AuthorFields are the fields that can be used to filter and sort a list of authors
+var AuthorFields = query.Schema{
+	"name": {Key: "name", Operators: []query.Operator{query.Equals, query.Contains}, Sortable: true},
+}
+
+// AuthorsResponse represents a paginated list of Authors
+type AuthorsResponse struct {
+	Items []Author `json:"items"`
+	pagination.Page
+}
+
+// A Contributor references an Author who contributed to a Book, and their role
+type Contributor struct {
+	AuthorID string `json:"author_id" bson:"author_id"`
+	Role     string `json:"role" bson:"role"`
+}
+
+var contributorRoles = map[string]bool{
+	RoleAuthor:     true,
+	RoleEditor:     true,
+	RoleTranslator: true,
+}
+
+// BooksBy is the filter that restricts a list of books to the ones that an Author contributed to, in any role
+func BooksBy(authorID string) query.Filter {
+	return query.Filter{Key: "authors.author_id", Operator: query.Equals, Value: authorID}
+}
+
+// NormaliseAuthorName collapses the spaces in the name of an author, and turns a name written as "Surname, Forenames"
+// into "Forenames Surname", so that both forms are recognised as the same author.
+func NormaliseAuthorName(name string) string {
+	if parts := strings.Split(name, ","); len(parts) == 2 {
+		name = parts[1] + " " + parts[0]
+	}
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// SplitAuthorNames returns the names of the authors of a free-text author field, in which several authors are
+// separated by "and", "&" or ";", e.g. "Terry Pratchett and Neil Gaiman". The names are normalised.
+func SplitAuthorNames(authors string) []string {
+	separators := strings.NewReplacer(" and ", ";", " & ", ";")
+
+	var names []string
+	for _, name := range strings.Split(separators.Replace(authors), ";") {
+		if name = NormaliseAuthorName(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// normaliseContributors sets the role of the contributors without one to author, and removes duplicates.
+// It returns the field errors of the contributors without an author ID or with an unknown role.
+func normaliseContributors(contributors []Contributor) ([]Contributor, []apierrors.FieldError) {
+	if len(contributors) == 0 {
+		return nil, nil
+	}
+
+	var invalid []apierrors.FieldError
+	normalised := make([]Contributor, 0, len(contributors))
+	seen := make(map[Contributor]bool, len(contributors))
+	for i, contributor := range contributors {
+		contributor.Role = strings.ToLower(strings.TrimSpace(contributor.Role))
+		if contributor.Role == "" {
+			contributor.Role = RoleAuthor
+		}
+
+		if contributor.AuthorID == "" {
+			invalid = append(invalid, apierrors.FieldError{Field: fmt.Sprintf("authors[%d].author_id", i), Rule: apierrors.RuleRequired})
+		}
+		if !contributorRoles[contributor.Role] {
+			invalid = append(invalid, apierrors.FieldError{Field: fmt.Sprintf("authors[%d].role", i), Rule: apierrors.RuleFormat})
+		}
+
+		if !seen[contributor] {
+			seen[contributor] = true
+			normalised = append(normalised, contributor)
+		}
+	}
+	return normalised, invalid
+}
+
+// Byline returns the names of the authors of a book, e.g. "Terry Pratchett & Neil Gaiman", from its contributors and
+// the names of their Authors by ID. The editors and translators are only named if the book has no authors.
+func Byline(contributors []Contributor, names map[string]string) string {
+	var byline, others []string
+	for _, contributor := range contributors {
+		name := names[contributor.AuthorID]
+		if name == "" {
+			continue
+		}
+		if contributor.Role == RoleAuthor {
+			byline = append(byline, name)
+		} else {
+			others = append(others, name)
+		}
+	}
+
+	if len(byline) == 0 {
+		byline = others
+	}
+	return strings.Join(byline, " & ")
+}
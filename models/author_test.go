@@ -0,0 +1,113 @@
+package models
+
+import (
+	"errors"
+	"github.com/cadmiumcat/books-api/apierrors"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestNormaliseAuthorName(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "Octavia E. Butler", expected: "Octavia E. Butler"},
+		{input: "  Octavia   E.  Butler ", expected: "Octavia E. Butler"},
+		{input: "Butler, Octavia E.", expected: "Octavia E. Butler"},
+		{input: "Butler,Octavia E.", expected: "Octavia E. Butler"},
+		{input: "", expected: ""},
+	}
+
+	Convey("Given the name of an author", t, func() {
+		for _, tt := range tests {
+			Convey("When the name '"+tt.input+"' is normalised", func() {
+				Convey("Then the name is written as forenames followed by surname, with single spaces", func() {
+					So(NormaliseAuthorName(tt.input), ShouldEqual, tt.expected)
+				})
+			})
+		}
+	})
+}
+
+func TestSplitAuthorNames(t *testing.T) {
+	Convey("Given a free-text author field naming several authors", t, func() {
+		authors := "Pratchett, Terry and Neil Gaiman; Ursula K. Le Guin &  "
+
+		Convey("When it is split", func() {
+			names := SplitAuthorNames(authors)
+
+			Convey("Then the normalised name of each author is returned", func() {
+				So(names, ShouldResemble, []string{"Terry Pratchett", "Neil Gaiman", "Ursula K. Le Guin"})
+			})
+		})
+	})
+}
+
+func TestByline(t *testing.T) {
+	names := map[string]string{"1": "Terry Pratchett", "2": "Neil Gaiman", "3": "Edith Grossman"}
+
+	Convey("Given the contributors of a book with two authors and a translator", t, func() {
+		contributors := []Contributor{{AuthorID: "1", Role: RoleAuthor}, {AuthorID: "3", Role: RoleTranslator}, {AuthorID: "2", Role: RoleAuthor}}
+
+		Convey("Then the byline only names the authors", func() {
+			So(Byline(contributors, names), ShouldEqual, "Terry Pratchett & Neil Gaiman")
+		})
+	})
+
+	Convey("Given the contributors of a book with an editor only", t, func() {
+		contributors := []Contributor{{AuthorID: "3", Role: RoleEditor}}
+
+		Convey("Then the byline names the editor", func() {
+			So(Byline(contributors, names), ShouldEqual, "Edith Grossman")
+		})
+	})
+}
+
+func TestValidateAuthors(t *testing.T) {
+	Convey("Given a book with authors but no author", t, func() {
+		book := Book{Title: "Good Omens", Authors: []Contributor{{AuthorID: "1"}, {AuthorID: "2", Role: " Translator"}, {AuthorID: "1", Role: "author"}}}
+
+		Convey("When it is validated", func() {
+			err := book.Validate()
+
+			Convey("Then it is valid, and the roles are normalised without duplicates", func() {
+				So(err, ShouldBeNil)
+				So(book.Authors, ShouldResemble, []Contributor{{AuthorID: "1", Role: RoleAuthor}, {AuthorID: "2", Role: RoleTranslator}})
+			})
+		})
+	})
+
+	Convey("Given a book with an author without ID and an author with an unknown role", t, func() {
+		book := Book{Title: "Good Omens", Authors: []Contributor{{Role: RoleAuthor}, {AuthorID: "2", Role: "illustrator"}}}
+
+		Convey("When it is validated", func() {
+			err := book.Validate()
+
+			Convey("Then a validation error lists both authors", func() {
+				So(err, ShouldBeError)
+				var validationErr *apierrors.ValidationError
+				So(errors.As(err, &validationErr), ShouldBeTrue)
+				So(validationErr.Fields, ShouldResemble, []apierrors.FieldError{
+					{Field: "authors[0].author_id", Rule: apierrors.RuleRequired},
+					{Field: "authors[1].role", Rule: apierrors.RuleFormat},
+				})
+			})
+		})
+	})
+
+	Convey("Given an author without a name", t, func() {
+		author := Author{Name: "  "}
+
+		Convey("When it is validated", func() {
+			err := author.Validate()
+
+			Convey("Then the name is required", func() {
+				So(err, ShouldBeError)
+				var validationErr *apierrors.ValidationError
+				So(errors.As(err, &validationErr), ShouldBeTrue)
+				So(validationErr.Fields, ShouldResemble, []apierrors.FieldError{{Field: "name", Rule: apierrors.RuleRequired}})
+			})
+		})
+	})
+}
@@ -4,45 +4,280 @@ import (
 	"fmt"
 	"github.com/cadmiumcat/books-api/apierrors"
 	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/cadmiumcat/books-api/query"
 	uuid "github.com/satori/go.uuid"
+	"regexp"
+	"strings"
 	"time"
 )
 
-// A Book contains the fields that identify a book and its status.
+// A Book contains the fields that identify a book, its catalogue metadata and its status.
+// The Author is the byline of the book, whereas the Authors reference the Authors who contributed to it, with their role.
+// The ISBN is stored as an ISBN-13, and is unique. The Language is an ISO 639-1 code, e.g. "en".
+// The Revision is incremented every time the Book changes, and is served as the ETag of the Book.
 type Book struct {
-	ID       string     `json:"id" bson:"_id"`
-	Title    string     `json:"title" bson:"title"`
-	Author   string     `json:"author" bson:"author"`
-	Synopsis string     `json:"synopsis,omitempty" bson:"synopsis,omitempty"`
-	Links    *Link      `json:"links,omitempty" bson:"links,omitempty"`
-	History  []Checkout `json:"history,omitempty" bson:"history,omitempty"`
+	ID              string         `json:"id" bson:"_id"`
+	Title           string         `json:"title" bson:"title"`
+	Author          string         `json:"author" bson:"author"`
+	Authors         []Contributor  `json:"authors,omitempty" bson:"authors,omitempty"`
+	Synopsis        string         `json:"synopsis,omitempty" bson:"synopsis,omitempty"`
+	ISBN            string         `json:"isbn,omitempty" bson:"isbn,omitempty"`
+	Publisher       string         `json:"publisher,omitempty" bson:"publisher,omitempty"`
+	PublicationYear int            `json:"publication_year,omitempty" bson:"publication_year,omitempty"`
+	PageCount       int            `json:"page_count,omitempty" bson:"page_count,omitempty"`
+	Language        string         `json:"language,omitempty" bson:"language,omitempty"`
+	Edition         string         `json:"edition,omitempty" bson:"edition,omitempty"`
+	Genres          []string       `json:"genres,omitempty" bson:"genres,omitempty"`
+	Links           *Link          `json:"links,omitempty" bson:"-"`
+	Ratings         *RatingSummary `json:"rating_summary,omitempty" bson:"rating_summary,omitempty"`
+	Revision        int            `json:"-" bson:"revision"`
 }
 
-// Validate checks a Book for missing required fields.
-// It returns an error when required fields (e.g. author/title) are not provided.
+// Validate checks a Book for missing required fields and invalid metadata, and normalises the metadata:
+// the ISBN is converted to an ISBN-13, the language code to lower case, and the genres are trimmed and deduplicated.
+// A Book needs an author, or the authors who contributed to it, from which its byline can be made.
+// It returns a validation error listing the required fields (e.g. author/title) that are not provided or,
+// if they all are, the fields that are not valid.
 func (b *Book) Validate() error {
+	var missing []apierrors.FieldError
+	if b.Title == "" {
+		missing = append(missing, apierrors.FieldError{Field: "title", Rule: apierrors.RuleRequired})
+	}
+	if b.Author == "" && len(b.Authors) == 0 {
+		missing = append(missing, apierrors.FieldError{Field: "author", Rule: apierrors.RuleRequired})
+	}
+
+	if len(missing) > 0 {
+		return apierrors.NewValidationError(apierrors.ErrRequiredFieldMissing, missing...)
+	}
+
+	if b.ISBN != "" {
+		isbn, ok := NormaliseISBN(b.ISBN)
+		if !ok {
+			return apierrors.NewValidationError(apierrors.ErrInvalidISBN, apierrors.FieldError{Field: "isbn", Rule: apierrors.RuleChecksum})
+		}
+		b.ISBN = isbn
+	}
+
+	authors, invalid := normaliseContributors(b.Authors)
+	b.Authors = authors
+
+	if b.PublicationYear < 0 || b.PublicationYear > time.Now().Year()+1 {
+		invalid = append(invalid, apierrors.FieldError{Field: "publication_year", Rule: apierrors.RuleRange})
+	}
+	if b.PageCount < 0 {
+		invalid = append(invalid, apierrors.FieldError{Field: "page_count", Rule: apierrors.RuleRange})
+	}
+
+	b.Language = strings.ToLower(b.Language)
+	if b.Language != "" && !languageCode.MatchString(b.Language) {
+		invalid = append(invalid, apierrors.FieldError{Field: "language", Rule: apierrors.RuleFormat})
+	}
+
+	genres, ok := normaliseGenres(b.Genres)
+	if !ok {
+		invalid = append(invalid, apierrors.FieldError{Field: "genres", Rule: apierrors.RuleRequired})
+	}
+	b.Genres = genres
 
-	if b.Title == "" || b.Author == "" {
-		return apierrors.ErrRequiredFieldMissing
+	if len(invalid) > 0 {
+		return apierrors.NewValidationError(apierrors.ErrInvalidBook, invalid...)
 	}
 
 	return nil
 }
 
-// Checkout stores the details of when a someone has borrowed/returned a Book, as well as their review.
-// To be deprecated
-type Checkout struct {
-	Who    string
-	Out    time.Time
-	In     time.Time
-	Review int
+// languageCode matches ISO 639-1 language codes
+var languageCode = regexp.MustCompile(`^[a-z]{2}$`)
+
+// normaliseGenres trims the genres and removes duplicates, keeping the first occurrence of each genre.
+// It returns false if a genre is empty.
+func normaliseGenres(genres []string) ([]string, bool) {
+	if len(genres) == 0 {
+		return nil, true
+	}
+
+	normalised := make([]string, 0, len(genres))
+	seen := make(map[string]bool, len(genres))
+	for _, genre := range genres {
+		genre = strings.TrimSpace(genre)
+		if genre == "" {
+			return genres, false
+		}
+		if key := strings.ToLower(genre); !seen[key] {
+			seen[key] = true
+			normalised = append(normalised, genre)
+		}
+	}
+	return normalised, true
+}
+
+// patchableFields are the fields of a Book that can be changed with a merge patch, and the kind of their value
+var patchableFields = map[string]string{
+	"title":            "string",
+	"author":           "string",
+	"synopsis":         "string",
+	"isbn":             "string",
+	"publisher":        "string",
+	"publication_year": "integer",
+	"page_count":       "integer",
+	"language":         "string",
+	"edition":          "string",
+	"genres":           "strings",
+	"authors":          "contributors",
+}
+
+// ApplyMergePatch applies a JSON merge patch (RFC 7396) to a copy of the Book and returns the patched copy.
+// Fields set to null in the patch are removed. It returns an error if the patch tries to modify a field that cannot be
+// patched, or to give it a value of the wrong type.
+func (b Book) ApplyMergePatch(patch map[string]interface{}) (*Book, error) {
+	patched := b
+	for field, value := range patch {
+		kind, ok := patchableFields[field]
+		if !ok {
+			return nil, apierrors.NewValidationError(apierrors.ErrInvalidPatch, apierrors.FieldError{Field: field, Rule: apierrors.RuleNotPatchable})
+		}
+
+		if !patched.set(field, kind, value) {
+			return nil, apierrors.NewValidationError(apierrors.ErrInvalidPatch, apierrors.FieldError{Field: field, Rule: apierrors.RuleType})
+		}
+	}
+
+	return &patched, nil
+}
+
+// set sets a field of the Book to a value decoded from JSON, or to its zero value if the value is nil.
+// It returns false if the value is not of the kind of the field.
+func (b *Book) set(field, kind string, value interface{}) bool {
+	var (
+		s            string
+		n            int
+		values       []string
+		contributors []Contributor
+		ok           = true
+	)
+
+	if value != nil {
+		switch kind {
+		case "string":
+			s, ok = value.(string)
+		case "integer":
+			n, ok = integerValue(value)
+		case "strings":
+			values, ok = stringsValue(value)
+		case "contributors":
+			contributors, ok = contributorsValue(value)
+		}
+	}
+	if !ok {
+		return false
+	}
+
+	switch field {
+	case "title":
+		b.Title = s
+	case "author":
+		b.Author = s
+	case "synopsis":
+		b.Synopsis = s
+	case "isbn":
+		b.ISBN = s
+	case "publisher":
+		b.Publisher = s
+	case "publication_year":
+		b.PublicationYear = n
+	case "page_count":
+		b.PageCount = n
+	case "language":
+		b.Language = s
+	case "edition":
+		b.Edition = s
+	case "genres":
+		b.Genres = values
+	case "authors":
+		b.Authors = contributors
+	}
+	return true
+}
+
+// NormalisedPatch returns the values that a merge patch has given to the Book, once it has been patched and validated,
+// so that the normalised values are stored instead of the ones in the patch. Fields removed by the patch stay nil.
+func (b Book) NormalisedPatch(patch map[string]interface{}) map[string]interface{} {
+	values := map[string]interface{}{
+		"title":            b.Title,
+		"author":           b.Author,
+		"synopsis":         b.Synopsis,
+		"isbn":             b.ISBN,
+		"publisher":        b.Publisher,
+		"publication_year": b.PublicationYear,
+		"page_count":       b.PageCount,
+		"language":         b.Language,
+		"edition":          b.Edition,
+		"genres":           b.Genres,
+		"authors":          b.Authors,
+	}
+
+	normalised := make(map[string]interface{}, len(patch))
+	for field, value := range patch {
+		if value == nil {
+			normalised[field] = nil
+			continue
+		}
+		normalised[field] = values[field]
+	}
+	return normalised
+}
+
+// integerValue returns the whole number of a JSON number
+func integerValue(value interface{}) (int, bool) {
+	switch n := value.(type) {
+	case int:
+		return n, true
+	case float64:
+		if n != float64(int(n)) {
+			return 0, false
+		}
+		return int(n), true
+	}
+	return 0, false
+}
+
+// stringsValue returns the strings of a JSON array of strings
+func stringsValue(value interface{}) ([]string, bool) {
+	switch v := value.(type) {
+	case []string:
+		return v, true
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			values = append(values, s)
+		}
+		return values, true
+	}
+	return nil, false
 }
 
 // Link stores the details of when a someone has borrowed/returned a Book, as well user reviews.
 type Link struct {
-	Self         string `json:"self" bson:"self"`
-	Reservations string `json:"reservations" bson:"reservations"`
-	Reviews      string `json:"reviews" bson:"reviews"`
+	Self         string `json:"self"`
+	Reservations string `json:"reservations"`
+	Reviews      string `json:"reviews"`
+}
+
+// BookFields are the fields that can be used to filter and sort a list of books
+var BookFields = query.Schema{
+	"title":            {Key: "title", Operators: []query.Operator{query.Equals, query.Contains}, Sortable: true},
+	"author":           {Key: "author", Operators: []query.Operator{query.Equals, query.Contains}, Sortable: true},
+	"synopsis":         {Key: "synopsis", Operators: []query.Operator{query.Contains}},
+	"isbn":             {Key: "isbn", Operators: []query.Operator{query.Equals}},
+	"publisher":        {Key: "publisher", Operators: []query.Operator{query.Equals, query.Contains}, Sortable: true},
+	"publication_year": {Key: "publication_year", Sortable: true},
+	"language":         {Key: "language", Operators: []query.Operator{query.Equals}},
+	"genre":            {Key: "genres", Operators: []query.Operator{query.Equals}},
 }
 
 // BooksResponse represents a paginated list of Books
@@ -51,14 +286,54 @@ type BooksResponse struct {
 	pagination.Page
 }
 
-// NewBook returns a Book structure
+// NewBook returns a Book structure with a new ID
 func NewBook() *Book {
-	bookID := uuid.NewV4().String()
 	return &Book{
-		ID: bookID,
-		Links: &Link{
-			Self:    fmt.Sprintf("/books/%s", bookID),
-			Reviews: fmt.Sprintf("/books/%s/reviews", bookID),
-		},
+		ID: uuid.NewV4().String(),
+	}
+}
+
+// SetLinks sets the links of a Book, relative to the base URL of the API. The links are not stored, so that they
+// follow the URL of the API.
+func (b *Book) SetLinks(baseURL string) {
+	b.Links = &Link{
+		Self:         fmt.Sprintf("%s/books/%s", baseURL, b.ID),
+		Reservations: fmt.Sprintf("%s/books/%s/reservations", baseURL, b.ID),
+		Reviews:      fmt.Sprintf("%s/books/%s/reviews", baseURL, b.ID),
+	}
+}
+
+// contributorsValue returns the contributors of a JSON array of objects with an author_id and a role
+func contributorsValue(value interface{}) ([]Contributor, bool) {
+	switch v := value.(type) {
+	case []Contributor:
+		return v, true
+	case []interface{}:
+		contributors := make([]Contributor, 0, len(v))
+		for _, item := range v {
+			object, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+
+			var contributor Contributor
+			for field, value := range object {
+				s, ok := value.(string)
+				if !ok {
+					return nil, false
+				}
+				switch field {
+				case "author_id":
+					contributor.AuthorID = s
+				case "role":
+					contributor.Role = s
+				default:
+					return nil, false
+				}
+			}
+			contributors = append(contributors, contributor)
+		}
+		return contributors, true
 	}
+	return nil, false
 }
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"errors"
+	"github.com/cadmiumcat/books-api/apierrors"
 	. "github.com/smartystreets/goconvey/convey"
 	"testing"
 )
@@ -47,8 +49,145 @@ func TestBook_Validate(t *testing.T) {
 		book := Book{}
 		Convey("When the book is validated", func() {
 			err := book.Validate()
-			Convey("Then an invalid book error is returned", func() {
+			Convey("Then an invalid book error is returned, with every missing field", func() {
 				So(err, ShouldBeError, "invalid book. Missing required field")
+
+				var validationErr *apierrors.ValidationError
+				So(errors.As(err, &validationErr), ShouldBeTrue)
+				So(validationErr.Fields, ShouldResemble, []apierrors.FieldError{
+					{Field: "title", Rule: apierrors.RuleRequired},
+					{Field: "author", Rule: apierrors.RuleRequired},
+				})
+			})
+		})
+	})
+}
+
+func TestBook_ValidateMetadata(t *testing.T) {
+	Convey("Given a book with catalogue metadata to normalise", t, func() {
+		book := Book{
+			Title:           "Kindred",
+			Author:          "Octavia E. Butler",
+			ISBN:            "0-306-40615-2",
+			PublicationYear: 1979,
+			PageCount:       264,
+			Language:        "EN",
+			Genres:          []string{" Science fiction", "science fiction", "Slave narrative"},
+		}
+		Convey("When the book is validated", func() {
+			err := book.Validate()
+			Convey("Then no errors are returned, and the metadata is normalised", func() {
+				So(err, ShouldBeNil)
+				So(book.ISBN, ShouldEqual, "9780306406157")
+				So(book.Language, ShouldEqual, "en")
+				So(book.Genres, ShouldResemble, []string{"Science fiction", "Slave narrative"})
+			})
+		})
+	})
+
+	Convey("Given a book with an ISBN that has an invalid check digit", t, func() {
+		book := Book{Title: "Kindred", Author: "Octavia E. Butler", ISBN: "9780306406158"}
+		Convey("When the book is validated", func() {
+			err := book.Validate()
+			Convey("Then an invalid ISBN error is returned", func() {
+				So(err, ShouldBeError, apierrors.ErrInvalidISBN)
+			})
+		})
+	})
+
+	Convey("Given a book with invalid metadata", t, func() {
+		book := Book{Title: "Kindred", Author: "Octavia E. Butler", PublicationYear: 3000, PageCount: -1, Language: "english", Genres: []string{" "}}
+		Convey("When the book is validated", func() {
+			err := book.Validate()
+			Convey("Then an invalid book error is returned, with every invalid field", func() {
+				So(err, ShouldBeError, apierrors.ErrInvalidBook)
+
+				var validationErr *apierrors.ValidationError
+				So(errors.As(err, &validationErr), ShouldBeTrue)
+				So(validationErr.Fields, ShouldResemble, []apierrors.FieldError{
+					{Field: "publication_year", Rule: apierrors.RuleRange},
+					{Field: "page_count", Rule: apierrors.RuleRange},
+					{Field: "language", Rule: apierrors.RuleFormat},
+					{Field: "genres", Rule: apierrors.RuleRequired},
+				})
+			})
+		})
+	})
+}
+
+func TestBook_ApplyMergePatch(t *testing.T) {
+	Convey("Given a book with a title, an author and a synopsis", t, func() {
+		book := Book{
+			ID:       "1",
+			Title:    "Kindred",
+			Author:   "Octavia Butler",
+			Synopsis: "Time travel",
+		}
+
+		Convey("When a patch that changes the author and removes the synopsis is applied", func() {
+			patched, err := book.ApplyMergePatch(map[string]interface{}{"author": "Octavia E. Butler", "synopsis": nil})
+			Convey("Then the patched book contains the changes", func() {
+				So(err, ShouldBeNil)
+				So(patched, ShouldResemble, &Book{ID: "1", Title: "Kindred", Author: "Octavia E. Butler"})
+			})
+			Convey("And the original book is not modified", func() {
+				So(book.Author, ShouldEqual, "Octavia Butler")
+				So(book.Synopsis, ShouldEqual, "Time travel")
+			})
+		})
+
+		Convey("When a patch that changes the ID is applied", func() {
+			patched, err := book.ApplyMergePatch(map[string]interface{}{"id": "2"})
+			Convey("Then an invalid patch error is returned", func() {
+				So(patched, ShouldBeNil)
+				So(err, ShouldBeError, apierrors.ErrInvalidPatch)
+			})
+		})
+
+		Convey("When a patch with a value of the wrong type is applied", func() {
+			patched, err := book.ApplyMergePatch(map[string]interface{}{"title": 42.0})
+			Convey("Then an invalid patch error is returned", func() {
+				So(patched, ShouldBeNil)
+				So(err, ShouldBeError, apierrors.ErrInvalidPatch)
+			})
+		})
+
+		Convey("When a patch that sets the metadata is applied", func() {
+			patched, err := book.ApplyMergePatch(map[string]interface{}{
+				"isbn":             "0-306-40615-2",
+				"publication_year": 1979.0,
+				"genres":           []interface{}{"Science fiction"},
+			})
+			Convey("Then the patched book contains the metadata", func() {
+				So(err, ShouldBeNil)
+				So(patched.ISBN, ShouldEqual, "0-306-40615-2")
+				So(patched.PublicationYear, ShouldEqual, 1979)
+				So(patched.Genres, ShouldResemble, []string{"Science fiction"})
+			})
+		})
+
+		Convey("When a patch with a year that is not a whole number is applied", func() {
+			patched, err := book.ApplyMergePatch(map[string]interface{}{"publication_year": 1979.5})
+			Convey("Then an invalid patch error is returned", func() {
+				So(patched, ShouldBeNil)
+				So(err, ShouldBeError, apierrors.ErrInvalidPatch)
+			})
+		})
+	})
+}
+
+func TestBook_NormalisedPatch(t *testing.T) {
+	Convey("Given a book that has been patched and validated", t, func() {
+		patch := map[string]interface{}{"isbn": "0-306-40615-2", "synopsis": nil}
+		book := Book{Title: "Kindred", Author: "Octavia E. Butler"}
+		patched, err := book.ApplyMergePatch(patch)
+		So(err, ShouldBeNil)
+		So(patched.Validate(), ShouldBeNil)
+
+		Convey("When the normalised patch is returned", func() {
+			normalised := patched.NormalisedPatch(patch)
+			Convey("Then it contains the normalised values of the patched fields", func() {
+				So(normalised, ShouldResemble, map[string]interface{}{"isbn": "9780306406157", "synopsis": nil})
 			})
 		})
 	})
@@ -61,12 +200,26 @@ func TestNewBook(t *testing.T) {
 			Convey("Then the book ID should not be empty", func() {
 				So(book.ID, ShouldNotBeEmpty)
 			})
-			Convey("And the book's reviews link should have the correct structure", func() {
-				So(book.Links.Reviews, ShouldStartWith, "/books/")
-				So(book.Links.Reviews, ShouldEndWith, "/reviews")
+			Convey("And the book has no links until it is served", func() {
+				So(book.Links, ShouldBeNil)
 			})
-			Convey("And the book's self link should have the correct structure", func() {
-				So(book.Links.Self, ShouldStartWith, "/books/")
+		})
+	})
+}
+
+func TestBookSetLinks(t *testing.T) {
+	Convey("Given a book", t, func() {
+		book := &Book{ID: "1"}
+
+		Convey("When its links are set from the base URL of the API", func() {
+			book.SetLinks("http://localhost:8080/v1")
+
+			Convey("Then the links are absolute", func() {
+				So(book.Links, ShouldResemble, &Link{
+					Self:         "http://localhost:8080/v1/books/1",
+					Reservations: "http://localhost:8080/v1/books/1/reservations",
+					Reviews:      "http://localhost:8080/v1/books/1/reviews",
+				})
 			})
 		})
 	})
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"net/http"
+	"time"
+)
+
+// IdempotencyRecord records a request made with an Idempotency-Key header, and the response it was given, so that a
+// retry of the request gets the same response instead of creating another resource.
+// The status is 0 while the request is in progress. The record is removed by the data store once it has expired.
+type IdempotencyRecord struct {
+	ID          string      `bson:"_id"`
+	RequestHash string      `bson:"request_hash"`
+	Status      int         `bson:"status,omitempty"`
+	Header      http.Header `bson:"header,omitempty"`
+	Body        []byte      `bson:"body,omitempty"`
+	ExpiresAt   time.Time   `bson:"expires_at"`
+}
+
+// Completed returns true if the response to the request has been recorded
+func (r *IdempotencyRecord) Completed() bool {
+	return r.Status != 0
+}
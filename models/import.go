@@ -0,0 +1,95 @@
+package models
+
+import (
+	"fmt"
+	"github.com/cadmiumcat/books-api/apierrors"
+	uuid "github.com/satori/go.uuid"
+	"time"
+)
+
+// Outcomes of the rows of an import
+const (
+	RowCreated = "created"
+	RowSkipped = "skipped"
+	RowFailed  = "failed"
+)
+
+// Import job states
+const (
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// An ImportRow is the outcome of a row of an import. Rows are numbered from 1, without the header of a CSV file and
+// the blank lines of a JSON Lines file. A row that is not created has the code and detail of the problem it caused.
+type ImportRow struct {
+	Row    int                    `json:"row"`
+	Status string                 `json:"status"`
+	BookID string                 `json:"book_id,omitempty"`
+	Code   string                 `json:"code,omitempty"`
+	Detail string                 `json:"detail,omitempty"`
+	Errors []apierrors.FieldError `json:"errors,omitempty"`
+}
+
+// An ImportReport counts the rows of an import by outcome, and lists the outcome of each row
+type ImportReport struct {
+	Created int         `json:"created"`
+	Skipped int         `json:"skipped"`
+	Failed  int         `json:"failed"`
+	Rows    []ImportRow `json:"rows,omitempty"`
+}
+
+// Add records the outcome of a row
+func (r *ImportReport) Add(row ImportRow) {
+	switch row.Status {
+	case RowCreated:
+		r.Created++
+	case RowSkipped:
+		r.Skipped++
+	case RowFailed:
+		r.Failed++
+	}
+	r.Rows = append(r.Rows, row)
+}
+
+// An ImportJob is an import that runs in the background. While it is running, its report only counts the rows
+// imported so far: the outcome of each row is listed once the job has finished.
+type ImportJob struct {
+	ID         string         `json:"id"`
+	Format     string         `json:"format"`
+	State      string         `json:"state"`
+	Error      string         `json:"error,omitempty"`
+	Report     ImportReport   `json:"report"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+	Links      *ImportJobLink `json:"links,omitempty"`
+}
+
+// ImportJobLink is the link of an ImportJob to its status
+type ImportJobLink struct {
+	Self string `json:"self"`
+}
+
+// NewImportJob returns a running ImportJob of a file in the given format
+func NewImportJob(format string) *ImportJob {
+	return &ImportJob{
+		ID:        uuid.NewV4().String(),
+		Format:    format,
+		State:     ImportRunning,
+		StartedAt: time.Now().UTC(),
+	}
+}
+
+// SetLinks sets the link of an ImportJob to its status, relative to the base URL of the API
+func (j *ImportJob) SetLinks(baseURL string) {
+	j.Links = &ImportJobLink{
+		Self: fmt.Sprintf("%s/books/import/%s", baseURL, j.ID),
+	}
+}
+
+// An ExportedBook is a book of an export, with its reviews if they are exported too
+type ExportedBook struct {
+	*Book
+	Reviews []Review `json:"reviews,omitempty"`
+}
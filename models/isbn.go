@@ -0,0 +1,71 @@
+package models
+
+import (
+	"strings"
+)
+
+// NormaliseISBN returns the ISBN-13 of an ISBN-10 or ISBN-13, without hyphens or spaces.
+// ISBN-10s are converted, so that a book has a single ISBN whichever form it is provided in.
+// It returns false if the ISBN does not have a valid length, prefix or check digit.
+func NormaliseISBN(isbn string) (string, bool) {
+	digits := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(isbn))
+
+	switch len(digits) {
+	case 10:
+		if !validISBN10(digits) {
+			return "", false
+		}
+		isbn13 := "978" + digits[:9]
+		return isbn13 + string(isbn13CheckDigit(isbn13)), true
+	case 13:
+		if !isDigits(digits) || !(strings.HasPrefix(digits, "978") || strings.HasPrefix(digits, "979")) {
+			return "", false
+		}
+		if isbn13CheckDigit(digits[:12]) != digits[12] {
+			return "", false
+		}
+		return digits, true
+	default:
+		return "", false
+	}
+}
+
+// validISBN10 checks the check digit of an ISBN-10, which is X when it is 10
+func validISBN10(digits string) bool {
+	sum := 0
+	for i, c := range digits {
+		var value int
+		switch {
+		case c >= '0' && c <= '9':
+			value = int(c - '0')
+		case c == 'X' && i == 9:
+			value = 10
+		default:
+			return false
+		}
+		sum += (10 - i) * value
+	}
+	return sum%11 == 0
+}
+
+// isbn13CheckDigit returns the check digit of the first 12 digits of an ISBN-13
+func isbn13CheckDigit(digits string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		value := int(digits[i] - '0')
+		if i%2 == 1 {
+			value *= 3
+		}
+		sum += value
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
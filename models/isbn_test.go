@@ -0,0 +1,37 @@
+package models
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestNormaliseISBN(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		valid    bool
+	}{
+		{input: "9780306406157", expected: "9780306406157", valid: true},
+		{input: "978-0-306-40615-7", expected: "9780306406157", valid: true},
+		{input: "0-306-40615-2", expected: "9780306406157", valid: true},
+		{input: "080508049x", expected: "9780805080490", valid: true},
+		{input: "9780306406158", valid: false},
+		{input: "0306406153", valid: false},
+		{input: "X306406152", valid: false},
+		{input: "9770306406157", valid: false},
+		{input: "97803064061", valid: false},
+		{input: "", valid: false},
+	}
+
+	Convey("Given an ISBN", t, func() {
+		for _, tt := range tests {
+			Convey("When the ISBN "+tt.input+" is normalised", func() {
+				isbn, ok := NormaliseISBN(tt.input)
+				Convey("Then the ISBN-13 is returned if the ISBN is valid", func() {
+					So(ok, ShouldEqual, tt.valid)
+					So(isbn, ShouldEqual, tt.expected)
+				})
+			})
+		}
+	})
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// AppliedMigration records a migration that has been applied to the data store
+type AppliedMigration struct {
+	Version     int       `json:"version" bson:"_id"`
+	Description string    `json:"description" bson:"description"`
+	AppliedAt   time.Time `json:"applied_at" bson:"applied_at"`
+}
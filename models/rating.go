@@ -0,0 +1,83 @@
+package models
+
+import (
+	"math"
+	"strconv"
+)
+
+// A RatingSummary aggregates the ratings of the reviews of a Book: how many reviews have a rating,
+// their average, and how many reviews gave each number of stars. It is kept up to date as reviews are
+// added, updated and deleted, so that it never has to be calculated from the reviews.
+type RatingSummary struct {
+	Average   float64        `json:"average" bson:"-"`
+	Count     int            `json:"count" bson:"count"`
+	Total     int            `json:"-" bson:"total"`
+	Histogram map[string]int `json:"histogram" bson:"histogram"`
+}
+
+// RatingKey returns the key of a rating in the histogram of a RatingSummary
+func RatingKey(rating int) string {
+	return strconv.Itoa(rating)
+}
+
+// Add counts a rating in the summary. A review without a rating is not counted.
+func (s *RatingSummary) Add(rating int) {
+	s.change(rating, 1)
+}
+
+// Remove stops counting a rating in the summary, e.g. because its review has been deleted or its rating has changed
+func (s *RatingSummary) Remove(rating int) {
+	s.change(rating, -1)
+}
+
+func (s *RatingSummary) change(rating, delta int) {
+	if rating == NoRating {
+		return
+	}
+	if s.Histogram == nil {
+		s.Histogram = map[string]int{}
+	}
+
+	s.Count += delta
+	s.Total += delta * rating
+	s.Histogram[RatingKey(rating)] += delta
+	s.UpdateAverage()
+}
+
+// UpdateAverage calculates the average rating, rounded to one decimal place, from the count and total of the ratings.
+// The average is not stored, so it must be calculated after reading a summary.
+func (s *RatingSummary) UpdateAverage() {
+	if s.Count <= 0 {
+		s.Average = 0
+		return
+	}
+	s.Average = math.Round(float64(s.Total)/float64(s.Count)*10) / 10
+}
+
+// RatingChanges returns the increments to apply to a stored RatingSummary when the rating of a review changes
+// from previous to current (either of which can be NoRating), by stored field name.
+func RatingChanges(previous, current int) map[string]int {
+	changes := map[string]int{}
+	if previous == current {
+		return changes
+	}
+
+	if previous != NoRating {
+		changes["count"]--
+		changes["total"] -= previous
+		changes["histogram."+RatingKey(previous)]--
+	}
+	if current != NoRating {
+		changes["count"]++
+		changes["total"] += current
+		changes["histogram."+RatingKey(current)]++
+	}
+
+	for field, change := range changes {
+		if change == 0 {
+			delete(changes, field)
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestRatingSummary(t *testing.T) {
+	Convey("Given an empty rating summary", t, func() {
+		summary := &RatingSummary{}
+
+		Convey("When ratings are added", func() {
+			summary.Add(5)
+			summary.Add(4)
+			summary.Add(4)
+			summary.Add(NoRating)
+
+			Convey("Then only the reviews with a rating are counted", func() {
+				So(summary.Count, ShouldEqual, 3)
+				So(summary.Total, ShouldEqual, 13)
+				So(summary.Histogram, ShouldResemble, map[string]int{"4": 2, "5": 1})
+			})
+			Convey("And the average is rounded to one decimal place", func() {
+				So(summary.Average, ShouldEqual, 4.3)
+			})
+
+			Convey("When a rating is removed", func() {
+				summary.Remove(5)
+
+				Convey("Then the summary no longer counts it", func() {
+					So(summary.Count, ShouldEqual, 2)
+					So(summary.Histogram, ShouldResemble, map[string]int{"4": 2, "5": 0})
+					So(summary.Average, ShouldEqual, 4)
+				})
+			})
+		})
+
+		Convey("When the average is updated", func() {
+			summary.UpdateAverage()
+
+			Convey("Then it is zero", func() {
+				So(summary.Average, ShouldEqual, 0)
+			})
+		})
+	})
+}
+
+func TestRatingChanges(t *testing.T) {
+	Convey("When a rating is given to a review", t, func() {
+		changes := RatingChanges(NoRating, 3)
+
+		Convey("Then the rating is counted", func() {
+			So(changes, ShouldResemble, map[string]int{"count": 1, "total": 3, "histogram.3": 1})
+		})
+	})
+
+	Convey("When the rating of a review changes", t, func() {
+		changes := RatingChanges(2, 5)
+
+		Convey("Then the rating moves in the histogram, without changing the count", func() {
+			So(changes, ShouldResemble, map[string]int{"total": 3, "histogram.2": -1, "histogram.5": 1})
+		})
+	})
+
+	Convey("When the rating of a review is removed", t, func() {
+		changes := RatingChanges(4, NoRating)
+
+		Convey("Then the rating is no longer counted", func() {
+			So(changes, ShouldResemble, map[string]int{"count": -1, "total": -4, "histogram.4": -1})
+		})
+	})
+
+	Convey("When the rating of a review does not change", t, func() {
+		changes := RatingChanges(4, 4)
+
+		Convey("Then nothing changes", func() {
+			So(changes, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"fmt"
+	"github.com/cadmiumcat/books-api/apierrors"
+	"github.com/cadmiumcat/books-api/pagination"
+	uuid "github.com/satori/go.uuid"
+	"time"
+)
+
+// Reservation states
+const (
+	ReservationReserved   = "reserved"
+	ReservationCheckedOut = "checked_out"
+	ReservationReturned   = "returned"
+)
+
+// A Reservation records who has reserved a Book, as well as when it was borrowed and returned.
+type Reservation struct {
+	ID           string           `json:"id" bson:"_id"`
+	BookID       string           `json:"book_id" bson:"book_id"`
+	User         User             `json:"user" bson:"user"`
+	State        string           `json:"state" bson:"state"`
+	ReservedAt   time.Time        `json:"reserved_at" bson:"reserved_at"`
+	CheckedOutAt *time.Time       `json:"checked_out_at,omitempty" bson:"checked_out_at,omitempty"`
+	ReturnedAt   *time.Time       `json:"returned_at,omitempty" bson:"returned_at,omitempty"`
+	Links        *ReservationLink `json:"links,omitempty" bson:"-"`
+	LastUpdated  time.Time        `json:"last_updated" bson:"last_updated"`
+}
+
+// ReservationLink is the relationship between a Book and a Reservation
+type ReservationLink struct {
+	Self string `json:"self"`
+	Book string `json:"book"`
+}
+
+// ReservationsResponse represents a paginated list of Reservations
+type ReservationsResponse struct {
+	Items []Reservation `json:"items"`
+	pagination.Page
+}
+
+// Validate checks a Reservation for missing required fields.
+// It returns an error when the user making the reservation is not provided.
+func (r Reservation) Validate() error {
+	if missing := r.User.missingFields(); len(missing) > 0 {
+		return apierrors.NewValidationError(apierrors.ErrEmptyReservationUser, missing...)
+	}
+
+	return nil
+}
+
+// IsActive returns true if the reserved Book has not been returned yet.
+func (r Reservation) IsActive() bool {
+	return r.State == ReservationReserved || r.State == ReservationCheckedOut
+}
+
+// Checkout records that the reserved Book has been borrowed at the given time.
+// It returns an error if the Reservation is not in the reserved state.
+func (r *Reservation) Checkout(t time.Time) error {
+	if r.State != ReservationReserved {
+		return apierrors.ErrInvalidReservationState
+	}
+
+	r.State = ReservationCheckedOut
+	r.CheckedOutAt = &t
+	r.LastUpdated = t
+
+	return nil
+}
+
+// Return records that the borrowed Book has been returned at the given time.
+// It returns an error if the Book has not been checked out.
+func (r *Reservation) Return(t time.Time) error {
+	if r.State != ReservationCheckedOut {
+		return apierrors.ErrInvalidReservationState
+	}
+
+	r.State = ReservationReturned
+	r.ReturnedAt = &t
+	r.LastUpdated = t
+
+	return nil
+}
+
+// NewReservation returns a Reservation structure based on a bookID
+func NewReservation(bookID string) *Reservation {
+	now := time.Now().UTC()
+
+	return &Reservation{
+		ID:          uuid.NewV4().String(),
+		BookID:      bookID,
+		State:       ReservationReserved,
+		ReservedAt:  now,
+		LastUpdated: now,
+	}
+}
+
+// SetLinks sets the links of a Reservation to itself and to its Book, relative to the base URL of the API
+func (r *Reservation) SetLinks(baseURL string) {
+	r.Links = &ReservationLink{
+		Self: fmt.Sprintf("%s/books/%s/reservations/%s", baseURL, r.BookID, r.ID),
+		Book: fmt.Sprintf("%s/books/%s", baseURL, r.BookID),
+	}
+}
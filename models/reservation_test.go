@@ -0,0 +1,108 @@
+package models
+
+import (
+	"fmt"
+	"github.com/cadmiumcat/books-api/apierrors"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestReservation_Validate(t *testing.T) {
+	Convey("Given a reservation without a user", t, func() {
+		reservation := Reservation{}
+		Convey("When the reservation is validated", func() {
+			err := reservation.Validate()
+			Convey("Then an empty user error is returned", func() {
+				So(err, ShouldBeError, apierrors.ErrEmptyReservationUser)
+			})
+		})
+	})
+
+	Convey("Given a reservation with a user", t, func() {
+		reservation := Reservation{User: User{Forenames: "Avid", Surname: "Reader"}}
+		Convey("When the reservation is validated", func() {
+			err := reservation.Validate()
+			Convey("Then no errors are returned", func() {
+				So(err, ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestReservation_Checkout(t *testing.T) {
+	now := time.Now().UTC()
+
+	Convey("Given a new reservation", t, func() {
+		reservation := NewReservation(bookID)
+		Convey("When the book is checked out", func() {
+			err := reservation.Checkout(now)
+			Convey("Then the reservation is checked out at the given time", func() {
+				So(err, ShouldBeNil)
+				So(reservation.State, ShouldEqual, ReservationCheckedOut)
+				So(*reservation.CheckedOutAt, ShouldEqual, now)
+				So(reservation.IsActive(), ShouldBeTrue)
+			})
+
+			Convey("And when the book is checked out again", func() {
+				err := reservation.Checkout(now)
+				Convey("Then an invalid state error is returned", func() {
+					So(err, ShouldBeError, apierrors.ErrInvalidReservationState)
+				})
+			})
+		})
+
+		Convey("When the book is returned before being checked out", func() {
+			err := reservation.Return(now)
+			Convey("Then an invalid state error is returned", func() {
+				So(err, ShouldBeError, apierrors.ErrInvalidReservationState)
+				So(reservation.State, ShouldEqual, ReservationReserved)
+			})
+		})
+	})
+}
+
+func TestReservation_Return(t *testing.T) {
+	out := time.Now().UTC()
+	in := out.Add(24 * time.Hour)
+
+	Convey("Given a checked out reservation", t, func() {
+		reservation := NewReservation(bookID)
+		So(reservation.Checkout(out), ShouldBeNil)
+
+		Convey("When the book is returned", func() {
+			err := reservation.Return(in)
+			Convey("Then the reservation is returned at the given time", func() {
+				So(err, ShouldBeNil)
+				So(reservation.State, ShouldEqual, ReservationReturned)
+				So(*reservation.CheckedOutAt, ShouldEqual, out)
+				So(*reservation.ReturnedAt, ShouldEqual, in)
+				So(reservation.IsActive(), ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func TestNewReservation(t *testing.T) {
+	Convey("Given a bookID", t, func() {
+		Convey("When a new reservation is created for that book", func() {
+			reservation := NewReservation(bookID)
+			Convey("Then the reservation ID should not be empty", func() {
+				So(reservation.ID, ShouldNotBeEmpty)
+			})
+			Convey("And the reservation is in the reserved state", func() {
+				So(reservation.State, ShouldEqual, ReservationReserved)
+				So(reservation.CheckedOutAt, ShouldBeNil)
+				So(reservation.ReturnedAt, ShouldBeNil)
+			})
+			Convey("And the reservation has no links until it is served", func() {
+				So(reservation.Links, ShouldBeNil)
+			})
+			Convey("And its links are made from the base URL of the API", func() {
+				reservation.SetLinks("http://localhost:8080/v1")
+				So(reservation.Links.Book, ShouldEqual, fmt.Sprintf("http://localhost:8080/v1/books/%s", bookID))
+				So(reservation.Links.Self, ShouldEqual, fmt.Sprintf("http://localhost:8080/v1/books/%s/reservations/%s", bookID, reservation.ID))
+			})
+		})
+	})
+}
@@ -4,64 +4,262 @@ import (
 	"fmt"
 	"github.com/cadmiumcat/books-api/apierrors"
 	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/cadmiumcat/books-api/query"
 	uuid "github.com/satori/go.uuid"
 	"time"
 )
 
-// A Review contains the fields that identify a review
+const (
+	// NoRating is the Rating of a Review without a rating, as the rating is optional
+	NoRating = 0
+
+	// MinRating is the lowest rating that can be given to a book
+	MinRating = 1
+
+	// MaxRating is the highest rating that can be given to a book
+	MaxRating = 5
+)
+
+// Review moderation states
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// A Review contains the fields that identify a review.
+// The Rating is optional, from MinRating to MaxRating stars.
+// A Review is only shown to readers once it has been approved by a moderator.
+// The Owner is the authenticated caller that wrote the Review: only they or a moderator can change it.
+// The Revision is incremented every time the Review changes, and is served as the ETag of the Review.
 type Review struct {
 	ID          string      `json:"id" bson:"_id"`
 	User        User        `json:"user,omitempty" bson:"user,omitempty"`
 	Message     string      `json:"message,omitempty" bson:"message,omitempty"`
+	Rating      int         `json:"rating,omitempty" bson:"rating,omitempty"`
+	State       string      `json:"state" bson:"state"`
+	Owner       string      `json:"-" bson:"owner,omitempty"`
 	BookID      string      `json:"book_id" bson:"book_id"`
-	Links       *ReviewLink `json:"links,omitempty" bson:"links,omitempty"`
+	Links       *ReviewLink `json:"links,omitempty" bson:"-"`
 	LastUpdated time.Time   `json:"last_updated" bson:"last_updated"`
+	Revision    int         `json:"-" bson:"revision"`
 }
 
 // ReviewLink is the relationship between a Book and a Review
 type ReviewLink struct {
-	Self string `json:"self" bson:"self"`
-	Book string `json:"book" bson:"book"`
+	Self string `json:"self"`
+	Book string `json:"book"`
 }
 
+// Validate checks a Review for missing or invalid fields.
+// It returns a validation error for the first problem found, with the fields that cause it.
 func (r Review) Validate() error {
 	if r.Message == "" {
-		return apierrors.ErrEmptyReviewMessage
+		return apierrors.NewValidationError(apierrors.ErrEmptyReviewMessage, apierrors.FieldError{Field: "message", Rule: apierrors.RuleRequired})
 	}
 
-	if r.User.Forenames == "" || r.User.Surname == "" {
-		return apierrors.ErrEmptyReviewUser
+	if missing := r.User.missingFields(); len(missing) > 0 {
+		return apierrors.NewValidationError(apierrors.ErrEmptyReviewUser, missing...)
 	}
 
 	if len(r.Message) > 200 {
-		return apierrors.ErrLongReviewMessage
+		return apierrors.NewValidationError(apierrors.ErrLongReviewMessage, apierrors.FieldError{Field: "message", Rule: apierrors.RuleMaxLength})
+	}
+
+	return ValidateRating(r.Rating)
+}
+
+// ValidateRating checks that a rating is a whole number of stars from MinRating to MaxRating, or NoRating
+func ValidateRating(rating int) error {
+	if rating != NoRating && (rating < MinRating || rating > MaxRating) {
+		return apierrors.NewValidationError(apierrors.ErrInvalidRating, apierrors.FieldError{Field: "rating", Rule: apierrors.RuleRange})
 	}
 
 	return nil
 }
 
+// IsApproved returns true if the Review can be shown to readers
+func (r Review) IsApproved() bool {
+	return r.State == ReviewApproved
+}
+
+// CountedRating returns the rating that the Review contributes to the rating summary of its Book.
+// Only the ratings of approved reviews are counted.
+func (r Review) CountedRating() int {
+	if !r.IsApproved() {
+		return NoRating
+	}
+	return r.Rating
+}
+
+// Approve records that a moderator has approved the Review at the given time.
+// A rejected Review can be approved later on.
+// It returns an error if the Review is already approved.
+func (r *Review) Approve(t time.Time) error {
+	if r.State != ReviewPending && r.State != ReviewRejected {
+		return apierrors.ErrInvalidReviewState
+	}
+
+	r.State = ReviewApproved
+	r.LastUpdated = t
+
+	return nil
+}
+
+// Reject records that a moderator has rejected the Review at the given time, which hides it from readers.
+// An approved Review can be rejected later on.
+// It returns an error if the Review is already rejected.
+func (r *Review) Reject(t time.Time) error {
+	if r.State != ReviewPending && r.State != ReviewApproved {
+		return apierrors.ErrInvalidReviewState
+	}
+
+	r.State = ReviewRejected
+	r.LastUpdated = t
+
+	return nil
+}
+
+// ApplyMergePatch applies a JSON merge patch (RFC 7396) to a copy of the Review and returns the patched copy.
+// Only the message, user and rating can be patched, and fields set to null in the patch are removed. It returns an
+// error if the patch tries to modify another field, or to give a field a value of the wrong type.
+func (r Review) ApplyMergePatch(patch map[string]interface{}) (*Review, error) {
+	patched := r
+	for field, value := range patch {
+		var fieldErr *apierrors.FieldError
+		switch field {
+		case "message":
+			patched.Message, fieldErr = stringValue(field, value)
+		case "rating":
+			patched.Rating, fieldErr = ratingValue(value)
+		case "user":
+			patched.User, fieldErr = patched.User.applyMergePatch(value)
+		default:
+			fieldErr = &apierrors.FieldError{Field: field, Rule: apierrors.RuleNotPatchable}
+		}
+
+		if fieldErr != nil {
+			return nil, apierrors.NewValidationError(apierrors.ErrInvalidReviewPatch, *fieldErr)
+		}
+	}
+
+	return &patched, nil
+}
+
 type User struct {
 	Forenames string `json:"forenames,omitempty" bson:"forenames,omitempty"`
 	Surname   string `json:"surname,omitempty" bson:"surname,omitempty"`
 }
 
+// missingFields returns the field errors of the names of the User that are not provided
+func (u User) missingFields() []apierrors.FieldError {
+	var missing []apierrors.FieldError
+	if u.Forenames == "" {
+		missing = append(missing, apierrors.FieldError{Field: "user.forenames", Rule: apierrors.RuleRequired})
+	}
+	if u.Surname == "" {
+		missing = append(missing, apierrors.FieldError{Field: "user.surname", Rule: apierrors.RuleRequired})
+	}
+	return missing
+}
+
+// applyMergePatch returns the User with a merge patch applied to its names. A nil patch removes both names.
+// It returns the error of the field that cannot be patched, if any.
+func (u User) applyMergePatch(value interface{}) (User, *apierrors.FieldError) {
+	if value == nil {
+		return User{}, nil
+	}
+	patch, ok := value.(map[string]interface{})
+	if !ok {
+		return u, &apierrors.FieldError{Field: "user", Rule: apierrors.RuleType}
+	}
+
+	var fieldErr *apierrors.FieldError
+	for field, value := range patch {
+		switch field {
+		case "forenames":
+			u.Forenames, fieldErr = stringValue("user.forenames", value)
+		case "surname":
+			u.Surname, fieldErr = stringValue("user.surname", value)
+		default:
+			fieldErr = &apierrors.FieldError{Field: "user." + field, Rule: apierrors.RuleNotPatchable}
+		}
+		if fieldErr != nil {
+			return u, fieldErr
+		}
+	}
+	return u, nil
+}
+
+// ratingValue returns the rating of a JSON value, or NoRating if the value is null.
+// It returns a field error if the value is not a whole number.
+func ratingValue(value interface{}) (int, *apierrors.FieldError) {
+	if value == nil {
+		return NoRating, nil
+	}
+	rating, ok := integerValue(value)
+	if !ok {
+		return NoRating, &apierrors.FieldError{Field: "rating", Rule: apierrors.RuleType}
+	}
+	return rating, nil
+}
+
+// stringValue returns the string of the JSON value of a field, or an empty string if the value is null.
+// It returns a field error if the value is not a string.
+func stringValue(field string, value interface{}) (string, *apierrors.FieldError) {
+	if value == nil {
+		return "", nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", &apierrors.FieldError{Field: field, Rule: apierrors.RuleType}
+	}
+	return s, nil
+}
+
+// ReviewFields are the fields that can be used to filter and sort a list of reviews
+var ReviewFields = query.Schema{
+	"forenames":    {Key: "user.forenames", Operators: []query.Operator{query.Equals}, Sortable: true},
+	"surname":      {Key: "user.surname", Operators: []query.Operator{query.Equals}, Sortable: true},
+	"message":      {Key: "message", Operators: []query.Operator{query.Contains}},
+	"last_updated": {Key: "last_updated", Sortable: true},
+}
+
+// ModeratedReviewFields are the fields that moderators can use to filter and sort a list of reviews.
+// Moderators can filter the reviews by state, on top of the ReviewFields.
+var ModeratedReviewFields = moderatedReviewFields()
+
+func moderatedReviewFields() query.Schema {
+	fields := query.Schema{"state": {Key: "state", Operators: []query.Operator{query.Equals}}}
+	for name, field := range ReviewFields {
+		fields[name] = field
+	}
+	return fields
+}
+
+// ApprovedReviews is the filter that restricts a list of reviews to the ones that can be shown to readers
+var ApprovedReviews = query.Filter{Key: "state", Operator: query.Equals, Value: ReviewApproved}
+
 // ReviewsResponse represents a paginated list of Books
 type ReviewsResponse struct {
 	Items []Review `json:"items"`
 	pagination.Page
 }
 
-// NewReview returns a Review structure based on a bookID
+// NewReview returns a Review structure based on a bookID, pending moderation
 func NewReview(bookID string) *Review {
-	reviewID := uuid.NewV4().String()
-
 	return &Review{
-		ID:     reviewID,
-		BookID: bookID,
-		Links: &ReviewLink{
-			Self: fmt.Sprintf("/books/%s/reviews/%s", bookID, reviewID),
-			Book: fmt.Sprintf("/books/%s", bookID),
-		},
+		ID:          uuid.NewV4().String(),
+		BookID:      bookID,
+		State:       ReviewPending,
 		LastUpdated: time.Now().UTC(),
 	}
 }
+
+// SetLinks sets the links of a Review to itself and to its Book, relative to the base URL of the API
+func (r *Review) SetLinks(baseURL string) {
+	r.Links = &ReviewLink{
+		Self: fmt.Sprintf("%s/books/%s/reviews/%s", baseURL, r.BookID, r.ID),
+		Book: fmt.Sprintf("%s/books/%s", baseURL, r.BookID),
+	}
+}
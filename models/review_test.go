@@ -6,6 +6,7 @@ import (
 	. "github.com/smartystreets/goconvey/convey"
 	"math/rand"
 	"testing"
+	"time"
 )
 
 const bookID = "123"
@@ -35,6 +36,24 @@ func TestReview_Validate(t *testing.T) {
 			input:    Review{Message: "my review"},
 			expected: apierrors.ErrEmptyReviewUser,
 		},
+		{
+			name: "Review with a rating over the maximum",
+			input: Review{
+				Message: "my review",
+				User:    User{Forenames: "Avid", Surname: "Reader"},
+				Rating:  MaxRating + 1,
+			},
+			expected: apierrors.ErrInvalidRating,
+		},
+		{
+			name: "Review with a negative rating",
+			input: Review{
+				Message: "my review",
+				User:    User{Forenames: "Avid", Surname: "Reader"},
+				Rating:  -1,
+			},
+			expected: apierrors.ErrInvalidRating,
+		},
 	}
 
 	Convey("Given a review", t, func() {
@@ -61,6 +80,128 @@ func TestReview_Validate(t *testing.T) {
 				So(err, ShouldBeNil)
 			})
 		})
+
+		Convey("When it has a rating from 1 to 5 stars", func() {
+			for rating := MinRating; rating <= MaxRating; rating++ {
+				review.Rating = rating
+				So(review.Validate(), ShouldBeNil)
+			}
+		})
+	})
+}
+
+func TestReview_Approve(t *testing.T) {
+	now := time.Now().UTC()
+
+	Convey("Given a new review, pending moderation", t, func() {
+		review := NewReview(bookID)
+		review.Rating = 4
+		So(review.State, ShouldEqual, ReviewPending)
+		So(review.CountedRating(), ShouldEqual, NoRating)
+
+		Convey("When the review is approved", func() {
+			err := review.Approve(now)
+			Convey("Then the review is shown to readers, and its rating is counted", func() {
+				So(err, ShouldBeNil)
+				So(review.State, ShouldEqual, ReviewApproved)
+				So(review.IsApproved(), ShouldBeTrue)
+				So(review.CountedRating(), ShouldEqual, 4)
+				So(review.LastUpdated, ShouldEqual, now)
+			})
+
+			Convey("And when the review is approved again", func() {
+				err := review.Approve(now)
+				Convey("Then an invalid state error is returned", func() {
+					So(err, ShouldBeError, apierrors.ErrInvalidReviewState)
+				})
+			})
+
+			Convey("And when the review is rejected later on", func() {
+				err := review.Reject(now)
+				Convey("Then the review is hidden from readers, and its rating is no longer counted", func() {
+					So(err, ShouldBeNil)
+					So(review.State, ShouldEqual, ReviewRejected)
+					So(review.IsApproved(), ShouldBeFalse)
+					So(review.CountedRating(), ShouldEqual, NoRating)
+				})
+			})
+		})
+	})
+}
+
+func TestReview_Reject(t *testing.T) {
+	now := time.Now().UTC()
+
+	Convey("Given a rejected review", t, func() {
+		review := NewReview(bookID)
+		So(review.Reject(now), ShouldBeNil)
+
+		Convey("When the review is rejected again", func() {
+			err := review.Reject(now)
+			Convey("Then an invalid state error is returned", func() {
+				So(err, ShouldBeError, apierrors.ErrInvalidReviewState)
+				So(review.State, ShouldEqual, ReviewRejected)
+			})
+		})
+
+		Convey("When the review is approved after all", func() {
+			err := review.Approve(now)
+			Convey("Then the review is approved", func() {
+				So(err, ShouldBeNil)
+				So(review.State, ShouldEqual, ReviewApproved)
+			})
+		})
+	})
+}
+
+func TestReview_ApplyMergePatch(t *testing.T) {
+	Convey("Given a review with a message, a user and a rating", t, func() {
+		review := Review{
+			ID:      "1",
+			Message: "my review",
+			User:    User{Forenames: "Avid", Surname: "Reader"},
+			Rating:  4,
+			State:   ReviewApproved,
+		}
+
+		Convey("When a patch that changes the message and the surname, and removes the rating, is applied", func() {
+			patched, err := review.ApplyMergePatch(map[string]interface{}{
+				"message": "my better review",
+				"user":    map[string]interface{}{"surname": "Writer"},
+				"rating":  nil,
+			})
+			Convey("Then the patched review contains the changes", func() {
+				So(err, ShouldBeNil)
+				So(patched, ShouldResemble, &Review{
+					ID:      "1",
+					Message: "my better review",
+					User:    User{Forenames: "Avid", Surname: "Writer"},
+					Rating:  NoRating,
+					State:   ReviewApproved,
+				})
+			})
+			Convey("And the original review is not modified", func() {
+				So(review.Message, ShouldEqual, "my review")
+				So(review.User.Surname, ShouldEqual, "Reader")
+				So(review.Rating, ShouldEqual, 4)
+			})
+		})
+
+		Convey("When a patch that changes the state or the owner is applied", func() {
+			for _, patch := range []map[string]interface{}{{"state": ReviewPending}, {"owner": "someone.else"}, {"user": map[string]interface{}{"email": "a@b.c"}}} {
+				patched, err := review.ApplyMergePatch(patch)
+				So(patched, ShouldBeNil)
+				So(err, ShouldBeError, apierrors.ErrInvalidReviewPatch)
+			}
+		})
+
+		Convey("When a patch with a value of the wrong type is applied", func() {
+			for _, patch := range []map[string]interface{}{{"message": 42.0}, {"rating": "five"}, {"rating": 4.5}, {"user": "Avid Reader"}} {
+				patched, err := review.ApplyMergePatch(patch)
+				So(patched, ShouldBeNil)
+				So(err, ShouldBeError, apierrors.ErrInvalidReviewPatch)
+			}
+		})
 	})
 }
 
@@ -74,11 +215,25 @@ func TestNewReview(t *testing.T) {
 			Convey("And the review's BookID should match the given bookID", func() {
 				So(review.BookID, ShouldEqual, bookID)
 			})
-			Convey("And the review's book link is correct", func() {
-				So(review.Links.Book, ShouldEqual, fmt.Sprintf("/books/%s", bookID))
+			Convey("And the review has no links until it is served", func() {
+				So(review.Links, ShouldBeNil)
 			})
-			Convey("And the review's self link should have the correct structure", func() {
-				So(review.Links.Self, ShouldStartWith, fmt.Sprintf("/books/%s/reviews/", bookID))
+		})
+	})
+}
+
+func TestReviewSetLinks(t *testing.T) {
+	Convey("Given a review of a book", t, func() {
+		review := &Review{ID: "2", BookID: "1"}
+
+		Convey("When its links are set from the base URL of the API", func() {
+			review.SetLinks("http://localhost:8080/v1")
+
+			Convey("Then the links to the review and to its book are absolute", func() {
+				So(review.Links, ShouldResemble, &ReviewLink{
+					Self: "http://localhost:8080/v1/books/1/reviews/2",
+					Book: "http://localhost:8080/v1/books/1",
+				})
 			})
 		})
 	})
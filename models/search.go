@@ -0,0 +1,17 @@
+package models
+
+import "github.com/cadmiumcat/books-api/pagination"
+
+// A SearchResult is a Book that matches a search, with its relevance to the search (the higher the score, the more relevant)
+// and, for each field that matches, a snippet of the field in which the matching words are highlighted with <em> tags.
+type SearchResult struct {
+	Book       `bson:",inline"`
+	Score      float64           `json:"score" bson:"score"`
+	Highlights map[string]string `json:"highlights,omitempty" bson:"-"`
+}
+
+// SearchResponse represents a paginated list of SearchResults, sorted by relevance
+type SearchResponse struct {
+	Items []SearchResult `json:"items"`
+	pagination.Page
+}
@@ -0,0 +1,292 @@
+package mongo
+
+import (
+	"context"
+	"github.com/ONSdigital/log.go/log"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/cadmiumcat/books-api/query"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// booksAuthorsIndex finds the books that an author contributed to
+var booksAuthorsIndex = mongoDriver.IndexModel{
+	Keys:    bson.D{{Key: "authors.author_id", Value: 1}},
+	Options: options.Index().SetName("books_authors"),
+}
+
+// authorsNameIndex finds an author by name, when the authors of the books are migrated
+var authorsNameIndex = mongoDriver.IndexModel{
+	Keys:    bson.D{{Key: "name", Value: 1}},
+	Options: options.Index().SetName("authors_name"),
+}
+
+// AddAuthor adds an Author
+func (m *Mongo) AddAuthor(ctx context.Context, author *models.Author) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	logData := log.Data{
+		"author":     author,
+		"database":   m.Database,
+		"collection": m.AuthorsCollection}
+
+	if _, err := m.collection(m.AuthorsCollection).InsertOne(ctx, author); err != nil {
+		log.Event(ctx, "unexpected error when adding an author", log.ERROR, log.Error(err), logData)
+		return writeError(err, "unexpected error when adding an author")
+	}
+
+	return nil
+}
+
+// GetAuthor returns a models.Author for a given ID.
+// It returns an error if the Author is not found
+func (m *Mongo) GetAuthor(ctx context.Context, ID string) (*models.Author, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	logData := log.Data{
+		"author_id":  ID,
+		"database":   m.Database,
+		"collection": m.AuthorsCollection}
+
+	var author models.Author
+	if err := m.collection(m.AuthorsCollection).FindOne(ctx, bson.M{"_id": ID}).Decode(&author); err != nil {
+		if err == mongoDriver.ErrNoDocuments {
+			log.Event(ctx, ErrAuthorNotFound.Error(), log.ERROR, log.Error(err), logData)
+			return nil, ErrAuthorNotFound
+		}
+		log.Event(ctx, "unexpected error when getting an author", log.ERROR, log.Error(err), logData)
+		return nil, errors.Wrap(err, "unexpected error when getting an author")
+	}
+
+	return &author, nil
+}
+
+// GetAuthors returns the authors that match the filters of the query, sorted as requested by the query.
+// The page starts at the cursor if one is provided, and at the offset otherwise.
+// It returns an error if the authors cannot be listed.
+func (m *Mongo) GetAuthors(ctx context.Context, q *query.Query, cursor *pagination.Cursor, offset, limit int) ([]models.Author, int, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	logData := log.Data{
+		"query":      q,
+		"cursor":     cursor,
+		"database":   m.Database,
+		"collection": m.AuthorsCollection}
+
+	collection := m.collection(m.AuthorsCollection)
+	selector := querySelector(nil, q)
+	var authors []models.Author
+
+	totalCount, err := collection.CountDocuments(ctx, selector)
+	if err != nil {
+		log.Event(ctx, "failure to retrieve list of authors", log.ERROR, log.Error(err), logData)
+		return nil, 0, errors.Wrap(err, "unexpected error when getting authors")
+	}
+
+	if limit > 0 {
+		filter, findOptions := pageQuery(selector, q, cursor, offset, limit)
+		if err := findAll(ctx, collection, filter, &authors, findOptions); err != nil {
+			log.Event(ctx, "unable to retrieve authors", log.ERROR, log.Error(err), logData)
+			return []models.Author{}, int(totalCount), errors.Wrap(err, "unexpected error when getting authors")
+		}
+		reversePage(cursor, &authors)
+	}
+
+	return authors, int(totalCount), nil
+}
+
+// UpdateAuthor renames an existing Author, and refreshes the author of the books it contributed to.
+// It returns an error if the Author is not found
+func (m *Mongo) UpdateAuthor(ctx context.Context, ID string, author *models.Author) error {
+	logData := log.Data{
+		"author_id":  ID,
+		"database":   m.Database,
+		"collection": m.AuthorsCollection}
+
+	updateCtx, cancel := m.withTimeout(ctx)
+	result, err := m.collection(m.AuthorsCollection).UpdateOne(updateCtx, bson.M{"_id": ID}, bson.M{"$set": bson.M{"name": author.Name}})
+	cancel()
+	if err != nil {
+		log.Event(ctx, "unexpected error when updating an author", log.ERROR, log.Error(err), logData)
+		return writeError(err, "unexpected error when updating an author")
+	}
+	if result.MatchedCount == 0 {
+		log.Event(ctx, ErrAuthorNotFound.Error(), log.ERROR, logData)
+		return ErrAuthorNotFound
+	}
+
+	if err := m.refreshBylines(ctx, ID); err != nil {
+		log.Event(ctx, "unexpected error when refreshing the author of the books of an author", log.ERROR, log.Error(err), logData)
+		return writeError(err, "unexpected error when updating an author")
+	}
+
+	return nil
+}
+
+// refreshBylines sets the author of the books that the Author with the given ID contributed to, from the current
+// names of their contributors
+func (m *Mongo) refreshBylines(ctx context.Context, authorID string) error {
+	books := m.collection(m.BooksCollection)
+	authors := m.collection(m.AuthorsCollection)
+
+	names := make(map[string]string)
+	filter := bson.M{"authors.author_id": authorID}
+	findOptions := options.Find().SetProjection(bson.M{"authors": 1})
+	return m.iterate(ctx, books, filter, findOptions, func(cursor *mongoDriver.Cursor) error {
+		var book struct {
+			ID      string               `bson:"_id"`
+			Authors []models.Contributor `bson:"authors"`
+		}
+		if err := cursor.Decode(&book); err != nil {
+			return err
+		}
+
+		ctx, cancel := m.withTimeout(ctx)
+		defer cancel()
+
+		for _, contributor := range book.Authors {
+			if _, ok := names[contributor.AuthorID]; ok {
+				continue
+			}
+			var author models.Author
+			err := authors.FindOne(ctx, bson.M{"_id": contributor.AuthorID}).Decode(&author)
+			if err != nil && err != mongoDriver.ErrNoDocuments {
+				return err
+			}
+			names[contributor.AuthorID] = author.Name
+		}
+
+		update := bson.M{"$set": bson.M{"author": models.Byline(book.Authors, names)}, "$inc": bson.M{"revision": 1}}
+		_, err := books.UpdateOne(ctx, bson.M{"_id": book.ID}, update)
+		return err
+	})
+}
+
+// DeleteAuthor removes an Author.
+// It returns an error if the Author is not found, or if it contributed to any books
+func (m *Mongo) DeleteAuthor(ctx context.Context, ID string) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	logData := log.Data{
+		"author_id":  ID,
+		"database":   m.Database,
+		"collection": m.AuthorsCollection}
+
+	count, err := m.collection(m.BooksCollection).CountDocuments(ctx, bson.M{"authors.author_id": ID})
+	if err != nil {
+		log.Event(ctx, "unexpected error when counting the books of an author", log.ERROR, log.Error(err), logData)
+		return writeError(err, "unexpected error when deleting an author")
+	}
+	if count > 0 {
+		logData["books"] = count
+		log.Event(ctx, ErrAuthorHasBooks.Error(), log.ERROR, logData)
+		return ErrAuthorHasBooks
+	}
+
+	result, err := m.collection(m.AuthorsCollection).DeleteOne(ctx, bson.M{"_id": ID})
+	if err != nil {
+		log.Event(ctx, "unexpected error when deleting an author", log.ERROR, log.Error(err), logData)
+		return writeError(err, "unexpected error when deleting an author")
+	}
+	if result.DeletedCount == 0 {
+		log.Event(ctx, ErrAuthorNotFound.Error(), log.ERROR, logData)
+		return ErrAuthorNotFound
+	}
+
+	return nil
+}
+
+// migrateAuthors turns the free-text author of the books without authors into Authors that the books reference.
+// An author field naming several authors, e.g. "Terry Pratchett and Neil Gaiman", is split into one Author per name,
+// and books by the same author reference the same Author. Books that already reference authors are left untouched,
+// so it is safe to run it more than once.
+func (m *Mongo) migrateAuthors(ctx context.Context) error {
+	logData := log.Data{
+		"database":           m.Database,
+		"books_collection":   m.BooksCollection,
+		"authors_collection": m.AuthorsCollection}
+
+	books := m.collection(m.BooksCollection)
+
+	filter := bson.M{"author": bson.M{"$nin": bson.A{"", nil}}, "authors": bson.M{"$exists": false}}
+	findOptions := options.Find().SetProjection(bson.M{"author": 1})
+	authorIDs := make(map[string]string)
+	migrated := 0
+	err := m.iterate(ctx, books, filter, findOptions, func(cursor *mongoDriver.Cursor) error {
+		var book struct {
+			ID     string `bson:"_id"`
+			Author string `bson:"author"`
+		}
+		if err := cursor.Decode(&book); err != nil {
+			return err
+		}
+
+		var contributors []models.Contributor
+		for _, name := range models.SplitAuthorNames(book.Author) {
+			authorID, err := m.authorIDByName(ctx, authorIDs, name)
+			if err != nil {
+				logData["book_id"] = book.ID
+				log.Event(ctx, "unexpected error when migrating the authors of a book", log.ERROR, log.Error(err), logData)
+				return err
+			}
+			contributors = append(contributors, models.Contributor{AuthorID: authorID, Role: models.RoleAuthor})
+		}
+
+		if len(contributors) == 0 {
+			return nil
+		}
+
+		updateCtx, cancel := m.withTimeout(ctx)
+		defer cancel()
+		if _, err := books.UpdateOne(updateCtx, bson.M{"_id": book.ID}, bson.M{"$set": bson.M{"authors": contributors}}); err != nil {
+			logData["book_id"] = book.ID
+			log.Event(ctx, "unexpected error when setting the authors of a book", log.ERROR, log.Error(err), logData)
+			return err
+		}
+		migrated++
+		return nil
+	})
+	if err != nil {
+		log.Event(ctx, "unexpected error when iterating over books without authors", log.ERROR, log.Error(err), logData)
+		return errors.Wrap(err, "unexpected error when migrating authors")
+	}
+
+	logData["books_migrated"] = migrated
+	log.Event(ctx, "migrated the authors of the books", log.INFO, logData)
+
+	return nil
+}
+
+// authorIDByName returns the ID of the Author with the given name, adding the Author if there is none.
+// The IDs already found are kept in authorIDs by name.
+func (m *Mongo) authorIDByName(ctx context.Context, authorIDs map[string]string, name string) (string, error) {
+	if id, ok := authorIDs[name]; ok {
+		return id, nil
+	}
+
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	authors := m.collection(m.AuthorsCollection)
+
+	var author models.Author
+	err := authors.FindOne(ctx, bson.M{"name": name}).Decode(&author)
+	if err == mongoDriver.ErrNoDocuments {
+		author = *models.NewAuthor()
+		author.Name = name
+		_, err = authors.InsertOne(ctx, &author)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	authorIDs[name] = author.ID
+	return author.ID, nil
+}
@@ -0,0 +1,102 @@
+package mongo
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/cadmiumcat/books-api/config"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
+)
+
+// Error codes returned by MongoDB when a collection does not exist, and when it is created while it already exists
+const (
+	namespaceNotFound = 26
+	namespaceExists   = 48
+)
+
+// clientOptions returns the options of the MongoDB client for the given configuration.
+// The bind address is either a host and port, or a connection string (e.g. a mongodb+srv:// URI). The credentials,
+// if any, and the TLS setting are added to those of the connection string.
+func clientOptions(mongoConfig config.MongoConfig) *options.ClientOptions {
+	uri := mongoConfig.BindAddr
+	if !strings.Contains(uri, "://") {
+		uri = "mongodb://" + uri
+	}
+
+	clientOptions := options.Client().
+		ApplyURI(uri).
+		SetConnectTimeout(mongoConfig.ConnectTimeout).
+		SetServerSelectionTimeout(mongoConfig.ConnectTimeout).
+		SetMaxPoolSize(mongoConfig.MaxPoolSize).
+		SetMinPoolSize(mongoConfig.MinPoolSize).
+		SetMaxConnIdleTime(mongoConfig.MaxConnIdleTime)
+
+	if mongoConfig.Username != "" {
+		clientOptions.SetAuth(options.Credential{
+			AuthSource: mongoConfig.AuthSource,
+			Username:   mongoConfig.Username,
+			Password:   mongoConfig.Password,
+		})
+	}
+
+	if mongoConfig.EnableTLS {
+		clientOptions.SetTLSConfig(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+
+	return clientOptions
+}
+
+// withTimeout returns a context that is cancelled after the query timeout, so that a call to MongoDB cannot hang
+func (m *Mongo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if m.QueryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, m.QueryTimeout)
+}
+
+// collection returns a collection of the database
+func (m *Mongo) collection(name string) *mongoDriver.Collection {
+	return m.Client.Database(m.Database).Collection(name)
+}
+
+// collections returns the names of all the collections used by the data store
+func (m *Mongo) collections() []string {
+	return []string{m.BooksCollection, m.ReviewsCollection, m.ReservationsCollection, m.AuthorsCollection, m.OutboxCollection, m.IdempotencyCollection, m.CountersCollection}
+}
+
+// ensureCollections creates the collections that do not exist yet.
+// MongoDB creates a collection when a document is first inserted, but not within a transaction before version 4.4.
+func (m *Mongo) ensureCollections(ctx context.Context) error {
+	existing, err := m.Client.Database(m.Database).ListCollectionNames(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range m.collections() {
+		if contains(existing, name) {
+			continue
+		}
+		err := m.Client.Database(m.Database).CreateCollection(ctx, name)
+		var commandErr mongoDriver.CommandError
+		if errors.As(err, &commandErr) && commandErr.Code == namespaceExists {
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to create the %s collection", name)
+		}
+	}
+
+	return nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
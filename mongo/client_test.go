@@ -0,0 +1,80 @@
+package mongo
+
+import (
+	"github.com/cadmiumcat/books-api/config"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestClientOptions(t *testing.T) {
+	mongoConfig := config.MongoConfig{
+		BindAddr:        "localhost:27017",
+		AuthSource:      "admin",
+		ConnectTimeout:  10 * time.Second,
+		MaxPoolSize:     100,
+		MinPoolSize:     5,
+		MaxConnIdleTime: 5 * time.Minute,
+	}
+
+	Convey("Given a bind address without a scheme", t, func() {
+		Convey("When the client options are built", func() {
+			clientOptions := clientOptions(mongoConfig)
+
+			Convey("Then the bind address is used as the host", func() {
+				So(clientOptions.Validate(), ShouldBeNil)
+				So(clientOptions.Hosts, ShouldResemble, []string{"localhost:27017"})
+			})
+
+			Convey("And the connection pool is configured", func() {
+				So(*clientOptions.ConnectTimeout, ShouldEqual, 10*time.Second)
+				So(*clientOptions.ServerSelectionTimeout, ShouldEqual, 10*time.Second)
+				So(*clientOptions.MaxPoolSize, ShouldEqual, 100)
+				So(*clientOptions.MinPoolSize, ShouldEqual, 5)
+				So(*clientOptions.MaxConnIdleTime, ShouldEqual, 5*time.Minute)
+			})
+
+			Convey("And there are no credentials and no TLS", func() {
+				So(clientOptions.Auth, ShouldBeNil)
+				So(clientOptions.TLSConfig, ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given a connection string", t, func() {
+		withURI := mongoConfig
+		withURI.BindAddr = "mongodb://db1:27017,db2:27017/?replicaSet=rs0"
+
+		Convey("When the client options are built", func() {
+			clientOptions := clientOptions(withURI)
+
+			Convey("Then the options of the connection string are used", func() {
+				So(clientOptions.Validate(), ShouldBeNil)
+				So(clientOptions.Hosts, ShouldResemble, []string{"db1:27017", "db2:27017"})
+				So(*clientOptions.ReplicaSet, ShouldEqual, "rs0")
+			})
+		})
+	})
+
+	Convey("Given credentials and TLS", t, func() {
+		secured := mongoConfig
+		secured.Username = "books"
+		secured.Password = "secret"
+		secured.EnableTLS = true
+
+		Convey("When the client options are built", func() {
+			clientOptions := clientOptions(secured)
+
+			Convey("Then the client authenticates against the auth source", func() {
+				So(clientOptions.Auth, ShouldNotBeNil)
+				So(clientOptions.Auth.Username, ShouldEqual, "books")
+				So(clientOptions.Auth.Password, ShouldEqual, "secret")
+				So(clientOptions.Auth.AuthSource, ShouldEqual, "admin")
+			})
+
+			Convey("And the client connects over TLS", func() {
+				So(clientOptions.TLSConfig, ShouldNotBeNil)
+			})
+		})
+	})
+}
@@ -1,8 +1,62 @@
 package mongo
 
-import "github.com/pkg/errors"
+import (
+	"github.com/pkg/errors"
+	mongoDriver "go.mongodb.org/mongo-driver/mongo"
+)
 
 var (
 	ErrBookNotFound   = errors.New("book not found")
 	ErrReviewNotFound = errors.New("review not found")
+	ErrBookConflict   = errors.New("book has been modified by another request")
+	ErrBookHasReviews = errors.New("book has reviews and cannot be deleted")
+	ErrDuplicateISBN  = errors.New("a book with the same ISBN already exists")
+	ErrReviewConflict = errors.New("review has been modified by another request")
+
+	ErrAuthorNotFound = errors.New("author not found")
+	ErrAuthorHasBooks = errors.New("author has books and cannot be deleted")
+
+	ErrReservationNotFound = errors.New("reservation not found")
+	ErrBookUnavailable     = errors.New("book already has an active reservation")
+	ErrReservationConflict = errors.New("reservation has been modified by another request")
+)
+
+// The kinds of WriteError
+var (
+	ErrDuplicateKey = errors.New("a resource with the same ID or unique field already exists")
+	ErrUnavailable  = errors.New("the data store is unavailable. Please try again later")
 )
+
+// WriteError is the error of a write that the data store rejected, or that it could not complete in time.
+// Its Kind is ErrDuplicateKey or ErrUnavailable, and errors.Is matches both the Kind and
+// the underlying error of the database.
+type WriteError struct {
+	Kind error
+	Err  error
+}
+
+func (e *WriteError) Error() string {
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error of the database
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the Kind of the error
+func (e *WriteError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// writeError returns the error of a failed write as a WriteError if it is of a known kind, or wrapped with message otherwise
+func writeError(err error, message string) error {
+	switch {
+	case mongoDriver.IsDuplicateKeyError(err):
+		return &WriteError{Kind: ErrDuplicateKey, Err: err}
+	case mongoDriver.IsTimeout(err), mongoDriver.IsNetworkError(err):
+		return &WriteError{Kind: ErrUnavailable, Err: err}
+	default:
+		return errors.Wrap(err, message)
+	}
+}
@@ -0,0 +1,45 @@
+package mongo
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+	mongoDriver "go.mongodb.org/mongo-driver/mongo"
+	"testing"
+)
+
+func TestWriteError(t *testing.T) {
+	Convey("Given the errors that a write to MongoDB can fail with", t, func() {
+		duplicateKey := mongoDriver.WriteException{WriteErrors: []mongoDriver.WriteError{{Code: 11000, Message: "E11000 duplicate key error"}}}
+		unexpected := errors.New("unexpected error")
+
+		Convey("When a duplicate key error is classified", func() {
+			err := writeError(duplicateKey, "unexpected error when adding a book")
+
+			Convey("Then it is a duplicate key write error that wraps the error of the driver", func() {
+				So(errors.Is(err, ErrDuplicateKey), ShouldBeTrue)
+				So(errors.Is(err, ErrUnavailable), ShouldBeFalse)
+				So(errors.As(err, &mongoDriver.WriteException{}), ShouldBeTrue)
+			})
+		})
+
+		Convey("When a timeout is classified", func() {
+			err := writeError(errors.Wrap(context.DeadlineExceeded, "transaction"), "unexpected error when adding a book")
+
+			Convey("Then the data store is unavailable", func() {
+				So(errors.Is(err, ErrUnavailable), ShouldBeTrue)
+				So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+			})
+		})
+
+		Convey("When any other error is classified", func() {
+			err := writeError(unexpected, "unexpected error when adding a book")
+
+			Convey("Then it is not a write error, and is wrapped with the message", func() {
+				So(errors.As(err, new(*WriteError)), ShouldBeFalse)
+				So(errors.Is(err, unexpected), ShouldBeTrue)
+				So(err.Error(), ShouldEqual, "unexpected error when adding a book: unexpected error")
+			})
+		})
+	})
+}
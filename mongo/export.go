@@ -0,0 +1,79 @@
+package mongo
+
+import (
+	"context"
+	"github.com/ONSdigital/log.go/log"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/query"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// reviewsBookIndex finds the reviews of a book, which are listed by GetReviews and read for each book of an export
+var reviewsBookIndex = mongoDriver.IndexModel{
+	Keys:    bson.D{{Key: "book_id", Value: 1}},
+	Options: options.Index().SetName("reviews_book_id"),
+}
+
+// legacyReviewsBookIndex found the reviews of a book by the link to the book that they used to store
+var legacyReviewsBookIndex = mongoDriver.IndexModel{
+	Keys:    bson.D{{Key: "links.book", Value: 1}},
+	Options: options.Index().SetName("reviews_book"),
+}
+
+// ExportBooks calls export with every book, in the order of their IDs. The books are read with a cursor, so that
+// only one batch of books is held in memory at a time, however many books there are. The query timeout applies
+// to each read from MongoDB, rather than to the whole export.
+// If reviews is not nil, each book is exported with its reviews that match the filters of the reviews query.
+// It stops at the first error returned by export, and returns it.
+func (m *Mongo) ExportBooks(ctx context.Context, reviews *query.Query, export func(book *models.Book, reviews []models.Review) error) error {
+	logData := log.Data{
+		"reviews":    reviews,
+		"database":   m.Database,
+		"collection": m.BooksCollection}
+
+	var exportErr error
+	sortByID := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	err := m.iterate(ctx, m.collection(m.BooksCollection), bson.M{}, sortByID, func(cursor *mongoDriver.Cursor) error {
+		if err := ctx.Err(); err != nil {
+			exportErr = err
+			return err
+		}
+
+		var book models.Book
+		if err := cursor.Decode(&book); err != nil {
+			return err
+		}
+		updateAverages(&book)
+
+		var bookReviews []models.Review
+		if reviews != nil {
+			selector := querySelector(bson.M{"book_id": book.ID}, reviews)
+			bookReviews = []models.Review{}
+
+			reviewsCtx, cancel := m.withTimeout(ctx)
+			err := findAll(reviewsCtx, m.collection(m.ReviewsCollection), selector, &bookReviews, sortByID)
+			cancel()
+			if err != nil {
+				logData["book_id"] = book.ID
+				log.Event(ctx, "unable to retrieve the reviews of an exported book", log.ERROR, log.Error(err), logData)
+				exportErr = errors.Wrap(err, "unexpected error when exporting the reviews of a book")
+				return exportErr
+			}
+		}
+
+		exportErr = export(&book, bookReviews)
+		return exportErr
+	})
+
+	if exportErr != nil {
+		return exportErr
+	}
+	if err != nil {
+		log.Event(ctx, "unable to retrieve the exported books", log.ERROR, log.Error(err), logData)
+		return errors.Wrap(err, "unexpected error when exporting books")
+	}
+	return nil
+}
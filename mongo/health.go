@@ -0,0 +1,47 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/ONSdigital/log.go/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// HealthyMessage is the message of the health check when MongoDB is reachable and all the collections exist
+const HealthyMessage = "mongodb is OK and all expected collections exist"
+
+// Checker reports the state of MongoDB to the health check.
+// The state is CRITICAL when the primary cannot be reached, or when any of the collections is missing.
+func (m *Mongo) Checker(ctx context.Context, state *healthcheck.CheckState) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	logData := log.Data{"database": m.Database}
+
+	if err := m.Client.Ping(ctx, readpref.Primary()); err != nil {
+		log.Event(ctx, "unable to ping mongodb", log.ERROR, log.Error(err), logData)
+		state.Update(healthcheck.StatusCritical, "unable to connect with MongoDB", 0)
+		return nil
+	}
+
+	existing, err := m.Client.Database(m.Database).ListCollectionNames(ctx, bson.M{})
+	if err != nil {
+		log.Event(ctx, "unable to list the collections of the database", log.ERROR, log.Error(err), logData)
+		state.Update(healthcheck.StatusCritical, "unable to connect with MongoDB", 0)
+		return nil
+	}
+
+	for _, name := range m.collections() {
+		if !contains(existing, name) {
+			logData["collection"] = name
+			log.Event(ctx, "collection does not exist in the database", log.ERROR, logData)
+			state.Update(healthcheck.StatusCritical, fmt.Sprintf("collection %s not found in database", name), 0)
+			return nil
+		}
+	}
+
+	state.Update(healthcheck.StatusOK, HealthyMessage, 0)
+	return nil
+}
@@ -0,0 +1,92 @@
+package mongo
+
+import (
+	"context"
+	"github.com/ONSdigital/log.go/log"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// idempotencyExpiryIndex lets MongoDB remove the idempotency records once they have expired.
+// The records are removed in the background, about once a minute, so an expired record may still be found.
+var idempotencyExpiryIndex = mongoDriver.IndexModel{
+	Keys:    bson.D{{Key: "expires_at", Value: 1}},
+	Options: options.Index().SetName("idempotency_expiry").SetExpireAfterSeconds(0),
+}
+
+// ReserveIdempotencyKey stores the record of a request made with an idempotency key, unless the key is reserved.
+// The record is only matched if it has expired: otherwise, inserting it fails on its unique ID, and the record of the
+// request that reserved the key is returned. It returns nil if the key is reserved for this request.
+func (m *Mongo) ReserveIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	logData := log.Data{
+		"idempotency_record_id": record.ID,
+		"database":              m.Database,
+		"collection":            m.IdempotencyCollection}
+
+	filter := bson.M{"_id": record.ID, "expires_at": bson.M{"$lte": time.Now().UTC()}}
+	_, err := m.collection(m.IdempotencyCollection).ReplaceOne(ctx, filter, record, options.Replace().SetUpsert(true))
+	if err == nil {
+		return nil, nil
+	}
+	if !mongoDriver.IsDuplicateKeyError(err) {
+		log.Event(ctx, "unexpected error when reserving an idempotency key", log.ERROR, log.Error(err), logData)
+		return nil, writeError(err, "unexpected error when reserving an idempotency key")
+	}
+
+	var existing models.IdempotencyRecord
+	if err := m.collection(m.IdempotencyCollection).FindOne(ctx, bson.M{"_id": record.ID}).Decode(&existing); err != nil {
+		log.Event(ctx, "unexpected error when getting a reserved idempotency key", log.ERROR, log.Error(err), logData)
+		return nil, errors.Wrap(err, "unexpected error when getting a reserved idempotency key")
+	}
+
+	return &existing, nil
+}
+
+// CompleteIdempotencyKey records the response to the request that reserved an idempotency key, and when it expires
+func (m *Mongo) CompleteIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	logData := log.Data{
+		"idempotency_record_id": record.ID,
+		"database":              m.Database,
+		"collection":            m.IdempotencyCollection}
+
+	update := bson.M{"$set": bson.M{
+		"status":     record.Status,
+		"header":     record.Header,
+		"body":       record.Body,
+		"expires_at": record.ExpiresAt,
+	}}
+	if _, err := m.collection(m.IdempotencyCollection).UpdateOne(ctx, bson.M{"_id": record.ID}, update); err != nil {
+		log.Event(ctx, "unexpected error when completing an idempotency key", log.ERROR, log.Error(err), logData)
+		return writeError(err, "unexpected error when completing an idempotency key")
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey removes the record of a request made with an idempotency key, so that the key can be used again
+func (m *Mongo) ReleaseIdempotencyKey(ctx context.Context, id string) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	logData := log.Data{
+		"idempotency_record_id": id,
+		"database":              m.Database,
+		"collection":            m.IdempotencyCollection}
+
+	if _, err := m.collection(m.IdempotencyCollection).DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		log.Event(ctx, "unexpected error when releasing an idempotency key", log.ERROR, log.Error(err), logData)
+		return writeError(err, "unexpected error when releasing an idempotency key")
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package mongo
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	mongoDriver "go.mongodb.org/mongo-driver/mongo"
+)
+
+// Error codes returned by MongoDB when an index exists with the same name but different options or keys,
+// and when an index that is dropped does not exist
+const (
+	indexOptionsConflict  = 85
+	indexKeySpecsConflict = 86
+	indexNotFound         = 27
+)
+
+// ensureIndex creates an index if it does not exist yet, replacing an index of the same name that has different options.
+// The index must be named.
+func ensureIndex(ctx context.Context, collection *mongoDriver.Collection, index mongoDriver.IndexModel) error {
+	_, err := collection.Indexes().CreateOne(ctx, index)
+	if !isIndexConflict(err) {
+		return err
+	}
+
+	if _, err := collection.Indexes().DropOne(ctx, *index.Options.Name); err != nil {
+		return err
+	}
+	_, err = collection.Indexes().CreateOne(ctx, index)
+	return err
+}
+
+// dropIndex drops an index by name, if it exists
+func dropIndex(ctx context.Context, collection *mongoDriver.Collection, index mongoDriver.IndexModel) error {
+	_, err := collection.Indexes().DropOne(ctx, *index.Options.Name)
+	var commandErr mongoDriver.CommandError
+	if errors.As(err, &commandErr) && (commandErr.Code == indexNotFound || commandErr.Code == namespaceNotFound) {
+		return nil
+	}
+	return err
+}
+
+func isIndexConflict(err error) bool {
+	var commandErr mongoDriver.CommandError
+	if !errors.As(err, &commandErr) {
+		return false
+	}
+	return commandErr.Code == indexOptionsConflict || commandErr.Code == indexKeySpecsConflict
+}
@@ -0,0 +1,53 @@
+package mongo
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
+)
+
+// booksISBNIndex makes the ISBN of the books unique. It is sparse, as the ISBN is optional.
+var booksISBNIndex = mongoDriver.IndexModel{
+	Keys:    bson.D{{Key: "isbn", Value: 1}},
+	Options: options.Index().SetName("books_isbn").SetUnique(true).SetSparse(true),
+}
+
+// isDuplicateISBN returns true if a write failed because of the unique ISBN index.
+// The index guarantees that two books cannot have the same ISBN, even when they are written concurrently.
+func isDuplicateISBN(err error) bool {
+	return mongoDriver.IsDuplicateKeyError(err) && strings.Contains(err.Error(), *booksISBNIndex.Options.Name)
+}
+
+// existingISBNs returns the ISBNs of the books that are already stored, out of the ISBNs of the given books
+func (m *Mongo) existingISBNs(ctx context.Context, books []*models.Book) (map[string]bool, error) {
+	var isbns []string
+	for _, book := range books {
+		if book.ISBN != "" {
+			isbns = append(isbns, book.ISBN)
+		}
+	}
+
+	existing := make(map[string]bool)
+	if len(isbns) == 0 {
+		return existing, nil
+	}
+
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	var found []struct {
+		ISBN string `bson:"isbn"`
+	}
+	filter := bson.M{"isbn": bson.M{"$in": isbns}}
+	if err := findAll(ctx, m.collection(m.BooksCollection), filter, &found, options.Find().SetProjection(bson.M{"isbn": 1})); err != nil {
+		return nil, err
+	}
+
+	for _, book := range found {
+		existing[book.ISBN] = true
+	}
+	return existing, nil
+}
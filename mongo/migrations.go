@@ -0,0 +1,420 @@
+package mongo
+
+import (
+	"context"
+	"github.com/ONSdigital/log.go/log"
+	"github.com/cadmiumcat/books-api/migrations"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
+	"time"
+)
+
+// migrationLockID is the ID of the lock document that an instance holds while it migrates the database
+const migrationLockID = "migrations"
+
+// reservationsBookIndex finds the reservations of a book, in the order in which they were made
+var reservationsBookIndex = mongoDriver.IndexModel{
+	Keys:    bson.D{{Key: "book_id", Value: 1}, {Key: "reserved_at", Value: 1}},
+	Options: options.Index().SetName("reservations_book"),
+}
+
+// outboxOrderIndex finds the pending events of the outbox in the order in which they occurred
+var outboxOrderIndex = mongoDriver.IndexModel{
+	Keys:    bson.D{{Key: "occurred_at", Value: 1}, {Key: "_id", Value: 1}},
+	Options: options.Index().SetName("outbox_order"),
+}
+
+// outboxSequenceIndex finds the pending events of the outbox in the order in which they were written
+var outboxSequenceIndex = mongoDriver.IndexModel{
+	Keys:    bson.D{{Key: "sequence", Value: 1}},
+	Options: options.Index().SetName("outbox_sequence"),
+}
+
+// collectionIndex is an index of a collection
+type collectionIndex struct {
+	collection string
+	index      mongoDriver.IndexModel
+}
+
+// Migrations returns the migrations of the database, which create the collections and the indexes that the queries need,
+// and change the shape of the documents written by previous versions of the service.
+// A migration must never be changed once released: a new migration must be added instead.
+// The indexes are built without the query timeout, as they take as long as their collection is large.
+func (m *Mongo) Migrations() []migrations.Migration {
+	queryIndexes := []collectionIndex{
+		{m.BooksCollection, booksTextIndex},
+		{m.BooksCollection, booksISBNIndex},
+		{m.BooksCollection, booksAuthorsIndex},
+		{m.AuthorsCollection, authorsNameIndex},
+		{m.ReviewsCollection, legacyReviewsBookIndex},
+	}
+	pageIndexes := []collectionIndex{
+		{m.ReservationsCollection, reservationsBookIndex},
+		{m.OutboxCollection, outboxOrderIndex},
+	}
+	idempotencyIndexes := []collectionIndex{
+		{m.IdempotencyCollection, idempotencyExpiryIndex},
+	}
+	orderIndexes := []collectionIndex{
+		{m.OutboxCollection, outboxOrderIndex},
+	}
+	sequenceIndexes := []collectionIndex{
+		{m.OutboxCollection, outboxSequenceIndex},
+	}
+
+	return []migrations.Migration{
+		{
+			Version:     1,
+			Description: "create the collections, and the indexes of the books, authors and reviews",
+			Up: func(ctx context.Context) error {
+				if err := m.ensureCollections(ctx); err != nil {
+					return err
+				}
+				return m.ensureIndexes(ctx, queryIndexes)
+			},
+			Down: func(ctx context.Context) error {
+				return m.dropIndexes(ctx, queryIndexes)
+			},
+		},
+		{
+			Version:     2,
+			Description: "move the checkout history of the books into the reservations collection",
+			Up:          m.migrateHistory,
+		},
+		{
+			Version:     3,
+			Description: "approve the reviews added before reviews were moderated",
+			Up:          m.migrateReviewStates,
+		},
+		{
+			Version:     4,
+			Description: "turn the free-text authors of the books into authors that the books reference",
+			Up:          m.migrateAuthors,
+		},
+		{
+			Version:     5,
+			Description: "create the indexes of the reservations and of the outbox",
+			Up: func(ctx context.Context) error {
+				return m.ensureIndexes(ctx, pageIndexes)
+			},
+			Down: func(ctx context.Context) error {
+				return m.dropIndexes(ctx, pageIndexes)
+			},
+		},
+		{
+			Version:     6,
+			Description: "set the book_id of the reviews from their links, and stop storing the links of the resources",
+			Up:          m.migrateLinks,
+		},
+		{
+			Version:     7,
+			Description: "find the reviews of a book by book_id instead of by their link to the book",
+			Up: func(ctx context.Context) error {
+				if err := ensureIndex(ctx, m.collection(m.ReviewsCollection), reviewsBookIndex); err != nil {
+					return errors.Wrapf(err, "failed to create the %s index", *reviewsBookIndex.Options.Name)
+				}
+				return m.dropIndexes(ctx, []collectionIndex{{m.ReviewsCollection, legacyReviewsBookIndex}})
+			},
+			Down: func(ctx context.Context) error {
+				if err := ensureIndex(ctx, m.collection(m.ReviewsCollection), legacyReviewsBookIndex); err != nil {
+					return errors.Wrapf(err, "failed to create the %s index", *legacyReviewsBookIndex.Options.Name)
+				}
+				return m.dropIndexes(ctx, []collectionIndex{{m.ReviewsCollection, reviewsBookIndex}})
+			},
+		},
+		{
+			Version:     8,
+			Description: "start the revisions of the books and reviews, which are only updated at the revision that was read",
+			Up:          m.migrateRevisions,
+		},
+		{
+			Version:     9,
+			Description: "create the collection of the idempotency keys, whose records expire",
+			Up: func(ctx context.Context) error {
+				if err := m.ensureCollections(ctx); err != nil {
+					return err
+				}
+				return m.ensureIndexes(ctx, idempotencyIndexes)
+			},
+			Down: func(ctx context.Context) error {
+				return m.dropIndexes(ctx, idempotencyIndexes)
+			},
+		},
+		{
+			Version:     10,
+			Description: "number the events of the outbox, and relay them in the order of their numbers",
+			Up: func(ctx context.Context) error {
+				if err := m.ensureCollections(ctx); err != nil {
+					return err
+				}
+				if err := m.migrateOutboxSequence(ctx); err != nil {
+					return err
+				}
+				if err := m.ensureIndexes(ctx, sequenceIndexes); err != nil {
+					return err
+				}
+				return m.dropIndexes(ctx, orderIndexes)
+			},
+			Down: func(ctx context.Context) error {
+				if err := m.ensureIndexes(ctx, orderIndexes); err != nil {
+					return err
+				}
+				return m.dropIndexes(ctx, sequenceIndexes)
+			},
+		},
+	}
+}
+
+// migrateOutboxSequence numbers the events of the outbox written before they were numbered, in the order of their
+// timestamps, from the counter that numbers the new events. It is safe to run it more than once.
+func (m *Mongo) migrateOutboxSequence(ctx context.Context) error {
+	logData := log.Data{
+		"database":   m.Database,
+		"collection": m.OutboxCollection}
+
+	outbox := m.collection(m.OutboxCollection)
+
+	filter := bson.M{"sequence": bson.M{"$exists": false}}
+	findOptions := options.Find().SetSort(bson.D{{Key: "occurred_at", Value: 1}, {Key: "_id", Value: 1}}).SetProjection(bson.M{"_id": 1})
+	migrated := 0
+	err := m.iterate(ctx, outbox, filter, findOptions, func(cursor *mongoDriver.Cursor) error {
+		var event struct {
+			ID string `bson:"_id"`
+		}
+		if err := cursor.Decode(&event); err != nil {
+			return err
+		}
+
+		updateCtx, cancel := m.withTimeout(ctx)
+		defer cancel()
+		sequence, err := m.nextSequence(updateCtx, outboxSequence)
+		if err != nil {
+			return err
+		}
+		if _, err := outbox.UpdateOne(updateCtx, bson.M{"_id": event.ID}, bson.M{"$set": bson.M{"sequence": sequence}}); err != nil {
+			logData["event_id"] = event.ID
+			log.Event(ctx, "unexpected error when numbering an event", log.ERROR, log.Error(err), logData)
+			return err
+		}
+		migrated++
+		return nil
+	})
+	if err != nil {
+		log.Event(ctx, "unexpected error when iterating over the events without a number", log.ERROR, log.Error(err), logData)
+		return errors.Wrap(err, "unexpected error when numbering the events of the outbox")
+	}
+	logData["events_migrated"] = migrated
+
+	log.Event(ctx, "numbered the events of the outbox", log.INFO, logData)
+
+	return nil
+}
+
+// migrateRevisions sets the revision of the books and reviews stored before they had one, as their writes only match
+// the revision that was read. It is safe to run it more than once.
+func (m *Mongo) migrateRevisions(ctx context.Context) error {
+	logData := log.Data{"database": m.Database}
+
+	for _, name := range []string{m.BooksCollection, m.ReviewsCollection} {
+		updateCtx, cancel := m.withTimeout(ctx)
+		result, err := m.collection(name).UpdateMany(updateCtx, bson.M{"revision": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"revision": 0}})
+		cancel()
+		if err != nil {
+			logData["collection"] = name
+			log.Event(ctx, "unexpected error when starting the revisions", log.ERROR, log.Error(err), logData)
+			return errors.Wrap(err, "unexpected error when starting the revisions")
+		}
+		logData[name+"_migrated"] = result.ModifiedCount
+	}
+
+	log.Event(ctx, "started the revisions of the books and reviews", log.INFO, logData)
+
+	return nil
+}
+
+// migrateLinks sets the book_id of the reviews that only reference their book by a link, e.g. /books/{id}, and removes
+// the links stored by previous versions from the books, reviews, reservations and authors, as the links are now made
+// when the resources are served. It is safe to run it more than once.
+func (m *Mongo) migrateLinks(ctx context.Context) error {
+	logData := log.Data{
+		"database":           m.Database,
+		"reviews_collection": m.ReviewsCollection}
+
+	reviews := m.collection(m.ReviewsCollection)
+
+	filter := bson.M{"book_id": bson.M{"$in": bson.A{"", nil}}, "links.book": bson.M{"$exists": true}}
+	findOptions := options.Find().SetProjection(bson.M{"links.book": 1})
+	migrated := 0
+	err := m.iterate(ctx, reviews, filter, findOptions, func(cursor *mongoDriver.Cursor) error {
+		var review struct {
+			ID    string `bson:"_id"`
+			Links struct {
+				Book string `bson:"book"`
+			} `bson:"links"`
+		}
+		if err := cursor.Decode(&review); err != nil {
+			return err
+		}
+
+		bookID := strings.TrimPrefix(review.Links.Book, "/books/")
+
+		updateCtx, cancel := m.withTimeout(ctx)
+		defer cancel()
+		if _, err := reviews.UpdateOne(updateCtx, bson.M{"_id": review.ID}, bson.M{"$set": bson.M{"book_id": bookID}}); err != nil {
+			logData["review_id"] = review.ID
+			log.Event(ctx, "unexpected error when setting the book_id of a review", log.ERROR, log.Error(err), logData)
+			return err
+		}
+		migrated++
+		return nil
+	})
+	if err != nil {
+		log.Event(ctx, "unexpected error when iterating over reviews without book_id", log.ERROR, log.Error(err), logData)
+		return errors.Wrap(err, "unexpected error when migrating the book_id of the reviews")
+	}
+	logData["reviews_migrated"] = migrated
+
+	for _, name := range []string{m.BooksCollection, m.ReviewsCollection, m.ReservationsCollection, m.AuthorsCollection} {
+		updateCtx, cancel := m.withTimeout(ctx)
+		_, err := m.collection(name).UpdateMany(updateCtx, bson.M{"links": bson.M{"$exists": true}}, bson.M{"$unset": bson.M{"links": ""}})
+		cancel()
+		if err != nil {
+			logData["collection"] = name
+			log.Event(ctx, "unexpected error when removing the stored links", log.ERROR, log.Error(err), logData)
+			return errors.Wrap(err, "unexpected error when removing the stored links")
+		}
+	}
+
+	log.Event(ctx, "migrated the links of the resources", log.INFO, logData)
+
+	return nil
+}
+
+// ensureIndexes creates the indexes that do not exist yet, replacing those that exist with different options
+func (m *Mongo) ensureIndexes(ctx context.Context, indexes []collectionIndex) error {
+	for _, index := range indexes {
+		if err := ensureIndex(ctx, m.collection(index.collection), index.index); err != nil {
+			return errors.Wrapf(err, "failed to create the %s index", *index.index.Options.Name)
+		}
+	}
+	return nil
+}
+
+// dropIndexes drops the indexes that exist, in the reverse order of their creation
+func (m *Mongo) dropIndexes(ctx context.Context, indexes []collectionIndex) error {
+	for i := len(indexes) - 1; i >= 0; i-- {
+		if err := dropIndex(ctx, m.collection(indexes[i].collection), indexes[i].index); err != nil {
+			return errors.Wrapf(err, "failed to drop the %s index", *indexes[i].index.Options.Name)
+		}
+	}
+	return nil
+}
+
+// GetAppliedMigrations returns the migrations that have been applied to the database, in the order of their versions
+func (m *Mongo) GetAppliedMigrations(ctx context.Context) ([]models.AppliedMigration, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	logData := log.Data{
+		"database":   m.Database,
+		"collection": m.MigrationsCollection}
+
+	applied := []models.AppliedMigration{}
+	sortByVersion := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if err := findAll(ctx, m.collection(m.MigrationsCollection), bson.M{}, &applied, sortByVersion); err != nil {
+		log.Event(ctx, "unexpected error when retrieving the applied migrations", log.ERROR, log.Error(err), logData)
+		return nil, errors.Wrap(err, "unexpected error when retrieving the applied migrations")
+	}
+
+	return applied, nil
+}
+
+// AddAppliedMigration records a migration that has been applied to the database
+func (m *Mongo) AddAppliedMigration(ctx context.Context, migration *models.AppliedMigration) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	logData := log.Data{
+		"version":    migration.Version,
+		"database":   m.Database,
+		"collection": m.MigrationsCollection}
+
+	replaceOptions := options.Replace().SetUpsert(true)
+	if _, err := m.collection(m.MigrationsCollection).ReplaceOne(ctx, bson.M{"_id": migration.Version}, migration, replaceOptions); err != nil {
+		log.Event(ctx, "unexpected error when recording an applied migration", log.ERROR, log.Error(err), logData)
+		return errors.Wrap(err, "unexpected error when recording an applied migration")
+	}
+
+	return nil
+}
+
+// DeleteAppliedMigration removes the record of a migration that has been rolled back
+func (m *Mongo) DeleteAppliedMigration(ctx context.Context, version int) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	logData := log.Data{
+		"version":    version,
+		"database":   m.Database,
+		"collection": m.MigrationsCollection}
+
+	if _, err := m.collection(m.MigrationsCollection).DeleteOne(ctx, bson.M{"_id": version}); err != nil {
+		log.Event(ctx, "unexpected error when removing the record of a migration", log.ERROR, log.Error(err), logData)
+		return errors.Wrap(err, "unexpected error when removing the record of a migration")
+	}
+
+	return nil
+}
+
+// AcquireMigrationLock takes the migration lock for owner until ttl has passed, or extends it if owner already holds it.
+// The lock is a single document, which is only matched if owner holds it or if it has expired: otherwise, inserting
+// it fails on its unique ID, and migrations.ErrLocked is returned.
+func (m *Mongo) AcquireMigrationLock(ctx context.Context, owner string, ttl time.Duration) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	logData := log.Data{
+		"owner":      owner,
+		"database":   m.Database,
+		"collection": m.LocksCollection}
+
+	now := time.Now().UTC()
+	filter := bson.M{
+		"_id": migrationLockID,
+		"$or": bson.A{bson.M{"owner": owner}, bson.M{"expires_at": bson.M{"$lte": now}}},
+	}
+	update := bson.M{"$set": bson.M{"owner": owner, "expires_at": now.Add(ttl)}}
+
+	_, err := m.collection(m.LocksCollection).UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongoDriver.IsDuplicateKeyError(err) {
+		return migrations.ErrLocked
+	}
+	if err != nil {
+		log.Event(ctx, "unexpected error when acquiring the migration lock", log.ERROR, log.Error(err), logData)
+		return errors.Wrap(err, "unexpected error when acquiring the migration lock")
+	}
+
+	return nil
+}
+
+// ReleaseMigrationLock releases the migration lock, if owner holds it
+func (m *Mongo) ReleaseMigrationLock(ctx context.Context, owner string) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	logData := log.Data{
+		"owner":      owner,
+		"database":   m.Database,
+		"collection": m.LocksCollection}
+
+	if _, err := m.collection(m.LocksCollection).DeleteOne(ctx, bson.M{"_id": migrationLockID, "owner": owner}); err != nil {
+		log.Event(ctx, "unexpected error when releasing the migration lock", log.ERROR, log.Error(err), logData)
+		return errors.Wrap(err, "unexpected error when releasing the migration lock")
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package mongo
+
+import (
+	"context"
+	"github.com/ONSdigital/log.go/log"
+	"github.com/cadmiumcat/books-api/events"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDriver "go.mongodb.org/mongo-driver/mongo"
+)
+
+// UpdateReviewState stores the new moderation state of a Review, as long as it has not changed from previousState,
+// and stores a review-moderated event in the outbox in the same transaction. The rating of the Review starts or stops
+// counting in the rating summary of the Book, in the same transaction, when the Review is approved or stops being approved.
+// It returns an error if the Review is not found in previousState
+func (m *Mongo) UpdateReviewState(ctx context.Context, review *models.Review, previousState string) error {
+	logData := log.Data{
+		"review_id":      review.ID,
+		"state":          review.State,
+		"previous_state": previousState,
+		"database":       m.Database,
+		"collection":     m.ReviewsCollection}
+
+	previous := *review
+	previous.State = previousState
+
+	event, err := events.NewReviewModerated(review)
+	if err != nil {
+		log.Event(ctx, "unexpected error when creating a review-moderated event", log.ERROR, log.Error(err), logData)
+		return errors.Wrap(err, "unexpected error when moderating a review")
+	}
+
+	err = m.runTransaction(ctx, func(sc mongoDriver.SessionContext) error {
+		update := bson.M{"$set": bson.M{"state": review.State, "last_updated": review.LastUpdated}, "$inc": bson.M{"revision": 1}}
+		if err := updateExisting(sc, m.collection(m.ReviewsCollection), countedRatingFilter(&previous), update); err != nil {
+			return err
+		}
+		if err := m.insertEvent(sc, event); err != nil {
+			return err
+		}
+		return m.updateRatingSummary(sc, review.BookID, previous.CountedRating(), review.CountedRating())
+	})
+	if err != nil {
+		if err == errAborted {
+			return m.abortedReviewError(ctx, review.ID, err, logData)
+		}
+		log.Event(ctx, "unexpected error when moderating a review", log.ERROR, log.Error(err), logData)
+		return writeError(err, "unexpected error when moderating a review")
+	}
+
+	return nil
+}
+
+// migrateReviewStates approves the reviews that were added before reviews were moderated, as they were already shown
+// to readers and counted in the rating summaries of their books. It is safe to run it more than once.
+func (m *Mongo) migrateReviewStates(ctx context.Context) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	logData := log.Data{
+		"database":   m.Database,
+		"collection": m.ReviewsCollection}
+
+	filter := bson.M{"state": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"state": models.ReviewApproved}}
+	result, err := m.collection(m.ReviewsCollection).UpdateMany(ctx, filter, update)
+	if err != nil {
+		log.Event(ctx, "unexpected error when approving the reviews added before moderation", log.ERROR, log.Error(err), logData)
+		return errors.Wrap(err, "unexpected error when migrating review states")
+	}
+
+	logData["reviews_migrated"] = result.ModifiedCount
+	log.Event(ctx, "approved the reviews added before moderation", log.INFO, logData)
+
+	return nil
+}
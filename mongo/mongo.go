@@ -2,76 +2,172 @@ package mongo
 
 import (
 	"context"
-	"fmt"
-	dpMongodb "github.com/ONSdigital/dp-mongodb"
-	dpMongoLock "github.com/ONSdigital/dp-mongodb/dplock"
 	"github.com/ONSdigital/log.go/log"
 	"github.com/cadmiumcat/books-api/config"
+	"github.com/cadmiumcat/books-api/events"
 	"github.com/cadmiumcat/books-api/models"
-	"github.com/globalsign/mgo"
-	"github.com/globalsign/mgo/bson"
+	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/cadmiumcat/books-api/query"
 	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 	"time"
 )
 
-// Mongo contains the information needed to create and interact with a mongo session
+// Mongo contains the information needed to create and interact with a mongo client
 type Mongo struct {
-	BooksCollection   string
-	ReviewsCollection string
-	Database          string
-	Session           *mgo.Session
-	URI               string
-	lockClient        *dpMongoLock.Lock
+	BooksCollection        string
+	ReviewsCollection      string
+	ReservationsCollection string
+	AuthorsCollection      string
+	OutboxCollection       string
+	MigrationsCollection   string
+	LocksCollection        string
+	IdempotencyCollection  string
+	CountersCollection     string
+	Database               string
+	QueryTimeout           time.Duration
+	Client                 *mongoDriver.Client
 }
 
-// Init initialises a mongo session with the given configuration.
-// It returns an error if the session already exists or if it cannot connect.
+// Init connects a mongo client with the given configuration. The collections and indexes are created by the migrations.
+// It returns an error if the client already exists or if it cannot connect.
 func (m *Mongo) Init(mongoConfig config.MongoConfig) (err error) {
-	if m.Session != nil {
-		return errors.New("session already exists")
+	if m.Client != nil {
+		return errors.New("client already exists")
 	}
 
-	if m.Session, err = mgo.Dial(mongoConfig.BindAddr); err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoConfig.ConnectTimeout)
+	defer cancel()
+
+	client, err := mongoDriver.Connect(ctx, clientOptions(mongoConfig))
+	if err != nil {
 		return err
 	}
 
+	if err = client.Ping(ctx, readpref.Primary()); err != nil {
+		client.Disconnect(ctx)
+		return errors.Wrap(err, "failed to connect to mongodb")
+	}
+
+	m.Client = client
 	m.BooksCollection = mongoConfig.BooksCollection
 	m.ReviewsCollection = mongoConfig.ReviewsCollection
+	m.ReservationsCollection = mongoConfig.ReservationsCollection
+	m.AuthorsCollection = mongoConfig.AuthorsCollection
+	m.OutboxCollection = mongoConfig.OutboxCollection
+	m.MigrationsCollection = mongoConfig.MigrationsCollection
+	m.LocksCollection = mongoConfig.LocksCollection
+	m.IdempotencyCollection = mongoConfig.IdempotencyCollection
+	m.CountersCollection = mongoConfig.CountersCollection
 	m.Database = mongoConfig.Database
+	m.QueryTimeout = mongoConfig.QueryTimeout
 
 	return nil
 }
 
-// Close closes the mongo session and returns any error
-func (m *Mongo) Close(ctx context.Context) (err error) {
-	m.lockClient.Close(ctx)
-	return dpMongodb.Close(ctx, m.Session)
+// Close disconnects the mongo client, once the operations in progress have completed, and returns any error
+func (m *Mongo) Close(ctx context.Context) error {
+	if m.Client == nil {
+		return nil
+	}
+	return m.Client.Disconnect(ctx)
 }
 
-// AddBook adds a Book
+// AddBook adds a Book, and stores a book-created event in the outbox in the same transaction.
+// It returns an error if another Book has the same ISBN
 func (m *Mongo) AddBook(ctx context.Context, book *models.Book) error {
-	session := m.Session.Copy()
-	defer session.Close()
-
 	logData := log.Data{
 		"book": book,
 	}
 
-	collection := session.DB(m.Database).C(m.BooksCollection)
-	err := collection.Insert(book)
+	event, err := events.NewBookCreated(book)
 	if err != nil {
-		log.Event(ctx, "unexpected error when adding a book", log.ERROR, log.Error(err), logData)
+		log.Event(ctx, "unexpected error when creating a book-created event", log.ERROR, log.Error(err), logData)
 		return errors.Wrap(err, "unexpected error when adding a book")
 	}
 
+	err = m.runTransaction(ctx, func(sc mongoDriver.SessionContext) error {
+		if _, err := m.collection(m.BooksCollection).InsertOne(sc, book); err != nil {
+			return err
+		}
+		return m.insertEvent(sc, event)
+	})
+	if err != nil {
+		if isDuplicateISBN(err) {
+			log.Event(ctx, ErrDuplicateISBN.Error(), log.ERROR, log.Error(err), logData)
+			return ErrDuplicateISBN
+		}
+		log.Event(ctx, "unexpected error when adding a book", log.ERROR, log.Error(err), logData)
+		return writeError(err, "unexpected error when adding a book")
+	}
+
 	return nil
 }
 
+// AddBooks adds a batch of Books, and stores their book-created events in the outbox in the same transaction.
+// A Book with the ISBN of an existing Book, or of a previous Book of the batch, is not added.
+// It returns the error of each Book, which is nil if the Book is added and ErrDuplicateISBN if it is not,
+// or an error if the batch cannot be added, in which case none of its Books are.
+func (m *Mongo) AddBooks(ctx context.Context, books []*models.Book) ([]error, error) {
+	logData := log.Data{
+		"books":      len(books),
+		"database":   m.Database,
+		"collection": m.BooksCollection}
+
+	existing, err := m.existingISBNs(ctx, books)
+	if err != nil {
+		log.Event(ctx, "unexpected error when checking the ISBNs of a batch of books", log.ERROR, log.Error(err), logData)
+		return nil, errors.Wrap(err, "unexpected error when adding books")
+	}
+
+	results := make([]error, len(books))
+	var added, createdEvents []interface{}
+	for i, book := range books {
+		if book.ISBN != "" {
+			if existing[book.ISBN] {
+				results[i] = ErrDuplicateISBN
+				continue
+			}
+			existing[book.ISBN] = true
+		}
+
+		event, err := events.NewBookCreated(book)
+		if err != nil {
+			log.Event(ctx, "unexpected error when creating a book-created event", log.ERROR, log.Error(err), logData)
+			return nil, errors.Wrap(err, "unexpected error when adding books")
+		}
+
+		added = append(added, book)
+		createdEvents = append(createdEvents, event)
+	}
+
+	if len(added) == 0 {
+		return results, nil
+	}
+
+	err = m.runTransaction(ctx, func(sc mongoDriver.SessionContext) error {
+		if _, err := m.collection(m.BooksCollection).InsertMany(sc, added); err != nil {
+			return err
+		}
+		_, err := m.collection(m.OutboxCollection).InsertMany(sc, createdEvents)
+		return err
+	})
+	if err != nil {
+		log.Event(ctx, "unexpected error when adding a batch of books", log.ERROR, log.Error(err), logData)
+		return nil, writeError(err, "unexpected error when adding books")
+	}
+
+	return results, nil
+}
+
 // GetBook returns a models.Book for a given ID.
 // It returns an error if the Book is not found
 func (m *Mongo) GetBook(ctx context.Context, ID string) (*models.Book, error) {
-	session := m.Session.Copy()
-	defer session.Close()
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
 
 	logData := log.Data{
 		"book_id":    ID,
@@ -79,135 +175,493 @@ func (m *Mongo) GetBook(ctx context.Context, ID string) (*models.Book, error) {
 		"collection": m.BooksCollection}
 
 	var book models.Book
-	err := session.DB(m.Database).C(m.BooksCollection).Find(bson.M{"_id": ID}).One(&book)
+	err := m.collection(m.BooksCollection).FindOne(ctx, bson.M{"_id": ID}).Decode(&book)
 
 	if err != nil {
-		if err == mgo.ErrNotFound {
+		if err == mongoDriver.ErrNoDocuments {
 			log.Event(ctx, ErrBookNotFound.Error(), log.ERROR, log.Error(err), logData)
 			return nil, ErrBookNotFound
 		}
 		log.Event(ctx, "unexpected error when getting a book", log.ERROR, log.Error(err), logData)
 		return nil, errors.Wrap(err, "unexpected error when getting a book")
 	}
+	updateAverages(&book)
 
 	return &book, nil
 }
 
-// GetBooks returns all the existing []models.Book.
+// GetBooks returns the existing []models.Book that match the filters of the query, sorted as requested by the query.
+// The page starts at the cursor if one is provided, and at the offset otherwise.
 // It returns an error if the []models.Book cannot be listed.
-func (m *Mongo) GetBooks(ctx context.Context, offset, limit int) ([]models.Book, int, error) {
-
-	session := m.Session.Copy()
-	defer session.Close()
+func (m *Mongo) GetBooks(ctx context.Context, q *query.Query, cursor *pagination.Cursor, offset, limit int) ([]models.Book, int, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
 
 	logData := log.Data{
+		"query":      q,
+		"cursor":     cursor,
 		"database":   m.Database,
 		"collection": m.BooksCollection}
 
-	list := session.DB(m.Database).C(m.BooksCollection).Find(nil)
+	collection := m.collection(m.BooksCollection)
+	selector := querySelector(nil, q)
 	var books []models.Book
 
-	totalCount, err := list.Count()
+	totalCount, err := collection.CountDocuments(ctx, selector)
 	if err != nil {
-		if err == mgo.ErrNotFound {
-			log.Event(ctx, "no book resources found", log.WARN, log.Error(err))
-			return []models.Book{}, totalCount, nil
-		}
-		log.Event(ctx, "failure to retrieve list of books", log.ERROR, log.Error(err))
-		return nil, totalCount, err
+		log.Event(ctx, "failure to retrieve list of books", log.ERROR, log.Error(err), logData)
+		return nil, 0, errors.Wrap(err, "unexpected error when getting books")
 	}
 
 	if limit > 0 {
-		iter := list.Skip(offset).Limit(limit).Iter()
+		filter, findOptions := pageQuery(selector, q, cursor, offset, limit)
+		if err := findAll(ctx, collection, filter, &books, findOptions); err != nil {
+			log.Event(ctx, "unable to retrieve books", log.ERROR, log.Error(err), logData)
+			return []models.Book{}, int(totalCount), errors.Wrap(err, "unexpected error when getting books")
+		}
+		reversePage(cursor, &books)
+		for i := range books {
+			updateAverages(&books[i])
+		}
+	}
+
+	return books, int(totalCount), nil
+}
+
+// UpdateBook replaces the title, author and synopsis of an existing Book, as long as it is still at the revision of book,
+// and stores a book-updated event in the outbox in the same transaction. The revision of the Book is incremented.
+// It returns an error if the Book is not found, if it has been changed since that revision, or if another Book has
+// the same ISBN
+func (m *Mongo) UpdateBook(ctx context.Context, ID string, book *models.Book) error {
+	logData := log.Data{
+		"book_id":    ID,
+		"revision":   book.Revision,
+		"database":   m.Database,
+		"collection": m.BooksCollection}
+
+	update := bookUpdate(book)
+	update["$inc"] = bson.M{"revision": 1}
+
+	event, err := events.NewBookUpdated(book)
+	if err != nil {
+		log.Event(ctx, "unexpected error when creating a book-updated event", log.ERROR, log.Error(err), logData)
+		return errors.Wrap(err, "unexpected error when updating a book")
+	}
+
+	err = m.runTransaction(ctx, func(sc mongoDriver.SessionContext) error {
+		if err := updateExisting(sc, m.collection(m.BooksCollection), bson.M{"_id": ID, "revision": book.Revision}, update); err != nil {
+			return err
+		}
+		return m.insertEvent(sc, event)
+	})
+	if err != nil {
+		if err == errAborted {
+			return m.abortedBookError(ctx, ID, err, logData)
+		}
+		if isDuplicateISBN(err) {
+			log.Event(ctx, ErrDuplicateISBN.Error(), log.ERROR, log.Error(err), logData)
+			return ErrDuplicateISBN
+		}
+		log.Event(ctx, "unexpected error when updating a book", log.ERROR, log.Error(err), logData)
+		return writeError(err, "unexpected error when updating a book")
+	}
+
+	return nil
+}
+
+// bookUpdate returns the update that replaces the title, authors and metadata of a book.
+// Optional fields that are empty are removed, as they are omitted when a book is inserted.
+func bookUpdate(book *models.Book) bson.M {
+	sets := bson.M{
+		"title":  book.Title,
+		"author": book.Author,
+	}
+	unsets := bson.M{}
+
+	optional := bson.M{
+		"synopsis":         book.Synopsis,
+		"isbn":             book.ISBN,
+		"publisher":        book.Publisher,
+		"publication_year": book.PublicationYear,
+		"page_count":       book.PageCount,
+		"language":         book.Language,
+		"edition":          book.Edition,
+		"genres":           book.Genres,
+		"authors":          book.Authors,
+	}
+	for field, value := range optional {
+		switch v := value.(type) {
+		case string:
+			if v == "" {
+				unsets[field] = ""
+				continue
+			}
+		case int:
+			if v == 0 {
+				unsets[field] = ""
+				continue
+			}
+		case []string:
+			if len(v) == 0 {
+				unsets[field] = ""
+				continue
+			}
+		case []models.Contributor:
+			if len(v) == 0 {
+				unsets[field] = ""
+				continue
+			}
+		}
+		sets[field] = value
+	}
+
+	update := bson.M{"$set": sets}
+	if len(unsets) > 0 {
+		update["$unset"] = unsets
+	}
+	return update
+}
+
+// PatchBook applies a JSON merge patch to an existing Book, as long as it is still at the given revision, and stores
+// a book-updated event in the outbox in the same transaction. Fields with a nil value in the patch are removed from
+// the Book, and the revision of the Book is incremented.
+// It returns an error if the Book is not found, if it has been changed since that revision, or if the patch gives
+// the Book the ISBN of another Book
+func (m *Mongo) PatchBook(ctx context.Context, ID string, revision int, patch map[string]interface{}) error {
+	logData := log.Data{
+		"book_id":    ID,
+		"revision":   revision,
+		"patch":      patch,
+		"database":   m.Database,
+		"collection": m.BooksCollection}
+
+	sets := make(bson.M)
+	unsets := make(bson.M)
+	for field, value := range patch {
+		if value == nil {
+			unsets[field] = ""
+			continue
+		}
+		sets[field] = value
+	}
+
+	update := make(bson.M)
+	if len(sets) > 0 {
+		update["$set"] = sets
+	}
+	if len(unsets) > 0 {
+		update["$unset"] = unsets
+	}
+
+	if len(update) == 0 {
+		return nil
+	}
+	update["$inc"] = bson.M{"revision": 1}
 
-		defer func() {
-			err := iter.Close()
+	// The event describes the whole book after the patch has been applied
+	var book models.Book
+	findCtx, cancel := m.withTimeout(ctx)
+	err := m.collection(m.BooksCollection).FindOne(findCtx, bson.M{"_id": ID}).Decode(&book)
+	cancel()
+	if err != nil {
+		if err == mongoDriver.ErrNoDocuments {
+			log.Event(ctx, ErrBookNotFound.Error(), log.ERROR, log.Error(err), logData)
+			return ErrBookNotFound
+		}
+		log.Event(ctx, "unexpected error when getting the book to patch", log.ERROR, log.Error(err), logData)
+		return writeError(err, "unexpected error when patching a book")
+	}
+	if book.Revision != revision {
+		log.Event(ctx, ErrBookConflict.Error(), log.ERROR, logData)
+		return ErrBookConflict
+	}
+
+	patched, err := book.ApplyMergePatch(patch)
+	if err != nil {
+		log.Event(ctx, "invalid patch", log.ERROR, log.Error(err), logData)
+		return err
+	}
+
+	event, err := events.NewBookUpdated(patched)
+	if err != nil {
+		log.Event(ctx, "unexpected error when creating a book-updated event", log.ERROR, log.Error(err), logData)
+		return errors.Wrap(err, "unexpected error when patching a book")
+	}
+
+	err = m.runTransaction(ctx, func(sc mongoDriver.SessionContext) error {
+		if err := updateExisting(sc, m.collection(m.BooksCollection), bson.M{"_id": ID, "revision": revision}, update); err != nil {
+			return err
+		}
+		return m.insertEvent(sc, event)
+	})
+	if err != nil {
+		if err == errAborted {
+			return m.abortedBookError(ctx, ID, err, logData)
+		}
+		if isDuplicateISBN(err) {
+			log.Event(ctx, ErrDuplicateISBN.Error(), log.ERROR, log.Error(err), logData)
+			return ErrDuplicateISBN
+		}
+		log.Event(ctx, "unexpected error when patching a book", log.ERROR, log.Error(err), logData)
+		return writeError(err, "unexpected error when patching a book")
+	}
+
+	return nil
+}
+
+// DeleteBook removes a Book, as long as it is still at the given revision.
+// If cascadeReviews is true, the reviews of the Book are removed as well. Otherwise, a Book with reviews is not removed.
+// The reviews are counted or removed in the same transaction as the Book, which conflicts with any review being added
+// to the Book meanwhile, so that no review is left without its Book. A book-deleted event, together with a review-deleted
+// event for every review removed with the Book, is stored in the outbox in the same transaction.
+// It returns an error if the Book is not found, if it has been changed since that revision, or if it has reviews and
+// cascadeReviews is false
+func (m *Mongo) DeleteBook(ctx context.Context, ID string, revision int, cascadeReviews bool) error {
+	logData := log.Data{
+		"book_id":         ID,
+		"revision":        revision,
+		"cascade_reviews": cascadeReviews,
+		"database":        m.Database}
+
+	reviews := m.collection(m.ReviewsCollection)
+	reviewsFilter := bson.M{"book_id": ID}
+
+	var reviewsRemoved int64
+	err := m.runTransaction(ctx, func(sc mongoDriver.SessionContext) error {
+		if !cascadeReviews {
+			count, err := reviews.CountDocuments(sc, reviewsFilter)
 			if err != nil {
-				log.Event(ctx, "error closing iterator", log.ERROR, log.Error(err), logData)
+				return err
+			}
+			if count > 0 {
+				logData["reviews"] = count
+				return ErrBookHasReviews
 			}
-		}()
+		}
 
-		if err := iter.All(&books); err != nil {
-			if err == mgo.ErrNotFound {
-				return []models.Book{}, totalCount, nil
+		if err := deleteExisting(sc, m.collection(m.BooksCollection), bson.M{"_id": ID, "revision": revision}); err != nil {
+			return err
+		}
+
+		if cascadeReviews {
+			var removed []models.Review
+			cursor, err := reviews.Find(sc, reviewsFilter, options.Find().SetSort(bson.M{"_id": 1}))
+			if err != nil {
+				return err
 			}
-			log.Event(ctx, "unable to retrieve books", log.ERROR, log.Error(err), logData)
-			return []models.Book{}, totalCount, errors.Wrap(err, "unexpected error when getting books")
+			if err := cursor.All(sc, &removed); err != nil {
+				return err
+			}
+			for i := range removed {
+				event, err := events.NewReviewDeleted(&removed[i])
+				if err != nil {
+					return err
+				}
+				if err := m.insertEvent(sc, event); err != nil {
+					return err
+				}
+			}
+
+			result, err := reviews.DeleteMany(sc, reviewsFilter)
+			if err != nil {
+				return err
+			}
+			reviewsRemoved = result.DeletedCount
+		}
+
+		event, err := events.NewBookDeleted(ID)
+		if err != nil {
+			return err
 		}
+		return m.insertEvent(sc, event)
+	})
+	if err != nil {
+		switch err {
+		case ErrBookHasReviews:
+			log.Event(ctx, ErrBookHasReviews.Error(), log.ERROR, logData)
+			return ErrBookHasReviews
+		case errAborted:
+			return m.abortedBookError(ctx, ID, err, logData)
+		}
+		log.Event(ctx, "unexpected error when deleting a book", log.ERROR, log.Error(err), logData)
+		return writeError(err, "unexpected error when deleting a book")
 	}
 
-	return books, totalCount, nil
+	if cascadeReviews {
+		logData["reviews_removed"] = reviewsRemoved
+		log.Event(ctx, "removed the reviews of a deleted book", log.INFO, logData)
+	}
+
+	return nil
 }
 
-// AddReview adds a Review to a Book
+// AddReview adds a Review to a Book, and stores a review-added event in the outbox in the same transaction.
+// The rating of an approved Review is counted in the rating summary of the Book in the same transaction.
+// It returns an error if the Book is not found, or if it is deleted while the Review is added
 func (m *Mongo) AddReview(ctx context.Context, review *models.Review) error {
-	session := m.Session.Copy()
-	defer session.Close()
-
 	logData := log.Data{
 		"review": review,
 	}
 
-	collection := session.DB(m.Database).C(m.ReviewsCollection)
-	err := collection.Insert(review)
+	event, err := events.NewReviewAdded(review)
+	if err != nil {
+		log.Event(ctx, "unexpected error when creating a review-added event", log.ERROR, log.Error(err), logData)
+		return errors.Wrap(err, "unexpected error when adding a review")
+	}
 
+	err = m.runTransaction(ctx, func(sc mongoDriver.SessionContext) error {
+		if err := m.lockBook(sc, review.BookID); err != nil {
+			return err
+		}
+		if _, err := m.collection(m.ReviewsCollection).InsertOne(sc, review); err != nil {
+			return err
+		}
+		if err := m.insertEvent(sc, event); err != nil {
+			return err
+		}
+		return m.updateRatingSummary(sc, review.BookID, models.NoRating, review.CountedRating())
+	})
 	if err != nil {
-		log.Event(ctx, "unexpected error when adding a book", log.ERROR, log.Error(err), logData)
-		return errors.Wrap(err, "unexpected error when adding a book")
+		if err == errAborted {
+			log.Event(ctx, ErrBookNotFound.Error(), log.ERROR, log.Error(err), logData)
+			return ErrBookNotFound
+		}
+		log.Event(ctx, "unexpected error when adding a review", log.ERROR, log.Error(err), logData)
+		return writeError(err, "unexpected error when adding a review")
 	}
 
 	return nil
 }
 
-// UpdateReview updates an existing Review.
-// Only the message and user can be updated.
-// It returns an error if the review is not found
-func (m *Mongo) UpdateReview(ctx context.Context, reviewID string, review *models.Review) error {
-	s := m.Session.Copy()
-	defer s.Close()
-
+// UpdateReview replaces the message, user and rating of a Review, as long as it is still as previous was read, and
+// stores a review-updated event in the outbox in the same transaction. A Review without a rating loses its rating.
+// An updated review goes back to pending, and has to be approved again by a moderator: its rating stops counting
+// in the rating summary of the Book in the same transaction. The revision of the Review is incremented.
+// The Review is found and modified at once, and returned as it is after the update.
+// It returns an error if the review is not found, or if it has been changed by another request
+func (m *Mongo) UpdateReview(ctx context.Context, review *models.Review, previous *models.Review) (*models.Review, error) {
 	logData := log.Data{
-		"review_id":  reviewID,
+		"review_id":  previous.ID,
+		"revision":   previous.Revision,
 		"database":   m.Database,
 		"collection": m.ReviewsCollection}
 
-	updates := make(bson.M)
-	if review.Message != "" {
-		updates["message"] = review.Message
+	set := bson.M{
+		"message":      review.Message,
+		"user":         review.User,
+		"state":        models.ReviewPending,
+		"last_updated": time.Now().UTC(),
+	}
+	update := bson.M{"$set": set, "$inc": bson.M{"revision": 1}}
+	if review.Rating == models.NoRating {
+		update["$unset"] = bson.M{"rating": ""}
+	} else {
+		set["rating"] = review.Rating
 	}
 
-	if review.User != (models.User{}) {
-		if review.User.Forenames != "" {
-			updates["user.forenames"] = review.User.Forenames
+	updated := &models.Review{}
+	findOptions := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := m.runTransaction(ctx, func(sc mongoDriver.SessionContext) error {
+		err := m.collection(m.ReviewsCollection).FindOneAndUpdate(sc, countedRatingFilter(previous), update, findOptions).Decode(updated)
+		if err == mongoDriver.ErrNoDocuments {
+			return errAborted
 		}
-		if review.User.Surname != "" {
-			updates["user.surname"] = review.User.Surname
+		if err != nil {
+			return err
 		}
-	}
 
-	if len(updates) == 0 {
-		return nil
+		// The event describes the whole review after the update has been applied
+		event, err := events.NewReviewUpdated(updated)
+		if err != nil {
+			return errors.Wrap(err, "failed to create a review-updated event")
+		}
+		if err := m.insertEvent(sc, event); err != nil {
+			return err
+		}
+		return m.updateRatingSummary(sc, updated.BookID, previous.CountedRating(), updated.CountedRating())
+	})
+	if err != nil {
+		if err == errAborted {
+			return nil, m.abortedReviewError(ctx, previous.ID, err, logData)
+		}
+		log.Event(ctx, "unexpected error when updating a review", log.ERROR, log.Error(err), logData)
+		return nil, writeError(err, "unexpected error when updating a review")
 	}
 
-	updates["last_updated"] = time.Now().UTC()
+	return updated, nil
+}
 
-	update := bson.M{"$set": updates}
-	if err := s.DB(m.Database).C(m.ReviewsCollection).UpdateId(reviewID, update); err != nil {
-		if err == mgo.ErrNotFound {
-			log.Event(ctx, ErrReviewNotFound.Error(), log.ERROR, log.Error(err), logData)
-			return ErrReviewNotFound
+// DeleteReview removes a Review, and stores a review-deleted event in the outbox in the same transaction.
+// The rating of an approved Review stops counting in the rating summary of the Book in the same transaction.
+// It returns an error if the review is not found, or if it has been changed by another request since it was read
+func (m *Mongo) DeleteReview(ctx context.Context, review *models.Review) error {
+	logData := log.Data{
+		"review_id":  review.ID,
+		"book_id":    review.BookID,
+		"database":   m.Database,
+		"collection": m.ReviewsCollection}
+
+	event, err := events.NewReviewDeleted(review)
+	if err != nil {
+		log.Event(ctx, "unexpected error when creating a review-deleted event", log.ERROR, log.Error(err), logData)
+		return errors.Wrap(err, "unexpected error when deleting a review")
+	}
+
+	err = m.runTransaction(ctx, func(sc mongoDriver.SessionContext) error {
+		if err := deleteExisting(sc, m.collection(m.ReviewsCollection), countedRatingFilter(review)); err != nil {
+			return err
 		}
-		return err
+		if err := m.insertEvent(sc, event); err != nil {
+			return err
+		}
+		return m.updateRatingSummary(sc, review.BookID, review.CountedRating(), models.NoRating)
+	})
+	if err != nil {
+		if err == errAborted {
+			return m.abortedReviewError(ctx, review.ID, err, logData)
+		}
+		log.Event(ctx, "unexpected error when deleting a review", log.ERROR, log.Error(err), logData)
+		return writeError(err, "unexpected error when deleting a review")
 	}
 
 	return nil
 }
 
+// abortedBookError returns the error of a write on a book that has not been applied because the book has been removed,
+// or because it has been changed since the revision that was read
+func (m *Mongo) abortedBookError(ctx context.Context, ID string, err error, logData log.Data) error {
+	countCtx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	count, countErr := m.collection(m.BooksCollection).CountDocuments(countCtx, bson.M{"_id": ID})
+	if countErr == nil && count == 0 {
+		log.Event(ctx, ErrBookNotFound.Error(), log.ERROR, log.Error(err), logData)
+		return ErrBookNotFound
+	}
+	log.Event(ctx, ErrBookConflict.Error(), log.ERROR, log.Error(err), logData)
+	return ErrBookConflict
+}
+
+// abortedReviewError returns the error of a transaction on a review that has been aborted because the review
+// has been removed, or because its rating or state has been changed, since it was read
+func (m *Mongo) abortedReviewError(ctx context.Context, reviewID string, err error, logData log.Data) error {
+	countCtx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	count, countErr := m.collection(m.ReviewsCollection).CountDocuments(countCtx, bson.M{"_id": reviewID})
+	if countErr == nil && count == 0 {
+		log.Event(ctx, ErrReviewNotFound.Error(), log.ERROR, log.Error(err), logData)
+		return ErrReviewNotFound
+	}
+	log.Event(ctx, ErrReviewConflict.Error(), log.ERROR, log.Error(err), logData)
+	return ErrReviewConflict
+}
+
 // GetReview returns a models.Review for a given reviewID.
 // It returns an error if the review is not found.
 func (m *Mongo) GetReview(ctx context.Context, reviewID string) (*models.Review, error) {
-	session := m.Session.Copy()
-	defer session.Close()
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
 
 	logData := log.Data{
 		"review_id":  reviewID,
@@ -215,10 +669,10 @@ func (m *Mongo) GetReview(ctx context.Context, reviewID string) (*models.Review,
 		"collection": m.ReviewsCollection}
 
 	var review models.Review
-	err := session.DB(m.Database).C(m.ReviewsCollection).Find(bson.M{"_id": reviewID}).One(&review)
+	err := m.collection(m.ReviewsCollection).FindOne(ctx, bson.M{"_id": reviewID}).Decode(&review)
 
 	if err != nil {
-		if err == mgo.ErrNotFound {
+		if err == mongoDriver.ErrNoDocuments {
 			log.Event(ctx, ErrReviewNotFound.Error(), log.ERROR, log.Error(err), logData)
 			return nil, ErrReviewNotFound
 		}
@@ -228,48 +682,38 @@ func (m *Mongo) GetReview(ctx context.Context, reviewID string) (*models.Review,
 	return &review, nil
 }
 
-// GetReviews returns all the existing models.Reviews.
-// It returns an error if the models.Reviews cannot be listed.
-func (m *Mongo) GetReviews(ctx context.Context, bookID string, offset, limit int) ([]models.Review, int, error) {
-
-	session := m.Session.Copy()
-	defer session.Close()
+// GetReviews returns the reviews of a book that match the filters of the query, sorted as requested by the query.
+// The page starts at the cursor if one is provided, and at the offset otherwise.
+// It returns an error if the reviews cannot be listed.
+func (m *Mongo) GetReviews(ctx context.Context, bookID string, q *query.Query, cursor *pagination.Cursor, offset, limit int) ([]models.Review, int, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
 
 	logData := log.Data{
+		"book_id":    bookID,
+		"query":      q,
+		"cursor":     cursor,
 		"database":   m.Database,
 		"collection": m.ReviewsCollection}
 
-	list := session.DB(m.Database).C(m.ReviewsCollection).Find(bson.M{"links.book": fmt.Sprintf("/books/%s", bookID)})
+	collection := m.collection(m.ReviewsCollection)
+	selector := querySelector(bson.M{"book_id": bookID}, q)
 	var reviews []models.Review
 
-	totalCount, err := list.Count()
+	totalCount, err := collection.CountDocuments(ctx, selector)
 	if err != nil {
-		if err == mgo.ErrNotFound {
-			log.Event(ctx, "no reviews resources found for the book", log.WARN, log.Error(err))
-			return []models.Review{}, totalCount, nil
-		}
-		log.Event(ctx, "failure to retrieve list of reviews", log.ERROR, log.Error(err))
-		return nil, totalCount, err
+		log.Event(ctx, "failure to retrieve list of reviews", log.ERROR, log.Error(err), logData)
+		return nil, 0, errors.Wrap(err, "unexpected error when getting reviews")
 	}
 
 	if limit > 0 {
-		iter := list.Skip(offset).Limit(limit).Iter()
-
-		defer func() {
-			err := iter.Close()
-			if err != nil {
-				log.Event(ctx, "error closing iterator", log.ERROR, log.Error(err), logData)
-			}
-		}()
-
-		if err := iter.All(&reviews); err != nil {
-			if err == mgo.ErrNotFound {
-				return []models.Review{}, totalCount, nil
-			}
+		filter, findOptions := pageQuery(selector, q, cursor, offset, limit)
+		if err := findAll(ctx, collection, filter, &reviews, findOptions); err != nil {
 			log.Event(ctx, "unable to retrieve reviews", log.ERROR, log.Error(err), logData)
-			return []models.Review{}, totalCount, errors.Wrap(err, "unexpected error when getting reviews")
+			return []models.Review{}, int(totalCount), errors.Wrap(err, "unexpected error when getting reviews")
 		}
+		reversePage(cursor, &reviews)
 	}
 
-	return reviews, totalCount, nil
+	return reviews, int(totalCount), nil
 }
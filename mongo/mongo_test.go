@@ -0,0 +1,185 @@
+package mongo_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/cadmiumcat/books-api/config"
+	"github.com/cadmiumcat/books-api/interfaces"
+	"github.com/cadmiumcat/books-api/migrations"
+	"github.com/cadmiumcat/books-api/mongo"
+	"github.com/cadmiumcat/books-api/storetest"
+	uuid "github.com/satori/go.uuid"
+	. "github.com/smartystreets/goconvey/convey"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMongo runs the data store conformance suite against the MongoDB at MONGODB_TEST_BIND_ADDR, e.g. localhost:27017,
+// which must be a replica set. Every test uses a new database, which is dropped when the test finishes.
+// It is skipped if there is no MongoDB to use.
+func TestMongo(t *testing.T) {
+	bindAddr := os.Getenv("MONGODB_TEST_BIND_ADDR")
+	if bindAddr == "" {
+		t.Skip("MONGODB_TEST_BIND_ADDR is not set")
+	}
+
+	storetest.Run(t, func(t *testing.T) interfaces.DataStore {
+		return newTestMongo(t, bindAddr)
+	})
+}
+
+// TestChecker checks the health of the MongoDB at MONGODB_TEST_BIND_ADDR. It is skipped if there is no MongoDB to use.
+func TestChecker(t *testing.T) {
+	bindAddr := os.Getenv("MONGODB_TEST_BIND_ADDR")
+	if bindAddr == "" {
+		t.Skip("MONGODB_TEST_BIND_ADDR is not set")
+	}
+
+	Convey("Given a data store connected to MongoDB", t, func() {
+		m := newTestMongo(t, bindAddr)
+		state := healthcheck.NewCheckState("mongodb")
+
+		Convey("When all the collections exist", func() {
+			err := m.Checker(context.Background(), state)
+
+			Convey("Then the state is OK", func() {
+				So(err, ShouldBeNil)
+				So(state.Status(), ShouldEqual, healthcheck.StatusOK)
+				So(state.Message(), ShouldEqual, mongo.HealthyMessage)
+			})
+		})
+
+		Convey("When a collection is missing", func() {
+			So(m.Client.Database(m.Database).Collection(m.OutboxCollection).Drop(context.Background()), ShouldBeNil)
+			err := m.Checker(context.Background(), state)
+
+			Convey("Then the state is CRITICAL", func() {
+				So(err, ShouldBeNil)
+				So(state.Status(), ShouldEqual, healthcheck.StatusCritical)
+				So(state.Message(), ShouldEqual, "collection outbox not found in database")
+			})
+		})
+	})
+}
+
+// newTestMongo returns a data store connected to the MongoDB at bindAddr, using a new database that is dropped
+// when the test finishes
+func newTestMongo(t *testing.T, bindAddr string) *mongo.Mongo {
+	mongoConfig := config.MongoConfig{
+		BindAddr:               bindAddr,
+		ConnectTimeout:         10 * time.Second,
+		QueryTimeout:           10 * time.Second,
+		MaxPoolSize:            10,
+		Database:               "books_api_test_" + strings.Replace(uuid.NewV4().String(), "-", "", -1),
+		BooksCollection:        "books",
+		ReviewsCollection:      "reviews",
+		ReservationsCollection: "reservations",
+		AuthorsCollection:      "authors",
+		OutboxCollection:       "outbox",
+		MigrationsCollection:   "migrations",
+		LocksCollection:        "locks",
+		IdempotencyCollection:  "idempotency_keys",
+		CountersCollection:     "counters",
+	}
+
+	m := &mongo.Mongo{}
+	if err := m.Init(mongoConfig); err != nil {
+		t.Fatalf("failed to initialise mongo: %v", err)
+	}
+
+	migrator, err := migrations.NewMigrator(m, m.Migrations(), config.MigrationsConfig{LockTTL: time.Minute})
+	if err != nil {
+		t.Fatalf("failed to initialise the migrator: %v", err)
+	}
+
+	t.Cleanup(func() {
+		ctx := context.Background()
+		if err := m.Client.Database(mongoConfig.Database).Drop(ctx); err != nil {
+			t.Errorf("failed to drop the test database: %v", err)
+		}
+		m.Close(ctx)
+	})
+
+	if err := migrator.Up(context.Background(), 0); err != nil {
+		t.Fatalf("failed to migrate mongo: %v", err)
+	}
+
+	return m
+}
+
+// TestMigrations rolls back and re-applies the migrations of the MongoDB at MONGODB_TEST_BIND_ADDR, and takes its
+// migration lock. It is skipped if there is no MongoDB to use.
+func TestMigrations(t *testing.T) {
+	bindAddr := os.Getenv("MONGODB_TEST_BIND_ADDR")
+	if bindAddr == "" {
+		t.Skip("MONGODB_TEST_BIND_ADDR is not set")
+	}
+
+	ctx := context.Background()
+
+	Convey("Given a migrated database", t, func() {
+		m := newTestMongo(t, bindAddr)
+		migrator, err := migrations.NewMigrator(m, m.Migrations(), config.MigrationsConfig{LockTTL: time.Minute})
+		So(err, ShouldBeNil)
+
+		Convey("When the status is read", func() {
+			statuses, err := migrator.Status(ctx)
+
+			Convey("Then every migration is applied", func() {
+				So(err, ShouldBeNil)
+				So(statuses, ShouldHaveLength, len(m.Migrations()))
+				for _, status := range statuses {
+					So(status.Applied, ShouldBeTrue)
+				}
+			})
+		})
+
+		Convey("When the latest migration is rolled back and applied again", func() {
+			So(migrator.Down(ctx, migrations.OneStep), ShouldBeNil)
+			pending, err := migrator.Pending(ctx)
+			So(err, ShouldBeNil)
+			So(pending, ShouldEqual, 1)
+
+			So(migrator.Up(ctx, 0), ShouldBeNil)
+
+			Convey("Then no migration is pending", func() {
+				pending, err := migrator.Pending(ctx)
+				So(err, ShouldBeNil)
+				So(pending, ShouldEqual, 0)
+			})
+		})
+
+		Convey("When the migrations are rolled back past an irreversible migration", func() {
+			err := migrator.Down(ctx, 0)
+
+			Convey("Then nothing is rolled back", func() {
+				So(errors.Is(err, migrations.ErrIrreversible), ShouldBeTrue)
+				pending, err := migrator.Pending(ctx)
+				So(err, ShouldBeNil)
+				So(pending, ShouldEqual, 0)
+			})
+		})
+
+		Convey("When an instance holds the migration lock", func() {
+			So(m.AcquireMigrationLock(ctx, "first", time.Minute), ShouldBeNil)
+
+			Convey("Then another instance cannot take it until it is released", func() {
+				So(m.AcquireMigrationLock(ctx, "second", time.Minute), ShouldEqual, migrations.ErrLocked)
+				So(m.AcquireMigrationLock(ctx, "first", time.Minute), ShouldBeNil)
+				So(m.ReleaseMigrationLock(ctx, "first"), ShouldBeNil)
+				So(m.AcquireMigrationLock(ctx, "second", time.Minute), ShouldBeNil)
+			})
+		})
+
+		Convey("When the migration lock has expired", func() {
+			So(m.AcquireMigrationLock(ctx, "first", -time.Second), ShouldBeNil)
+
+			Convey("Then another instance can take it", func() {
+				So(m.AcquireMigrationLock(ctx, "second", time.Minute), ShouldBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,143 @@
+package mongo
+
+import (
+	"context"
+	"github.com/ONSdigital/log.go/log"
+	"github.com/cadmiumcat/books-api/events"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// errAborted is returned by a transaction whose conditional write did not find the document it expected,
+// in which case none of the writes of the transaction are applied
+var errAborted = errors.New("transaction aborted")
+
+// runTransaction runs write in a transaction, so that all its writes are applied, or none of them.
+// The transaction is retried if it fails with a transient error, e.g. a write conflict with another transaction,
+// until the context is done. Every write must use the session context passed to write.
+func (m *Mongo) runTransaction(ctx context.Context, write func(sc mongoDriver.SessionContext) error) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	session, err := m.Client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongoDriver.SessionContext) (interface{}, error) {
+		return nil, write(sc)
+	})
+	return err
+}
+
+// outboxSequence is the ID of the counter that numbers the events of the outbox
+const outboxSequence = "outbox"
+
+// insertEvent stores an event in the outbox, within a transaction, so that it is written atomically with the change it describes.
+// The event is numbered by a counter that is incremented in the same transaction: the transactions that write events
+// conflict on the counter, so the numbers follow the order in which they commit, which the timestamps of the events,
+// with their milliseconds, cannot tell apart.
+func (m *Mongo) insertEvent(sc mongoDriver.SessionContext, event *events.Event) error {
+	sequence, err := m.nextSequence(sc, outboxSequence)
+	if err != nil {
+		return err
+	}
+	event.Sequence = sequence
+
+	_, err = m.collection(m.OutboxCollection).InsertOne(sc, event)
+	return err
+}
+
+// nextSequence increments the counter, which is created at 1 if it does not exist yet, and returns its new value
+func (m *Mongo) nextSequence(ctx context.Context, counter string) (int64, error) {
+	var result struct {
+		Value int64 `bson:"value"`
+	}
+	findOptions := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := m.collection(m.CountersCollection).FindOneAndUpdate(ctx, bson.M{"_id": counter}, bson.M{"$inc": bson.M{"value": int64(1)}}, findOptions).Decode(&result)
+	return result.Value, err
+}
+
+// updateExisting applies an update to the document matching the filter, within a transaction.
+// It returns errAborted if no document matches.
+func updateExisting(sc mongoDriver.SessionContext, collection *mongoDriver.Collection, filter, update interface{}) error {
+	result, err := collection.UpdateOne(sc, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errAborted
+	}
+	return nil
+}
+
+// deleteExisting removes the document matching the filter, within a transaction.
+// It returns errAborted if no document matches.
+func deleteExisting(sc mongoDriver.SessionContext, collection *mongoDriver.Collection, filter interface{}) error {
+	result, err := collection.DeleteOne(sc, filter)
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errAborted
+	}
+	return nil
+}
+
+// GetPendingEvents returns the oldest events in the outbox that have not been published yet, in the order in which
+// they were written. All of them are returned if limit is 0.
+func (m *Mongo) GetPendingEvents(ctx context.Context, limit int) ([]events.Event, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	logData := log.Data{
+		"limit":      limit,
+		"database":   m.Database,
+		"collection": m.OutboxCollection}
+
+	pending := []events.Event{}
+	findOptions := options.Find().SetSort(bson.D{{Key: "sequence", Value: 1}}).SetLimit(int64(limit))
+	if err := findAll(ctx, m.collection(m.OutboxCollection), bson.M{}, &pending, findOptions); err != nil {
+		log.Event(ctx, "unexpected error when getting pending events", log.ERROR, log.Error(err), logData)
+		return nil, errors.Wrap(err, "unexpected error when getting pending events")
+	}
+
+	return pending, nil
+}
+
+// DeletePublishedEvent removes an event from the outbox once it has been published.
+func (m *Mongo) DeletePublishedEvent(ctx context.Context, eventID string) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	logData := log.Data{
+		"event_id":   eventID,
+		"database":   m.Database,
+		"collection": m.OutboxCollection}
+
+	if _, err := m.collection(m.OutboxCollection).DeleteOne(ctx, bson.M{"_id": eventID}); err != nil {
+		log.Event(ctx, "unexpected error when deleting a published event", log.ERROR, log.Error(err), logData)
+		return errors.Wrap(err, "unexpected error when deleting a published event")
+	}
+
+	return nil
+}
+
+// CountPendingEvents returns the number of events in the outbox that have not been published yet.
+func (m *Mongo) CountPendingEvents(ctx context.Context) (int, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	count, err := m.collection(m.OutboxCollection).CountDocuments(ctx, bson.M{})
+	if err != nil {
+		log.Event(ctx, "unexpected error when counting pending events", log.ERROR, log.Error(err), log.Data{
+			"database":   m.Database,
+			"collection": m.OutboxCollection})
+		return 0, errors.Wrap(err, "unexpected error when counting pending events")
+	}
+
+	return int(count), nil
+}
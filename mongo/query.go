@@ -0,0 +1,175 @@
+package mongo
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/cadmiumcat/books-api/query"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	mongoDriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reflect"
+	"regexp"
+)
+
+// querySelector adds the filters of a query to a selector
+func querySelector(selector bson.M, q *query.Query) bson.M {
+	if selector == nil {
+		selector = bson.M{}
+	}
+	if q == nil {
+		return selector
+	}
+
+	for _, filter := range q.Filters {
+		var condition interface{}
+		switch filter.Operator {
+		case query.Contains:
+			condition = primitive.Regex{Pattern: regexp.QuoteMeta(filter.Value), Options: "i"}
+		default:
+			condition = filter.Value
+		}
+
+		// The same field can be filtered with different operators, so each condition is added separately
+		if _, ok := selector[filter.Key]; ok {
+			and, _ := selector["$and"].([]bson.M)
+			selector["$and"] = append(and, bson.M{filter.Key: condition})
+			continue
+		}
+		selector[filter.Key] = condition
+	}
+
+	return selector
+}
+
+// querySort returns the fields to sort by, in order, or nil to keep the natural order.
+// The _id is added as the last field, so that items with the same values are always in the same order across pages.
+func querySort(q *query.Query) bson.D {
+	if q == nil || len(q.Sort) == 0 {
+		return nil
+	}
+
+	return sortFields(append(append([]query.Sort{}, q.Sort...), query.Sort{Key: "_id"}), false)
+}
+
+// sortFields returns the sort document of the keys. The order of each key is reversed if reverse is true.
+func sortFields(keys []query.Sort, reverse bool) bson.D {
+	fields := make(bson.D, 0, len(keys))
+	for _, key := range keys {
+		direction := 1
+		if key.Descending != reverse {
+			direction = -1
+		}
+		fields = append(fields, bson.E{Key: key.Key, Value: direction})
+	}
+	return fields
+}
+
+// cursorSelector restricts a selector to the items after (or, for a backward cursor, before) the position of the cursor.
+// For a sort key (k1, k2, _id), the items after (v1, v2, id) are those with k1 > v1, or k1 = v1 and k2 > v2,
+// or k1 = v1 and k2 = v2 and _id > id, where > is < for the fields sorted in descending order.
+// An optional field may be missing, which MongoDB sorts as null, before any other value, but which a comparison does
+// not match: the items after a missing value are those where the field is not null, and the items before a value
+// include those where the field is missing.
+func cursorSelector(selector bson.M, q *query.Query, cursor *pagination.Cursor) bson.M {
+	if cursor == nil || cursor.IsStart() {
+		return selector
+	}
+
+	keys := pagination.SortKeys(q)
+	var after []bson.M
+	for i, key := range keys {
+		condition := bson.M{}
+		for j := 0; j < i; j++ {
+			condition[keys[j].Key] = cursor.Values[j]
+		}
+
+		value := cursor.Values[i]
+		switch {
+		case key.Descending == cursor.Backward && value == nil:
+			condition[key.Key] = bson.M{"$ne": nil}
+		case key.Descending == cursor.Backward:
+			condition[key.Key] = bson.M{"$gt": value}
+		case value == nil:
+			// nothing is before a missing value
+			continue
+		default:
+			condition["$or"] = []bson.M{{key.Key: bson.M{"$lt": value}}, {key.Key: nil}}
+		}
+
+		after = append(after, condition)
+	}
+
+	return bson.M{"$and": []bson.M{selector, {"$or": after}}}
+}
+
+// pageQuery returns the filter and options of the query that reads a page of the items matching a selector,
+// in cursor mode if a cursor is provided, and in offset mode otherwise.
+// A backward page is read in reverse order, starting from the cursor, and must be reversed again afterwards.
+func pageQuery(selector bson.M, q *query.Query, cursor *pagination.Cursor, offset, limit int) (bson.M, *options.FindOptions) {
+	if cursor != nil {
+		sort := sortFields(pagination.SortKeys(q), cursor.Backward)
+		return cursorSelector(selector, q, cursor), options.Find().SetSort(sort).SetLimit(int64(limit))
+	}
+
+	findOptions := options.Find().SetSkip(int64(offset)).SetLimit(int64(limit))
+	if sort := querySort(q); sort != nil {
+		findOptions.SetSort(sort)
+	}
+	return selector, findOptions
+}
+
+// reversePage restores the order of a page read backwards from a cursor. page is a pointer to a slice.
+func reversePage(cursor *pagination.Cursor, page interface{}) {
+	if cursor == nil || !cursor.Backward {
+		return
+	}
+
+	items := reflect.ValueOf(page).Elem()
+	swap := reflect.Swapper(items.Interface())
+	for i, j := 0, items.Len()-1; i < j; i, j = i+1, j-1 {
+		swap(i, j)
+	}
+}
+
+// findAll decodes all the documents found by a query into results, which is a pointer to a slice
+func findAll(ctx context.Context, collection *mongoDriver.Collection, filter interface{}, results interface{}, opts ...*options.FindOptions) error {
+	cursor, err := collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, results)
+}
+
+// iterate calls next for each document found by a query, with the cursor positioned on the document, until next
+// returns an error. Each read from MongoDB has its own timeout, so that going through a large collection is not
+// limited by the query timeout.
+func (m *Mongo) iterate(ctx context.Context, collection *mongoDriver.Collection, filter interface{}, findOptions *options.FindOptions, next func(cursor *mongoDriver.Cursor) error) error {
+	findCtx, cancel := m.withTimeout(ctx)
+	cursor, err := collection.Find(findCtx, filter, findOptions)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		closeCtx, cancel := m.withTimeout(ctx)
+		defer cancel()
+		cursor.Close(closeCtx)
+	}()
+
+	for {
+		nextCtx, cancel := m.withTimeout(ctx)
+		ok := cursor.Next(nextCtx)
+		cancel()
+		if !ok {
+			break
+		}
+
+		if err := next(cursor); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
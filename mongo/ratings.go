@@ -0,0 +1,60 @@
+package mongo
+
+import (
+	"github.com/cadmiumcat/books-api/models"
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDriver "go.mongodb.org/mongo-driver/mongo"
+)
+
+const ratingSummaryField = "rating_summary"
+
+// lockField is the counter that the transactions adding a review or a reservation to a book increment, see lockBook
+const lockField = "lock"
+
+// updateRatingSummary updates the rating summary of a book, within a transaction, when the rating of one of its reviews
+// changes from previous to current. The summary is incremented in place, so it never has to be calculated from the
+// reviews. Nothing is written if the rating does not change. As the book changes, its revision is incremented.
+// It returns errAborted if the book is not found.
+func (m *Mongo) updateRatingSummary(sc mongoDriver.SessionContext, bookID string, previous, current int) error {
+	changes := models.RatingChanges(previous, current)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	increments := bson.M{"revision": 1}
+	for field, change := range changes {
+		increments[ratingSummaryField+"."+field] = change
+	}
+
+	return updateExisting(sc, m.collection(m.BooksCollection), bson.M{"_id": bookID}, bson.M{"$inc": increments})
+}
+
+// lockBook writes to a book within a transaction, so that the transaction conflicts with any concurrent transaction
+// that deletes the book, or that locks it as well, and one of them is retried. Reading the book would not be enough, as a transaction only
+// conflicts with the writes of another. The revision of the book is not incremented, as the book does not change.
+// It returns errAborted if the book is not found.
+func (m *Mongo) lockBook(sc mongoDriver.SessionContext, bookID string) error {
+	return updateExisting(sc, m.collection(m.BooksCollection), bson.M{"_id": bookID}, bson.M{"$inc": bson.M{lockField: 1}})
+}
+
+// countedRatingFilter matches a review as long as its revision, and so its rating and moderation state, are still
+// the ones that were read, so that its rating is not counted twice in the summary of its book when the review is
+// modified concurrently
+func countedRatingFilter(review *models.Review) bson.M {
+	filter := bson.M{"_id": review.ID, "revision": review.Revision, "state": review.State}
+	if review.Rating == models.NoRating {
+		filter["rating"] = bson.M{"$exists": false}
+	} else {
+		filter["rating"] = review.Rating
+	}
+	return filter
+}
+
+// updateAverages calculates the average rating of books read from the database, as it is not stored
+func updateAverages(books ...*models.Book) {
+	for _, book := range books {
+		if book.Ratings != nil {
+			book.Ratings.UpdateAverage()
+		}
+	}
+}
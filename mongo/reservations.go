@@ -0,0 +1,277 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"github.com/ONSdigital/log.go/log"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
+	"time"
+)
+
+// activeReservationStates are the states of a Reservation for a Book that has not been returned yet
+var activeReservationStates = []string{models.ReservationReserved, models.ReservationCheckedOut}
+
+// AddReservation adds a Reservation for a Book. The active reservations of the Book are counted in the same transaction
+// as the Reservation is added, and the transaction writes to the Book, so that concurrent reservations of the same Book
+// conflict and only one of them is added.
+// It returns an error if the Book is not found, or if it already has an active reservation
+func (m *Mongo) AddReservation(ctx context.Context, reservation *models.Reservation) error {
+	logData := log.Data{
+		"reservation": reservation,
+		"database":    m.Database,
+		"collection":  m.ReservationsCollection}
+
+	collection := m.collection(m.ReservationsCollection)
+
+	err := m.runTransaction(ctx, func(sc mongoDriver.SessionContext) error {
+		if err := m.lockBook(sc, reservation.BookID); err != nil {
+			return err
+		}
+
+		active, err := collection.CountDocuments(sc, bson.M{"book_id": reservation.BookID, "state": bson.M{"$in": activeReservationStates}})
+		if err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrBookUnavailable
+		}
+
+		_, err = collection.InsertOne(sc, reservation)
+		return err
+	})
+	if err != nil {
+		switch err {
+		case ErrBookUnavailable:
+			log.Event(ctx, ErrBookUnavailable.Error(), log.ERROR, logData)
+			return ErrBookUnavailable
+		case errAborted:
+			log.Event(ctx, ErrBookNotFound.Error(), log.ERROR, log.Error(err), logData)
+			return ErrBookNotFound
+		}
+		log.Event(ctx, "unexpected error when adding a reservation", log.ERROR, log.Error(err), logData)
+		return writeError(err, "unexpected error when adding a reservation")
+	}
+
+	return nil
+}
+
+// GetReservation returns a models.Reservation for a given reservationID.
+// It returns an error if the reservation is not found.
+func (m *Mongo) GetReservation(ctx context.Context, reservationID string) (*models.Reservation, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	logData := log.Data{
+		"reservation_id": reservationID,
+		"database":       m.Database,
+		"collection":     m.ReservationsCollection}
+
+	var reservation models.Reservation
+	err := m.collection(m.ReservationsCollection).FindOne(ctx, bson.M{"_id": reservationID}).Decode(&reservation)
+	if err != nil {
+		if err == mongoDriver.ErrNoDocuments {
+			log.Event(ctx, ErrReservationNotFound.Error(), log.ERROR, log.Error(err), logData)
+			return nil, ErrReservationNotFound
+		}
+		log.Event(ctx, "unexpected error when getting a reservation", log.ERROR, log.Error(err), logData)
+		return nil, errors.Wrap(err, "unexpected error when getting a reservation")
+	}
+
+	return &reservation, nil
+}
+
+// GetReservations returns the reservations of a Book, oldest first.
+// It returns an error if the reservations cannot be listed.
+func (m *Mongo) GetReservations(ctx context.Context, bookID string, offset, limit int) ([]models.Reservation, int, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	logData := log.Data{
+		"book_id":    bookID,
+		"database":   m.Database,
+		"collection": m.ReservationsCollection}
+
+	collection := m.collection(m.ReservationsCollection)
+	filter := bson.M{"book_id": bookID}
+	reservations := []models.Reservation{}
+
+	totalCount, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		log.Event(ctx, "failure to retrieve list of reservations", log.ERROR, log.Error(err), logData)
+		return nil, 0, errors.Wrap(err, "unexpected error when getting reservations")
+	}
+
+	if limit > 0 {
+		findOptions := options.Find().SetSort(bson.D{{Key: "reserved_at", Value: 1}}).SetSkip(int64(offset)).SetLimit(int64(limit))
+		if err := findAll(ctx, collection, filter, &reservations, findOptions); err != nil {
+			log.Event(ctx, "unable to retrieve reservations", log.ERROR, log.Error(err), logData)
+			return []models.Reservation{}, int(totalCount), errors.Wrap(err, "unexpected error when getting reservations")
+		}
+	}
+
+	return reservations, int(totalCount), nil
+}
+
+// UpdateReservation stores the new state of a Reservation, as long as it has not changed from previousState.
+// It returns an error if the Reservation is not found in previousState
+func (m *Mongo) UpdateReservation(ctx context.Context, reservation *models.Reservation, previousState string) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	logData := log.Data{
+		"reservation":    reservation,
+		"previous_state": previousState,
+		"database":       m.Database,
+		"collection":     m.ReservationsCollection}
+
+	filter := bson.M{"_id": reservation.ID, "state": previousState}
+	result, err := m.collection(m.ReservationsCollection).ReplaceOne(ctx, filter, reservation)
+	if err != nil {
+		log.Event(ctx, "unexpected error when updating a reservation", log.ERROR, log.Error(err), logData)
+		return writeError(err, "unexpected error when updating a reservation")
+	}
+	if result.MatchedCount == 0 {
+		log.Event(ctx, ErrReservationConflict.Error(), log.ERROR, logData)
+		return ErrReservationConflict
+	}
+
+	return nil
+}
+
+// DeleteReservation removes a Reservation that has not been checked out yet.
+// It returns an error if the Reservation is not found in the reserved state
+func (m *Mongo) DeleteReservation(ctx context.Context, reservationID string) error {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	logData := log.Data{
+		"reservation_id": reservationID,
+		"database":       m.Database,
+		"collection":     m.ReservationsCollection}
+
+	filter := bson.M{"_id": reservationID, "state": models.ReservationReserved}
+	result, err := m.collection(m.ReservationsCollection).DeleteOne(ctx, filter)
+	if err != nil {
+		log.Event(ctx, "unexpected error when deleting a reservation", log.ERROR, log.Error(err), logData)
+		return writeError(err, "unexpected error when deleting a reservation")
+	}
+	if result.DeletedCount == 0 {
+		log.Event(ctx, ErrReservationConflict.Error(), log.ERROR, logData)
+		return ErrReservationConflict
+	}
+
+	return nil
+}
+
+// legacyCheckout is the deprecated shape of the checkout history that used to be embedded in a book document
+type legacyCheckout struct {
+	Who    string    `bson:"who"`
+	Out    time.Time `bson:"out"`
+	In     time.Time `bson:"in"`
+	Review int       `bson:"review"`
+}
+
+// migrateHistory moves the checkout history embedded in book documents into the reservations collection,
+// and removes the history from the books. Books without history are left untouched, and the reservations are upserted
+// with an ID derived from their checkout, so it is safe to run it more than once, even after it has been interrupted
+// between adding the reservations of a book and removing its history.
+func (m *Mongo) migrateHistory(ctx context.Context) error {
+	logData := log.Data{
+		"database":                m.Database,
+		"books_collection":        m.BooksCollection,
+		"reservations_collection": m.ReservationsCollection}
+
+	books := m.collection(m.BooksCollection)
+	reservations := m.collection(m.ReservationsCollection)
+
+	filter := bson.M{"history": bson.M{"$exists": true}}
+	findOptions := options.Find().SetProjection(bson.M{"history": 1})
+	migrated := 0
+	err := m.iterate(ctx, books, filter, findOptions, func(cursor *mongoDriver.Cursor) error {
+		var book struct {
+			ID      string           `bson:"_id"`
+			History []legacyCheckout `bson:"history"`
+		}
+		if err := cursor.Decode(&book); err != nil {
+			return err
+		}
+
+		ctx, cancel := m.withTimeout(ctx)
+		defer cancel()
+
+		for i, checkout := range book.History {
+			reservation := reservationFromCheckout(book.ID, i, checkout)
+			replaceOptions := options.Replace().SetUpsert(true)
+			if _, err := reservations.ReplaceOne(ctx, bson.M{"_id": reservation.ID}, reservation, replaceOptions); err != nil {
+				logData["book_id"] = book.ID
+				log.Event(ctx, "unexpected error when migrating the checkout history of a book", log.ERROR, log.Error(err), logData)
+				return err
+			}
+		}
+
+		if _, err := books.UpdateOne(ctx, bson.M{"_id": book.ID}, bson.M{"$unset": bson.M{"history": ""}}); err != nil {
+			logData["book_id"] = book.ID
+			log.Event(ctx, "unexpected error when removing the checkout history of a book", log.ERROR, log.Error(err), logData)
+			return err
+		}
+		migrated++
+		return nil
+	})
+	if err != nil {
+		log.Event(ctx, "unexpected error when iterating over books with checkout history", log.ERROR, log.Error(err), logData)
+		return errors.Wrap(err, "unexpected error when migrating checkout history")
+	}
+
+	logData["books_migrated"] = migrated
+	log.Event(ctx, "migrated checkout history into reservations", log.INFO, logData)
+
+	return nil
+}
+
+// reservationFromCheckout converts the entry at the given index of the deprecated checkout history of a book into a
+// Reservation. The ID of the Reservation is derived from the book, the index and the time of the checkout, so that the
+// same entry is always converted into the same Reservation.
+func reservationFromCheckout(bookID string, index int, checkout legacyCheckout) *models.Reservation {
+	name := fmt.Sprintf("books-api/books/%s/history/%d/%s", bookID, index, checkout.Out.UTC().Format(time.RFC3339Nano))
+	reservation := &models.Reservation{
+		ID:          uuid.NewV5(uuid.NamespaceURL, name).String(),
+		BookID:      bookID,
+		User:        userFromName(checkout.Who),
+		State:       models.ReservationCheckedOut,
+		ReservedAt:  checkout.Out,
+		LastUpdated: checkout.Out,
+	}
+
+	if !checkout.Out.IsZero() {
+		out := checkout.Out
+		reservation.CheckedOutAt = &out
+	}
+
+	if !checkout.In.IsZero() {
+		in := checkout.In
+		reservation.State = models.ReservationReturned
+		reservation.ReturnedAt = &in
+		reservation.LastUpdated = in
+	}
+
+	return reservation
+}
+
+// userFromName splits a full name into forenames and surname, assuming the surname is the last word
+func userFromName(name string) models.User {
+	words := strings.Fields(name)
+	if len(words) < 2 {
+		return models.User{Forenames: name}
+	}
+
+	return models.User{
+		Forenames: strings.Join(words[:len(words)-1], " "),
+		Surname:   words[len(words)-1],
+	}
+}
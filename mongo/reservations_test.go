@@ -0,0 +1,36 @@
+package mongo
+
+import (
+	"github.com/cadmiumcat/books-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestReservationFromCheckout(t *testing.T) {
+	Convey("Given an entry of the checkout history of a book that has been returned", t, func() {
+		out := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+		checkout := legacyCheckout{Who: "Octavia E. Butler", Out: out, In: out.Add(48 * time.Hour)}
+
+		Convey("When it is converted into a reservation", func() {
+			reservation := reservationFromCheckout("1", 0, checkout)
+
+			Convey("Then the reservation has been returned by the user who checked the book out", func() {
+				So(reservation.BookID, ShouldEqual, "1")
+				So(reservation.User, ShouldResemble, models.User{Forenames: "Octavia E.", Surname: "Butler"})
+				So(reservation.State, ShouldEqual, models.ReservationReturned)
+				So(*reservation.CheckedOutAt, ShouldEqual, out)
+				So(*reservation.ReturnedAt, ShouldEqual, checkout.In)
+			})
+
+			Convey("Then converting it again gives the same ID, so that the migration can be run again", func() {
+				So(reservationFromCheckout("1", 0, checkout).ID, ShouldEqual, reservation.ID)
+			})
+
+			Convey("Then the other entries, and the entries of other books, have other IDs", func() {
+				So(reservationFromCheckout("1", 1, checkout).ID, ShouldNotEqual, reservation.ID)
+				So(reservationFromCheckout("2", 0, checkout).ID, ShouldNotEqual, reservation.ID)
+			})
+		})
+	})
+}
@@ -0,0 +1,64 @@
+package mongo
+
+import (
+	"context"
+	"github.com/ONSdigital/log.go/log"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/search"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	mongoDriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// booksTextIndex is the text index used to search the title, author and synopsis of the books.
+// The language of a book is an ISO 639-1 code that MongoDB may not support for text search,
+// so it must not override the language of the index, which it would do by default as it is called "language".
+var booksTextIndex = mongoDriver.IndexModel{
+	Keys: bson.D{{Key: "title", Value: "text"}, {Key: "author", Value: "text"}, {Key: "synopsis", Value: "text"}},
+	Options: options.Index().
+		SetName("books_text").
+		SetWeights(search.Weights).
+		SetDefaultLanguage("english").
+		SetLanguageOverride("text_language"),
+}
+
+// SearchBooks returns the books matching the query, from the most to the least relevant, and the total number of matches.
+// The relevance score is calculated by the MongoDB text index.
+func (m *Mongo) SearchBooks(ctx context.Context, query string, offset, limit int) ([]models.SearchResult, int, error) {
+	ctx, cancel := m.withTimeout(ctx)
+	defer cancel()
+
+	logData := log.Data{
+		"query":      query,
+		"offset":     offset,
+		"limit":      limit,
+		"database":   m.Database,
+		"collection": m.BooksCollection}
+
+	books := m.collection(m.BooksCollection)
+	filter := bson.M{"$text": bson.M{"$search": query}}
+
+	totalCount, err := books.CountDocuments(ctx, filter)
+	if err != nil {
+		log.Event(ctx, "unexpected error when searching books", log.ERROR, log.Error(err), logData)
+		return nil, 0, errors.Wrap(err, "unexpected error when searching books")
+	}
+
+	results := []models.SearchResult{}
+	if limit > 0 {
+		score := bson.M{"score": bson.M{"$meta": "textScore"}}
+		findOptions := options.Find().SetProjection(score).SetSort(score).SetSkip(int64(offset)).SetLimit(int64(limit))
+		if err := findAll(ctx, books, filter, &results, findOptions); err != nil {
+			log.Event(ctx, "unexpected error when searching books", log.ERROR, log.Error(err), logData)
+			return nil, 0, errors.Wrap(err, "unexpected error when searching books")
+		}
+	}
+
+	for i := range results {
+		updateAverages(&results[i].Book)
+		results[i].Highlights = search.Highlight(&results[i].Book, query)
+	}
+
+	return results, int(totalCount), nil
+}
@@ -0,0 +1,142 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/ONSdigital/log.go/log"
+	"github.com/cadmiumcat/books-api/config"
+	"github.com/cadmiumcat/books-api/interfaces"
+	"sync"
+	"time"
+)
+
+// Relay publishes the events stored in the outbox to a sink, in the order in which they occurred.
+// An event is only removed from the outbox once it has been published, so every event is delivered
+// at least once: consumers must be prepared to receive the same event (same ID) more than once.
+type Relay struct {
+	store interfaces.Outbox
+	sink  interfaces.EventProducer
+	cfg   config.OutboxConfig
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewRelay creates a new instance of Relay that publishes up to BatchSize events at a time.
+// A BatchSize below 1 is raised to 1, as a batch with no limit would never be full and an empty one always would.
+func NewRelay(store interfaces.Outbox, sink interfaces.EventProducer, cfg config.OutboxConfig) *Relay {
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = 1
+	}
+	return &Relay{
+		store: store,
+		sink:  sink,
+		cfg:   cfg,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+}
+
+// Start drains the outbox in the background until Stop is called.
+// The outbox is polled every PollInterval. A full batch is followed immediately by the next one,
+// and a failure is retried with an exponential backoff between MinRetryBackoff and MaxRetryBackoff.
+func (r *Relay) Start(ctx context.Context) {
+	go func() {
+		defer close(r.done)
+
+		var wait time.Duration
+		failures := 0
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-time.After(wait):
+			}
+
+			published, err := r.drain(ctx)
+			switch {
+			case err != nil:
+				failures++
+				wait = r.retryBackoff(failures)
+				log.Event(ctx, "failed to relay events from the outbox", log.WARN, log.Error(err), log.Data{
+					"published": published,
+					"failures":  failures,
+					"retry_in":  wait.String(),
+				})
+			case published > 0 && published == r.cfg.BatchSize:
+				failures = 0
+				wait = 0
+			default:
+				failures = 0
+				wait = r.cfg.PollInterval
+			}
+		}
+	}()
+}
+
+// Stop stops the relay and waits for the batch being published, if any, to finish
+func (r *Relay) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+	<-r.done
+}
+
+// drain publishes a batch of pending events and returns how many were published.
+// It stops at the first event that cannot be published, so that events are never published out of order.
+func (r *Relay) drain(ctx context.Context) (int, error) {
+	pending, err := r.store.GetPendingEvents(ctx, r.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range pending {
+		event := &pending[i]
+		if err := r.sink.Publish(ctx, event); err != nil {
+			return i, err
+		}
+
+		if err := r.store.DeletePublishedEvent(ctx, event.ID); err != nil {
+			return i, err
+		}
+
+		log.Event(ctx, "published event", log.INFO, log.Data{"event_id": event.ID, "event_type": event.Type})
+	}
+
+	return len(pending), nil
+}
+
+// retryBackoff returns the time to wait after the given number of consecutive failures
+func (r *Relay) retryBackoff(failures int) time.Duration {
+	backoff := r.cfg.MinRetryBackoff
+	for i := 1; i < failures && backoff < r.cfg.MaxRetryBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > r.cfg.MaxRetryBackoff {
+		return r.cfg.MaxRetryBackoff
+	}
+
+	return backoff
+}
+
+// Checker reports the state of the outbox to the health check.
+// The state is WARNING when the number of events waiting to be published reaches BacklogWarningThreshold,
+// as it means that the sink is unavailable or cannot keep up.
+func (r *Relay) Checker(ctx context.Context, state *healthcheck.CheckState) error {
+	count, err := r.store.CountPendingEvents(ctx)
+	if err != nil {
+		state.Update(healthcheck.StatusWarning, "unable to count the events in the outbox", 0)
+		return err
+	}
+
+	if count >= r.cfg.BacklogWarningThreshold {
+		state.Update(healthcheck.StatusWarning, fmt.Sprintf("%d events waiting to be published", count), 0)
+		return nil
+	}
+
+	state.Update(healthcheck.StatusOK, fmt.Sprintf("%d events waiting to be published", count), 0)
+	return nil
+}
@@ -0,0 +1,252 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"github.com/ONSdigital/dp-healthcheck/healthcheck"
+	"github.com/cadmiumcat/books-api/config"
+	"github.com/cadmiumcat/books-api/events"
+	"github.com/cadmiumcat/books-api/interfaces/mock"
+	. "github.com/smartystreets/goconvey/convey"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	errBroker = errors.New("broker unavailable")
+	errMongo  = errors.New("mongo unavailable")
+
+	testConfig = config.OutboxConfig{
+		PollInterval:            time.Millisecond,
+		BatchSize:               2,
+		MinRetryBackoff:         time.Millisecond,
+		MaxRetryBackoff:         10 * time.Millisecond,
+		BacklogWarningThreshold: 3,
+	}
+)
+
+func pendingEvents(ids ...string) []events.Event {
+	pending := make([]events.Event, 0, len(ids))
+	for _, id := range ids {
+		pending = append(pending, events.Event{ID: id, Type: events.BookCreated})
+	}
+	return pending
+}
+
+func mockOutbox(pending []events.Event) *mock.OutboxMock {
+	return &mock.OutboxMock{
+		GetPendingEventsFunc: func(ctx context.Context, limit int) ([]events.Event, error) {
+			return pending, nil
+		},
+		DeletePublishedEventFunc: func(ctx context.Context, eventID string) error {
+			return nil
+		},
+	}
+}
+
+func TestDrain(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given an outbox with pending events", t, func() {
+		store := mockOutbox(pendingEvents("1", "2"))
+
+		Convey("When the sink publishes every event", func() {
+			sink := &mock.EventProducerMock{
+				PublishFunc: func(ctx context.Context, event *events.Event) error {
+					return nil
+				},
+			}
+			relay := NewRelay(store, sink, testConfig)
+			published, err := relay.drain(ctx)
+
+			Convey("Then every event is published in order and removed from the outbox", func() {
+				So(err, ShouldBeNil)
+				So(published, ShouldEqual, 2)
+				So(store.GetPendingEventsCalls()[0].Limit, ShouldEqual, testConfig.BatchSize)
+				So(sink.PublishCalls(), ShouldHaveLength, 2)
+				So(sink.PublishCalls()[0].Event.ID, ShouldEqual, "1")
+				So(sink.PublishCalls()[1].Event.ID, ShouldEqual, "2")
+				So(store.DeletePublishedEventCalls(), ShouldHaveLength, 2)
+				So(store.DeletePublishedEventCalls()[0].EventID, ShouldEqual, "1")
+				So(store.DeletePublishedEventCalls()[1].EventID, ShouldEqual, "2")
+			})
+		})
+
+		Convey("When the sink fails to publish an event", func() {
+			sink := &mock.EventProducerMock{
+				PublishFunc: func(ctx context.Context, event *events.Event) error {
+					return errBroker
+				},
+			}
+			relay := NewRelay(store, sink, testConfig)
+			published, err := relay.drain(ctx)
+
+			Convey("Then the batch stops, and the event is kept in the outbox", func() {
+				So(err, ShouldEqual, errBroker)
+				So(published, ShouldEqual, 0)
+				So(sink.PublishCalls(), ShouldHaveLength, 1)
+				So(store.DeletePublishedEventCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+
+	Convey("Given an outbox that cannot be read", t, func() {
+		store := &mock.OutboxMock{
+			GetPendingEventsFunc: func(ctx context.Context, limit int) ([]events.Event, error) {
+				return nil, errMongo
+			},
+		}
+		sink := &mock.EventProducerMock{}
+
+		Convey("When the outbox is drained", func() {
+			relay := NewRelay(store, sink, testConfig)
+			_, err := relay.drain(ctx)
+
+			Convey("Then the error is returned, and nothing is published", func() {
+				So(err, ShouldEqual, errMongo)
+				So(sink.PublishCalls(), ShouldHaveLength, 0)
+			})
+		})
+	})
+}
+
+func TestRetryBackoff(t *testing.T) {
+	Convey("Given a relay with a minimum and maximum retry backoff", t, func() {
+		relay := NewRelay(&mock.OutboxMock{}, &mock.EventProducerMock{}, config.OutboxConfig{
+			MinRetryBackoff: time.Second,
+			MaxRetryBackoff: 10 * time.Second,
+		})
+
+		Convey("The backoff starts at the minimum, doubles with each failure and is capped at the maximum", func() {
+			So(relay.retryBackoff(1), ShouldEqual, time.Second)
+			So(relay.retryBackoff(2), ShouldEqual, 2*time.Second)
+			So(relay.retryBackoff(4), ShouldEqual, 8*time.Second)
+			So(relay.retryBackoff(5), ShouldEqual, 10*time.Second)
+			So(relay.retryBackoff(1000), ShouldEqual, 10*time.Second)
+		})
+	})
+}
+
+func TestStartStop(t *testing.T) {
+	Convey("Given a relay with a sink that fails once before recovering", t, func() {
+		var mutex sync.Mutex
+		pending := pendingEvents("1")
+		store := &mock.OutboxMock{
+			GetPendingEventsFunc: func(ctx context.Context, limit int) ([]events.Event, error) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				return pending, nil
+			},
+			DeletePublishedEventFunc: func(ctx context.Context, eventID string) error {
+				mutex.Lock()
+				defer mutex.Unlock()
+				pending = nil
+				return nil
+			},
+		}
+		published := make(chan string, 1)
+		failed := false
+		sink := &mock.EventProducerMock{
+			PublishFunc: func(ctx context.Context, event *events.Event) error {
+				if !failed {
+					failed = true
+					return errBroker
+				}
+				published <- event.ID
+				return nil
+			},
+		}
+		relay := NewRelay(store, sink, testConfig)
+
+		Convey("When the relay is started", func() {
+			relay.Start(context.Background())
+
+			Convey("Then the event is published after retrying, and the relay can be stopped", func() {
+				select {
+				case id := <-published:
+					So(id, ShouldEqual, "1")
+				case <-time.After(time.Second):
+					So("timed out waiting for the event to be published", ShouldBeEmpty)
+				}
+				relay.Stop()
+				So(len(sink.PublishCalls()), ShouldBeGreaterThanOrEqualTo, 2)
+			})
+		})
+	})
+}
+
+func TestBatchSize(t *testing.T) {
+	Convey("Given a relay configured with a batch size of 0, and an empty outbox", t, func() {
+		cfg := testConfig
+		cfg.BatchSize = 0
+		cfg.PollInterval = 50 * time.Millisecond
+		store := &mock.OutboxMock{
+			GetPendingEventsFunc: func(ctx context.Context, limit int) ([]events.Event, error) {
+				return nil, nil
+			},
+		}
+		relay := NewRelay(store, &mock.EventProducerMock{}, cfg)
+
+		Convey("When the relay is started", func() {
+			relay.Start(context.Background())
+			time.Sleep(120 * time.Millisecond)
+			relay.Stop()
+
+			Convey("Then it publishes one event at a time, and polls the outbox every poll interval", func() {
+				calls := store.GetPendingEventsCalls()
+				So(len(calls), ShouldBeBetweenOrEqual, 1, 4)
+				So(calls[0].Limit, ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestChecker(t *testing.T) {
+	ctx := context.Background()
+
+	cases := []struct {
+		description string
+		count       int
+		err         error
+		expected    string
+	}{
+		{
+			description: "the backlog is below the threshold",
+			count:       testConfig.BacklogWarningThreshold - 1,
+			expected:    healthcheck.StatusOK,
+		},
+		{
+			description: "the backlog has reached the threshold",
+			count:       testConfig.BacklogWarningThreshold,
+			expected:    healthcheck.StatusWarning,
+		},
+		{
+			description: "the backlog cannot be counted",
+			err:         errMongo,
+			expected:    healthcheck.StatusWarning,
+		},
+	}
+
+	Convey("Given an outbox relay", t, func() {
+		for _, test := range cases {
+			test := test
+			store := &mock.OutboxMock{
+				CountPendingEventsFunc: func(ctx context.Context) (int, error) {
+					return test.count, test.err
+				},
+			}
+			relay := NewRelay(store, &mock.EventProducerMock{}, testConfig)
+
+			Convey("When "+test.description, func() {
+				state := healthcheck.NewCheckState("outbox")
+				err := relay.Checker(ctx, state)
+
+				Convey("Then the health check state is "+test.expected, func() {
+					So(err, ShouldEqual, test.err)
+					So(state.Status(), ShouldEqual, test.expected)
+				})
+			})
+		}
+	})
+}
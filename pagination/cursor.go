@@ -0,0 +1,240 @@
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/cadmiumcat/books-api/query"
+	"go.mongodb.org/mongo-driver/bson"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// CursorParameter is the query parameter selecting cursor pagination. An empty cursor requests the first page.
+	CursorParameter = "cursor"
+
+	// signatureLength is the number of bytes of the HMAC-SHA256 signature kept in a cursor
+	signatureLength = 16
+)
+
+var (
+	// ErrInvalidCursorParameter represents an error case where a cursor has not been issued by the API, or it is used with a different filter or sort order
+	ErrInvalidCursorParameter = errors.New("invalid cursor query parameter. Use the next_cursor or prev_cursor of a previous page, with the same filters and sort order")
+
+	// ErrCursorWithOffset represents an error case where both pagination modes are requested
+	ErrCursorWithOffset = errors.New("the cursor and offset query parameters cannot be used together")
+)
+
+// A Cursor is a position in a list sorted by a query, used for keyset pagination:
+// the page starts right after (or, for a previous page, right before) the item whose sort key is Values.
+// The sort key is the value of each of the sort fields of the query, followed by the _id of the item.
+// A Cursor without values is the start of the list.
+type Cursor struct {
+	Backward bool          `bson:"b,omitempty"`
+	Values   []interface{} `bson:"v,omitempty"`
+	Query    string        `bson:"q"`
+}
+
+// IsStart returns true if the Cursor points to the start of the list
+func (c *Cursor) IsStart() bool {
+	return len(c.Values) == 0
+}
+
+// SortKeys returns the fields that define the order of the list in cursor mode: the sort fields of the query, followed by the _id.
+// Without a sort order, the list is sorted by _id, so that it has a stable order to page through.
+func SortKeys(q *query.Query) []query.Sort {
+	var keys []query.Sort
+	if q != nil {
+		keys = append(keys, q.Sort...)
+	}
+	return append(keys, query.Sort{Key: "_id"})
+}
+
+// fingerprint identifies the filters and sort order of a query, so that a cursor cannot be used with a different one
+func fingerprint(q *query.Query) string {
+	if q == nil {
+		q = &query.Query{}
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%v|%v", q.Filters, q.Sort)))
+	return base64.RawURLEncoding.EncodeToString(sum[:8])
+}
+
+// encodeCursor returns the opaque, signed, representation of a cursor
+func (p *Paginator) encodeCursor(cursor *Cursor) (string, error) {
+	payload, err := bson.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(append(payload, p.sign(payload)...)), nil
+}
+
+// decodeCursor returns the cursor of an opaque representation, if its signature is valid
+func (p *Paginator) decodeCursor(encoded string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(data) <= signatureLength {
+		return nil, ErrInvalidCursorParameter
+	}
+
+	payload, signature := data[:len(data)-signatureLength], data[len(data)-signatureLength:]
+	if !hmac.Equal(signature, p.sign(payload)) {
+		return nil, ErrInvalidCursorParameter
+	}
+
+	cursor := &Cursor{}
+	if err := bson.Unmarshal(payload, cursor); err != nil {
+		return nil, ErrInvalidCursorParameter
+	}
+	return cursor, nil
+}
+
+func (p *Paginator) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, p.cursorKey)
+	mac.Write(payload)
+	return mac.Sum(nil)[:signatureLength]
+}
+
+// GetCursor returns the cursor of a request, or nil if the request uses offset pagination.
+// It returns an error if the cursor is not valid, if it was issued for a different query, or if an offset is also requested.
+func (p *Paginator) GetCursor(r *http.Request, q *query.Query) (*Cursor, error) {
+	values := r.URL.Query()
+	if _, ok := values[CursorParameter]; !ok {
+		return nil, nil
+	}
+
+	if _, ok := values["offset"]; ok {
+		return nil, ErrCursorWithOffset
+	}
+
+	encoded := values.Get(CursorParameter)
+	if encoded == "" {
+		return &Cursor{Query: fingerprint(q)}, nil
+	}
+
+	cursor, err := p.decodeCursor(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	if cursor.Query != fingerprint(q) || len(cursor.Values) != len(SortKeys(q)) {
+		return nil, ErrInvalidCursorParameter
+	}
+
+	return cursor, nil
+}
+
+// NewPage returns the Page of a list, with the links to the next and previous pages.
+// items is the slice of the items in the page. In offset mode (cursor is nil), the links are based on the offset.
+// In cursor mode, the next and previous cursors point after the last, and before the first, item of the page.
+// A next cursor is only provided for a full page, as a shorter page is the end of the list, and an empty page has no cursors.
+func (p *Paginator) NewPage(r *http.Request, q *query.Query, cursor *Cursor, items interface{}, offset, limit, totalCount int) (Page, error) {
+	list := reflect.ValueOf(items)
+	count := 0
+	if list.Kind() == reflect.Slice {
+		count = list.Len()
+	}
+
+	page := Page{
+		Count:      count,
+		Offset:     offset,
+		Limit:      limit,
+		TotalCount: totalCount,
+	}
+
+	links := &PageLinks{}
+	if cursor == nil {
+		if offset+count < totalCount {
+			links.Next = p.pageLink(r, "offset", strconv.Itoa(offset+count))
+		}
+		if offset > 0 {
+			previous := offset - limit
+			if previous < 0 {
+				previous = 0
+			}
+			links.Prev = p.pageLink(r, "offset", strconv.Itoa(previous))
+		}
+	} else {
+		page.Offset = 0
+		if count > 0 {
+			// A backward page that is not full is the start of the list; a forward page that is not full is its end
+			hasNext := count == limit || cursor.Backward
+			hasPrev := !cursor.IsStart() && (count == limit || !cursor.Backward)
+
+			if hasNext {
+				next, err := p.itemCursor(q, list.Index(count-1).Interface(), false)
+				if err != nil {
+					return Page{}, err
+				}
+				page.NextCursor, links.Next = next, p.pageLink(r, CursorParameter, next)
+			}
+			if hasPrev {
+				prev, err := p.itemCursor(q, list.Index(0).Interface(), true)
+				if err != nil {
+					return Page{}, err
+				}
+				page.PrevCursor, links.Prev = prev, p.pageLink(r, CursorParameter, prev)
+			}
+		}
+	}
+
+	if links.Next != "" || links.Prev != "" {
+		page.Links = links
+	}
+
+	return page, nil
+}
+
+// itemCursor returns the encoded cursor pointing after (or before, if backward) an item of the list
+func (p *Paginator) itemCursor(q *query.Query, item interface{}, backward bool) (string, error) {
+	values, err := sortKeyValues(item, SortKeys(q))
+	if err != nil {
+		return "", err
+	}
+	return p.encodeCursor(&Cursor{Backward: backward, Values: values, Query: fingerprint(q)})
+}
+
+// sortKeyValues returns the values of the sort keys of an item, as stored in the data store
+func sortKeyValues(item interface{}, keys []query.Sort) ([]interface{}, error) {
+	data, err := bson.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	document := bson.M{}
+	if err := bson.Unmarshal(data, document); err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		var value interface{} = document
+		for _, name := range strings.Split(key.Key, ".") {
+			if embedded, ok := value.(bson.M); ok {
+				value = embedded[name]
+				continue
+			}
+			value = nil
+		}
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+// pageLink returns the absolute link to the same list as the request, with a different offset or cursor
+func (p *Paginator) pageLink(r *http.Request, parameter, value string) string {
+	values := url.Values{}
+	for name, parameterValues := range r.URL.Query() {
+		if name == "offset" || name == CursorParameter {
+			continue
+		}
+		values[name] = parameterValues
+	}
+	values.Set(parameter, value)
+
+	return p.baseURL + r.URL.Path + "?" + values.Encode()
+}
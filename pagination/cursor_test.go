@@ -0,0 +1,196 @@
+package pagination
+
+import (
+	"github.com/cadmiumcat/books-api/query"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+type item struct {
+	ID    string `bson:"_id"`
+	Title string `bson:"title"`
+}
+
+var (
+	titleQuery = &query.Query{Sort: []query.Sort{{Key: "title", Descending: true}}}
+	items      = []item{{ID: "1", Title: "Kindred"}, {ID: "2", Title: "Beloved"}}
+)
+
+func TestGetCursor(t *testing.T) {
+	paginator, _ := NewPaginator(defaultLimit, defaultOffset, defaultMaximumLimit, cursorSecret, apiURL)
+
+	Convey("Given a request without a cursor", t, func() {
+		r := httptest.NewRequest("GET", "/books?offset=2", nil)
+
+		Convey("When GetCursor is called", func() {
+			cursor, err := paginator.GetCursor(r, titleQuery)
+
+			Convey("Then there is no cursor, as the request uses offset pagination", func() {
+				So(err, ShouldBeNil)
+				So(cursor, ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given a request with an empty cursor", t, func() {
+		r := httptest.NewRequest("GET", "/books?cursor=", nil)
+
+		Convey("When GetCursor is called", func() {
+			cursor, err := paginator.GetCursor(r, titleQuery)
+
+			Convey("Then the cursor is the start of the list", func() {
+				So(err, ShouldBeNil)
+				So(cursor.IsStart(), ShouldBeTrue)
+				So(cursor.Backward, ShouldBeFalse)
+			})
+		})
+	})
+
+	Convey("Given a request with a cursor issued for the same query", t, func() {
+		encoded, err := paginator.itemCursor(titleQuery, items[1], true)
+		So(err, ShouldBeNil)
+		r := httptest.NewRequest("GET", "/books?sort=-title&cursor="+encoded, nil)
+
+		Convey("When GetCursor is called", func() {
+			cursor, err := paginator.GetCursor(r, titleQuery)
+
+			Convey("Then the cursor points to the item it was issued for", func() {
+				So(err, ShouldBeNil)
+				So(cursor.Backward, ShouldBeTrue)
+				So(cursor.Values, ShouldResemble, []interface{}{"Beloved", "2"})
+			})
+		})
+
+		Convey("When GetCursor is called for a different query", func() {
+			_, err := paginator.GetCursor(r, &query.Query{})
+
+			Convey("Then the cursor is rejected", func() {
+				So(err, ShouldEqual, ErrInvalidCursorParameter)
+			})
+		})
+
+		Convey("When the cursor was signed with a different secret", func() {
+			other, _ := NewPaginator(defaultLimit, defaultOffset, defaultMaximumLimit, "another secret", apiURL)
+			_, err := other.GetCursor(r, titleQuery)
+
+			Convey("Then the cursor is rejected", func() {
+				So(err, ShouldEqual, ErrInvalidCursorParameter)
+			})
+		})
+	})
+
+	Convey("Given a request with a cursor that has been tampered with", t, func() {
+		r := httptest.NewRequest("GET", "/books?cursor=bm90IGEgY3Vyc29yIGF0IGFsbA", nil)
+
+		Convey("When GetCursor is called", func() {
+			_, err := paginator.GetCursor(r, titleQuery)
+
+			Convey("Then the cursor is rejected", func() {
+				So(err, ShouldEqual, ErrInvalidCursorParameter)
+			})
+		})
+	})
+
+	Convey("Given a request with both a cursor and an offset", t, func() {
+		r := httptest.NewRequest("GET", "/books?cursor=&offset=2", nil)
+
+		Convey("When GetCursor is called", func() {
+			_, err := paginator.GetCursor(r, titleQuery)
+
+			Convey("Then an error is returned, as the pagination modes cannot be mixed", func() {
+				So(err, ShouldEqual, ErrCursorWithOffset)
+			})
+		})
+	})
+}
+
+func TestNewPage(t *testing.T) {
+	paginator, _ := NewPaginator(defaultLimit, defaultOffset, defaultMaximumLimit, cursorSecret, apiURL)
+
+	Convey("Given a page in the middle of a list in offset mode", t, func() {
+		r := httptest.NewRequest("GET", "/books?sort=-title&offset=3&limit=2", nil)
+
+		Convey("When NewPage is called", func() {
+			page, err := paginator.NewPage(r, titleQuery, nil, items, 3, 2, 10)
+
+			Convey("Then the page has links to the previous and next offsets", func() {
+				So(err, ShouldBeNil)
+				So(page.Count, ShouldEqual, 2)
+				So(page.Offset, ShouldEqual, 3)
+				So(page.TotalCount, ShouldEqual, 10)
+				So(page.NextCursor, ShouldBeEmpty)
+				So(page.Links.Next, ShouldEqual, apiURL+"/books?limit=2&offset=5&sort=-title")
+				So(page.Links.Prev, ShouldEqual, apiURL+"/books?limit=2&offset=1&sort=-title")
+			})
+		})
+	})
+
+	Convey("Given the only page of a list in offset mode", t, func() {
+		r := httptest.NewRequest("GET", "/books", nil)
+
+		Convey("When NewPage is called", func() {
+			page, err := paginator.NewPage(r, nil, nil, items, 0, 20, 2)
+
+			Convey("Then the page has no links", func() {
+				So(err, ShouldBeNil)
+				So(page.Links, ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given a full page in cursor mode, after the start of the list", t, func() {
+		r := httptest.NewRequest("GET", "/books?sort=-title&limit=2&cursor=abc", nil)
+		cursor := &Cursor{Values: []interface{}{"Zami", "0"}, Query: fingerprint(titleQuery)}
+
+		Convey("When NewPage is called", func() {
+			page, err := paginator.NewPage(r, titleQuery, cursor, items, 0, 2, 10)
+
+			Convey("Then the next cursor points after the last item, and the previous cursor before the first item", func() {
+				So(err, ShouldBeNil)
+				next, err := paginator.decodeCursor(page.NextCursor)
+				So(err, ShouldBeNil)
+				So(next, ShouldResemble, &Cursor{Values: []interface{}{"Beloved", "2"}, Query: fingerprint(titleQuery)})
+
+				prev, err := paginator.decodeCursor(page.PrevCursor)
+				So(err, ShouldBeNil)
+				So(prev, ShouldResemble, &Cursor{Backward: true, Values: []interface{}{"Kindred", "1"}, Query: fingerprint(titleQuery)})
+
+				So(page.Links.Next, ShouldEqual, apiURL+"/books?"+url.Values{"cursor": {page.NextCursor}, "limit": {"2"}, "sort": {"-title"}}.Encode())
+				So(page.Links.Prev, ShouldEqual, apiURL+"/books?"+url.Values{"cursor": {page.PrevCursor}, "limit": {"2"}, "sort": {"-title"}}.Encode())
+			})
+		})
+	})
+
+	Convey("Given the first page in cursor mode, that is not full", t, func() {
+		r := httptest.NewRequest("GET", "/books?cursor=", nil)
+		cursor := &Cursor{Query: fingerprint(nil)}
+
+		Convey("When NewPage is called", func() {
+			page, err := paginator.NewPage(r, nil, cursor, items, 0, 20, 2)
+
+			Convey("Then there are no cursors, as the page is the whole list", func() {
+				So(err, ShouldBeNil)
+				So(page.NextCursor, ShouldBeEmpty)
+				So(page.PrevCursor, ShouldBeEmpty)
+				So(page.Links, ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given a backward page in cursor mode, that is not full", t, func() {
+		r := httptest.NewRequest("GET", "/books?cursor=abc&limit=5", nil)
+		cursor := &Cursor{Backward: true, Values: []interface{}{"Zami", "9"}, Query: fingerprint(titleQuery)}
+
+		Convey("When NewPage is called", func() {
+			page, err := paginator.NewPage(r, titleQuery, cursor, items, 0, 5, 10)
+
+			Convey("Then there is a next cursor, but no previous cursor, as the page is the start of the list", func() {
+				So(err, ShouldBeNil)
+				So(page.NextCursor, ShouldNotBeEmpty)
+				So(page.PrevCursor, ShouldBeEmpty)
+			})
+		})
+	})
+}
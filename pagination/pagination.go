@@ -1,6 +1,7 @@
 package pagination
 
 import (
+	"crypto/rand"
 	"errors"
 	"net/http"
 	"strconv"
@@ -21,23 +22,47 @@ type Paginator struct {
 	DefaultLimit        int
 	DefaultOffset       int
 	DefaultMaximumLimit int
+	cursorKey           []byte
+	baseURL             string
 }
 
-// NewPaginator creates a new instance of Paginator
-func NewPaginator(limit, offset, maximumLimit int) *Paginator {
+// NewPaginator creates a new instance of Paginator.
+// The cursors are signed with cursorSecret. If it is empty, a random secret is used,
+// and the cursors are only valid for this instance of the service, until it restarts.
+// The links to the pages start with baseURL, as the links to the resources do.
+func NewPaginator(limit, offset, maximumLimit int, cursorSecret, baseURL string) (*Paginator, error) {
+	cursorKey := []byte(cursorSecret)
+	if cursorSecret == "" {
+		cursorKey = make([]byte, 32)
+		if _, err := rand.Read(cursorKey); err != nil {
+			return nil, err
+		}
+	}
+
 	return &Paginator{
 		DefaultLimit:        limit,
 		DefaultOffset:       offset,
 		DefaultMaximumLimit: maximumLimit,
-	}
+		cursorKey:           cursorKey,
+		baseURL:             baseURL,
+	}, nil
 }
 
 // A Page is a section of paginated items, as well as the parameters used to determine the items that belong to the it
 type Page struct {
-	Count      int `json:"count"`
-	Offset     int `json:"offset"`
-	Limit      int `json:"limit"`
-	TotalCount int `json:"total_count"`
+	Count      int        `json:"count"`
+	Offset     int        `json:"offset"`
+	Limit      int        `json:"limit"`
+	TotalCount int        `json:"total_count"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+	PrevCursor string     `json:"prev_cursor,omitempty"`
+	Links      *PageLinks `json:"links,omitempty"`
+}
+
+// PageLinks are the links to the pages before and after a Page, when they exist
+type PageLinks struct {
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
 }
 
 // GetPaginationValues returns pagination parameters based on a request, or the default values if the request does not specify them.
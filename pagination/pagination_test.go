@@ -10,10 +10,12 @@ const (
 	defaultLimit        = 10
 	defaultOffset       = 1
 	defaultMaximumLimit = 100
+	cursorSecret        = "secret"
+	apiURL              = "http://localhost:8080/v1"
 )
 
 func TestReadPaginationValues(t *testing.T) {
-	defaultPaginator := NewPaginator(defaultLimit, defaultOffset, defaultMaximumLimit)
+	defaultPaginator, _ := NewPaginator(defaultLimit, defaultOffset, defaultMaximumLimit, cursorSecret, apiURL)
 
 	Convey("Given a request without pagination parameters", t, func() {
 		r := httptest.NewRequest("GET", "/endpoint_to_paginate", nil)
@@ -113,13 +115,24 @@ func TestNewPaginator(t *testing.T) {
 			DefaultLimit:        defaultLimit,
 			DefaultOffset:       defaultOffset,
 			DefaultMaximumLimit: defaultMaximumLimit,
+			cursorKey:           []byte(cursorSecret),
+			baseURL:             apiURL,
 		}
 
 		Convey("When NewPaginator is called using the same values", func() {
-			actualPaginator := NewPaginator(defaultLimit, defaultOffset, defaultMaximumLimit)
+			actualPaginator, err := NewPaginator(defaultLimit, defaultOffset, defaultMaximumLimit, cursorSecret, apiURL)
 			Convey("Then the Paginator returned resembles the expectedPaginator", func() {
+				So(err, ShouldBeNil)
 				So(actualPaginator, ShouldResemble, expectedPaginator)
 			})
 		})
+
+		Convey("When NewPaginator is called without a cursor secret", func() {
+			actualPaginator, err := NewPaginator(defaultLimit, defaultOffset, defaultMaximumLimit, "", apiURL)
+			Convey("Then a random secret is used to sign the cursors", func() {
+				So(err, ShouldBeNil)
+				So(actualPaginator.cursorKey, ShouldHaveLength, 32)
+			})
+		})
 	})
 }
@@ -0,0 +1,167 @@
+package query
+
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrUnknownQueryParameter represents an error case where a query parameter is not a filter of the resource listed
+	ErrUnknownQueryParameter = errors.New("unknown query parameter. Only the documented filters can be used")
+
+	// ErrInvalidFilterParameter represents an error case where a filter is empty or provided more than once
+	ErrInvalidFilterParameter = errors.New("invalid filter query parameter. Filters must have a value and be provided only once")
+
+	// ErrInvalidSortParameter represents an error case where the sort parameter contains unknown, unsortable or repeated fields
+	ErrInvalidSortParameter = errors.New("invalid sort query parameter. Only the documented fields can be used to sort, each of them once")
+)
+
+const (
+	// SortParameter is the query parameter listing the fields to sort by, separated by commas.
+	// A field prefixed with "-" is sorted in descending order.
+	SortParameter = "sort"
+
+	containsSuffix = "_contains"
+)
+
+// reservedParameters are the query parameters that are not filters
+var reservedParameters = map[string]bool{
+	"offset":      true,
+	"limit":       true,
+	"cursor":      true,
+	SortParameter: true,
+}
+
+// An Operator compares the value of a field to the value of a filter
+type Operator string
+
+const (
+	// Equals matches the fields whose value is the value of the filter. The query parameter is the name of the field.
+	Equals Operator = "eq"
+
+	// Contains matches the fields whose value contains the value of the filter, ignoring case.
+	// The query parameter is the name of the field followed by "_contains".
+	Contains Operator = "contains"
+)
+
+// A Field is a field of a resource that can be used to filter or sort a list of the resource
+type Field struct {
+	// Key is the name of the field in the data store
+	Key       string
+	Operators []Operator
+	Sortable  bool
+}
+
+// A Schema is the whitelist of the fields that can be used to filter or sort a list of a resource, by name
+type Schema map[string]Field
+
+// A Filter restricts a list to the items whose field matches a value
+type Filter struct {
+	Key      string
+	Operator Operator
+	Value    string
+}
+
+// A Sort orders a list by a field
+type Sort struct {
+	Key        string
+	Descending bool
+}
+
+// A Query is a set of filters, all of which must match, and the order in which the list must be sorted
+type Query struct {
+	Filters []Filter
+	Sort    []Sort
+}
+
+// Parse returns the Query described by the query parameters of a request.
+// The pagination parameters are ignored. It returns an error if a parameter is not a filter
+// of the schema, or if the sort parameter is not valid.
+func (s Schema) Parse(values url.Values) (*Query, error) {
+	q := &Query{}
+
+	for parameter, parameterValues := range values {
+		if reservedParameters[parameter] {
+			continue
+		}
+
+		filter, err := s.parseFilter(parameter)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(parameterValues) != 1 || parameterValues[0] == "" {
+			return nil, ErrInvalidFilterParameter
+		}
+		filter.Value = parameterValues[0]
+
+		q.Filters = append(q.Filters, filter)
+	}
+
+	if sortValues, ok := values[SortParameter]; ok {
+		if len(sortValues) != 1 {
+			return nil, ErrInvalidSortParameter
+		}
+
+		order, err := s.parseSort(sortValues[0])
+		if err != nil {
+			return nil, err
+		}
+		q.Sort = order
+	}
+
+	// Query parameters are not ordered: sort the filters, so that the same request always produces the same Query
+	sort.Slice(q.Filters, func(i, j int) bool {
+		if q.Filters[i].Key != q.Filters[j].Key {
+			return q.Filters[i].Key < q.Filters[j].Key
+		}
+		return q.Filters[i].Operator < q.Filters[j].Operator
+	})
+
+	return q, nil
+}
+
+func (s Schema) parseFilter(parameter string) (Filter, error) {
+	name, operator := parameter, Equals
+	if strings.HasSuffix(parameter, containsSuffix) {
+		name, operator = strings.TrimSuffix(parameter, containsSuffix), Contains
+	}
+
+	field, ok := s[name]
+	if !ok || !field.allows(operator) {
+		return Filter{}, ErrUnknownQueryParameter
+	}
+
+	return Filter{Key: field.Key, Operator: operator}, nil
+}
+
+func (s Schema) parseSort(value string) ([]Sort, error) {
+	var order []Sort
+	seen := map[string]bool{}
+
+	for _, name := range strings.Split(value, ",") {
+		descending := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+
+		field, ok := s[name]
+		if !ok || !field.Sortable || seen[name] {
+			return nil, ErrInvalidSortParameter
+		}
+		seen[name] = true
+
+		order = append(order, Sort{Key: field.Key, Descending: descending})
+	}
+
+	return order, nil
+}
+
+func (f Field) allows(operator Operator) bool {
+	for _, allowed := range f.Operators {
+		if allowed == operator {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package query
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"net/url"
+	"testing"
+)
+
+var testSchema = Schema{
+	"title":    {Key: "title", Operators: []Operator{Equals, Contains}, Sortable: true},
+	"surname":  {Key: "user.surname", Operators: []Operator{Equals}, Sortable: true},
+	"synopsis": {Key: "synopsis", Operators: []Operator{Contains}},
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	Convey("Given query parameters with filters, a sort order and pagination parameters", t, func() {
+		values, _ := url.ParseQuery("title_contains=girl&surname=Evaristo&synopsis_contains=London&sort=-surname,title&offset=2&limit=10")
+
+		Convey("When they are parsed", func() {
+			q, err := testSchema.Parse(values)
+
+			Convey("Then the filters and the sort order are returned, using the keys of the fields", func() {
+				So(err, ShouldBeNil)
+				So(q, ShouldResemble, &Query{
+					Filters: []Filter{
+						{Key: "synopsis", Operator: Contains, Value: "London"},
+						{Key: "title", Operator: Contains, Value: "girl"},
+						{Key: "user.surname", Operator: Equals, Value: "Evaristo"},
+					},
+					Sort: []Sort{
+						{Key: "user.surname", Descending: true},
+						{Key: "title"},
+					},
+				})
+			})
+		})
+	})
+
+	Convey("Given no query parameters", t, func() {
+		Convey("When they are parsed", func() {
+			q, err := testSchema.Parse(url.Values{})
+
+			Convey("Then an empty query is returned", func() {
+				So(err, ShouldBeNil)
+				So(q, ShouldResemble, &Query{})
+			})
+		})
+	})
+
+	cases := []struct {
+		description string
+		query       string
+		expected    error
+	}{
+		{"an unknown field", "publisher=Penguin", ErrUnknownQueryParameter},
+		{"an operator that the field does not support", "synopsis=London", ErrUnknownQueryParameter},
+		{"an empty filter", "title=", ErrInvalidFilterParameter},
+		{"a repeated filter", "title=Kindred&title=Beloved", ErrInvalidFilterParameter},
+		{"a sort by an unknown field", "sort=publisher", ErrInvalidSortParameter},
+		{"a sort by a field that cannot be sorted", "sort=synopsis", ErrInvalidSortParameter},
+		{"a sort by a repeated field", "sort=title,-title", ErrInvalidSortParameter},
+		{"an empty sort", "sort=", ErrInvalidSortParameter},
+		{"a repeated sort", "sort=title&sort=surname", ErrInvalidSortParameter},
+	}
+
+	for _, test := range cases {
+		test := test
+		Convey("Given query parameters with "+test.description, t, func() {
+			values, _ := url.ParseQuery(test.query)
+
+			Convey("When they are parsed", func() {
+				q, err := testSchema.Parse(values)
+
+				Convey("Then the error is "+test.expected.Error(), func() {
+					So(err, ShouldEqual, test.expected)
+					So(q, ShouldBeNil)
+				})
+			})
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package search
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/models"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Index is an in-memory inverted index of books.
+// It ranks books like the MongoDB text index: a book matches a query if it contains any of its terms,
+// and the score of a match depends on how often the term appears in each field, the weight of the field,
+// and how rare the term is across all the books.
+type Index struct {
+	mutex    sync.RWMutex
+	books    map[string]models.Book
+	postings map[string]map[string]float64 // term -> book ID -> weighted frequency of the term in the book
+}
+
+// NewIndex creates a new, empty, instance of Index
+func NewIndex() *Index {
+	return &Index{
+		books:    map[string]models.Book{},
+		postings: map[string]map[string]float64{},
+	}
+}
+
+// Add indexes a Book, replacing any previous version of it
+func (i *Index) Add(book models.Book) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.remove(book.ID)
+
+	i.books[book.ID] = book
+	for field, value := range fields(&book) {
+		for _, term := range Terms(value) {
+			if i.postings[term] == nil {
+				i.postings[term] = map[string]float64{}
+			}
+			i.postings[term][book.ID] += float64(Weights[field])
+		}
+	}
+}
+
+// Remove removes a Book from the index
+func (i *Index) Remove(id string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.remove(id)
+}
+
+func (i *Index) remove(id string) {
+	if _, ok := i.books[id]; !ok {
+		return
+	}
+
+	delete(i.books, id)
+	for term, books := range i.postings {
+		delete(books, id)
+		if len(books) == 0 {
+			delete(i.postings, term)
+		}
+	}
+}
+
+// SearchBooks returns the books matching the query, from the most to the least relevant, and the total number of matches
+func (i *Index) SearchBooks(ctx context.Context, query string, offset, limit int) ([]models.SearchResult, int, error) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	scores := map[string]float64{}
+	for _, term := range uniqueTerms(query) {
+		books := i.postings[term]
+		if len(books) == 0 {
+			continue
+		}
+		idf := math.Log(1 + float64(len(i.books))/float64(len(books)))
+		for id, frequency := range books {
+			scores[id] += frequency * idf
+		}
+	}
+
+	results := make([]models.SearchResult, 0, len(scores))
+	for id, score := range scores {
+		results = append(results, models.SearchResult{Book: i.books[id], Score: score})
+	}
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Score != results[b].Score {
+			return results[a].Score > results[b].Score
+		}
+		return results[a].ID < results[b].ID
+	})
+
+	totalCount := len(results)
+	if offset > totalCount {
+		offset = totalCount
+	}
+	end := offset + limit
+	if end > totalCount {
+		end = totalCount
+	}
+
+	page := results[offset:end]
+	for r := range page {
+		page[r].Highlights = Highlight(&page[r].Book, query)
+	}
+
+	return page, totalCount, nil
+}
+
+func uniqueTerms(text string) []string {
+	seen := map[string]bool{}
+	var terms []string
+	for _, term := range Terms(text) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
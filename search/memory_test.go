@@ -0,0 +1,94 @@
+package search
+
+import (
+	"context"
+	"github.com/cadmiumcat/books-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+var (
+	kindred  = models.Book{ID: "1", Title: "Kindred", Author: "Octavia E. Butler", Synopsis: "A woman travels in time"}
+	girl     = models.Book{ID: "2", Title: "Girl, Woman, Other", Author: "Bernardine Evaristo"}
+	parable  = models.Book{ID: "3", Title: "Parable of the Sower", Author: "Octavia E. Butler"}
+	roadside = models.Book{ID: "4", Title: "Roadside Picnic", Author: "Arkady and Boris Strugatsky"}
+)
+
+func TestIndex(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given an index of books", t, func() {
+		index := NewIndex()
+		for _, book := range []models.Book{kindred, girl, parable, roadside} {
+			index.Add(book)
+		}
+
+		Convey("When searching for a term that appears in different fields", func() {
+			results, totalCount, err := index.SearchBooks(ctx, "woman", 0, 10)
+
+			Convey("Then the books matching in the title are ranked first", func() {
+				So(err, ShouldBeNil)
+				So(totalCount, ShouldEqual, 2)
+				So(results, ShouldHaveLength, 2)
+				So(results[0].ID, ShouldEqual, girl.ID)
+				So(results[1].ID, ShouldEqual, kindred.ID)
+				So(results[0].Score, ShouldBeGreaterThan, results[1].Score)
+				So(results[0].Highlights["title"], ShouldEqual, "Girl, <em>Woman</em>, Other")
+			})
+		})
+
+		Convey("When searching for several terms", func() {
+			results, totalCount, err := index.SearchBooks(ctx, "Butler sower", 0, 10)
+
+			Convey("Then books matching any term are returned, those matching more terms first", func() {
+				So(err, ShouldBeNil)
+				So(totalCount, ShouldEqual, 2)
+				So(results[0].ID, ShouldEqual, parable.ID)
+				So(results[1].ID, ShouldEqual, kindred.ID)
+			})
+		})
+
+		Convey("When the results are paginated", func() {
+			results, totalCount, err := index.SearchBooks(ctx, "Butler sower", 1, 1)
+
+			Convey("Then only the requested page is returned, with the total number of matches", func() {
+				So(err, ShouldBeNil)
+				So(totalCount, ShouldEqual, 2)
+				So(results, ShouldHaveLength, 1)
+				So(results[0].ID, ShouldEqual, kindred.ID)
+			})
+		})
+
+		Convey("When a book is updated", func() {
+			updated := kindred
+			updated.Synopsis = "A writer travels in time"
+			index.Add(updated)
+			results, totalCount, _ := index.SearchBooks(ctx, "woman", 0, 10)
+
+			Convey("Then it is searched by its new content", func() {
+				So(totalCount, ShouldEqual, 1)
+				So(results[0].ID, ShouldEqual, girl.ID)
+			})
+		})
+
+		Convey("When a book is removed", func() {
+			index.Remove(girl.ID)
+			results, totalCount, _ := index.SearchBooks(ctx, "woman", 0, 10)
+
+			Convey("Then it is not returned anymore", func() {
+				So(totalCount, ShouldEqual, 1)
+				So(results[0].ID, ShouldEqual, kindred.ID)
+			})
+		})
+
+		Convey("When nothing matches the query", func() {
+			results, totalCount, err := index.SearchBooks(ctx, "the", 0, 10)
+
+			Convey("Then there are no results", func() {
+				So(err, ShouldBeNil)
+				So(totalCount, ShouldEqual, 0)
+				So(results, ShouldBeEmpty)
+			})
+		})
+	})
+}
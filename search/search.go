@@ -0,0 +1,169 @@
+package search
+
+import (
+	"github.com/cadmiumcat/books-api/models"
+	"html"
+	"strings"
+	"unicode"
+)
+
+const (
+	// maxSnippetWords is the maximum number of words of a field included in a highlighted snippet
+	maxSnippetWords = 20
+
+	// snippetLeadingWords is the number of words kept before the first match when a snippet is cut
+	snippetLeadingWords = 5
+
+	highlightStart = "<em>"
+	highlightEnd   = "</em>"
+	ellipsis       = "…"
+)
+
+// Weights is the relative importance of a match in each of the searchable fields of a Book
+var Weights = map[string]int{
+	"title":    10,
+	"author":   5,
+	"synopsis": 1,
+}
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true, "for": true,
+	"from": true, "in": true, "is": true, "it": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"to": true, "was": true, "with": true,
+}
+
+// fields returns the value of the searchable fields of a Book
+func fields(book *models.Book) map[string]string {
+	return map[string]string{
+		"title":    book.Title,
+		"author":   book.Author,
+		"synopsis": book.Synopsis,
+	}
+}
+
+// Terms splits a text into the terms used to index and search it: lower case words, without stop words,
+// and without the plural "s", so that "Books" and "book" are the same term.
+func Terms(text string) []string {
+	var terms []string
+	for _, word := range strings.FieldsFunc(text, isSeparator) {
+		if term := normalise(word); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+func normalise(word string) string {
+	term := strings.ToLower(word)
+	if stopWords[term] {
+		return ""
+	}
+	if len(term) > 3 && strings.HasSuffix(term, "s") && !strings.HasSuffix(term, "ss") {
+		term = strings.TrimSuffix(term, "s")
+	}
+	return term
+}
+
+// Highlight returns, for each field of the Book that contains a term of the query, a snippet of the field
+// in which the matching words are wrapped in <em> tags. The rest of the text is HTML escaped.
+// Long fields are cut to a snippet of maxSnippetWords words, starting a few words before the first match.
+func Highlight(book *models.Book, query string) map[string]string {
+	queryTerms := map[string]bool{}
+	for _, term := range Terms(query) {
+		queryTerms[term] = true
+	}
+
+	highlights := map[string]string{}
+	for field, value := range fields(book) {
+		if snippet, ok := highlight(value, queryTerms); ok {
+			highlights[field] = snippet
+		}
+	}
+
+	if len(highlights) == 0 {
+		return nil
+	}
+	return highlights
+}
+
+// token is a word of a text, with the separator that follows it
+type token struct {
+	word      string
+	separator string
+	matches   bool
+}
+
+func highlight(text string, queryTerms map[string]bool) (string, bool) {
+	var tokens []token
+	leading := ""
+	firstMatch := -1
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		start := i
+		for i < len(runes) && !isSeparator(runes[i]) {
+			i++
+		}
+		word := string(runes[start:i])
+
+		start = i
+		for i < len(runes) && isSeparator(runes[i]) {
+			i++
+		}
+		separator := string(runes[start:i])
+
+		if word == "" {
+			leading = separator
+			continue
+		}
+
+		matches := queryTerms[normalise(word)]
+		if matches && firstMatch < 0 {
+			firstMatch = len(tokens)
+		}
+		tokens = append(tokens, token{word: word, separator: separator, matches: matches})
+	}
+
+	if firstMatch < 0 {
+		return "", false
+	}
+
+	from, to := 0, len(tokens)
+	if len(tokens) > maxSnippetWords {
+		from = firstMatch - snippetLeadingWords
+		if from < 0 {
+			from = 0
+		}
+		to = from + maxSnippetWords
+		if to > len(tokens) {
+			to = len(tokens)
+			from = to - maxSnippetWords
+		}
+	}
+
+	var snippet strings.Builder
+	if from > 0 {
+		snippet.WriteString(ellipsis)
+	} else {
+		snippet.WriteString(html.EscapeString(leading))
+	}
+	for i := from; i < to; i++ {
+		if tokens[i].matches {
+			snippet.WriteString(highlightStart + html.EscapeString(tokens[i].word) + highlightEnd)
+		} else {
+			snippet.WriteString(html.EscapeString(tokens[i].word))
+		}
+		if i < to-1 || to == len(tokens) {
+			snippet.WriteString(html.EscapeString(tokens[i].separator))
+		}
+	}
+	if to < len(tokens) {
+		snippet.WriteString(ellipsis)
+	}
+
+	return snippet.String(), true
+}
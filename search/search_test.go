@@ -0,0 +1,69 @@
+package search
+
+import (
+	"github.com/cadmiumcat/books-api/models"
+	. "github.com/smartystreets/goconvey/convey"
+	"strings"
+	"testing"
+)
+
+func TestTerms(t *testing.T) {
+	Convey("Given a text with punctuation, upper case letters, plurals and stop words", t, func() {
+		text := "The Books of the Sea-Captains, and a Glass!"
+
+		Convey("When it is split into terms", func() {
+			terms := Terms(text)
+
+			Convey("Then the terms are lower case, singular, and exclude the stop words", func() {
+				So(terms, ShouldResemble, []string{"book", "sea", "captain", "glass"})
+			})
+		})
+	})
+}
+
+func TestHighlight(t *testing.T) {
+	Convey("Given a book", t, func() {
+		book := &models.Book{
+			Title:    "Girl, Woman, Other",
+			Author:   "Bernardine Evaristo",
+			Synopsis: "Twelve <very> different characters, mostly women, tell their stories",
+		}
+
+		Convey("When the query matches some of its fields", func() {
+			highlights := Highlight(book, "Woman women evaristo")
+
+			Convey("Then the matching words are highlighted, and the rest of the text is escaped", func() {
+				So(highlights, ShouldResemble, map[string]string{
+					"title":    "Girl, <em>Woman</em>, Other",
+					"author":   "Bernardine <em>Evaristo</em>",
+					"synopsis": "Twelve &lt;very&gt; different characters, mostly <em>women</em>, tell their stories",
+				})
+			})
+		})
+
+		Convey("When the query does not match any field", func() {
+			Convey("Then there are no highlights", func() {
+				So(Highlight(book, "kindred"), ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given a book with a long synopsis", t, func() {
+		words := make([]string, 50)
+		for i := range words {
+			words[i] = "word"
+		}
+		words[30] = "match"
+		book := &models.Book{Synopsis: strings.Join(words, " ")}
+
+		Convey("When the query matches a word in the middle of the synopsis", func() {
+			snippet := Highlight(book, "match")["synopsis"]
+
+			Convey("Then the snippet is cut around the first match", func() {
+				So(snippet, ShouldStartWith, "…word word word word word <em>match</em> word")
+				So(snippet, ShouldEndWith, "word…")
+				So(strings.Count(snippet, "word"), ShouldEqual, maxSnippetWords-1)
+			})
+		})
+	})
+}
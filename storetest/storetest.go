@@ -0,0 +1,1015 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"github.com/cadmiumcat/books-api/events"
+	"github.com/cadmiumcat/books-api/interfaces"
+	"github.com/cadmiumcat/books-api/models"
+	"github.com/cadmiumcat/books-api/mongo"
+	"github.com/cadmiumcat/books-api/pagination"
+	"github.com/cadmiumcat/books-api/query"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+	"time"
+)
+
+// NewStore returns a new, empty, data store for a test
+type NewStore func(t *testing.T) interfaces.DataStore
+
+// Run checks that a data store behaves as the service expects, so that every implementation of interfaces.DataStore
+// behaves the same. newStore is called for every test, each of which starts with an empty data store.
+// The searches, the outbox and the idempotency keys are checked if the data store is also an interfaces.Searcher,
+// an interfaces.Outbox and an interfaces.IdempotencyStore.
+func Run(t *testing.T, newStore NewStore) {
+	testBooks(t, newStore)
+	testBookLists(t, newStore)
+	testReviews(t, newStore)
+	testReservations(t, newStore)
+	testAuthors(t, newStore)
+	testExport(t, newStore)
+	testSearch(t, newStore)
+	testOutbox(t, newStore)
+	testIdempotency(t, newStore)
+}
+
+// newBook returns a new book with a title and an author
+func newBook(title, author string) *models.Book {
+	book := models.NewBook()
+	book.Title = title
+	book.Author = author
+	return book
+}
+
+// newReview returns a new review of a book, in the given state
+func newReview(bookID, state string, rating int) *models.Review {
+	review := models.NewReview(bookID)
+	review.Message = "A review"
+	review.User = models.User{Forenames: "Octavia", Surname: "Butler"}
+	review.Rating = rating
+	review.State = state
+	return review
+}
+
+// addBooks adds books to the data store, in order
+func addBooks(ds interfaces.DataStore, books ...*models.Book) {
+	for _, book := range books {
+		So(ds.AddBook(context.Background(), book), ShouldBeNil)
+	}
+}
+
+// ids returns the IDs of books
+func ids(books []models.Book) []string {
+	ids := make([]string, 0, len(books))
+	for _, book := range books {
+		ids = append(ids, book.ID)
+	}
+	return ids
+}
+
+func testBooks(t *testing.T, newStore NewStore) {
+	ctx := context.Background()
+
+	Convey("Given a data store with a book", t, func() {
+		ds := newStore(t)
+		book := newBook("Kindred", "Octavia E. Butler")
+		book.ISBN = "9780807083697"
+		book.Genres = []string{"science fiction"}
+		addBooks(ds, book)
+
+		Convey("Then the book can be read by ID", func() {
+			stored, err := ds.GetBook(ctx, book.ID)
+			So(err, ShouldBeNil)
+			So(stored, ShouldResemble, book)
+		})
+
+		Convey("Then the links of a book are not stored, as they are made when it is served", func() {
+			linked := newBook("Dawn", "Octavia E. Butler")
+			linked.SetLinks("http://localhost:8080/v1")
+			addBooks(ds, linked)
+
+			stored, err := ds.GetBook(ctx, linked.ID)
+			So(err, ShouldBeNil)
+			So(stored.Links, ShouldBeNil)
+		})
+
+		Convey("Then a book that does not exist is not found", func() {
+			stored, err := ds.GetBook(ctx, "unknown")
+			So(stored, ShouldBeNil)
+			So(err, ShouldEqual, mongo.ErrBookNotFound)
+		})
+
+		Convey("Then another book with the same ISBN cannot be added", func() {
+			duplicate := newBook("Kindred", "Octavia Butler")
+			duplicate.ISBN = book.ISBN
+			So(ds.AddBook(ctx, duplicate), ShouldEqual, mongo.ErrDuplicateISBN)
+
+			_, err := ds.GetBook(ctx, duplicate.ID)
+			So(err, ShouldEqual, mongo.ErrBookNotFound)
+		})
+
+		Convey("Then another book with the same ID cannot be added", func() {
+			duplicate := newBook("Kindred", "Octavia Butler")
+			duplicate.ID = book.ID
+
+			err := ds.AddBook(ctx, duplicate)
+			So(errors.Is(err, mongo.ErrDuplicateKey), ShouldBeTrue)
+
+			stored, err := ds.GetBook(ctx, book.ID)
+			So(err, ShouldBeNil)
+			So(stored.Author, ShouldEqual, book.Author)
+		})
+
+		Convey("When a batch of books is added", func() {
+			first := newBook("Parable of the Sower", "Octavia E. Butler")
+			first.ISBN = "9780446675505"
+			existing := newBook("Kindred", "Octavia E. Butler")
+			existing.ISBN = book.ISBN
+			repeated := newBook("Parable of the Sower", "Octavia E. Butler")
+			repeated.ISBN = first.ISBN
+			withoutISBN := newBook("Dawn", "Octavia E. Butler")
+
+			results, err := ds.AddBooks(ctx, []*models.Book{first, existing, repeated, withoutISBN})
+
+			Convey("Then the books with the ISBN of an existing book, or of a previous book of the batch, are not added", func() {
+				So(err, ShouldBeNil)
+				So(results, ShouldResemble, []error{nil, mongo.ErrDuplicateISBN, mongo.ErrDuplicateISBN, nil})
+
+				_, _, totalCount := listBooks(ds, nil)
+				So(totalCount, ShouldEqual, 3)
+			})
+		})
+
+		Convey("When the book is updated", func() {
+			update := &models.Book{ID: book.ID, Title: "Kindred: A Graphic Novel", Author: "Octavia E. Butler", Synopsis: "Time travel"}
+			So(ds.UpdateBook(ctx, book.ID, update), ShouldBeNil)
+
+			Convey("Then its title, author and metadata are replaced, and the empty fields are removed", func() {
+				stored, err := ds.GetBook(ctx, book.ID)
+				So(err, ShouldBeNil)
+				So(stored.Title, ShouldEqual, "Kindred: A Graphic Novel")
+				So(stored.Synopsis, ShouldEqual, "Time travel")
+				So(stored.ISBN, ShouldBeEmpty)
+				So(stored.Genres, ShouldBeNil)
+			})
+
+			Convey("Then its revision is incremented, and it cannot be updated again at the previous revision", func() {
+				stored, err := ds.GetBook(ctx, book.ID)
+				So(err, ShouldBeNil)
+				So(stored.Revision, ShouldEqual, 1)
+				So(ds.UpdateBook(ctx, book.ID, update), ShouldEqual, mongo.ErrBookConflict)
+			})
+		})
+
+		Convey("Then a book that does not exist cannot be updated", func() {
+			So(ds.UpdateBook(ctx, "unknown", newBook("Dawn", "Octavia E. Butler")), ShouldEqual, mongo.ErrBookNotFound)
+		})
+
+		Convey("Then a book cannot be updated with the ISBN of another book", func() {
+			other := newBook("Dawn", "Octavia E. Butler")
+			addBooks(ds, other)
+
+			update := newBook("Dawn", "Octavia E. Butler")
+			update.ISBN = book.ISBN
+			So(ds.UpdateBook(ctx, other.ID, update), ShouldEqual, mongo.ErrDuplicateISBN)
+		})
+
+		Convey("When the book is patched", func() {
+			patch := map[string]interface{}{"synopsis": "Time travel", "publication_year": 1979.0, "genres": nil}
+			So(ds.PatchBook(ctx, book.ID, 0, patch), ShouldBeNil)
+
+			Convey("Then the fields of the patch are set, the fields set to nil are removed and the others are kept", func() {
+				stored, err := ds.GetBook(ctx, book.ID)
+				So(err, ShouldBeNil)
+				So(stored.Title, ShouldEqual, book.Title)
+				So(stored.ISBN, ShouldEqual, book.ISBN)
+				So(stored.Synopsis, ShouldEqual, "Time travel")
+				So(stored.PublicationYear, ShouldEqual, 1979)
+				So(stored.Genres, ShouldBeNil)
+				So(stored.Revision, ShouldEqual, 1)
+			})
+
+			Convey("Then it cannot be patched or deleted at the previous revision", func() {
+				So(ds.PatchBook(ctx, book.ID, 0, map[string]interface{}{"title": "Dawn"}), ShouldEqual, mongo.ErrBookConflict)
+				So(ds.DeleteBook(ctx, book.ID, 0, true), ShouldEqual, mongo.ErrBookConflict)
+				_, err := ds.GetBook(ctx, book.ID)
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("Then a book that does not exist cannot be patched", func() {
+			So(ds.PatchBook(ctx, "unknown", 0, map[string]interface{}{"title": "Dawn"}), ShouldEqual, mongo.ErrBookNotFound)
+		})
+
+		Convey("Then a book cannot be patched with the ISBN of another book", func() {
+			other := newBook("Dawn", "Octavia E. Butler")
+			addBooks(ds, other)
+			So(ds.PatchBook(ctx, other.ID, 0, map[string]interface{}{"isbn": book.ISBN}), ShouldEqual, mongo.ErrDuplicateISBN)
+		})
+
+		Convey("When the book has a review", func() {
+			review := newReview(book.ID, models.ReviewPending, models.NoRating)
+			So(ds.AddReview(ctx, review), ShouldBeNil)
+
+			Convey("Then it cannot be deleted without its reviews", func() {
+				So(ds.DeleteBook(ctx, book.ID, 0, false), ShouldEqual, mongo.ErrBookHasReviews)
+				_, err := ds.GetBook(ctx, book.ID)
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then it can be deleted with its reviews", func() {
+				So(ds.DeleteBook(ctx, book.ID, 0, true), ShouldBeNil)
+
+				_, err := ds.GetBook(ctx, book.ID)
+				So(err, ShouldEqual, mongo.ErrBookNotFound)
+				_, err = ds.GetReview(ctx, review.ID)
+				So(err, ShouldEqual, mongo.ErrReviewNotFound)
+			})
+		})
+
+		Convey("Then a book that does not exist cannot be deleted", func() {
+			So(ds.DeleteBook(ctx, "unknown", 0, true), ShouldEqual, mongo.ErrBookNotFound)
+		})
+	})
+}
+
+// listBooks returns the first page of the books that match a query, their IDs, and the total number of books that match
+func listBooks(ds interfaces.DataStore, q *query.Query) ([]models.Book, []string, int) {
+	books, totalCount, err := ds.GetBooks(context.Background(), q, nil, 0, 100)
+	So(err, ShouldBeNil)
+	return books, ids(books), totalCount
+}
+
+// walkBooks pages through the books one at a time from a cursor, in the direction of the cursor, and returns their IDs
+// in the order in which they were read. The cursor of each page is made from the last book read, as the paginator does.
+func walkBooks(ds interfaces.DataStore, q *query.Query, backward bool) []string {
+	walked := []string{}
+	cursor := &pagination.Cursor{Backward: backward}
+	for i := 0; i < 10; i++ {
+		page, _, err := ds.GetBooks(context.Background(), q, cursor, 0, 1)
+		So(err, ShouldBeNil)
+		if len(page) == 0 {
+			break
+		}
+		walked = append(walked, page[0].ID)
+
+		data, err := bson.Marshal(page[0])
+		So(err, ShouldBeNil)
+		document := bson.M{}
+		So(bson.Unmarshal(data, document), ShouldBeNil)
+		cursor = &pagination.Cursor{Backward: backward}
+		for _, key := range pagination.SortKeys(q) {
+			cursor.Values = append(cursor.Values, document[key.Key])
+		}
+	}
+	return walked
+}
+
+// reversed returns the IDs in reverse order
+func reversed(ids []string) []string {
+	reversed := make([]string, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		reversed = append(reversed, ids[i])
+	}
+	return reversed
+}
+
+func testBookLists(t *testing.T, newStore NewStore) {
+	ctx := context.Background()
+
+	Convey("Given a data store with books", t, func() {
+		ds := newStore(t)
+		kindred := newBook("Kindred", "Octavia E. Butler")
+		kindred.Genres = []string{"science fiction", "classics"}
+		dawn := newBook("Dawn", "Octavia E. Butler")
+		dawn.Genres = []string{"science fiction"}
+		omens := newBook("Good Omens", "Terry Pratchett & Neil Gaiman")
+		evaristo := newBook("Girl, Woman, Other", "Bernardine Evaristo")
+		addBooks(ds, kindred, dawn, omens, evaristo)
+
+		byTitle := &query.Query{Sort: []query.Sort{{Key: "title"}}}
+
+		Convey("Then the books are listed in the order in which they were added, with their total", func() {
+			_, books, totalCount := listBooks(ds, nil)
+			So(totalCount, ShouldEqual, 4)
+			So(books, ShouldResemble, []string{kindred.ID, dawn.ID, omens.ID, evaristo.ID})
+		})
+
+		Convey("Then the books can be filtered by an exact value, or by a value contained in a field ignoring case", func() {
+			_, books, totalCount := listBooks(ds, &query.Query{Filters: []query.Filter{{Key: "author", Operator: query.Equals, Value: "Octavia E. Butler"}}})
+			So(totalCount, ShouldEqual, 2)
+			So(books, ShouldResemble, []string{kindred.ID, dawn.ID})
+
+			_, books, _ = listBooks(ds, &query.Query{Filters: []query.Filter{{Key: "title", Operator: query.Contains, Value: "WOMAN"}}})
+			So(books, ShouldResemble, []string{evaristo.ID})
+		})
+
+		Convey("Then the books can be filtered by any of the values of a list", func() {
+			_, books, _ := listBooks(ds, &query.Query{Filters: []query.Filter{{Key: "genres", Operator: query.Equals, Value: "classics"}}})
+			So(books, ShouldResemble, []string{kindred.ID})
+		})
+
+		Convey("Then the books can be sorted in either order", func() {
+			_, books, _ := listBooks(ds, byTitle)
+			So(books, ShouldResemble, []string{dawn.ID, evaristo.ID, omens.ID, kindred.ID})
+
+			_, books, _ = listBooks(ds, &query.Query{Sort: []query.Sort{{Key: "author", Descending: true}, {Key: "title"}}})
+			So(books, ShouldResemble, []string{omens.ID, dawn.ID, kindred.ID, evaristo.ID})
+		})
+
+		Convey("Then a page of the books starts at the offset, and the total is the number of books that match", func() {
+			page, totalCount, err := ds.GetBooks(ctx, byTitle, nil, 1, 2)
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 4)
+			So(ids(page), ShouldResemble, []string{evaristo.ID, omens.ID})
+
+			page, totalCount, err = ds.GetBooks(ctx, byTitle, nil, 10, 2)
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 4)
+			So(page, ShouldBeEmpty)
+		})
+
+		Convey("Then no books are returned without a limit, only their total", func() {
+			page, totalCount, err := ds.GetBooks(ctx, nil, nil, 0, 0)
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 4)
+			So(page, ShouldBeEmpty)
+		})
+
+		Convey("Then the books can be paged through from a cursor, in both directions", func() {
+			page, _, err := ds.GetBooks(ctx, byTitle, &pagination.Cursor{}, 0, 2)
+			So(err, ShouldBeNil)
+			So(ids(page), ShouldResemble, []string{dawn.ID, evaristo.ID})
+
+			next := &pagination.Cursor{Values: []interface{}{evaristo.Title, evaristo.ID}}
+			page, totalCount, err := ds.GetBooks(ctx, byTitle, next, 0, 2)
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 4)
+			So(ids(page), ShouldResemble, []string{omens.ID, kindred.ID})
+
+			previous := &pagination.Cursor{Backward: true, Values: []interface{}{kindred.Title, kindred.ID}}
+			page, _, err = ds.GetBooks(ctx, byTitle, previous, 0, 2)
+			So(err, ShouldBeNil)
+			So(ids(page), ShouldResemble, []string{evaristo.ID, omens.ID})
+		})
+	})
+	Convey("Given a data store with books of which only some have a publisher and a publication year", t, func() {
+		ds := newStore(t)
+		kindred := newBook("Kindred", "Octavia E. Butler")
+		kindred.Publisher = "Beacon Press"
+		kindred.PublicationYear = 1979
+		dawn := newBook("Dawn", "Octavia E. Butler")
+		omens := newBook("Good Omens", "Terry Pratchett & Neil Gaiman")
+		omens.Publisher = "Gollancz"
+		omens.PublicationYear = 1990
+		evaristo := newBook("Girl, Woman, Other", "Bernardine Evaristo")
+		addBooks(ds, kindred, dawn, omens, evaristo)
+
+		for _, key := range []string{"publisher", "publication_year"} {
+			ascending := &query.Query{Sort: []query.Sort{{Key: key}}}
+			descending := &query.Query{Sort: []query.Sort{{Key: key, Descending: true}}}
+
+			Convey("Then the books without a "+key+" are sorted first", func() {
+				_, books, _ := listBooks(ds, ascending)
+				So(books[2:], ShouldResemble, []string{kindred.ID, omens.ID})
+			})
+
+			Convey("Then every book is read when paging through the books sorted by "+key+" from a cursor", func() {
+				_, books, _ := listBooks(ds, ascending)
+				So(walkBooks(ds, ascending, false), ShouldResemble, books)
+				So(walkBooks(ds, ascending, true), ShouldResemble, reversed(books))
+
+				_, books, _ = listBooks(ds, descending)
+				So(walkBooks(ds, descending, false), ShouldResemble, books)
+				So(walkBooks(ds, descending, true), ShouldResemble, reversed(books))
+			})
+		}
+	})
+}
+
+func testReviews(t *testing.T, newStore NewStore) {
+	ctx := context.Background()
+
+	Convey("Given a data store with a book", t, func() {
+		ds := newStore(t)
+		book := newBook("Kindred", "Octavia E. Butler")
+		addBooks(ds, book)
+
+		Convey("Then a review cannot be added to a book that does not exist, whether its rating counts or not", func() {
+			So(ds.AddReview(ctx, newReview("unknown", models.ReviewApproved, 4)), ShouldEqual, mongo.ErrBookNotFound)
+			So(ds.AddReview(ctx, newReview("unknown", models.ReviewPending, models.NoRating)), ShouldEqual, mongo.ErrBookNotFound)
+		})
+
+		Convey("When approved reviews with a rating are added", func() {
+			So(ds.AddReview(ctx, newReview(book.ID, models.ReviewApproved, 4)), ShouldBeNil)
+			So(ds.AddReview(ctx, newReview(book.ID, models.ReviewApproved, 5)), ShouldBeNil)
+			So(ds.AddReview(ctx, newReview(book.ID, models.ReviewPending, 1)), ShouldBeNil)
+
+			Convey("Then their ratings are counted in the rating summary of the book", func() {
+				stored, err := ds.GetBook(ctx, book.ID)
+				So(err, ShouldBeNil)
+				So(stored.Ratings.Count, ShouldEqual, 2)
+				So(stored.Ratings.Average, ShouldEqual, 4.5)
+				So(stored.Ratings.Histogram, ShouldResemble, map[string]int{"4": 1, "5": 1})
+			})
+
+			Convey("Then the revision of the book is incremented each time its rating summary changes", func() {
+				stored, err := ds.GetBook(ctx, book.ID)
+				So(err, ShouldBeNil)
+				So(stored.Revision, ShouldEqual, 2)
+			})
+
+			Convey("Then the reviews of the book are listed, with their total", func() {
+				reviews, totalCount, err := ds.GetReviews(ctx, book.ID, nil, nil, 0, 10)
+				So(err, ShouldBeNil)
+				So(totalCount, ShouldEqual, 3)
+				So(reviews, ShouldHaveLength, 3)
+
+				reviews, totalCount, err = ds.GetReviews(ctx, book.ID, &query.Query{Filters: []query.Filter{{Key: "state", Operator: query.Equals, Value: models.ReviewApproved}}}, nil, 0, 10)
+				So(err, ShouldBeNil)
+				So(totalCount, ShouldEqual, 2)
+				So(reviews, ShouldHaveLength, 2)
+			})
+		})
+
+		Convey("Given an approved review with a rating", func() {
+			review := newReview(book.ID, models.ReviewApproved, 4)
+			So(ds.AddReview(ctx, review), ShouldBeNil)
+
+			Convey("Then the review can be read by ID", func() {
+				stored, err := ds.GetReview(ctx, review.ID)
+				So(err, ShouldBeNil)
+				So(stored.Message, ShouldEqual, review.Message)
+				So(stored.User, ShouldResemble, review.User)
+				So(stored.Rating, ShouldEqual, 4)
+				So(stored.LastUpdated, ShouldHappenWithin, time.Millisecond, review.LastUpdated)
+			})
+
+			Convey("Then a review that does not exist is not found", func() {
+				_, err := ds.GetReview(ctx, "unknown")
+				So(err, ShouldEqual, mongo.ErrReviewNotFound)
+			})
+
+			Convey("When it is replaced", func() {
+				replacement := *review
+				replacement.Message = "An updated review"
+				replacement.User = models.User{Forenames: "Octavia E.", Surname: "Butler"}
+				replacement.Rating = models.NoRating
+				updated, err := ds.UpdateReview(ctx, &replacement, review)
+				So(err, ShouldBeNil)
+
+				Convey("Then the review is returned as it is stored, and has gone back to pending without its rating", func() {
+					stored, err := ds.GetReview(ctx, review.ID)
+					So(err, ShouldBeNil)
+					So(updated.Message, ShouldEqual, "An updated review")
+					So(updated.User, ShouldResemble, replacement.User)
+					So(updated.Rating, ShouldEqual, models.NoRating)
+					So(updated.State, ShouldEqual, models.ReviewPending)
+					So(updated.BookID, ShouldEqual, book.ID)
+					So(updated.Revision, ShouldEqual, stored.Revision)
+					So(updated.LastUpdated, ShouldHappenWithin, time.Millisecond, stored.LastUpdated)
+					So(stored.Message, ShouldEqual, "An updated review")
+					So(stored.Rating, ShouldEqual, models.NoRating)
+				})
+
+				Convey("Then its rating stops counting in the rating summary of the book", func() {
+					stored, err := ds.GetBook(ctx, book.ID)
+					So(err, ShouldBeNil)
+					So(stored.Ratings.Count, ShouldEqual, 0)
+					So(stored.Ratings.Histogram["4"], ShouldEqual, 0)
+				})
+
+				Convey("Then its revision is incremented, and it cannot be updated again at the previous revision", func() {
+					So(updated.Revision, ShouldEqual, 1)
+					_, err := ds.UpdateReview(ctx, &replacement, review)
+					So(err, ShouldEqual, mongo.ErrReviewConflict)
+				})
+			})
+
+			Convey("Then a review that does not exist cannot be updated", func() {
+				unknown := newReview(book.ID, models.ReviewApproved, 4)
+				_, err := ds.UpdateReview(ctx, unknown, unknown)
+				So(err, ShouldEqual, mongo.ErrReviewNotFound)
+			})
+
+			Convey("When it is rejected", func() {
+				rejected := *review
+				So(rejected.Reject(time.Now().UTC()), ShouldBeNil)
+				So(ds.UpdateReviewState(ctx, &rejected, models.ReviewApproved), ShouldBeNil)
+
+				Convey("Then its rating stops counting in the rating summary of the book", func() {
+					stored, err := ds.GetBook(ctx, book.ID)
+					So(err, ShouldBeNil)
+					So(stored.Ratings.Count, ShouldEqual, 0)
+				})
+
+				Convey("Then it cannot be rejected again from the approved state", func() {
+					So(ds.UpdateReviewState(ctx, &rejected, models.ReviewApproved), ShouldEqual, mongo.ErrReviewConflict)
+				})
+			})
+
+			Convey("Then a review that does not exist cannot be moderated", func() {
+				unknown := newReview(book.ID, models.ReviewRejected, 4)
+				So(ds.UpdateReviewState(ctx, unknown, models.ReviewApproved), ShouldEqual, mongo.ErrReviewNotFound)
+			})
+
+			Convey("Then a review whose rating has changed since it was read cannot be deleted", func() {
+				changed := *review
+				changed.Rating = 2
+				So(ds.DeleteReview(ctx, &changed), ShouldEqual, mongo.ErrReviewConflict)
+			})
+
+			Convey("When it is deleted", func() {
+				So(ds.DeleteReview(ctx, review), ShouldBeNil)
+
+				Convey("Then it is not found, and its rating stops counting in the rating summary of the book", func() {
+					_, err := ds.GetReview(ctx, review.ID)
+					So(err, ShouldEqual, mongo.ErrReviewNotFound)
+
+					stored, err := ds.GetBook(ctx, book.ID)
+					So(err, ShouldBeNil)
+					So(stored.Ratings.Count, ShouldEqual, 0)
+				})
+
+				Convey("Then it cannot be deleted again", func() {
+					So(ds.DeleteReview(ctx, review), ShouldEqual, mongo.ErrReviewNotFound)
+				})
+			})
+		})
+	})
+}
+
+func testReservations(t *testing.T, newStore NewStore) {
+	ctx := context.Background()
+
+	Convey("Given a data store with a book that is reserved by several requests at once", t, func() {
+		ds := newStore(t)
+		book := newBook("Kindred", "Octavia E. Butler")
+		addBooks(ds, book)
+
+		results := make(chan error, 5)
+		for i := 0; i < cap(results); i++ {
+			go func() {
+				results <- ds.AddReservation(ctx, models.NewReservation(book.ID))
+			}()
+		}
+
+		Convey("Then only one of the reservations is added", func() {
+			added := 0
+			for i := 0; i < cap(results); i++ {
+				if err := <-results; err == nil {
+					added++
+				} else {
+					So(err, ShouldEqual, mongo.ErrBookUnavailable)
+				}
+			}
+			So(added, ShouldEqual, 1)
+
+			_, totalCount, err := ds.GetReservations(ctx, book.ID, 0, 10)
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 1)
+		})
+	})
+
+	Convey("Given a data store with a reserved book", t, func() {
+		ds := newStore(t)
+		book := newBook("Kindred", "Octavia E. Butler")
+		addBooks(ds, book)
+
+		returned := models.NewReservation(book.ID)
+		returned.User = models.User{Forenames: "Octavia", Surname: "Butler"}
+		returned.State = models.ReservationReturned
+		returned.ReservedAt = returned.ReservedAt.Add(-time.Hour)
+		So(ds.AddReservation(ctx, returned), ShouldBeNil)
+
+		reservation := models.NewReservation(book.ID)
+		reservation.User = models.User{Forenames: "Neil", Surname: "Gaiman"}
+		So(ds.AddReservation(ctx, reservation), ShouldBeNil)
+
+		Convey("Then the book cannot be reserved again until it is returned", func() {
+			So(ds.AddReservation(ctx, models.NewReservation(book.ID)), ShouldEqual, mongo.ErrBookUnavailable)
+		})
+
+		Convey("Then a book that does not exist cannot be reserved", func() {
+			So(ds.AddReservation(ctx, models.NewReservation("unknown")), ShouldEqual, mongo.ErrBookNotFound)
+		})
+
+		Convey("Then the reservations of the book are listed oldest first", func() {
+			reservations, totalCount, err := ds.GetReservations(ctx, book.ID, 0, 10)
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 2)
+			So(reservations, ShouldHaveLength, 2)
+			So(reservations[0].ID, ShouldEqual, returned.ID)
+			So(reservations[1].ID, ShouldEqual, reservation.ID)
+		})
+
+		Convey("Then a reservation that does not exist is not found", func() {
+			_, err := ds.GetReservation(ctx, "unknown")
+			So(err, ShouldEqual, mongo.ErrReservationNotFound)
+		})
+
+		Convey("When the book is checked out", func() {
+			checkedOut := *reservation
+			So(checkedOut.Checkout(time.Now().UTC()), ShouldBeNil)
+			So(ds.UpdateReservation(ctx, &checkedOut, models.ReservationReserved), ShouldBeNil)
+
+			Convey("Then the reservation is checked out", func() {
+				stored, err := ds.GetReservation(ctx, reservation.ID)
+				So(err, ShouldBeNil)
+				So(stored.State, ShouldEqual, models.ReservationCheckedOut)
+				So(stored.CheckedOutAt, ShouldNotBeNil)
+			})
+
+			Convey("Then it cannot be checked out again from the reserved state", func() {
+				So(ds.UpdateReservation(ctx, &checkedOut, models.ReservationReserved), ShouldEqual, mongo.ErrReservationConflict)
+			})
+
+			Convey("Then the reservation cannot be deleted", func() {
+				So(ds.DeleteReservation(ctx, reservation.ID), ShouldEqual, mongo.ErrReservationConflict)
+			})
+		})
+
+		Convey("When the reservation is deleted", func() {
+			So(ds.DeleteReservation(ctx, reservation.ID), ShouldBeNil)
+
+			Convey("Then it is not found, and the book can be reserved again", func() {
+				_, err := ds.GetReservation(ctx, reservation.ID)
+				So(err, ShouldEqual, mongo.ErrReservationNotFound)
+				So(ds.AddReservation(ctx, models.NewReservation(book.ID)), ShouldBeNil)
+			})
+		})
+	})
+}
+
+func testAuthors(t *testing.T, newStore NewStore) {
+	ctx := context.Background()
+
+	Convey("Given a data store with the authors of a book", t, func() {
+		ds := newStore(t)
+		pratchett := models.NewAuthor()
+		pratchett.Name = "Terry Pratchett"
+		gaiman := models.NewAuthor()
+		gaiman.Name = "Neil Gaiman"
+		unknown := models.NewAuthor()
+		unknown.Name = "Anonymous"
+		for _, author := range []*models.Author{pratchett, gaiman, unknown} {
+			So(ds.AddAuthor(ctx, author), ShouldBeNil)
+		}
+
+		book := newBook("Good Omens", "Terry Pratchett & Neil Gaiman")
+		book.Authors = []models.Contributor{{AuthorID: pratchett.ID, Role: models.RoleAuthor}, {AuthorID: gaiman.ID, Role: models.RoleAuthor}}
+		addBooks(ds, book)
+
+		Convey("Then an author can be read by ID", func() {
+			stored, err := ds.GetAuthor(ctx, gaiman.ID)
+			So(err, ShouldBeNil)
+			So(stored, ShouldResemble, gaiman)
+
+			_, err = ds.GetAuthor(ctx, "unknown")
+			So(err, ShouldEqual, mongo.ErrAuthorNotFound)
+		})
+
+		Convey("Then the authors can be filtered and sorted", func() {
+			q := &query.Query{Filters: []query.Filter{{Key: "name", Operator: query.Contains, Value: "E"}}, Sort: []query.Sort{{Key: "name"}}}
+			authors, totalCount, err := ds.GetAuthors(ctx, q, nil, 0, 10)
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 2)
+			So(authors, ShouldHaveLength, 2)
+			So(authors[0].ID, ShouldEqual, gaiman.ID)
+			So(authors[1].ID, ShouldEqual, pratchett.ID)
+		})
+
+		Convey("When an author is renamed", func() {
+			So(ds.UpdateAuthor(ctx, gaiman.ID, &models.Author{Name: "Neil Richard Gaiman"}), ShouldBeNil)
+
+			Convey("Then the author of the books it contributed to is refreshed", func() {
+				stored, err := ds.GetBook(ctx, book.ID)
+				So(err, ShouldBeNil)
+				So(stored.Author, ShouldEqual, "Terry Pratchett & Neil Richard Gaiman")
+			})
+		})
+
+		Convey("Then an author that does not exist cannot be renamed", func() {
+			So(ds.UpdateAuthor(ctx, "unknown", &models.Author{Name: "Nobody"}), ShouldEqual, mongo.ErrAuthorNotFound)
+		})
+
+		Convey("Then an author who contributed to a book cannot be deleted", func() {
+			So(ds.DeleteAuthor(ctx, gaiman.ID), ShouldEqual, mongo.ErrAuthorHasBooks)
+		})
+
+		Convey("Then an author without books can be deleted once", func() {
+			So(ds.DeleteAuthor(ctx, unknown.ID), ShouldBeNil)
+			So(ds.DeleteAuthor(ctx, unknown.ID), ShouldEqual, mongo.ErrAuthorNotFound)
+		})
+	})
+}
+
+func testExport(t *testing.T, newStore NewStore) {
+	ctx := context.Background()
+
+	Convey("Given a data store with books and reviews", t, func() {
+		ds := newStore(t)
+		first := newBook("Kindred", "Octavia E. Butler")
+		second := newBook("Dawn", "Octavia E. Butler")
+		if second.ID < first.ID {
+			first, second = second, first
+		}
+		addBooks(ds, second, first)
+
+		approved := newReview(first.ID, models.ReviewApproved, 5)
+		So(ds.AddReview(ctx, approved), ShouldBeNil)
+		So(ds.AddReview(ctx, newReview(first.ID, models.ReviewPending, models.NoRating)), ShouldBeNil)
+
+		Convey("When the books are exported with their approved reviews", func() {
+			var exported []string
+			reviews := map[string][]models.Review{}
+			approvedReviews := &query.Query{Filters: []query.Filter{{Key: "state", Operator: query.Equals, Value: models.ReviewApproved}}}
+			err := ds.ExportBooks(ctx, approvedReviews, func(book *models.Book, bookReviews []models.Review) error {
+				exported = append(exported, book.ID)
+				reviews[book.ID] = bookReviews
+				return nil
+			})
+
+			Convey("Then every book is exported in the order of their IDs, with the reviews that match", func() {
+				So(err, ShouldBeNil)
+				So(exported, ShouldResemble, []string{first.ID, second.ID})
+				So(reviews[first.ID], ShouldHaveLength, 1)
+				So(reviews[first.ID][0].ID, ShouldEqual, approved.ID)
+				So(reviews[second.ID], ShouldNotBeNil)
+				So(reviews[second.ID], ShouldBeEmpty)
+			})
+		})
+
+		Convey("When the books are exported without their reviews", func() {
+			var reviews [][]models.Review
+			err := ds.ExportBooks(ctx, nil, func(book *models.Book, bookReviews []models.Review) error {
+				reviews = append(reviews, bookReviews)
+				return nil
+			})
+
+			Convey("Then no reviews are read", func() {
+				So(err, ShouldBeNil)
+				So(reviews, ShouldResemble, [][]models.Review{nil, nil})
+			})
+		})
+
+		Convey("When the export of a book fails", func() {
+			exported := 0
+			err := ds.ExportBooks(ctx, nil, func(book *models.Book, bookReviews []models.Review) error {
+				exported++
+				return context.Canceled
+			})
+
+			Convey("Then the export stops, with the error", func() {
+				So(err, ShouldEqual, context.Canceled)
+				So(exported, ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func testSearch(t *testing.T, newStore NewStore) {
+	ctx := context.Background()
+
+	Convey("Given a data store with books that can be searched", t, func() {
+		ds := newStore(t)
+		searcher, ok := ds.(interfaces.Searcher)
+		if !ok {
+			return
+		}
+
+		kindred := newBook("Kindred", "Octavia E. Butler")
+		parable := newBook("Parable of the Sower", "Octavia E. Butler")
+		omens := newBook("Good Omens", "Terry Pratchett & Neil Gaiman")
+		addBooks(ds, kindred, parable, omens)
+
+		Convey("When the books are searched", func() {
+			results, totalCount, err := searcher.SearchBooks(ctx, "butler sower", 0, 10)
+
+			Convey("Then the books that match any term are returned, the most relevant first", func() {
+				So(err, ShouldBeNil)
+				So(totalCount, ShouldEqual, 2)
+				So(results, ShouldHaveLength, 2)
+				So(results[0].ID, ShouldEqual, parable.ID)
+				So(results[1].ID, ShouldEqual, kindred.ID)
+			})
+		})
+
+		Convey("When the books are changed, rated and deleted", func() {
+			So(ds.PatchBook(ctx, omens.ID, 0, map[string]interface{}{"title": "Dune"}), ShouldBeNil)
+			So(ds.AddReview(ctx, newReview(kindred.ID, models.ReviewApproved, 4)), ShouldBeNil)
+			So(ds.DeleteBook(ctx, parable.ID, 0, true), ShouldBeNil)
+
+			Convey("Then the searches find the books as they are now", func() {
+				results, totalCount, err := searcher.SearchBooks(ctx, "omens dune", 0, 10)
+				So(err, ShouldBeNil)
+				So(totalCount, ShouldEqual, 1)
+				So(results[0].Title, ShouldEqual, "Dune")
+
+				results, totalCount, err = searcher.SearchBooks(ctx, "butler", 0, 10)
+				So(err, ShouldBeNil)
+				So(totalCount, ShouldEqual, 1)
+				So(results[0].ID, ShouldEqual, kindred.ID)
+				So(results[0].Ratings.Count, ShouldEqual, 1)
+				So(results[0].Ratings.Average, ShouldEqual, 4)
+			})
+		})
+
+		Convey("When nothing matches", func() {
+			results, totalCount, err := searcher.SearchBooks(ctx, "dune", 0, 10)
+
+			Convey("Then no books are returned", func() {
+				So(err, ShouldBeNil)
+				So(totalCount, ShouldEqual, 0)
+				So(results, ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+func testOutbox(t *testing.T, newStore NewStore) {
+	ctx := context.Background()
+
+	Convey("Given a data store with an outbox", t, func() {
+		ds := newStore(t)
+		outbox, ok := ds.(interfaces.Outbox)
+		if !ok {
+			return
+		}
+
+		Convey("When books and reviews are changed", func() {
+			book := newBook("Kindred", "Octavia E. Butler")
+			addBooks(ds, book)
+			So(ds.PatchBook(ctx, book.ID, 0, map[string]interface{}{"synopsis": "Time travel"}), ShouldBeNil)
+
+			review := newReview(book.ID, models.ReviewPending, 4)
+			So(ds.AddReview(ctx, review), ShouldBeNil)
+			approved := *review
+			So(approved.Approve(time.Now().UTC()), ShouldBeNil)
+			So(ds.UpdateReviewState(ctx, &approved, models.ReviewPending), ShouldBeNil)
+			moderated, err := ds.GetReview(ctx, review.ID)
+			So(err, ShouldBeNil)
+			replacement := *moderated
+			replacement.Message = "An updated review"
+			updated, err := ds.UpdateReview(ctx, &replacement, moderated)
+			So(err, ShouldBeNil)
+			So(ds.DeleteReview(ctx, updated), ShouldBeNil)
+
+			Convey("Then an event describing each change is pending, keyed by book, in the order of the changes", func() {
+				count, err := outbox.CountPendingEvents(ctx)
+				So(err, ShouldBeNil)
+				So(count, ShouldEqual, 6)
+
+				pending, err := outbox.GetPendingEvents(ctx, 0)
+				So(err, ShouldBeNil)
+
+				types := []string{}
+				for _, event := range pending {
+					So(event.Key, ShouldEqual, book.ID)
+					types = append(types, event.Type)
+				}
+				So(types, ShouldResemble, []string{
+					events.BookCreated,
+					events.BookUpdated,
+					events.ReviewAdded,
+					events.ReviewModerated,
+					events.ReviewUpdated,
+					events.ReviewDeleted,
+				})
+			})
+
+			Convey("Then the oldest events are returned first, up to the limit", func() {
+				pending, err := outbox.GetPendingEvents(ctx, 2)
+				So(err, ShouldBeNil)
+				So(pending, ShouldHaveLength, 2)
+				So(pending[0].Type, ShouldEqual, events.BookCreated)
+				So(pending[1].Type, ShouldEqual, events.BookUpdated)
+				So(pending[0].Sequence, ShouldBeLessThan, pending[1].Sequence)
+			})
+
+			Convey("Then a published event is no longer pending", func() {
+				pending, err := outbox.GetPendingEvents(ctx, 1)
+				So(err, ShouldBeNil)
+				So(outbox.DeletePublishedEvent(ctx, pending[0].ID), ShouldBeNil)
+				So(outbox.DeletePublishedEvent(ctx, pending[0].ID), ShouldBeNil)
+
+				count, err := outbox.CountPendingEvents(ctx)
+				So(err, ShouldBeNil)
+				So(count, ShouldEqual, 5)
+			})
+		})
+
+		Convey("When a book is deleted with its reviews", func() {
+			book := newBook("Kindred", "Octavia E. Butler")
+			addBooks(ds, book)
+			first := newReview(book.ID, models.ReviewApproved, 4)
+			second := newReview(book.ID, models.ReviewPending, models.NoRating)
+			So(ds.AddReview(ctx, first), ShouldBeNil)
+			So(ds.AddReview(ctx, second), ShouldBeNil)
+			rated, err := ds.GetBook(ctx, book.ID)
+			So(err, ShouldBeNil)
+			So(ds.DeleteBook(ctx, book.ID, rated.Revision, true), ShouldBeNil)
+
+			Convey("Then a review-deleted event is pending for each review, followed by a book-deleted event", func() {
+				pending, err := outbox.GetPendingEvents(ctx, 0)
+				So(err, ShouldBeNil)
+
+				types := []string{}
+				for _, event := range pending {
+					So(event.Key, ShouldEqual, book.ID)
+					types = append(types, event.Type)
+				}
+				So(types, ShouldResemble, []string{
+					events.BookCreated,
+					events.ReviewAdded,
+					events.ReviewAdded,
+					events.ReviewDeleted,
+					events.ReviewDeleted,
+					events.BookDeleted,
+				})
+				So(string(pending[5].Payload), ShouldEqual, `{"id":"`+book.ID+`"}`)
+			})
+		})
+
+		Convey("When a book that has been changed is deleted", func() {
+			book := newBook("Kindred", "Octavia E. Butler")
+			addBooks(ds, book)
+			So(ds.PatchBook(ctx, book.ID, 0, map[string]interface{}{"synopsis": "Time travel"}), ShouldBeNil)
+			So(ds.DeleteBook(ctx, book.ID, 0, true), ShouldEqual, mongo.ErrBookConflict)
+
+			Convey("Then no deletion event is pending", func() {
+				count, err := outbox.CountPendingEvents(ctx)
+				So(err, ShouldBeNil)
+				So(count, ShouldEqual, 2)
+			})
+		})
+	})
+}
+
+func testIdempotency(t *testing.T, newStore NewStore) {
+	ctx := context.Background()
+
+	Convey("Given a data store that records idempotency keys", t, func() {
+		ds := newStore(t)
+		store, ok := ds.(interfaces.IdempotencyStore)
+		if !ok {
+			return
+		}
+
+		record := &models.IdempotencyRecord{
+			ID:          "key",
+			RequestHash: "request",
+			ExpiresAt:   time.Now().UTC().Add(time.Minute),
+		}
+		existing, err := store.ReserveIdempotencyKey(ctx, record)
+		So(err, ShouldBeNil)
+		So(existing, ShouldBeNil)
+
+		Convey("Then the key cannot be reserved again, and the request in progress is returned instead", func() {
+			existing, err := store.ReserveIdempotencyKey(ctx, &models.IdempotencyRecord{ID: "key", RequestHash: "other", ExpiresAt: record.ExpiresAt})
+			So(err, ShouldBeNil)
+			So(existing, ShouldNotBeNil)
+			So(existing.RequestHash, ShouldEqual, "request")
+			So(existing.Completed(), ShouldBeFalse)
+		})
+
+		Convey("When the response to the request is recorded", func() {
+			completed := *record
+			completed.Status = 201
+			completed.Header = map[string][]string{"Content-Type": {"application/json"}}
+			completed.Body = []byte(`{"id":"1"}`)
+			completed.ExpiresAt = time.Now().UTC().Add(time.Hour)
+			So(store.CompleteIdempotencyKey(ctx, &completed), ShouldBeNil)
+
+			Convey("Then the response is returned when the key is reserved again", func() {
+				existing, err := store.ReserveIdempotencyKey(ctx, record)
+				So(err, ShouldBeNil)
+				So(existing.Completed(), ShouldBeTrue)
+				So(existing.Status, ShouldEqual, 201)
+				So(existing.Header, ShouldResemble, completed.Header)
+				So(existing.Body, ShouldResemble, completed.Body)
+			})
+		})
+
+		Convey("When the key is released", func() {
+			So(store.ReleaseIdempotencyKey(ctx, record.ID), ShouldBeNil)
+
+			Convey("Then it can be reserved again", func() {
+				existing, err := store.ReserveIdempotencyKey(ctx, record)
+				So(err, ShouldBeNil)
+				So(existing, ShouldBeNil)
+			})
+		})
+
+		Convey("Then an expired key can be reserved again", func() {
+			expired := &models.IdempotencyRecord{ID: "expired", RequestHash: "request", ExpiresAt: time.Now().UTC().Add(-time.Second)}
+			_, err := store.ReserveIdempotencyKey(ctx, expired)
+			So(err, ShouldBeNil)
+
+			existing, err := store.ReserveIdempotencyKey(ctx, &models.IdempotencyRecord{ID: "expired", RequestHash: "other", ExpiresAt: record.ExpiresAt})
+			So(err, ShouldBeNil)
+			So(existing, ShouldBeNil)
+		})
+	})
+}